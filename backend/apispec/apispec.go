@@ -0,0 +1,270 @@
+// Package apispec checks the api's traffic against the OpenAPI spec in docs/api, so the
+// routes and the spec can't drift apart unnoticed. The tests fail on every mismatch it
+// finds, in development it can log them instead.
+package apispec
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+func init() {
+	// PATCH routes take merge patches, which are plain JSON documents
+	openapi3filter.RegisterBodyDecoder("application/merge-patch+json", openapi3filter.RegisteredBodyDecoder("application/json"))
+	openapi3filter.RegisterBodyDecoder("text/plain", formFieldDecoder)
+	// closet archives are opaque files as far as the spec is concerned
+	openapi3filter.RegisterBodyDecoder("application/zip", openapi3filter.FileBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("text/csv", openapi3filter.FileBodyDecoder)
+}
+
+// formFieldDecoder reads the fields of multipart forms, which are text/plain, as the type
+// their schema asks for. The default decoder keeps every field a string, so no number matches.
+func formFieldDecoder(body io.Reader, header http.Header, schema *openapi3.SchemaRef, encFn openapi3filter.EncodingFn) (interface{}, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, &openapi3filter.ParseError{Kind: openapi3filter.KindInvalidFormat, Cause: err}
+	}
+
+	raw := string(data)
+	switch schema.Value.Type {
+	case "integer", "number":
+		if value, err := strconv.ParseFloat(raw, 64); err == nil {
+			return value, nil
+		}
+	case "boolean":
+		if value, err := strconv.ParseBool(raw); err == nil {
+			return value, nil
+		}
+	}
+	// anything else stays a string and fails the schema check if it shouldn't be one
+	return raw, nil
+}
+
+// Validator matches requests to the operations of a spec and checks both directions against them
+type Validator struct {
+	router  routers.Router
+	ignored []string
+	options *openapi3filter.Options
+}
+
+// Load reads and validates the spec at path
+func Load(path string) (*Validator, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't load api spec %v: %w", path, err)
+	}
+
+	err = doc.Validate(loader.Context)
+	if err != nil {
+		return nil, fmt.Errorf("invalid api spec %v: %w", path, err)
+	}
+
+	closeResponseObjects(doc)
+
+	// match requests by path alone, whatever host they were sent to
+	doc.Servers = nil
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("can't route api spec %v: %w", path, err)
+	}
+
+	return &Validator{
+		router: router,
+		options: &openapi3filter.Options{
+			// statuses the spec doesn't list are mismatches too
+			IncludeResponseStatus: true,
+			// sessions are checked by the auth middleware, the spec only says which routes need one
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		},
+	}, nil
+}
+
+// closeResponseObjects forbids fields the spec doesn't list in the objects of every response,
+// where it doesn't say what to do with them. Left open any object would pass for any other,
+// a clothing item for a tag for example. Objects extended with allOf have to stay open.
+func closeResponseObjects(doc *openapi3.T) {
+	var schemas []*openapi3.SchemaRef
+	for _, pathItem := range doc.Paths {
+		for _, operation := range pathItem.Operations() {
+			for _, response := range operation.Responses {
+				if response.Value == nil {
+					continue
+				}
+				for _, mediaType := range response.Value.Content {
+					schemas = append(schemas, mediaType.Schema)
+				}
+			}
+		}
+	}
+
+	extended := map[*openapi3.Schema]bool{}
+	walkSchemas(schemas, func(schema *openapi3.Schema) {
+		for _, member := range schema.AllOf {
+			extended[member.Value] = true
+		}
+	})
+
+	closed := false
+	walkSchemas(schemas, func(schema *openapi3.Schema) {
+		if schema.Type == "object" && !extended[schema] &&
+			schema.AdditionalPropertiesAllowed == nil && schema.AdditionalProperties == nil {
+			schema.AdditionalPropertiesAllowed = &closed
+		}
+	})
+}
+
+// walkSchemas calls visit once for every schema in schemas and the schemas nested in them
+func walkSchemas(schemas []*openapi3.SchemaRef, visit func(*openapi3.Schema)) {
+	seen := map[*openapi3.Schema]bool{}
+
+	var walk func(ref *openapi3.SchemaRef)
+	walk = func(ref *openapi3.SchemaRef) {
+		if ref == nil || ref.Value == nil || seen[ref.Value] {
+			return
+		}
+		schema := ref.Value
+		seen[schema] = true
+		visit(schema)
+
+		for _, property := range schema.Properties {
+			walk(property)
+		}
+		walk(schema.Items)
+		walk(schema.AdditionalProperties)
+		walk(schema.Not)
+		for _, nested := range [][]*openapi3.SchemaRef{schema.AllOf, schema.AnyOf, schema.OneOf} {
+			for _, member := range nested {
+				walk(member)
+			}
+		}
+	}
+
+	for _, ref := range schemas {
+		walk(ref)
+	}
+}
+
+// Ignore leaves paths starting with any of prefixes unchecked, for routes that are
+// deliberately not part of the spec
+func (v *Validator) Ignore(prefixes ...string) {
+	v.ignored = append(v.ignored, prefixes...)
+}
+
+// Middleware checks every request and its response against the spec, calling report for
+// each mismatch before the response is sent. Responses reach the client unchanged.
+//
+// Requests are only checked when the handler accepted them with a 2xx, clients sending
+// invalid requests is what the error responses are for.
+func (v *Validator) Middleware(report func(r *http.Request, err error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, prefix := range v.ignored {
+				if strings.HasPrefix(r.URL.Path, prefix) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			// keep a copy of the body, the handler consumes it
+			var body []byte
+			if r.Body != nil {
+				var err error
+				body, err = io.ReadAll(r.Body)
+				if err != nil {
+					report(r, fmt.Errorf("can't read request body: %w", err))
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+
+			recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+
+			for _, err := range v.check(r, body, recorder) {
+				report(r, err)
+			}
+
+			recorder.flush()
+		})
+	}
+}
+
+// check returns the ways the request and its recorded response differ from the spec
+func (v *Validator) check(r *http.Request, body []byte, recorder *responseRecorder) []error {
+	route, pathParams, err := v.router.FindRoute(r)
+	if err != nil {
+		// the app turned it down as well
+		if recorder.status == http.StatusNotFound || recorder.status == http.StatusMethodNotAllowed {
+			return nil
+		}
+		return []error{fmt.Errorf("route is not in the spec but answered %d: %w", recorder.status, err)}
+	}
+
+	ctx := r.Context()
+	request := r.Clone(ctx)
+	request.Body = io.NopCloser(bytes.NewReader(body))
+
+	input := &openapi3filter.RequestValidationInput{
+		Request:    request,
+		PathParams: pathParams,
+		Route:      route,
+		Options:    v.options,
+	}
+
+	var errs []error
+
+	if recorder.status >= 200 && recorder.status < 300 {
+		if err := openapi3filter.ValidateRequest(ctx, input); err != nil {
+			errs = append(errs, fmt.Errorf("request was accepted with %d: %w", recorder.status, err))
+		}
+	}
+
+	response := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 recorder.status,
+		Header:                 recorder.Header(),
+		Options:                v.options,
+	}
+	if err := openapi3filter.ValidateResponse(ctx, response.SetBodyBytes(recorder.body.Bytes())); err != nil {
+		errs = append(errs, fmt.Errorf("response %d: %w", recorder.status, err))
+	}
+
+	return errs
+}
+
+// responseRecorder holds back the response so it can be checked before the client gets it.
+// Headers go straight to the underlying writer, they aren't sent before flush.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.wroteHeader {
+		return
+	}
+	rec.status = status
+	rec.wroteHeader = true
+}
+
+func (rec *responseRecorder) Write(data []byte) (int, error) {
+	rec.wroteHeader = true
+	return rec.body.Write(data)
+}
+
+// flush sends the recorded response
+func (rec *responseRecorder) flush() {
+	rec.ResponseWriter.WriteHeader(rec.status)
+	rec.ResponseWriter.Write(rec.body.Bytes())
+}
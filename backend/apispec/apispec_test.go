@@ -0,0 +1,72 @@
+package apispec_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"com.fukubox/apispec"
+)
+
+var specPath = filepath.Join("..", "..", "docs", "api", "openapi.yaml")
+
+// serve sends one request to handler behind the validator and returns the mismatches it reported
+func serve(t *testing.T, method string, path string, handler http.HandlerFunc) []error {
+	t.Helper()
+
+	spec, err := apispec.Load(specPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec.Ignore("/ping")
+
+	var mismatches []error
+	validated := spec.Middleware(func(r *http.Request, err error) {
+		mismatches = append(mismatches, err)
+	})(handler)
+
+	validated.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, path, nil))
+	return mismatches
+}
+
+func respond(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}
+}
+
+func TestMatchingResponsePasses(t *testing.T) {
+	tag := `{"id": 2, "user_id": 1, "name": "Winter", "created_at": "2024-07-01T12:00:00Z", "updated_at": "2024-07-01T12:00:00Z"}`
+	if mismatches := serve(t, http.MethodGet, "/tags/2", respond(http.StatusOK, tag)); len(mismatches) > 0 {
+		t.Fatalf("unexpected mismatches %v", mismatches)
+	}
+}
+
+func TestWrongHandlerIsReported(t *testing.T) {
+	// a clothing item where a tag belongs, as when GET /tags/{id} was routed to the clothes handler
+	cloth := `{"id": 2, "user_id": 1, "category_id": 2, "name": "Wool trousers", "image_url": "http://example.com/clothing2.jpg", "tags": []}`
+	if mismatches := serve(t, http.MethodGet, "/tags/2", respond(http.StatusOK, cloth)); len(mismatches) == 0 {
+		t.Fatal("expected the clothing item to mismatch the tag schema")
+	}
+}
+
+func TestUndocumentedStatusIsReported(t *testing.T) {
+	if mismatches := serve(t, http.MethodPost, "/tags", respond(http.StatusOK, `{"id": 4, "name": "Work"}`)); len(mismatches) == 0 {
+		t.Fatal("expected 200 to mismatch the documented 201")
+	}
+}
+
+func TestUndocumentedRoute(t *testing.T) {
+	if mismatches := serve(t, http.MethodGet, "/lookbooks", respond(http.StatusOK, `[]`)); len(mismatches) == 0 {
+		t.Fatal("expected an undocumented route answering 200 to be reported")
+	}
+	if mismatches := serve(t, http.MethodGet, "/lookbooks", respond(http.StatusNotFound, `{}`)); len(mismatches) > 0 {
+		t.Fatalf("unexpected mismatches for a route the app doesn't have either: %v", mismatches)
+	}
+	if mismatches := serve(t, http.MethodGet, "/ping", respond(http.StatusOK, `.`)); len(mismatches) > 0 {
+		t.Fatalf("unexpected mismatches for an ignored route: %v", mismatches)
+	}
+}
@@ -0,0 +1,102 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"com.fukubox/config"
+	"com.fukubox/database"
+)
+
+const migrateUsage = "usage: migrate [up | down [steps] | status | seed]"
+
+// RunMigrateCommand handles `migrate` on the command line without starting the api server
+func RunMigrateCommand(args []string) error {
+	err := config.LoadENV()
+	if err != nil {
+		return err
+	}
+
+	err = database.StartDB()
+	if err != nil {
+		return err
+	}
+	defer database.CloseDB()
+
+	ctx := context.Background()
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		count, err := database.MigrateUp(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migration(s)\n", count)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return errors.New(migrateUsage)
+			}
+		}
+
+		count, err := database.MigrateDown(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Reverted %d migration(s)\n", count)
+
+	case "status":
+		statuses, err := database.GetMigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30v %v\n", status.Version, status.Name, appliedAt)
+		}
+
+	case "seed":
+		err := database.Seed(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Seeded database")
+
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	return nil
+}
+
+// migrateOnStart brings the schema up to date, or with AUTO_MIGRATE=false only
+// checks that someone already did, so the server never runs against the wrong schema
+func migrateOnStart(ctx context.Context) error {
+	if os.Getenv("AUTO_MIGRATE") == "false" {
+		return database.CheckMigrations(ctx)
+	}
+
+	count, err := database.MigrateUp(ctx)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		fmt.Printf("Applied %d migration(s)\n", count)
+	}
+
+	return nil
+}
@@ -0,0 +1,1368 @@
+package app_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"com.fukubox/apptest"
+	"com.fukubox/repository"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(apptest.Main(m))
+}
+
+// The tests below run against database/seed.sql: user 1 owns categories 1 and 2, clothes 1
+// and 2, tags 1 and 2, sandbox 1 with positions 1 and 2 and outfit 1 of clothes 1 and 2,
+// worn on 2024-07-02 with cloth 1 worn again alone on 2024-07-05; user 2 owns category 3,
+// cloth 3, tag 3, sandbox 2 with position 3 and outfit 2 of cloth 3, worn on 2024-07-02.
+
+type idBody struct {
+	Id int `json:"id"`
+}
+
+type namedBody struct {
+	Id     int    `json:"id"`
+	UserId int    `json:"user_id"`
+	Name   string `json:"name"`
+}
+
+type clothBody struct {
+	Id         int     `json:"id"`
+	UserId     int     `json:"user_id"`
+	CategoryId int     `json:"category_id"`
+	Name       *string `json:"name"`
+	Notes      *string `json:"notes"`
+	ImageUrl   string  `json:"image_url"`
+	Tags       []struct {
+		Id   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"tags"`
+	TimesWorn  int     `json:"times_worn"`
+	LastWornAt *string `json:"last_worn_at"`
+}
+
+type positionBody struct {
+	Id             int     `json:"id"`
+	SandboxId      int     `json:"sandbox_id"`
+	ClothingItemId int     `json:"clothing_item_id"`
+	PositionX      float64 `json:"position_x"`
+	PositionY      float64 `json:"position_y"`
+}
+
+type outfitBody struct {
+	Id     int              `json:"id"`
+	UserId int              `json:"user_id"`
+	Name   string           `json:"name"`
+	Notes  *string          `json:"notes"`
+	Items  []outfitItemBody `json:"items"`
+	Tags   []idBody         `json:"tags"`
+}
+
+type outfitItemBody struct {
+	ClothingItemId int  `json:"clothing_item_id"`
+	Layer          *int `json:"layer"`
+}
+
+type wearBody struct {
+	Id              int        `json:"id"`
+	UserId          int        `json:"user_id"`
+	Date            string     `json:"date"`
+	Outfit          *namedBody `json:"outfit"`
+	ClothingItemIds []int      `json:"clothing_item_ids"`
+	Note            *string    `json:"note"`
+}
+
+type calendarDayBody struct {
+	Date  string     `json:"date"`
+	Wears []wearBody `json:"wears"`
+}
+
+type clothDeletionBody struct {
+	BrokenOutfits []namedBody `json:"broken_outfits"`
+}
+
+type categoryBody struct {
+	Id       int    `json:"id"`
+	ParentId *int   `json:"parent_id"`
+	Name     string `json:"name"`
+}
+
+type categoryNodeBody struct {
+	categoryBody
+	Children []categoryNodeBody `json:"children"`
+}
+
+type importReportBody struct {
+	Created struct {
+		Categories int `json:"categories"`
+		Tags       int `json:"tags"`
+		Clothes    int `json:"clothes"`
+		Outfits    int `json:"outfits"`
+		Sandboxes  int `json:"sandboxes"`
+	} `json:"created"`
+	Reused struct {
+		Categories int `json:"categories"`
+		Tags       int `json:"tags"`
+	} `json:"reused"`
+	Errors []struct {
+		Entry  string            `json:"entry"`
+		Errors map[string]string `json:"errors"`
+	} `json:"errors"`
+}
+
+type categoryDeletionBody struct {
+	OnItems       string      `json:"on_items"`
+	ItemCount     int         `json:"item_count"`
+	ReassignedTo  *int        `json:"reassigned_to"`
+	BrokenOutfits []namedBody `json:"broken_outfits"`
+}
+
+type trashEntryBody struct {
+	Type      string    `json:"type"`
+	Id        int       `json:"id"`
+	Name      *string   `json:"name"`
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
+
+type problemBody struct {
+	Type   string            `json:"type"`
+	Status int               `json:"status"`
+	Errors map[string]string `json:"errors"`
+}
+
+func ids[T any](items []T, id func(T) int) []int {
+	result := []int{}
+	for _, item := range items {
+		result = append(result, id(item))
+	}
+	return result
+}
+
+func expectIds(t *testing.T, what string, got []int, want ...int) {
+	t.Helper()
+
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("expected %v %v, got %v", what, want, got)
+	}
+}
+
+// latestToday is the date in UTC+14, the first timezone a day starts in
+func latestToday() time.Time {
+	return time.Now().UTC().Add(14 * time.Hour)
+}
+
+// hasRootCategory reports whether categories has a top level category called name
+func hasRootCategory(categories []categoryBody, name string) bool {
+	for _, category := range categories {
+		if category.Name == name && category.ParentId == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// imageForm builds a multipart body holding a small PNG in the image field and the given form fields
+func imageForm(t *testing.T, fields map[string]string) (string, *bytes.Buffer) {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	for x := 0; x < 32; x++ {
+		for y := 0; y < 32; y++ {
+			img.Set(x, y, color.White)
+			if x >= 8 && x < 24 && y >= 8 && y < 24 {
+				img.Set(x, y, color.RGBA{R: 200, A: 255})
+			}
+		}
+	}
+
+	var data bytes.Buffer
+	if err := png.Encode(&data, img); err != nil {
+		t.Fatal(err)
+	}
+
+	return imageFileForm(t, fields, data.Bytes())
+}
+
+// imageFileForm builds a multipart body holding data in the image field and the given form fields
+func imageFileForm(t *testing.T, fields map[string]string, data []byte) (string, *bytes.Buffer) {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+	file, err := form.CreateFormFile("image", "shirt.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write(data)
+	form.Close()
+
+	return form.FormDataContentType(), body
+}
+
+// pngHeader is the start of a PNG claiming to be width by height pixels, which is all it takes
+// to read its size
+func pngHeader(width uint32, height uint32) []byte {
+	chunk := binary.BigEndian.AppendUint32([]byte("IHDR"), width)
+	chunk = binary.BigEndian.AppendUint32(chunk, height)
+	// 8 bit RGBA, no interlacing
+	chunk = append(chunk, 8, 6, 0, 0, 0)
+
+	data := binary.BigEndian.AppendUint32([]byte("\x89PNG\r\n\x1a\n"), uint32(len(chunk)-4))
+	data = append(data, chunk...)
+	return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(chunk))
+}
+
+func TestUsers(t *testing.T) {
+	h := apptest.New(t)
+
+	var created struct {
+		idBody
+		Username string  `json:"username"`
+		Email    *string `json:"email"`
+	}
+	h.Do(t, apptest.User1, http.MethodPost, "/users", map[string]any{"username": "new", "email": "new@example.com"}).
+		ExpectStatus(t, http.StatusCreated).Decode(t, &created)
+	if created.Username != "new" || created.Email == nil || *created.Email != "new@example.com" {
+		t.Fatalf("unexpected user %+v", created)
+	}
+
+	// a new user starts with the starter categories and tags, in the language they asked for
+	var categories []categoryBody
+	h.Do(t, created.Id, http.MethodGet, "/categories", nil).ExpectStatus(t, http.StatusOK).Decode(t, &categories)
+	if !hasRootCategory(categories, "Tops") {
+		t.Fatalf("new user didn't get the starter categories: %+v", categories)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, h.URL+"/users", strings.NewReader(`{"username": "nouveau", "email": "nouveau@example.com"}`))
+	if err != nil {
+		t.Fatalf("Failed to build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Language", "fr-CA, en;q=0.5")
+	h.DoRequest(t, apptest.User1, req).ExpectStatus(t, http.StatusCreated).Decode(t, &created)
+
+	var tags []namedBody
+	h.Do(t, created.Id, http.MethodGet, "/categories", nil).ExpectStatus(t, http.StatusOK).Decode(t, &categories)
+	h.Do(t, created.Id, http.MethodGet, "/tags", nil).ExpectStatus(t, http.StatusOK).Decode(t, &tags)
+	if !hasRootCategory(categories, "Hauts") || hasRootCategory(categories, "Tops") || len(tags) == 0 {
+		t.Fatalf("new user didn't get the french starter kit: %+v %+v", categories, tags)
+	}
+
+	h.Do(t, apptest.User1, http.MethodPost, "/users", map[string]any{"username": "again", "email": "new@example.com"}).
+		ExpectStatus(t, http.StatusConflict)
+	h.Do(t, apptest.User1, http.MethodPost, "/users", map[string]any{"username": "bad", "email": "not an email"}).
+		ExpectStatus(t, http.StatusUnprocessableEntity)
+}
+
+func TestGoogleLogin(t *testing.T) {
+	h := apptest.New(t)
+	ctx := context.Background()
+	users := repository.NewPostgres(h.DB)
+
+	// the seeded users have an email but never logged in with Google
+	id, err := users.UpsertGoogleUser(ctx, "google-user1", "user1@example.com", false, "User 1")
+	if !errors.Is(err, repository.ErrDuplicate) {
+		t.Fatalf("unverified email took over user 1: %v, %v", id, err)
+	}
+	id, err = users.UpsertGoogleUser(ctx, "google-user1", "user1@example.com", true, "User 1")
+	if err != nil || id != apptest.User1 {
+		t.Fatalf("expected the verified email to sign in as user 1, got %v, %v", id, err)
+	}
+	id, err = users.UpsertGoogleUser(ctx, "google-user1", "renamed@example.com", true, "User 1")
+	if err != nil || id != apptest.User1 {
+		t.Fatalf("expected the Google account to stay linked to user 1, got %v, %v", id, err)
+	}
+
+	// once linked, another Google account can't take the email over
+	id, err = users.UpsertGoogleUser(ctx, "google-other", "user1@example.com", true, "Other")
+	if !errors.Is(err, repository.ErrDuplicate) {
+		t.Fatalf("expected the email to be taken, got %v, %v", id, err)
+	}
+
+	id, err = users.UpsertGoogleUser(ctx, "google-new", "new@example.com", true, "")
+	if err != nil || id == apptest.User1 || id == apptest.User2 {
+		t.Fatalf("expected a new user, got %v, %v", id, err)
+	}
+	var me struct {
+		Username string `json:"username"`
+	}
+	h.Do(t, id, http.MethodGet, "/me", nil).ExpectStatus(t, http.StatusOK).Decode(t, &me)
+	if me.Username != "new" {
+		t.Fatalf("expected the username to default to the start of the email, got %+v", me)
+	}
+}
+
+func TestMe(t *testing.T) {
+	h := apptest.New(t)
+
+	var me struct {
+		idBody
+		Username string  `json:"username"`
+		Email    *string `json:"email"`
+	}
+	h.Do(t, apptest.User1, http.MethodGet, "/me", nil).ExpectStatus(t, http.StatusOK).Decode(t, &me)
+	if me.Id != apptest.User1 || me.Username != "user1" {
+		t.Fatalf("unexpected user %+v", me)
+	}
+
+	h.Do(t, apptest.User1, http.MethodPatch, "/me", map[string]any{"username": "renamed", "email": nil}).
+		ExpectStatus(t, http.StatusOK).Decode(t, &me)
+	if me.Username != "renamed" || me.Email != nil {
+		t.Fatalf("patch wasn't applied: %+v", me)
+	}
+
+	h.Do(t, apptest.User1, http.MethodPatch, "/me", map[string]any{"email": "user2@example.com"}).
+		ExpectStatus(t, http.StatusConflict)
+
+	h.Do(t, apptest.User1, http.MethodDelete, "/me", nil).ExpectStatus(t, http.StatusNoContent)
+	h.Do(t, apptest.User1, http.MethodGet, "/me", nil).ExpectStatus(t, http.StatusUnauthorized)
+
+	// deleting user 1 must leave user 2 alone
+	h.Do(t, apptest.User2, http.MethodGet, "/clothes/3", nil).ExpectStatus(t, http.StatusOK)
+}
+
+func TestCategories(t *testing.T) {
+	h := apptest.New(t)
+
+	var categories []namedBody
+	h.Do(t, apptest.User1, http.MethodGet, "/categories", nil).ExpectStatus(t, http.StatusOK).Decode(t, &categories)
+	expectIds(t, "categories", ids(categories, func(c namedBody) int { return c.Id }), 1, 2)
+
+	var category namedBody
+	h.Do(t, apptest.User1, http.MethodGet, "/categories/1", nil).ExpectStatus(t, http.StatusOK).Decode(t, &category)
+	if category.Name != "Tops" {
+		t.Fatalf("unexpected category %+v", category)
+	}
+
+	h.Do(t, apptest.User1, http.MethodPost, "/categories", map[string]any{"name": "Shoes"}).
+		ExpectStatus(t, http.StatusCreated).Decode(t, &category)
+	if category.Name != "Shoes" || category.UserId != apptest.User1 {
+		t.Fatalf("unexpected category %+v", category)
+	}
+	path := fmt.Sprintf("/categories/%d", category.Id)
+
+	h.Do(t, apptest.User1, http.MethodPatch, path, map[string]any{"name": "Sneakers"}).
+		ExpectStatus(t, http.StatusOK).Decode(t, &category)
+	if category.Name != "Sneakers" {
+		t.Fatalf("patch wasn't applied: %+v", category)
+	}
+	h.Do(t, apptest.User1, http.MethodPatch, path, map[string]any{"name": nil}).
+		ExpectStatus(t, http.StatusUnprocessableEntity)
+	h.Do(t, apptest.User1, http.MethodPatch, path, map[string]any{"name": strings.Repeat("x", 256)}).
+		ExpectStatus(t, http.StatusUnprocessableEntity)
+
+	var problem problemBody
+	h.Do(t, apptest.User1, http.MethodPost, "/categories", map[string]any{}).
+		ExpectStatus(t, http.StatusUnprocessableEntity).Decode(t, &problem)
+	if problem.Errors["name"] != "is required" {
+		t.Fatalf("expected name to be required, got %+v", problem)
+	}
+	h.Do(t, apptest.User1, http.MethodPost, "/categories", map[string]any{"name": strings.Repeat("x", 256)}).
+		ExpectStatus(t, http.StatusUnprocessableEntity).Decode(t, &problem)
+	if problem.Errors["name"] != "must be at most 255 characters" {
+		t.Fatalf("expected name to be too long, got %+v", problem)
+	}
+
+	// an empty category is deleted whatever the policy
+	var deletion categoryDeletionBody
+	h.Do(t, apptest.User1, http.MethodDelete, path, nil).ExpectStatus(t, http.StatusOK).Decode(t, &deletion)
+	if deletion.OnItems != "refuse" || deletion.ItemCount != 0 || deletion.ReassignedTo != nil {
+		t.Fatalf("unexpected deletion %+v", deletion)
+	}
+	h.Do(t, apptest.User1, http.MethodGet, path, nil).ExpectStatus(t, http.StatusNotFound)
+}
+
+func TestCategoryTree(t *testing.T) {
+	h := apptest.New(t)
+
+	var shirts, tees categoryBody
+	h.Do(t, apptest.User1, http.MethodPost, "/categories", map[string]any{"name": "T-shirts", "parent_id": 1}).
+		ExpectStatus(t, http.StatusCreated).Decode(t, &shirts)
+	h.Do(t, apptest.User1, http.MethodPost, "/categories", map[string]any{"name": "Graphic tees", "parent_id": shirts.Id}).
+		ExpectStatus(t, http.StatusCreated).Decode(t, &tees)
+	if shirts.ParentId == nil || *shirts.ParentId != 1 || tees.ParentId == nil || *tees.ParentId != shirts.Id {
+		t.Fatalf("unexpected categories %+v %+v", shirts, tees)
+	}
+	h.Do(t, apptest.User1, http.MethodPost, "/categories", map[string]any{"name": "Borrowed", "parent_id": 3}).
+		ExpectStatus(t, http.StatusUnprocessableEntity)
+
+	var tree []categoryNodeBody
+	h.Do(t, apptest.User1, http.MethodGet, "/categories/tree", nil).ExpectStatus(t, http.StatusOK).Decode(t, &tree)
+	expectIds(t, "roots", ids(tree, func(node categoryNodeBody) int { return node.Id }), 1, 2)
+	expectIds(t, "children of 1", ids(tree[0].Children, func(node categoryNodeBody) int { return node.Id }), shirts.Id)
+	expectIds(t, "children of T-shirts", ids(tree[0].Children[0].Children, func(node categoryNodeBody) int { return node.Id }), tees.Id)
+
+	// a category can't go under itself or its own subtree
+	var problem problemBody
+	for _, parentId := range []int{1, tees.Id} {
+		h.Do(t, apptest.User1, http.MethodPost, "/categories/1/move", map[string]any{"parent_id": parentId}).
+			ExpectStatus(t, http.StatusUnprocessableEntity).Decode(t, &problem)
+		if problem.Errors["parent_id"] == "" {
+			t.Fatalf("expected an error on parent_id, got %+v", problem)
+		}
+	}
+
+	var cloth clothBody
+	h.Do(t, apptest.User1, http.MethodPost, "/clothes", map[string]any{"category_id": tees.Id, "image_url": "http://example.com/tee.jpg"}).
+		ExpectStatus(t, http.StatusCreated).Decode(t, &cloth)
+	var page struct {
+		Items []idBody `json:"items"`
+	}
+	h.Do(t, apptest.User1, http.MethodGet, "/clothes?category_id=1", nil).ExpectStatus(t, http.StatusOK).Decode(t, &page)
+	expectIds(t, "clothes in 1", ids(page.Items, func(item idBody) int { return item.Id }), 1)
+	h.Do(t, apptest.User1, http.MethodGet, "/clothes?category_id=1&include_subcategories=true", nil).ExpectStatus(t, http.StatusOK).Decode(t, &page)
+	expectIds(t, "clothes under 1", ids(page.Items, func(item idBody) int { return item.Id }), cloth.Id, 1)
+	h.Do(t, apptest.User1, http.MethodGet, "/clothes?category_id=1&include_subcategories=yes", nil).ExpectStatus(t, http.StatusBadRequest)
+
+	// moving a category takes its subtree along
+	var moved categoryBody
+	h.Do(t, apptest.User1, http.MethodPost, fmt.Sprintf("/categories/%d/move", shirts.Id), map[string]any{"parent_id": 2}).
+		ExpectStatus(t, http.StatusOK).Decode(t, &moved)
+	if moved.ParentId == nil || *moved.ParentId != 2 {
+		t.Fatalf("category wasn't moved: %+v", moved)
+	}
+	h.Do(t, apptest.User1, http.MethodGet, "/clothes?category_id=2&include_subcategories=true", nil).ExpectStatus(t, http.StatusOK).Decode(t, &page)
+	expectIds(t, "clothes under 2", ids(page.Items, func(item idBody) int { return item.Id }), cloth.Id, 2)
+
+	// deleting a category moves its subcategories up, and restoring it doesn't take them back
+	h.Do(t, apptest.User1, http.MethodDelete, fmt.Sprintf("/categories/%d", shirts.Id), nil).ExpectStatus(t, http.StatusOK)
+	h.Do(t, apptest.User1, http.MethodGet, fmt.Sprintf("/categories/%d", tees.Id), nil).ExpectStatus(t, http.StatusOK).Decode(t, &tees)
+	if tees.ParentId == nil || *tees.ParentId != 2 {
+		t.Fatalf("subcategory didn't move up: %+v", tees)
+	}
+	h.Do(t, apptest.User1, http.MethodDelete, "/categories/2?on_items=trash", nil).ExpectStatus(t, http.StatusOK)
+	h.Do(t, apptest.User1, http.MethodPost, fmt.Sprintf("/trash/categories/%d/restore", shirts.Id), nil).
+		ExpectStatus(t, http.StatusOK).Decode(t, &shirts)
+	if shirts.ParentId != nil {
+		t.Fatalf("category restored under a trashed parent: %+v", shirts)
+	}
+	h.Do(t, apptest.User1, http.MethodGet, "/categories/tree", nil).ExpectStatus(t, http.StatusOK).Decode(t, &tree)
+	expectIds(t, "roots", ids(tree, func(node categoryNodeBody) int { return node.Id }), 1, shirts.Id, tees.Id)
+
+	h.Do(t, apptest.User1, http.MethodPost, fmt.Sprintf("/categories/%d/move", tees.Id), map[string]any{"parent_id": nil}).
+		ExpectStatus(t, http.StatusOK)
+	h.Do(t, apptest.User1, http.MethodPost, fmt.Sprintf("/categories/%d/move", tees.Id), map[string]any{"parent_id": 2}).
+		ExpectStatus(t, http.StatusUnprocessableEntity)
+}
+
+func TestDeleteUsedCategory(t *testing.T) {
+	h := apptest.New(t)
+
+	var problem struct {
+		problemBody
+		Count int `json:"count"`
+	}
+	h.Do(t, apptest.User1, http.MethodDelete, "/categories/1", nil).ExpectStatus(t, http.StatusConflict).Decode(t, &problem)
+	if problem.Count != 1 {
+		t.Fatalf("expected a count of 1 item, got %+v", problem)
+	}
+	h.Do(t, apptest.User1, http.MethodGet, "/categories/1", nil).ExpectStatus(t, http.StatusOK)
+
+	for _, query := range []string{"?on_items=keep", "?on_items=reassign", "?on_items=trash&reassign_to=2", "?reassign_to=two"} {
+		h.Do(t, apptest.User1, http.MethodDelete, "/categories/1"+query, nil).ExpectStatus(t, http.StatusBadRequest)
+	}
+	// the target has to be another live category of the user's
+	for _, query := range []string{"?reassign_to=1", "?reassign_to=3", "?on_items=reassign&reassign_to=999"} {
+		h.Do(t, apptest.User1, http.MethodDelete, "/categories/1"+query, nil).ExpectStatus(t, http.StatusBadRequest)
+	}
+
+	var deletion categoryDeletionBody
+	h.Do(t, apptest.User1, http.MethodDelete, "/categories/1?reassign_to=2", nil).ExpectStatus(t, http.StatusOK).Decode(t, &deletion)
+	if deletion.OnItems != "reassign" || deletion.ItemCount != 1 || deletion.ReassignedTo == nil || *deletion.ReassignedTo != 2 {
+		t.Fatalf("unexpected deletion %+v", deletion)
+	}
+	var cloth clothBody
+	h.Do(t, apptest.User1, http.MethodGet, "/clothes/1", nil).ExpectStatus(t, http.StatusOK).Decode(t, &cloth)
+	if cloth.CategoryId != 2 {
+		t.Fatalf("cloth wasn't reassigned: %+v", cloth)
+	}
+
+	h.Do(t, apptest.User1, http.MethodDelete, "/categories/2?on_items=trash", nil).ExpectStatus(t, http.StatusOK).Decode(t, &deletion)
+	if deletion.OnItems != "trash" || deletion.ItemCount != 2 {
+		t.Fatalf("unexpected deletion %+v", deletion)
+	}
+	expectIds(t, "broken outfits", ids(deletion.BrokenOutfits, func(o namedBody) int { return o.Id }), 1)
+	h.Do(t, apptest.User1, http.MethodGet, "/clothes/1", nil).ExpectStatus(t, http.StatusNotFound)
+	h.Do(t, apptest.User1, http.MethodGet, "/clothes/2", nil).ExpectStatus(t, http.StatusNotFound)
+}
+
+func TestTags(t *testing.T) {
+	h := apptest.New(t)
+
+	var tags []namedBody
+	h.Do(t, apptest.User1, http.MethodGet, "/tags", nil).ExpectStatus(t, http.StatusOK).Decode(t, &tags)
+	expectIds(t, "tags", ids(tags, func(tag namedBody) int { return tag.Id }), 1, 2)
+
+	var tag namedBody
+	h.Do(t, apptest.User1, http.MethodGet, "/tags/2", nil).ExpectStatus(t, http.StatusOK).Decode(t, &tag)
+	if tag.Id != 2 || tag.Name != "Winter" {
+		t.Fatalf("unexpected tag %+v", tag)
+	}
+
+	h.Do(t, apptest.User1, http.MethodPost, "/tags", map[string]any{"name": "Work"}).
+		ExpectStatus(t, http.StatusCreated).Decode(t, &tag)
+	path := fmt.Sprintf("/tags/%d", tag.Id)
+
+	h.Do(t, apptest.User1, http.MethodPatch, path, map[string]any{"name": "Office"}).
+		ExpectStatus(t, http.StatusOK).Decode(t, &tag)
+	if tag.Name != "Office" {
+		t.Fatalf("patch wasn't applied: %+v", tag)
+	}
+	h.Do(t, apptest.User1, http.MethodPatch, path, map[string]any{"name": strings.Repeat("x", 256)}).
+		ExpectStatus(t, http.StatusUnprocessableEntity)
+
+	var problem problemBody
+	h.Do(t, apptest.User1, http.MethodPost, "/tags", map[string]any{"name": ""}).
+		ExpectStatus(t, http.StatusUnprocessableEntity).Decode(t, &problem)
+	if problem.Errors["name"] != "is required" {
+		t.Fatalf("expected name to be required, got %+v", problem)
+	}
+	h.Do(t, apptest.User1, http.MethodPost, "/tags", map[string]any{"name": strings.Repeat("x", 256)}).
+		ExpectStatus(t, http.StatusUnprocessableEntity)
+
+	// deleting a tag in use takes it off the clothes and outfits
+	h.Do(t, apptest.User1, http.MethodDelete, "/tags/1", nil).ExpectStatus(t, http.StatusNoContent)
+	var cloth clothBody
+	h.Do(t, apptest.User1, http.MethodGet, "/clothes/1", nil).ExpectStatus(t, http.StatusOK).Decode(t, &cloth)
+	if len(cloth.Tags) != 0 {
+		t.Fatalf("deleted tag still on cloth: %+v", cloth.Tags)
+	}
+	var outfit outfitBody
+	h.Do(t, apptest.User1, http.MethodGet, "/outfits/1", nil).ExpectStatus(t, http.StatusOK).Decode(t, &outfit)
+	if len(outfit.Tags) != 0 {
+		t.Fatalf("deleted tag still on outfit: %+v", outfit.Tags)
+	}
+}
+
+func TestClothes(t *testing.T) {
+	h := apptest.New(t)
+
+	var page struct {
+		Items      []clothBody `json:"items"`
+		NextCursor *string     `json:"next_cursor"`
+		Total      int         `json:"total"`
+	}
+	h.Do(t, apptest.User1, http.MethodGet, "/clothes?sort=created_at&order=asc&limit=1", nil).
+		ExpectStatus(t, http.StatusOK).Decode(t, &page)
+	if page.Total != 2 || len(page.Items) != 1 || page.NextCursor == nil {
+		t.Fatalf("unexpected first page %+v", page)
+	}
+	first := page.Items[0].Id
+	h.Do(t, apptest.User1, http.MethodGet, "/clothes?sort=created_at&order=asc&limit=1&cursor="+*page.NextCursor, nil).
+		ExpectStatus(t, http.StatusOK).Decode(t, &page)
+	if len(page.Items) != 1 || page.Items[0].Id == first || page.NextCursor != nil {
+		t.Fatalf("unexpected second page %+v", page)
+	}
+
+	var cloth clothBody
+	h.Do(t, apptest.User1, http.MethodGet, "/clothes/1", nil).ExpectStatus(t, http.StatusOK).Decode(t, &cloth)
+	if cloth.Name == nil || *cloth.Name != "White linen shirt" || len(cloth.Tags) != 1 || cloth.Tags[0].Id != 1 {
+		t.Fatalf("unexpected cloth %+v", cloth)
+	}
+
+	h.Do(t, apptest.User1, http.MethodPost, "/clothes", map[string]any{
+		"category_id": 1,
+		"image_url":   "http://example.com/new.jpg",
+		"name":        "Denim jacket",
+		"tag_ids":     []int{1, 2},
+	}).ExpectStatus(t, http.StatusCreated).Decode(t, &cloth)
+	if cloth.UserId != apptest.User1 || len(cloth.Tags) != 2 {
+		t.Fatalf("unexpected cloth %+v", cloth)
+	}
+	path := fmt.Sprintf("/clothes/%d", cloth.Id)
+
+	h.Do(t, apptest.User1, http.MethodPatch, path, map[string]any{"notes": "Vintage", "tag_ids": nil}).
+		ExpectStatus(t, http.StatusOK).Decode(t, &cloth)
+	if cloth.Notes == nil || *cloth.Notes != "Vintage" || len(cloth.Tags) != 0 {
+		t.Fatalf("patch wasn't applied: %+v", cloth)
+	}
+
+	// a date is today as long as it is today somewhere
+	today := latestToday()
+	h.Do(t, apptest.User1, http.MethodPatch, path, map[string]any{"purchase_date": today.Format("2006-01-02")}).
+		ExpectStatus(t, http.StatusOK)
+	h.Do(t, apptest.User1, http.MethodPatch, path, map[string]any{"purchase_date": today.AddDate(0, 0, 1).Format("2006-01-02")}).
+		ExpectStatus(t, http.StatusUnprocessableEntity)
+
+	var problem problemBody
+	h.Do(t, apptest.User1, http.MethodPatch, path, map[string]any{"purchase_price": 10}).
+		ExpectStatus(t, http.StatusUnprocessableEntity).Decode(t, &problem)
+	if problem.Errors["currency"] == "" {
+		t.Fatalf("expected an error on currency, got %+v", problem)
+	}
+
+	var deletion clothDeletionBody
+	h.Do(t, apptest.User1, http.MethodDelete, path, nil).ExpectStatus(t, http.StatusOK).Decode(t, &deletion)
+	if len(deletion.BrokenOutfits) != 0 {
+		t.Fatalf("cloth wasn't in any outfit: %+v", deletion)
+	}
+	h.Do(t, apptest.User1, http.MethodGet, path, nil).ExpectStatus(t, http.StatusNotFound)
+
+	// clothes placed in a sandbox or worn in an outfit can be deleted too
+	h.Do(t, apptest.User1, http.MethodDelete, "/clothes/1", nil).ExpectStatus(t, http.StatusOK).Decode(t, &deletion)
+	expectIds(t, "broken outfits", ids(deletion.BrokenOutfits, func(o namedBody) int { return o.Id }), 1)
+	var positions []positionBody
+	h.Do(t, apptest.User1, http.MethodGet, "/sandboxes/1/positions", nil).ExpectStatus(t, http.StatusOK).Decode(t, &positions)
+	expectIds(t, "positions", ids(positions, func(p positionBody) int { return p.Id }), 2)
+}
+
+func TestClothImages(t *testing.T) {
+	h := apptest.New(t)
+
+	contentType, body := imageForm(t, map[string]string{"category_id": "1", "name": "Red scarf", "tag_ids": "2"})
+	var cloth clothBody
+	h.DoRaw(t, apptest.User1, http.MethodPost, "/clothes", contentType, body).
+		ExpectStatus(t, http.StatusCreated).Decode(t, &cloth)
+	if cloth.ImageUrl == "" || len(cloth.Tags) != 1 {
+		t.Fatalf("unexpected cloth %+v", cloth)
+	}
+
+	// the stored image is served back by the app
+	original := cloth.ImageUrl
+	if resp := h.DoRaw(t, apptest.Anonymous, http.MethodGet, original, "", nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected stored image at %v, got status %d", original, resp.StatusCode)
+	}
+
+	contentType, body = imageForm(t, nil)
+	h.DoRaw(t, apptest.User1, http.MethodPut, fmt.Sprintf("/clothes/%d/image", cloth.Id), contentType, body).
+		ExpectStatus(t, http.StatusOK).Decode(t, &cloth)
+	if cloth.ImageUrl == original {
+		t.Fatalf("image wasn't replaced: %+v", cloth)
+	}
+
+	// pointing the item at another image drops the stored one along with its cutout and variants
+	replaced := cloth.ImageUrl
+	var patched struct {
+		ImageUrl  string            `json:"image_url"`
+		CutoutUrl *string           `json:"cutout_url"`
+		Images    map[string]string `json:"images"`
+	}
+	h.Do(t, apptest.User1, http.MethodPatch, fmt.Sprintf("/clothes/%d", cloth.Id), map[string]any{"image_url": "http://example.com/scarf.jpg"}).
+		ExpectStatus(t, http.StatusOK).Decode(t, &patched)
+	if patched.CutoutUrl != nil || len(patched.Images) != 1 || patched.Images["full"] != "http://example.com/scarf.jpg" {
+		t.Fatalf("item still shows its old images: %+v", patched)
+	}
+	if resp := h.DoRaw(t, apptest.Anonymous, http.MethodGet, replaced, "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected replaced image at %v to be deleted, got status %d", replaced, resp.StatusCode)
+	}
+
+	// a few bytes can claim more pixels than are safe to decode
+	contentType, body = imageFileForm(t, map[string]string{"category_id": "1"}, pngHeader(50000, 50000))
+	h.DoRaw(t, apptest.User1, http.MethodPost, "/clothes", contentType, body).ExpectStatus(t, http.StatusRequestEntityTooLarge)
+}
+
+func TestSandboxes(t *testing.T) {
+	h := apptest.New(t)
+
+	var sandboxes []namedBody
+	h.Do(t, apptest.User1, http.MethodGet, "/sandboxes", nil).ExpectStatus(t, http.StatusOK).Decode(t, &sandboxes)
+	expectIds(t, "sandboxes", ids(sandboxes, func(s namedBody) int { return s.Id }), 1)
+
+	var detail struct {
+		namedBody
+		Positions []positionBody `json:"positions"`
+	}
+	h.Do(t, apptest.User1, http.MethodGet, "/sandboxes/1", nil).ExpectStatus(t, http.StatusOK).Decode(t, &detail)
+	expectIds(t, "positions", ids(detail.Positions, func(p positionBody) int { return p.Id }), 1, 2)
+
+	var sandbox namedBody
+	h.Do(t, apptest.User1, http.MethodPost, "/sandboxes", map[string]any{"name": "Date night"}).
+		ExpectStatus(t, http.StatusCreated).Decode(t, &sandbox)
+	path := fmt.Sprintf("/sandboxes/%d", sandbox.Id)
+
+	h.Do(t, apptest.User1, http.MethodPatch, path, map[string]any{"name": "Dinner"}).
+		ExpectStatus(t, http.StatusOK).Decode(t, &sandbox)
+	if sandbox.Name != "Dinner" {
+		t.Fatalf("patch wasn't applied: %+v", sandbox)
+	}
+
+	var position positionBody
+	h.Do(t, apptest.User1, http.MethodPost, path+"/positions", map[string]any{"clothing_item_id": 1, "position_x": 10, "position_y": 20}).
+		ExpectStatus(t, http.StatusCreated).Decode(t, &position)
+	positionPath := fmt.Sprintf("%v/positions/%d", path, position.Id)
+
+	h.Do(t, apptest.User1, http.MethodPatch, positionPath, map[string]any{"position_x": 30}).
+		ExpectStatus(t, http.StatusOK).Decode(t, &position)
+	if position.PositionX != 30 || position.PositionY != 20 {
+		t.Fatalf("patch wasn't applied: %+v", position)
+	}
+
+	h.Do(t, apptest.User1, http.MethodDelete, positionPath, nil).ExpectStatus(t, http.StatusNoContent)
+
+	var positions []positionBody
+	h.Do(t, apptest.User1, http.MethodPut, path+"/positions", map[string]any{"positions": []map[string]any{
+		{"clothing_item_id": 1, "position_x": 1, "position_y": 1},
+		{"clothing_item_id": 2, "position_x": 2, "position_y": 2},
+	}}).ExpectStatus(t, http.StatusOK).Decode(t, &positions)
+	if len(positions) != 2 {
+		t.Fatalf("expected the saved layout, got %+v", positions)
+	}
+
+	h.Do(t, apptest.User1, http.MethodGet, path+"/positions", nil).ExpectStatus(t, http.StatusOK).Decode(t, &positions)
+	expectIds(t, "items", ids(positions, func(p positionBody) int { return p.ClothingItemId }), 1, 2)
+
+	h.Do(t, apptest.User1, http.MethodDelete, path, nil).ExpectStatus(t, http.StatusNoContent)
+	h.Do(t, apptest.User1, http.MethodGet, path, nil).ExpectStatus(t, http.StatusNotFound)
+}
+
+func TestSearch(t *testing.T) {
+	h := apptest.New(t)
+
+	var results struct {
+		Items struct {
+			Results []clothBody `json:"results"`
+		} `json:"items"`
+		Tags struct {
+			Results []namedBody `json:"results"`
+		} `json:"tags"`
+	}
+	h.Do(t, apptest.User1, http.MethodGet, "/search?q=linen", nil).ExpectStatus(t, http.StatusOK).Decode(t, &results)
+	expectIds(t, "clothes", ids(results.Items.Results, func(c clothBody) int { return c.Id }), 1)
+
+	// both users have a Summer tag, each only finds their own
+	h.Do(t, apptest.User2, http.MethodGet, "/search?q=summer&type=tags", nil).ExpectStatus(t, http.StatusOK).Decode(t, &results)
+	expectIds(t, "tags", ids(results.Tags.Results, func(tag namedBody) int { return tag.Id }), 3)
+
+	h.Do(t, apptest.User1, http.MethodGet, "/search", nil).ExpectStatus(t, http.StatusBadRequest)
+}
+
+// routeCase is a request to one of the authenticated routes
+type routeCase struct {
+	method string
+	path   string
+	body   any
+}
+
+func TestOutfits(t *testing.T) {
+	h := apptest.New(t)
+
+	var outfits []outfitBody
+	h.Do(t, apptest.User1, http.MethodGet, "/outfits", nil).ExpectStatus(t, http.StatusOK).Decode(t, &outfits)
+	expectIds(t, "outfits", ids(outfits, func(o outfitBody) int { return o.Id }), 1)
+
+	// items come ordered by layer
+	var outfit outfitBody
+	h.Do(t, apptest.User1, http.MethodGet, "/outfits/1", nil).ExpectStatus(t, http.StatusOK).Decode(t, &outfit)
+	expectIds(t, "items", ids(outfit.Items, func(item outfitItemBody) int { return item.ClothingItemId }), 2, 1)
+	if outfit.Name != "Smart casual" || len(outfit.Tags) != 1 || outfit.Tags[0].Id != 1 {
+		t.Fatalf("unexpected outfit %+v", outfit)
+	}
+
+	h.Do(t, apptest.User1, http.MethodPost, "/outfits", map[string]any{
+		"name":    "Layered",
+		"items":   []map[string]any{{"clothing_item_id": 1, "layer": 0}, {"clothing_item_id": 2}},
+		"tag_ids": []int{2},
+	}).ExpectStatus(t, http.StatusCreated).Decode(t, &outfit)
+	if outfit.UserId != apptest.User1 || len(outfit.Items) != 2 || outfit.Items[1].Layer != nil || len(outfit.Tags) != 1 {
+		t.Fatalf("unexpected outfit %+v", outfit)
+	}
+	path := fmt.Sprintf("/outfits/%d", outfit.Id)
+
+	h.Do(t, apptest.User1, http.MethodPatch, path, map[string]any{"notes": "Cold days", "items": []map[string]any{{"clothing_item_id": 2}}, "tag_ids": nil}).
+		ExpectStatus(t, http.StatusOK).Decode(t, &outfit)
+	if outfit.Name != "Layered" || outfit.Notes == nil || *outfit.Notes != "Cold days" || len(outfit.Items) != 1 || len(outfit.Tags) != 0 {
+		t.Fatalf("patch wasn't applied: %+v", outfit)
+	}
+
+	var problem problemBody
+	h.Do(t, apptest.User1, http.MethodPatch, path, map[string]any{"name": nil, "items": []map[string]any{{"clothing_item_id": 1}, {"clothing_item_id": 1, "layer": -1}}}).
+		ExpectStatus(t, http.StatusUnprocessableEntity).Decode(t, &problem)
+	for _, field := range []string{"name", "items[1].clothing_item_id", "items[1].layer"} {
+		if problem.Errors[field] == "" {
+			t.Fatalf("expected an error on %v, got %+v", field, problem)
+		}
+	}
+
+	// listing the outfits wearing an item shows which ones deleting it would break
+	h.Do(t, apptest.User1, http.MethodGet, "/outfits?clothing_item_id=2", nil).ExpectStatus(t, http.StatusOK).Decode(t, &outfits)
+	expectIds(t, "outfits wearing cloth 2", ids(outfits, func(o outfitBody) int { return o.Id }), 1, outfit.Id)
+
+	var deletion clothDeletionBody
+	h.Do(t, apptest.User1, http.MethodDelete, "/clothes/2", nil).ExpectStatus(t, http.StatusOK).Decode(t, &deletion)
+	expectIds(t, "broken outfits", ids(deletion.BrokenOutfits, func(o namedBody) int { return o.Id }), 1, outfit.Id)
+
+	h.Do(t, apptest.User1, http.MethodGet, "/outfits/1", nil).ExpectStatus(t, http.StatusOK).Decode(t, &outfit)
+	if len(outfit.Items) != 1 || outfit.Items[0].ClothingItemId != 1 {
+		t.Fatalf("deleted cloth still in outfit: %+v", outfit)
+	}
+
+	h.Do(t, apptest.User1, http.MethodDelete, path, nil).ExpectStatus(t, http.StatusNoContent)
+	h.Do(t, apptest.User1, http.MethodGet, path, nil).ExpectStatus(t, http.StatusNotFound)
+
+	// the outfit's clothes are kept
+	h.Do(t, apptest.User1, http.MethodGet, "/clothes/1", nil).ExpectStatus(t, http.StatusOK)
+}
+
+func TestWear(t *testing.T) {
+	h := apptest.New(t)
+
+	var cloth clothBody
+	h.Do(t, apptest.User1, http.MethodGet, "/clothes/1", nil).ExpectStatus(t, http.StatusOK).Decode(t, &cloth)
+	if cloth.TimesWorn != 2 || cloth.LastWornAt == nil || *cloth.LastWornAt != "2024-07-05" {
+		t.Fatalf("unexpected wear stats %+v", cloth)
+	}
+
+	var days []calendarDayBody
+	h.Do(t, apptest.User1, http.MethodGet, "/calendar?from=2024-07-01&to=2024-07-31", nil).
+		ExpectStatus(t, http.StatusOK).Decode(t, &days)
+	if len(days) != 2 || days[0].Date != "2024-07-02" || days[1].Date != "2024-07-05" {
+		t.Fatalf("unexpected calendar %+v", days)
+	}
+	if wear := days[0].Wears[0]; len(days[0].Wears) != 1 || wear.Outfit == nil || wear.Outfit.Name != "Smart casual" {
+		t.Fatalf("unexpected wear %+v", days[0].Wears)
+	}
+	expectIds(t, "clothes worn", days[0].Wears[0].ClothingItemIds, 1, 2)
+
+	// a wear of an outfit includes its items along with the ones listed
+	var wear wearBody
+	h.Do(t, apptest.User1, http.MethodPost, "/wear", map[string]any{
+		"date": "2024-07-05", "outfit_id": 1, "clothing_item_ids": []int{2}, "note": "Dinner",
+	}).ExpectStatus(t, http.StatusCreated).Decode(t, &wear)
+	if wear.UserId != apptest.User1 || wear.Date != "2024-07-05" || wear.Outfit == nil || wear.Outfit.Id != 1 {
+		t.Fatalf("unexpected wear %+v", wear)
+	}
+	expectIds(t, "clothes worn", wear.ClothingItemIds, 1, 2)
+
+	h.Do(t, apptest.User1, http.MethodPost, "/wear", map[string]any{"date": "2024-07-10", "clothing_item_ids": []int{2}}).
+		ExpectStatus(t, http.StatusCreated).Decode(t, &wear)
+	if wear.Outfit != nil {
+		t.Fatalf("unexpected wear %+v", wear)
+	}
+
+	h.Do(t, apptest.User1, http.MethodGet, "/clothes/2", nil).ExpectStatus(t, http.StatusOK).Decode(t, &cloth)
+	if cloth.TimesWorn != 3 || cloth.LastWornAt == nil || *cloth.LastWornAt != "2024-07-10" {
+		t.Fatalf("unexpected wear stats %+v", cloth)
+	}
+
+	var problem problemBody
+	h.Do(t, apptest.User1, http.MethodPost, "/wear", map[string]any{"date": "2999-01-01", "note": nil}).
+		ExpectStatus(t, http.StatusUnprocessableEntity).Decode(t, &problem)
+	for _, field := range []string{"date", "clothing_item_ids"} {
+		if problem.Errors[field] == "" {
+			t.Fatalf("expected an error on %v, got %+v", field, problem)
+		}
+	}
+
+	// a wear can be logged for today as soon as today has started somewhere
+	today := latestToday()
+	h.Do(t, apptest.User1, http.MethodPost, "/wear", map[string]any{"date": today.Format("2006-01-02"), "clothing_item_ids": []int{1}}).
+		ExpectStatus(t, http.StatusCreated)
+	h.Do(t, apptest.User1, http.MethodPost, "/wear", map[string]any{"date": today.AddDate(0, 0, 1).Format("2006-01-02"), "clothing_item_ids": []int{1}}).
+		ExpectStatus(t, http.StatusUnprocessableEntity)
+
+	for _, query := range []string{"", "?from=2024-07-01", "?from=2024-07-31&to=2024-07-01", "?from=2024-01-01&to=2025-12-31", "?from=July&to=2024-07-31"} {
+		h.Do(t, apptest.User1, http.MethodGet, "/calendar"+query, nil).ExpectStatus(t, http.StatusBadRequest)
+	}
+
+	// deleting the outfit keeps its wears and what was worn
+	h.Do(t, apptest.User1, http.MethodDelete, "/outfits/1", nil).ExpectStatus(t, http.StatusNoContent)
+	h.Do(t, apptest.User1, http.MethodGet, "/calendar?from=2024-07-02&to=2024-07-02", nil).
+		ExpectStatus(t, http.StatusOK).Decode(t, &days)
+	if len(days) != 1 || len(days[0].Wears) != 1 || days[0].Wears[0].Outfit != nil {
+		t.Fatalf("unexpected calendar %+v", days)
+	}
+	expectIds(t, "clothes worn", days[0].Wears[0].ClothingItemIds, 1, 2)
+
+	// a cloth in the trash stays in the wears, deleting it for good takes it out
+	h.Do(t, apptest.User1, http.MethodDelete, "/clothes/2", nil).ExpectStatus(t, http.StatusOK)
+	h.Do(t, apptest.User1, http.MethodGet, "/calendar?from=2024-07-02&to=2024-07-02", nil).
+		ExpectStatus(t, http.StatusOK).Decode(t, &days)
+	expectIds(t, "clothes worn", days[0].Wears[0].ClothingItemIds, 1, 2)
+	h.Do(t, apptest.User1, http.MethodDelete, "/trash/clothes/2", nil).ExpectStatus(t, http.StatusNoContent)
+	h.Do(t, apptest.User1, http.MethodGet, "/calendar?from=2024-07-02&to=2024-07-02", nil).
+		ExpectStatus(t, http.StatusOK).Decode(t, &days)
+	expectIds(t, "clothes worn", days[0].Wears[0].ClothingItemIds, 1)
+}
+
+func TestTrash(t *testing.T) {
+	h := apptest.New(t)
+
+	var trash []trashEntryBody
+	h.Do(t, apptest.User1, http.MethodGet, "/trash", nil).ExpectStatus(t, http.StatusOK).Decode(t, &trash)
+	if len(trash) != 0 {
+		t.Fatalf("expected an empty trash, got %+v", trash)
+	}
+
+	// a deleted cloth waits in the trash with its tags, out of its sandbox and outfit
+	h.Do(t, apptest.User1, http.MethodDelete, "/clothes/1", nil).ExpectStatus(t, http.StatusOK)
+	h.Do(t, apptest.User1, http.MethodGet, "/trash", nil).ExpectStatus(t, http.StatusOK).Decode(t, &trash)
+	if len(trash) != 1 || trash[0].Type != "clothes" || trash[0].Id != 1 || trash[0].Name == nil || *trash[0].Name != "White linen shirt" {
+		t.Fatalf("unexpected trash %+v", trash)
+	}
+	if !trash[0].PurgeAt.After(trash[0].DeletedAt) {
+		t.Fatalf("purge_at isn't after deleted_at: %+v", trash[0])
+	}
+	h.Do(t, apptest.User1, http.MethodGet, "/clothes/1", nil).ExpectStatus(t, http.StatusNotFound)
+	h.Do(t, apptest.User1, http.MethodPost, "/outfits", map[string]any{"name": "Trashed", "items": []map[string]any{{"clothing_item_id": 1}}}).
+		ExpectStatus(t, http.StatusUnprocessableEntity)
+
+	var cloth clothBody
+	h.Do(t, apptest.User1, http.MethodPost, "/trash/clothes/1/restore", nil).ExpectStatus(t, http.StatusOK).Decode(t, &cloth)
+	if cloth.Id != 1 || len(cloth.Tags) != 1 {
+		t.Fatalf("unexpected restored cloth %+v", cloth)
+	}
+	var outfit outfitBody
+	h.Do(t, apptest.User1, http.MethodGet, "/outfits/1", nil).ExpectStatus(t, http.StatusOK).Decode(t, &outfit)
+	expectIds(t, "items", ids(outfit.Items, func(item outfitItemBody) int { return item.ClothingItemId }), 2)
+	h.Do(t, apptest.User1, http.MethodPost, "/trash/clothes/1/restore", nil).ExpectStatus(t, http.StatusNotFound)
+
+	// a deleted category takes its clothes along, and brings them back when restored
+	h.Do(t, apptest.User1, http.MethodDelete, "/clothes/2", nil).ExpectStatus(t, http.StatusOK)
+	h.Do(t, apptest.User1, http.MethodDelete, "/categories/1?on_items=trash", nil).ExpectStatus(t, http.StatusOK)
+	h.Do(t, apptest.User1, http.MethodGet, "/categories/1", nil).ExpectStatus(t, http.StatusNotFound)
+	h.Do(t, apptest.User1, http.MethodGet, "/trash", nil).ExpectStatus(t, http.StatusOK).Decode(t, &trash)
+	if len(trash) != 3 {
+		t.Fatalf("unexpected trash %+v", trash)
+	}
+	var problem problemBody
+	h.Do(t, apptest.User1, http.MethodPost, "/clothes", map[string]any{"category_id": 1, "image_url": "http://example.com/new.jpg"}).
+		ExpectStatus(t, http.StatusUnprocessableEntity).Decode(t, &problem)
+	if problem.Errors["category_id"] == "" {
+		t.Fatalf("expected an error on category_id, got %+v", problem)
+	}
+	h.Do(t, apptest.User1, http.MethodPost, "/trash/clothes/1/restore", nil).ExpectStatus(t, http.StatusConflict)
+
+	var category namedBody
+	h.Do(t, apptest.User1, http.MethodPost, "/trash/categories/1/restore", nil).ExpectStatus(t, http.StatusOK).Decode(t, &category)
+	if category.Id != 1 || category.Name != "Tops" {
+		t.Fatalf("unexpected restored category %+v", category)
+	}
+	h.Do(t, apptest.User1, http.MethodGet, "/clothes/1", nil).ExpectStatus(t, http.StatusOK)
+	h.Do(t, apptest.User1, http.MethodGet, "/trash", nil).ExpectStatus(t, http.StatusOK).Decode(t, &trash)
+	if len(trash) != 1 || trash[0].Id != 2 {
+		t.Fatalf("cloth deleted before its category should stay in the trash: %+v", trash)
+	}
+
+	// deleting for good
+	h.Do(t, apptest.User1, http.MethodDelete, "/trash/clothes/2", nil).ExpectStatus(t, http.StatusNoContent)
+	h.Do(t, apptest.User1, http.MethodPost, "/trash/clothes/2/restore", nil).ExpectStatus(t, http.StatusNotFound)
+	h.Do(t, apptest.User1, http.MethodDelete, "/trash/clothes/1", nil).ExpectStatus(t, http.StatusNotFound)
+
+	h.Do(t, apptest.User1, http.MethodDelete, "/categories/1?on_items=trash", nil).ExpectStatus(t, http.StatusOK)
+	h.Do(t, apptest.User1, http.MethodDelete, "/trash/categories/1", nil).ExpectStatus(t, http.StatusNoContent)
+	h.Do(t, apptest.User1, http.MethodPost, "/trash/clothes/1/restore", nil).ExpectStatus(t, http.StatusNotFound)
+
+	h.Do(t, apptest.User1, http.MethodDelete, "/categories/2", nil).ExpectStatus(t, http.StatusOK)
+	h.Do(t, apptest.User1, http.MethodDelete, "/trash", nil).ExpectStatus(t, http.StatusNoContent)
+	h.Do(t, apptest.User1, http.MethodGet, "/trash", nil).ExpectStatus(t, http.StatusOK).Decode(t, &trash)
+	if len(trash) != 0 {
+		t.Fatalf("expected an empty trash, got %+v", trash)
+	}
+	h.Do(t, apptest.User1, http.MethodGet, "/categories/2", nil).ExpectStatus(t, http.StatusNotFound)
+
+	// another user's trash can't be restored or emptied
+	h.Do(t, apptest.User2, http.MethodDelete, "/categories/3?on_items=trash", nil).ExpectStatus(t, http.StatusOK)
+	for _, route := range []routeCase{
+		{http.MethodPost, "/trash/clothes/3/restore", nil},
+		{http.MethodDelete, "/trash/clothes/3", nil},
+		{http.MethodPost, "/trash/categories/3/restore", nil},
+		{http.MethodDelete, "/trash/categories/3", nil},
+	} {
+		h.Do(t, apptest.User1, route.method, route.path, route.body).ExpectStatus(t, http.StatusNotFound)
+	}
+	h.Do(t, apptest.User1, http.MethodDelete, "/trash", nil).ExpectStatus(t, http.StatusNoContent)
+	h.Do(t, apptest.User2, http.MethodGet, "/trash", nil).ExpectStatus(t, http.StatusOK).Decode(t, &trash)
+	if len(trash) != 2 {
+		t.Fatalf("user 2's trash was changed: %+v", trash)
+	}
+}
+
+// userTwoResources are requests on everything user 2 owns, addressed directly by id
+var userTwoResources = []routeCase{
+	{http.MethodGet, "/sandboxes/2", nil},
+	{http.MethodPatch, "/sandboxes/2", map[string]any{"name": "Mine now"}},
+	{http.MethodDelete, "/sandboxes/2", nil},
+	{http.MethodGet, "/sandboxes/2/positions", nil},
+	{http.MethodPost, "/sandboxes/2/positions", map[string]any{"clothing_item_id": 1, "position_x": 0, "position_y": 0}},
+	{http.MethodPut, "/sandboxes/2/positions", map[string]any{"positions": []any{}}},
+	{http.MethodPatch, "/sandboxes/2/positions/3", map[string]any{"position_x": 0}},
+	{http.MethodDelete, "/sandboxes/2/positions/3", nil},
+	// user 2's position through user 1's own sandbox
+	{http.MethodPatch, "/sandboxes/1/positions/3", map[string]any{"position_x": 0}},
+	{http.MethodDelete, "/sandboxes/1/positions/3", nil},
+	{http.MethodGet, "/clothes/3", nil},
+	{http.MethodPatch, "/clothes/3", map[string]any{"name": "Mine now"}},
+	{http.MethodDelete, "/clothes/3", nil},
+	{http.MethodGet, "/categories/3", nil},
+	{http.MethodPatch, "/categories/3", map[string]any{"name": "Mine now"}},
+	{http.MethodDelete, "/categories/3", nil},
+	{http.MethodPost, "/categories/3/move", map[string]any{"parent_id": nil}},
+	{http.MethodGet, "/tags/3", nil},
+	{http.MethodPatch, "/tags/3", map[string]any{"name": "Mine now"}},
+	{http.MethodDelete, "/tags/3", nil},
+	{http.MethodGet, "/outfits/2", nil},
+	{http.MethodPatch, "/outfits/2", map[string]any{"name": "Mine now"}},
+	{http.MethodDelete, "/outfits/2", nil},
+}
+
+func TestExportImport(t *testing.T) {
+	h := apptest.New(t)
+
+	// an uploaded image travels in the archive, the seeded clothes keep their external urls
+	contentType, body := imageForm(t, map[string]string{"category_id": "1", "name": "Red scarf", "tag_ids": "2"})
+	var uploaded clothBody
+	h.DoRaw(t, apptest.User1, http.MethodPost, "/clothes", contentType, body).ExpectStatus(t, http.StatusCreated).Decode(t, &uploaded)
+
+	export := h.Do(t, apptest.User1, http.MethodGet, "/export", nil).ExpectStatus(t, http.StatusOK)
+	if contentType := export.Header.Get("Content-Type"); contentType != "application/zip" {
+		t.Fatalf("export is %v, not a zip", contentType)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(export.Body), int64(len(export.Body)))
+	if err != nil {
+		t.Fatalf("export is not a zip: %v", err)
+	}
+	files := map[string]*zip.File{}
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	var manifest struct {
+		Version    int            `json:"version"`
+		Categories []categoryBody `json:"categories"`
+		Tags       []namedBody    `json:"tags"`
+		Clothes    []struct {
+			Id       int     `json:"id"`
+			Image    *string `json:"image"`
+			ImageUrl string  `json:"image_url"`
+			TagIds   []int   `json:"tag_ids"`
+		} `json:"clothes"`
+		Outfits []struct {
+			Items []struct {
+				ClothingItemId int `json:"clothing_item_id"`
+			} `json:"items"`
+		} `json:"outfits"`
+		Sandboxes []struct {
+			Positions []struct {
+				ClothingItemId int `json:"clothing_item_id"`
+			} `json:"positions"`
+		} `json:"sandboxes"`
+	}
+	if files["manifest.json"] == nil {
+		t.Fatalf("export has no manifest: %v", files)
+	}
+	manifestFile, err := files["manifest.json"].Open()
+	if err != nil {
+		t.Fatalf("Failed to open manifest: %v", err)
+	}
+	if err := json.NewDecoder(manifestFile).Decode(&manifest); err != nil {
+		t.Fatalf("Failed to decode manifest: %v", err)
+	}
+	manifestFile.Close()
+
+	expectIds(t, "exported categories", ids(manifest.Categories, func(c categoryBody) int { return c.Id }), 1, 2)
+	expectIds(t, "exported tags", ids(manifest.Tags, func(t namedBody) int { return t.Id }), 1, 2)
+	if len(manifest.Clothes) != 3 || len(manifest.Outfits) != 1 || len(manifest.Outfits[0].Items) != 2 ||
+		len(manifest.Sandboxes) != 1 || len(manifest.Sandboxes[0].Positions) != 2 {
+		t.Fatalf("export is missing part of the closet: %+v", manifest)
+	}
+	for _, cloth := range manifest.Clothes {
+		stored := cloth.Id == uploaded.Id
+		if (cloth.Image != nil) != stored || (stored && files[*cloth.Image] == nil) || (cloth.ImageUrl == "") != stored {
+			t.Fatalf("clothing item %v doesn't have its image in the export: %+v", cloth.Id, cloth)
+		}
+	}
+
+	// user 2 already has a Summer tag, everything else is new to them
+	var report importReportBody
+	h.DoRaw(t, apptest.User2, http.MethodPost, "/import", "application/zip", bytes.NewReader(export.Body)).
+		ExpectStatus(t, http.StatusOK).Decode(t, &report)
+	if len(report.Errors) != 0 || report.Created.Categories != 2 || report.Created.Tags != 1 || report.Created.Clothes != 3 ||
+		report.Created.Outfits != 1 || report.Created.Sandboxes != 1 || report.Reused.Tags != 1 {
+		t.Fatalf("unexpected import report %+v", report)
+	}
+
+	var page struct {
+		Items []clothBody `json:"items"`
+	}
+	h.Do(t, apptest.User2, http.MethodGet, "/clothes?sort=created_at&order=asc", nil).ExpectStatus(t, http.StatusOK).Decode(t, &page)
+	if len(page.Items) != 4 {
+		t.Fatalf("expected user 2 to have 4 clothing items, got %+v", page.Items)
+	}
+	scarf := page.Items[3]
+	if scarf.Name == nil || *scarf.Name != "Red scarf" || scarf.ImageUrl == uploaded.ImageUrl || scarf.ImageUrl == "" {
+		t.Fatalf("the uploaded item wasn't imported with its own copy of the image: %+v", scarf)
+	}
+
+	var outfits []outfitBody
+	h.Do(t, apptest.User2, http.MethodGet, "/outfits", nil).ExpectStatus(t, http.StatusOK).Decode(t, &outfits)
+	if len(outfits) != 2 {
+		t.Fatalf("expected user 2 to have 2 outfits, got %+v", outfits)
+	}
+	for _, item := range outfits[1].Items {
+		if item.ClothingItemId == 1 || item.ClothingItemId == 2 {
+			t.Fatalf("imported outfit still points at user 1's clothes: %+v", outfits[1])
+		}
+	}
+
+	// one bad row of a CSV doesn't stop the others
+	csv := "name,category,tags,image_url,purchase_price,currency\n" +
+		"Linen shirt,Tops/Shirts,Summer; Linen,http://example.com/shirt.jpg,25,EUR\n" +
+		"Broken,Tops,,http://example.com/broken.jpg,-1,EUR\n" +
+		"\n" +
+		"No category,,,http://example.com/none.jpg,,\n"
+	report = importReportBody{}
+	h.DoRaw(t, apptest.User1, http.MethodPost, "/import", "text/csv", strings.NewReader(csv)).
+		ExpectStatus(t, http.StatusOK).Decode(t, &report)
+	if report.Created.Clothes != 1 || report.Created.Categories != 1 || report.Reused.Categories != 1 ||
+		report.Created.Tags != 1 || report.Reused.Tags != 1 || len(report.Errors) != 2 ||
+		report.Errors[0].Entry != "line 3" || report.Errors[0].Errors["purchase_price"] == "" ||
+		report.Errors[1].Entry != "line 5" || report.Errors[1].Errors["category"] == "" {
+		t.Fatalf("unexpected csv import report %+v", report)
+	}
+
+	var categories []categoryBody
+	h.Do(t, apptest.User1, http.MethodGet, "/categories", nil).ExpectStatus(t, http.StatusOK).Decode(t, &categories)
+	shirts := categories[len(categories)-1]
+	if shirts.Name != "Shirts" || shirts.ParentId == nil || *shirts.ParentId != 1 {
+		t.Fatalf("expected Shirts to be created under Tops, got %+v", categories)
+	}
+
+	// entries that don't hold together are reported one by one
+	var crafted bytes.Buffer
+	writer := zip.NewWriter(&crafted)
+	file, err := writer.Create("manifest.json")
+	if err != nil {
+		t.Fatalf("Failed to build archive: %v", err)
+	}
+	fmt.Fprint(file, `{
+		"version": 1,
+		"categories": [{"id": 1, "name": "Kept"}, {"id": 2, "parent_id": 3, "name": "Loop"}, {"id": 3, "parent_id": 2, "name": "Loop"}],
+		"tags": [{"id": 1, "name": ""}],
+		"clothes": [
+			{"id": 1, "category_id": 2, "image_url": "http://example.com/a.jpg"},
+			{"id": 2, "category_id": 1, "image": "images/missing.png"},
+			{"id": 3, "category_id": 1, "image_url": "http://example.com/c.jpg", "purchase_price": "cheap"},
+			{"id": 4, "category_id": 1, "image_url": "http://example.com/d.jpg", "tag_ids": [1]}
+		],
+		"outfits": [{"name": "Incomplete", "items": [{"clothing_item_id": 1}]}],
+		"sandboxes": [{"name": "Unknown", "positions": [{"clothing_item_id": 9, "position_x": 1, "position_y": 2}]}]
+	}`)
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to build archive: %v", err)
+	}
+
+	report = importReportBody{}
+	h.DoRaw(t, apptest.User1, http.MethodPost, "/import", "application/zip", &crafted).
+		ExpectStatus(t, http.StatusOK).Decode(t, &report)
+	entries := []string{}
+	for _, importErr := range report.Errors {
+		entries = append(entries, importErr.Entry)
+	}
+	if fmt.Sprint(entries) != "[categories[2] categories[1] tags[0] clothes[0] clothes[1] clothes[2] clothes[3] outfits[0] sandboxes[0]]" ||
+		report.Created.Categories != 1 || report.Created.Clothes != 0 {
+		t.Fatalf("unexpected import report %+v", report)
+	}
+	if report.Errors[6].Errors["tag_ids"] != "contains a tag that wasn't imported" ||
+		report.Errors[7].Errors["items[0].clothing_item_id"] != "is a clothing item that wasn't imported" ||
+		report.Errors[8].Errors["positions[0].clothing_item_id"] != "is not a clothing item in the archive" {
+		t.Fatalf("unexpected import errors %+v", report.Errors)
+	}
+
+	h.DoRaw(t, apptest.User1, http.MethodPost, "/import", "text/plain", strings.NewReader("hello")).
+		ExpectStatus(t, http.StatusUnsupportedMediaType)
+	h.DoRaw(t, apptest.User1, http.MethodPost, "/import", "application/zip", strings.NewReader("not a zip")).
+		ExpectStatus(t, http.StatusBadRequest)
+	h.DoRaw(t, apptest.User1, http.MethodPost, "/import", "text/csv", strings.NewReader("colour,category,image_url\n")).
+		ExpectStatus(t, http.StatusBadRequest)
+}
+
+func TestOtherUsersResourcesAreNotFound(t *testing.T) {
+	h := apptest.New(t)
+
+	for _, route := range userTwoResources {
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			h.Do(t, apptest.User1, route.method, route.path, route.body).ExpectStatus(t, http.StatusNotFound)
+		})
+	}
+
+	contentType, body := imageForm(t, nil)
+	h.DoRaw(t, apptest.User1, http.MethodPut, "/clothes/3/image", contentType, body).ExpectStatus(t, http.StatusNotFound)
+
+	// none of it changed anything
+	var cloth clothBody
+	h.Do(t, apptest.User2, http.MethodGet, "/clothes/3", nil).ExpectStatus(t, http.StatusOK).Decode(t, &cloth)
+	if cloth.Name == nil || *cloth.Name != "Leather belt" || cloth.ImageUrl != "http://example.com/clothing3.jpg" {
+		t.Fatalf("user 2's cloth was changed: %+v", cloth)
+	}
+	var sandbox struct {
+		namedBody
+		Positions []positionBody `json:"positions"`
+	}
+	h.Do(t, apptest.User2, http.MethodGet, "/sandboxes/2", nil).ExpectStatus(t, http.StatusOK).Decode(t, &sandbox)
+	if sandbox.Name != "Office" || len(sandbox.Positions) != 1 || sandbox.Positions[0].PositionX != 200 {
+		t.Fatalf("user 2's sandbox was changed: %+v", sandbox)
+	}
+	h.Do(t, apptest.User2, http.MethodGet, "/categories/3", nil).ExpectStatus(t, http.StatusOK)
+	h.Do(t, apptest.User2, http.MethodGet, "/tags/3", nil).ExpectStatus(t, http.StatusOK)
+	var outfit outfitBody
+	h.Do(t, apptest.User2, http.MethodGet, "/outfits/2", nil).ExpectStatus(t, http.StatusOK).Decode(t, &outfit)
+	if outfit.Name != "Belted" || len(outfit.Items) != 1 {
+		t.Fatalf("user 2's outfit was changed: %+v", outfit)
+	}
+}
+
+func TestOtherUsersResourcesCantBeReferenced(t *testing.T) {
+	h := apptest.New(t)
+
+	h.Do(t, apptest.User1, http.MethodPost, "/clothes", map[string]any{
+		"category_id": 1, "image_url": "http://example.com/new.jpg", "tag_ids": []int{3},
+	}).ExpectStatus(t, http.StatusUnprocessableEntity)
+	h.Do(t, apptest.User1, http.MethodPatch, "/clothes/1", map[string]any{"tag_ids": []int{1, 3}}).
+		ExpectStatus(t, http.StatusUnprocessableEntity)
+	h.Do(t, apptest.User1, http.MethodPost, "/clothes", map[string]any{
+		"category_id": 3, "image_url": "http://example.com/new.jpg",
+	}).ExpectStatus(t, http.StatusUnprocessableEntity)
+	h.Do(t, apptest.User1, http.MethodPatch, "/clothes/1", map[string]any{"category_id": 3}).
+		ExpectStatus(t, http.StatusUnprocessableEntity)
+
+	contentType, body := imageForm(t, map[string]string{"category_id": "1", "tag_ids": "3"})
+	h.DoRaw(t, apptest.User1, http.MethodPost, "/clothes", contentType, body).ExpectStatus(t, http.StatusUnprocessableEntity)
+
+	// images stored for user 2 are deleted with their items, so user 1 can't point at them
+	var uploaded clothBody
+	contentType, body = imageForm(t, map[string]string{"category_id": "3"})
+	h.DoRaw(t, apptest.User2, http.MethodPost, "/clothes", contentType, body).ExpectStatus(t, http.StatusCreated).Decode(t, &uploaded)
+	h.Do(t, apptest.User1, http.MethodPost, "/clothes", map[string]any{"category_id": 1, "image_url": uploaded.ImageUrl}).
+		ExpectStatus(t, http.StatusUnprocessableEntity)
+	h.Do(t, apptest.User1, http.MethodPatch, "/clothes/1", map[string]any{"image_url": uploaded.ImageUrl}).
+		ExpectStatus(t, http.StatusUnprocessableEntity)
+	var report importReportBody
+	h.DoRaw(t, apptest.User1, http.MethodPost, "/import", "text/csv", strings.NewReader("category,image_url\nTops,"+uploaded.ImageUrl+"\n")).
+		ExpectStatus(t, http.StatusOK).Decode(t, &report)
+	if report.Created.Clothes != 0 || len(report.Errors) != 1 || report.Errors[0].Errors["image_url"] == "" {
+		t.Fatalf("expected the import to refuse user 2's image, got %+v", report)
+	}
+
+	h.Do(t, apptest.User1, http.MethodPost, "/sandboxes/1/positions", map[string]any{"clothing_item_id": 3, "position_x": 0, "position_y": 0}).
+		ExpectStatus(t, http.StatusBadRequest)
+	h.Do(t, apptest.User1, http.MethodPut, "/sandboxes/1/positions", map[string]any{"positions": []map[string]any{
+		{"clothing_item_id": 1, "position_x": 0, "position_y": 0},
+		{"clothing_item_id": 3, "position_x": 0, "position_y": 0},
+	}}).ExpectStatus(t, http.StatusBadRequest)
+
+	// the rejected layout left the old one in place
+	var positions []positionBody
+	h.Do(t, apptest.User1, http.MethodGet, "/sandboxes/1/positions", nil).ExpectStatus(t, http.StatusOK).Decode(t, &positions)
+	expectIds(t, "positions", ids(positions, func(p positionBody) int { return p.Id }), 1, 2)
+
+	var problem problemBody
+	h.Do(t, apptest.User1, http.MethodPost, "/outfits", map[string]any{
+		"name": "Borrowed", "items": []map[string]any{{"clothing_item_id": 1}, {"clothing_item_id": 3}},
+	}).ExpectStatus(t, http.StatusUnprocessableEntity).Decode(t, &problem)
+	if problem.Errors["items"] == "" {
+		t.Fatalf("expected an error on items, got %+v", problem)
+	}
+	h.Do(t, apptest.User1, http.MethodPatch, "/outfits/1", map[string]any{"tag_ids": []int{3}}).
+		ExpectStatus(t, http.StatusUnprocessableEntity)
+
+	h.Do(t, apptest.User1, http.MethodPost, "/wear", map[string]any{"date": "2024-07-06", "outfit_id": 2}).
+		ExpectStatus(t, http.StatusUnprocessableEntity).Decode(t, &problem)
+	if problem.Errors["outfit_id"] == "" {
+		t.Fatalf("expected an error on outfit_id, got %+v", problem)
+	}
+	h.Do(t, apptest.User1, http.MethodPost, "/wear", map[string]any{"date": "2024-07-06", "outfit_id": 1, "clothing_item_ids": []int{3}}).
+		ExpectStatus(t, http.StatusUnprocessableEntity).Decode(t, &problem)
+	if problem.Errors["clothing_item_ids"] == "" {
+		t.Fatalf("expected an error on clothing_item_ids, got %+v", problem)
+	}
+
+	// the rejected patch left the outfit as it was
+	var outfit outfitBody
+	h.Do(t, apptest.User1, http.MethodGet, "/outfits/1", nil).ExpectStatus(t, http.StatusOK).Decode(t, &outfit)
+	if len(outfit.Tags) != 1 || outfit.Tags[0].Id != 1 {
+		t.Fatalf("outfit was changed: %+v", outfit)
+	}
+}
+
+func TestListsOnlyShowOwnResources(t *testing.T) {
+	h := apptest.New(t)
+
+	lists := map[string][]int{
+		"/categories": {3},
+		"/tags":       {3},
+		"/sandboxes":  {2},
+		"/outfits":    {2},
+	}
+	for path, want := range lists {
+		var items []idBody
+		h.Do(t, apptest.User2, http.MethodGet, path, nil).ExpectStatus(t, http.StatusOK).Decode(t, &items)
+		expectIds(t, path, ids(items, func(item idBody) int { return item.Id }), want...)
+	}
+
+	var page struct {
+		Items []idBody `json:"items"`
+	}
+	h.Do(t, apptest.User2, http.MethodGet, "/clothes", nil).ExpectStatus(t, http.StatusOK).Decode(t, &page)
+	expectIds(t, "clothes", ids(page.Items, func(item idBody) int { return item.Id }), 3)
+
+	var days []calendarDayBody
+	h.Do(t, apptest.User2, http.MethodGet, "/calendar?from=2024-07-01&to=2024-07-31", nil).ExpectStatus(t, http.StatusOK).Decode(t, &days)
+	if len(days) != 1 || len(days[0].Wears) != 1 {
+		t.Fatalf("unexpected calendar %+v", days)
+	}
+	expectIds(t, "clothes worn", days[0].Wears[0].ClothingItemIds, 3)
+}
+
+func TestRoutesRequireASession(t *testing.T) {
+	h := apptest.New(t)
+
+	routes := append([]routeCase{
+		{http.MethodPost, "/users", nil},
+		{http.MethodGet, "/me", nil},
+		{http.MethodPatch, "/me", nil},
+		{http.MethodDelete, "/me", nil},
+		{http.MethodGet, "/sandboxes", nil},
+		{http.MethodPost, "/sandboxes", nil},
+		{http.MethodGet, "/clothes", nil},
+		{http.MethodPost, "/clothes", nil},
+		{http.MethodPut, "/clothes/1/image", nil},
+		{http.MethodGet, "/categories", nil},
+		{http.MethodGet, "/categories/tree", nil},
+		{http.MethodPost, "/categories", nil},
+		{http.MethodGet, "/tags", nil},
+		{http.MethodPost, "/tags", nil},
+		{http.MethodGet, "/outfits", nil},
+		{http.MethodPost, "/outfits", nil},
+		{http.MethodPost, "/wear", nil},
+		{http.MethodGet, "/calendar?from=2024-07-01&to=2024-07-31", nil},
+		{http.MethodGet, "/search?q=shirt", nil},
+		{http.MethodGet, "/trash", nil},
+		{http.MethodDelete, "/trash", nil},
+		{http.MethodPost, "/trash/clothes/1/restore", nil},
+		{http.MethodDelete, "/trash/clothes/1", nil},
+		{http.MethodPost, "/trash/categories/1/restore", nil},
+		{http.MethodDelete, "/trash/categories/1", nil},
+		{http.MethodGet, "/export", nil},
+		{http.MethodPost, "/import", nil},
+	}, userTwoResources...)
+
+	for _, route := range routes {
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			h.Do(t, apptest.Anonymous, route.method, route.path, route.body).ExpectStatus(t, http.StatusUnauthorized)
+		})
+	}
+
+	// a session of a deleted user is no longer accepted
+	h.Do(t, apptest.User2, http.MethodDelete, "/me", nil).ExpectStatus(t, http.StatusNoContent)
+	h.Do(t, apptest.User2, http.MethodGet, "/clothes", nil).ExpectStatus(t, http.StatusUnauthorized)
+}
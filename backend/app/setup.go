@@ -1,19 +1,29 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"time"
 
+	"com.fukubox/apispec"
+	"com.fukubox/auth"
 	"com.fukubox/config"
 	"com.fukubox/database" // Import the package that contains the StartDB function
+	"com.fukubox/handlers"
+	"com.fukubox/problem"
+	"com.fukubox/repository"
 	"com.fukubox/router"
+	"com.fukubox/starter"
+	"com.fukubox/storage"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 )
 
+const defaultArchiveTimeout = 30 * time.Minute
+
 func SetupAndRunApp() error {
 	// load env
 	err := config.LoadENV()
@@ -30,6 +40,67 @@ func SetupAndRunApp() error {
 	fmt.Println("Database connected")
 	defer database.CloseDB()
 
+	// refuse to run against a schema this build doesn't match
+	err = migrateOnStart(context.Background())
+	if err != nil {
+		return err
+	}
+
+	// configure login and sessions
+	err = auth.StartSessions()
+	if err != nil {
+		return err
+	}
+
+	err = auth.StartOIDC(context.Background())
+	if err != nil {
+		return err
+	}
+
+	// configure where uploaded images are stored
+	err = storage.StartStorage(context.Background())
+	if err != nil {
+		return err
+	}
+
+	// configure what new users start their closet with
+	err = starter.StartStarter()
+	if err != nil {
+		return err
+	}
+
+	// handlers reach the database only through the repositories they are given
+	server := handlers.NewServer(repository.NewPostgres(database.GetDB()))
+	var handler http.Handler = NewRouter(server)
+
+	// deleted clothes and categories are purged for good once TRASH_RETENTION is up
+	go server.RunTrashPurge(context.Background(), time.Hour)
+
+	// log where traffic and the api spec disagree, see apispec
+	if specPath := os.Getenv("OPENAPI_SPEC"); specPath != "" {
+		spec, err := LoadSpec(specPath)
+		if err != nil {
+			return err
+		}
+		handler = spec.Middleware(func(r *http.Request, err error) {
+			log.Printf("API spec mismatch \n\tRequest: %v %v \n\tError: %v", r.Method, r.URL.Path, err)
+		})(handler)
+	}
+
+	// get the port and start
+	port := os.Getenv("PORT")
+
+	err = http.ListenAndServe(":"+port, handler)
+
+	if err != nil {
+		log.Printf("Failed to launch api server:%+v\n", err)
+	}
+
+	return nil
+}
+
+// NewRouter builds the api's routes and middleware around server
+func NewRouter(server *handlers.Server) *chi.Mux {
 	r := chi.NewRouter()
 	// A good base middleware stack
 	r.Use(middleware.RequestID)
@@ -38,21 +109,53 @@ func SetupAndRunApp() error {
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Heartbeat("/ping"))
 
-	// Set a timeout value on the request context (ctx), that will signal
-	// through ctx.Done() that the request has timed out and further
-	// processing should be stopped.
-	r.Use(middleware.Timeout(60 * time.Second))
+	// unknown routes fail with the same problem+json body as the handlers
+	r.NotFound(problem.NotFoundHandler)
+	r.MethodNotAllowed(problem.MethodNotAllowedHandler)
 
-	router.SetupAuthenticatedRoutes(r)
+	// the api spec and its docs
+	config.AddSwaggerRoutes(r)
 
-	// get the port and start
-	port := os.Getenv("PORT")
+	r.Group(func(r chi.Router) {
+		// Set a timeout value on the request context (ctx), that will signal
+		// through ctx.Done() that the request has timed out and further
+		// processing should be stopped.
+		r.Use(middleware.Timeout(60 * time.Second))
 
-	err = http.ListenAndServe(":"+port, r)
+		router.SetupPublicRoutes(r, server)
+		router.SetupAuthenticatedRoutes(r, server)
+	})
 
-	if err != nil {
-		log.Printf("Failed to launch api server:%+v\n", err)
+	// exporting or importing a whole closet takes longer than any other request
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(archiveTimeout()))
+
+		router.SetupArchiveRoutes(r, server)
+	})
+
+	return r
+}
+
+// archiveTimeout reads how long a closet export or import may take from ARCHIVE_TIMEOUT, a Go
+// duration like 30m
+func archiveTimeout() time.Duration {
+	if value := os.Getenv("ARCHIVE_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err == nil && timeout > 0 {
+			return timeout
+		}
+		log.Printf("Ignoring invalid ARCHIVE_TIMEOUT %q", value)
 	}
+	return defaultArchiveTimeout
+}
 
-	return nil
+// LoadSpec loads the OpenAPI spec at path for checking the routes NewRouter sets up
+func LoadSpec(path string) (*apispec.Validator, error) {
+	spec, err := apispec.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	// the heartbeat, the docs and locally stored images aren't part of the api
+	spec.Ignore("/ping", "/openapi.", "/docs", storage.LocalRoutePrefix+"/")
+	return spec, nil
 }
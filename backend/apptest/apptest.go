@@ -0,0 +1,283 @@
+// Package apptest runs the api against a real PostgreSQL for integration tests. A test
+// package hands its TestMain to Main, which starts a throwaway cluster from the locally
+// installed binaries, and every test calls New for its own migrated and seeded database
+// behind the app's router on an ephemeral port. NewInMemory serves the same router from a
+// store the test arranged itself, usually a memory.Store, without PostgreSQL. All traffic is
+// checked against the OpenAPI spec, so a test fails when a route and its documentation disagree.
+package apptest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"com.fukubox/apispec"
+	"com.fukubox/app"
+	"com.fukubox/auth"
+	"com.fukubox/handlers"
+	"com.fukubox/repository"
+	"com.fukubox/starter"
+	"com.fukubox/storage"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// The users database/seed.sql creates, see it for what each of them owns
+const (
+	// Anonymous sends requests without a session
+	Anonymous = 0
+
+	User1 = 1
+	User2 = 2
+)
+
+var (
+	servicesOnce sync.Once
+	servicesErr  error
+	spec         *apispec.Validator
+
+	clusterOnce sync.Once
+	shared      *cluster
+	clusterErr  error
+	databaseId  atomic.Int64
+)
+
+// Main runs the tests of a package and stops the cluster afterwards, use it as
+//
+//	func TestMain(m *testing.M) { os.Exit(apptest.Main(m)) }
+func Main(m *testing.M) int {
+	code := m.Run()
+	if shared != nil {
+		shared.stop()
+	}
+	return code
+}
+
+// startServices configures the app's global services once for the whole test binary
+func startServices() error {
+	servicesOnce.Do(func() {
+		spec, servicesErr = app.LoadSpec(specPath())
+		if servicesErr != nil {
+			return
+		}
+
+		if os.Getenv("SESSION_SECRET") == "" {
+			secret := make([]byte, 32)
+			rand.Read(secret)
+			os.Setenv("SESSION_SECRET", hex.EncodeToString(secret))
+		}
+		servicesErr = auth.StartSessions()
+		if servicesErr != nil {
+			return
+		}
+
+		var uploads string
+		uploads, servicesErr = os.MkdirTemp("", "fukubox-uploads-")
+		if servicesErr != nil {
+			return
+		}
+		os.Setenv("STORAGE_BACKEND", "local")
+		os.Setenv("STORAGE_LOCAL_DIR", uploads)
+		// image urls stay relative, so they can be fetched from the test server
+		os.Setenv("STORAGE_PUBLIC_URL", "")
+		servicesErr = storage.StartStorage(context.Background())
+		if servicesErr != nil {
+			return
+		}
+		servicesErr = starter.StartStarter()
+	})
+	return servicesErr
+}
+
+// start brings up the cluster and the app's global services once for the whole test binary
+func start() (*cluster, error) {
+	if err := startServices(); err != nil {
+		return nil, err
+	}
+	clusterOnce.Do(func() {
+		shared, clusterErr = startCluster(context.Background())
+	})
+	return shared, clusterErr
+}
+
+// specPath is OPENAPI_SPEC when it is set, otherwise the spec in docs/api next to the backend
+func specPath() string {
+	if path := os.Getenv("OPENAPI_SPEC"); path != "" {
+		return path
+	}
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "docs", "api", "openapi.yaml")
+}
+
+// Harness is the api running on its own copy of the seeded database, or on an in-memory store
+type Harness struct {
+	// URL is where the api listens, without a trailing slash
+	URL string
+	// DB is a pool on the test's database, for arranging and checking state directly. It is
+	// nil when the api runs on an in-memory store.
+	DB *pgxpool.Pool
+
+	server *httptest.Server
+}
+
+// New starts the api for one test on a fresh copy of the seeded database. The test is
+// skipped when PostgreSQL isn't installed, or fails when CI is set, so a pipeline can't
+// pass without running it. Everything is torn down when the test ends.
+func New(t testing.TB) *Harness {
+	t.Helper()
+
+	c, err := start()
+	if errors.Is(err, errNoPostgres) && os.Getenv("CI") == "" {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("Failed to start PostgreSQL: %v", err)
+	}
+
+	ctx := context.Background()
+	name := fmt.Sprintf("fukubox_test_%d", databaseId.Add(1))
+
+	url, err := c.createDatabase(ctx, name)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := c.dropDatabase(ctx, name); err != nil {
+			t.Logf("Failed to drop test database %v: %v", name, err)
+		}
+	})
+
+	db, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	t.Cleanup(db.Close)
+
+	h := serve(t, repository.NewPostgres(db))
+	h.DB = db
+	return h
+}
+
+// NewInMemory starts the api for one test on repos, usually a memory.Store the test arranged
+// itself, for tests that don't need PostgreSQL. It is torn down when the test ends.
+func NewInMemory(t testing.TB, repos repository.Repositories) *Harness {
+	t.Helper()
+
+	if err := startServices(); err != nil {
+		t.Fatalf("Failed to start the api's services: %v", err)
+	}
+	return serve(t, repos)
+}
+
+// serve runs the app's router on repos until the test ends
+func serve(t testing.TB, repos repository.Repositories) *Harness {
+	// every request and response is checked against the api spec, a mismatch fails the test
+	router := app.NewRouter(handlers.NewServer(repos))
+	server := httptest.NewServer(spec.Middleware(func(r *http.Request, err error) {
+		t.Errorf("%v %v doesn't match the api spec: %v", r.Method, r.URL.Path, err)
+	})(router))
+	t.Cleanup(server.Close)
+
+	return &Harness{URL: server.URL, server: server}
+}
+
+// Response is a finished response with its body already read
+type Response struct {
+	*http.Response
+	Body []byte
+}
+
+// Decode unmarshals the JSON body into v, failing the test when it can't
+func (resp *Response) Decode(t testing.TB, v any) {
+	t.Helper()
+
+	if err := json.Unmarshal(resp.Body, v); err != nil {
+		t.Fatalf("Failed to decode response body %s: %v", resp.Body, err)
+	}
+}
+
+// ExpectStatus fails the test unless the response has status
+func (resp *Response) ExpectStatus(t testing.TB, status int) *Response {
+	t.Helper()
+
+	if resp.StatusCode != status {
+		t.Fatalf("%v %v: expected status %d, got %d: %s",
+			resp.Request.Method, resp.Request.URL.Path, status, resp.StatusCode, resp.Body)
+	}
+	return resp
+}
+
+// Do sends a request as userId, with body encoded as JSON unless it is nil
+func (h *Harness) Do(t testing.TB, userId int, method string, path string, body any) *Response {
+	t.Helper()
+
+	if body == nil {
+		return h.DoRaw(t, userId, method, path, "", nil)
+	}
+
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("Failed to encode request body: %v", err)
+	}
+	return h.DoRaw(t, userId, method, path, "application/json", bytes.NewReader(encoded))
+}
+
+// DoRaw sends body with contentType as userId, for requests that aren't JSON
+func (h *Harness) DoRaw(t testing.TB, userId int, method string, path string, contentType string, body io.Reader) *Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, h.URL+path, body)
+	if err != nil {
+		t.Fatalf("Failed to build request: %v", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return h.DoRequest(t, userId, req)
+}
+
+// DoRequest sends req as userId, for requests that need headers of their own
+func (h *Harness) DoRequest(t testing.TB, userId int, req *http.Request) *Response {
+	t.Helper()
+
+	method, path := req.Method, req.URL.Path
+	if userId != Anonymous {
+		req.Header.Set("Authorization", "Bearer "+h.Session(t, userId))
+	}
+
+	resp, err := h.server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%v %v failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response of %v %v: %v", method, path, err)
+	}
+
+	return &Response{Response: resp, Body: data}
+}
+
+// Session returns a session token for userId, as login would issue it
+func (h *Harness) Session(t testing.TB, userId int) string {
+	t.Helper()
+
+	token, _, err := auth.IssueSession(userId)
+	if err != nil {
+		t.Fatalf("Failed to issue session for user %v: %v", userId, err)
+	}
+	return token
+}
@@ -0,0 +1,182 @@
+package apptest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"com.fukubox/database"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// templateDatabase is migrated and seeded once, every test gets a copy of it
+const templateDatabase = "fukubox_template"
+
+// errNoPostgres means no PostgreSQL binaries were found, so the tests are skipped rather than
+// failed unless CI is set
+var errNoPostgres = errors.New("no PostgreSQL binaries found, set PG_BIN to the directory holding initdb and pg_ctl")
+
+// cluster is a throwaway PostgreSQL server in a temporary directory, only reachable over localhost
+type cluster struct {
+	bin   string
+	dir   string
+	port  int
+	admin *pgxpool.Pool
+}
+
+// findPostgresBin returns the directory holding initdb and pg_ctl: PG_BIN, then PATH, then
+// the versioned directories Debian and Ubuntu install them in
+func findPostgresBin() (string, error) {
+	if bin := os.Getenv("PG_BIN"); bin != "" {
+		return bin, nil
+	}
+
+	if initdb, err := exec.LookPath("initdb"); err == nil {
+		return filepath.Dir(initdb), nil
+	}
+
+	candidates, _ := filepath.Glob("/usr/lib/postgresql/*/bin/initdb")
+	if len(candidates) == 0 {
+		return "", errNoPostgres
+	}
+	// the newest version sorts last
+	sort.Strings(candidates)
+	return filepath.Dir(candidates[len(candidates)-1]), nil
+}
+
+// startCluster initializes and starts a new cluster, then builds the template database in it
+func startCluster(ctx context.Context) (*cluster, error) {
+	bin, err := findPostgresBin()
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "fukubox-postgres-")
+	if err != nil {
+		return nil, err
+	}
+
+	c := &cluster{bin: bin, dir: dir}
+
+	port, err := freePort()
+	if err != nil {
+		c.stop()
+		return nil, err
+	}
+	c.port = port
+
+	data := filepath.Join(dir, "data")
+	err = c.run("initdb", "-D", data, "-U", "postgres", "-A", "trust", "-E", "UTF8", "--no-sync")
+	if err != nil {
+		c.stop()
+		return nil, err
+	}
+
+	// durability is pointless for a cluster that is deleted after the run
+	options := fmt.Sprintf("-p %d -k %v -c listen_addresses=127.0.0.1 -c fsync=off -c synchronous_commit=off -c full_page_writes=off", port, dir)
+	err = c.run("pg_ctl", "-D", data, "-l", filepath.Join(dir, "postgres.log"), "-o", options, "-w", "start")
+	if err != nil {
+		c.stop()
+		return nil, err
+	}
+
+	c.admin, err = pgxpool.New(ctx, c.url("postgres"))
+	if err != nil {
+		c.stop()
+		return nil, err
+	}
+
+	err = c.buildTemplate(ctx)
+	if err != nil {
+		c.stop()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// buildTemplate applies every migration and the seed data to the template database
+func (c *cluster) buildTemplate(ctx context.Context) error {
+	_, err := c.admin.Exec(ctx, "CREATE DATABASE "+templateDatabase)
+	if err != nil {
+		return fmt.Errorf("can't create template database: %w", err)
+	}
+
+	// the migrations run on the database package's own pool, which the tests don't use otherwise
+	os.Setenv("DB_URL", c.url(templateDatabase))
+	err = database.StartDB()
+	if err != nil {
+		return err
+	}
+	defer database.CloseDB()
+
+	_, err = database.MigrateUp(ctx)
+	if err != nil {
+		return fmt.Errorf("can't migrate template database: %w", err)
+	}
+
+	err = database.Seed(ctx)
+	if err != nil {
+		return fmt.Errorf("can't seed template database: %w", err)
+	}
+
+	return nil
+}
+
+// createDatabase copies the template into a new database and returns its url
+func (c *cluster) createDatabase(ctx context.Context, name string) (string, error) {
+	_, err := c.admin.Exec(ctx, fmt.Sprintf("CREATE DATABASE %v TEMPLATE %v", name, templateDatabase))
+	if err != nil {
+		return "", err
+	}
+	return c.url(name), nil
+}
+
+func (c *cluster) dropDatabase(ctx context.Context, name string) error {
+	_, err := c.admin.Exec(ctx, fmt.Sprintf("DROP DATABASE IF EXISTS %v WITH (FORCE)", name))
+	return err
+}
+
+func (c *cluster) url(database string) string {
+	return fmt.Sprintf("postgres://postgres@127.0.0.1:%d/%v?sslmode=disable", c.port, database)
+}
+
+// stop shuts the server down and deletes everything it wrote
+func (c *cluster) stop() {
+	if c.admin != nil {
+		c.admin.Close()
+	}
+	if c.port != 0 {
+		c.run("pg_ctl", "-D", filepath.Join(c.dir, "data"), "-m", "immediate", "-w", "stop")
+	}
+	os.RemoveAll(c.dir)
+}
+
+func (c *cluster) run(name string, args ...string) error {
+	output, err := exec.Command(filepath.Join(c.bin, name), args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v failed: %w\n%s", name, err, output)
+	}
+	return nil
+}
+
+// freePort asks the kernel for a port nothing is listening on
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(port)
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const sessionIssuer = "fukubox"
+const defaultSessionTTL = 24 * time.Hour
+
+var sessionSecret []byte
+var sessionTTL time.Duration
+
+// StartSessions loads the HMAC key used to sign session tokens from SESSION_SECRET
+func StartSessions() error {
+	secret := os.Getenv("SESSION_SECRET")
+	if len(secret) < 32 {
+		return errors.New("SESSION_SECRET must be set to at least 32 characters")
+	}
+	sessionSecret = []byte(secret)
+
+	sessionTTL = defaultSessionTTL
+	if ttl := os.Getenv("SESSION_TTL"); ttl != "" {
+		parsed, err := time.ParseDuration(ttl)
+		if err != nil {
+			return fmt.Errorf("invalid SESSION_TTL %q: %w", ttl, err)
+		}
+		sessionTTL = parsed
+	}
+
+	return nil
+}
+
+// IssueSession signs a session token identifying the given user
+func IssueSession(userId int) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(sessionTTL)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    sessionIssuer,
+		Subject:   strconv.Itoa(userId),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	})
+
+	signed, err := token.SignedString(sessionSecret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return signed, expiresAt, nil
+}
+
+// ParseSession verifies a session token and returns the user id it was issued for
+func ParseSession(tokenString string) (int, error) {
+	var claims jwt.RegisteredClaims
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(t *jwt.Token) (interface{}, error) {
+		return sessionSecret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(sessionIssuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	userId, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return 0, fmt.Errorf("invalid session subject %q", claims.Subject)
+	}
+
+	return userId, nil
+}
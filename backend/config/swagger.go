@@ -1,14 +1,64 @@
 package config
 
-// import (
-// 	"net/http"
-
-// 	"github.com/go-chi/chi"
-// )
-
-// func AddSwaggerRoutes(r *chi.Mux) {
-// 	// Setup Swagger
-// 	// This assumes you have a Swagger handler or middleware compatible with Chi.
-// 	// You might need to adapt this part based on the actual handler you have.
-// 	r.Mount("/swagger", http.StripPrefix("/swagger", YourSwaggerHandler()))
-// }
+import (
+	"bytes"
+	"embed"
+	"io/fs"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"com.fukubox/problem"
+	"github.com/ghodss/yaml"
+	"github.com/go-chi/chi"
+)
+
+// openapi.yaml is a copy of docs/api/openapi.yaml, which lies outside the module and can't be embedded itself
+//go:generate cp ../../docs/api/openapi.yaml openapi.yaml
+
+//go:embed openapi.yaml
+var specYAML []byte
+
+// swaggerUI holds the Swagger UI page and the assets it loads, see swagger-ui/README.md
+//
+//go:embed swagger-ui/index.html swagger-ui/swagger-ui-bundle.js swagger-ui/swagger-ui.css
+var swaggerUI embed.FS
+
+var specJSON = sync.OnceValues(func() ([]byte, error) {
+	return yaml.YAMLToJSON(specYAML)
+})
+
+// the embedded spec changes with the binary, so it was last modified when it started
+var startedAt = time.Now()
+
+// AddSwaggerRoutes serves the api spec at /openapi.yaml and /openapi.json, and Swagger UI
+// under /docs unless API_DOCS=false. The UI is built into the binary, the page loads
+// nothing from elsewhere.
+func AddSwaggerRoutes(r *chi.Mux) {
+	r.Get("/openapi.yaml", serveFile("application/yaml", specYAML))
+	r.Get("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		spec, err := specJSON()
+		if err != nil {
+			problem.Write(w, r, problem.Internal())
+			return
+		}
+		serveFile("application/json", spec)(w, r)
+	})
+
+	if os.Getenv("API_DOCS") == "false" {
+		return
+	}
+
+	files, _ := fs.Sub(swaggerUI, "swagger-ui")
+	r.Get("/docs", http.RedirectHandler("/docs/", http.StatusMovedPermanently).ServeHTTP)
+	r.Get("/docs/*", http.StripPrefix("/docs", http.FileServer(http.FS(files))).ServeHTTP)
+}
+
+// serveFile answers with content, supporting conditional and range requests
+func serveFile(contentType string, content []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		http.ServeContent(w, r, "", startedAt, bytes.NewReader(content))
+	}
+}
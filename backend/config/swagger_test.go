@@ -0,0 +1,65 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chi/chi"
+)
+
+func TestEmbeddedSpecIsCurrent(t *testing.T) {
+	source, err := os.ReadFile(filepath.Join("..", "..", "docs", "api", "openapi.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(source, specYAML) {
+		t.Fatal("config/openapi.yaml is out of date with docs/api/openapi.yaml, run go generate ./config")
+	}
+}
+
+func get(t *testing.T, path string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	r := chi.NewRouter()
+	AddSwaggerRoutes(r)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestSpecRoutes(t *testing.T) {
+	if w := get(t, "/openapi.yaml"); w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), specYAML) {
+		t.Fatalf("GET /openapi.yaml: %d", w.Code)
+	}
+
+	w := get(t, "/openapi.json")
+	var spec struct {
+		OpenAPI string         `json:"openapi"`
+		Paths   map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json: %d %v", w.Code, err)
+	}
+	if spec.OpenAPI == "" || spec.Paths["/tags/{id}"] == nil {
+		t.Fatalf("unexpected spec %+v", spec)
+	}
+}
+
+func TestDocs(t *testing.T) {
+	for _, path := range []string{"/docs/", "/docs/swagger-ui.css", "/docs/swagger-ui-bundle.js"} {
+		if w := get(t, path); w.Code != http.StatusOK || w.Body.Len() == 0 {
+			t.Fatalf("GET %v: %d", path, w.Code)
+		}
+	}
+
+	t.Setenv("API_DOCS", "false")
+	if w := get(t, "/docs/"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected no docs with API_DOCS=false, got %d", w.Code)
+	}
+}
@@ -0,0 +1,329 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// migrationFiles holds the schema history as NNNN_name.up.sql / NNNN_name.down.sql pairs
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+//go:embed seed.sql
+var seedSQL string
+
+// migrationLockId keeps two instances from migrating the same database at once
+const migrationLockId = 7305176302
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// ErrDatabaseAhead is returned when the database has migrations this build doesn't know about
+var ErrDatabaseAhead = errors.New("database schema is newer than this build")
+
+// ErrPendingMigrations is returned by CheckMigrations when the database is behind this build
+var ErrPendingMigrations = errors.New("database has pending migrations")
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// LoadMigrations returns the embedded migrations ordered by version
+func LoadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %v", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		contents, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %v and %v", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(contents)
+		} else {
+			migration.Down = string(contents)
+		}
+	}
+
+	migrations := []Migration{}
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%v needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// MigrateUp applies every pending migration and returns how many ran
+func MigrateUp(ctx context.Context) (int, error) {
+	count := 0
+
+	err := withMigrationLock(ctx, func(conn *pgxpool.Conn, migrations []Migration, applied map[int]time.Time) error {
+		for _, migration := range migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			log.Printf("Applying migration %d_%v", migration.Version, migration.Name)
+			err := runMigrationTx(ctx, conn, migration.Up,
+				`INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, now())`,
+				migration.Version, migration.Name)
+			if err != nil {
+				return fmt.Errorf("migration %d_%v failed: %w", migration.Version, migration.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+
+	return count, err
+}
+
+// MigrateDown reverts the latest steps migrations and returns how many were reverted
+func MigrateDown(ctx context.Context, steps int) (int, error) {
+	reverted := 0
+
+	err := withMigrationLock(ctx, func(conn *pgxpool.Conn, migrations []Migration, applied map[int]time.Time) error {
+		for i := len(migrations) - 1; i >= 0 && reverted < steps; i-- {
+			migration := migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+
+			log.Printf("Reverting migration %d_%v", migration.Version, migration.Name)
+			err := runMigrationTx(ctx, conn, migration.Down,
+				`DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+			if err != nil {
+				return fmt.Errorf("reverting migration %d_%v failed: %w", migration.Version, migration.Name, err)
+			}
+			reverted++
+		}
+		return nil
+	})
+
+	return reverted, err
+}
+
+// GetMigrationStatus lists every known migration with when it was applied, if it was
+func GetMigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	conn := AcquireConnection(ctx)
+	if conn == nil {
+		return nil, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	migrations, applied, err := loadMigrationState(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []MigrationStatus{}
+	for _, migration := range migrations {
+		status := MigrationStatus{Migration: migration}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// CheckMigrations returns an error unless the database is at exactly this build's schema version
+func CheckMigrations(ctx context.Context) error {
+	statuses, err := GetMigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			return fmt.Errorf("%w, starting with %d_%v", ErrPendingMigrations, status.Version, status.Name)
+		}
+	}
+
+	return nil
+}
+
+// Seed loads the demo data into an empty database
+func Seed(ctx context.Context) error {
+	conn := AcquireConnection(ctx)
+	if conn == nil {
+		return errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	var hasUsers bool
+	err := conn.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users)`).Scan(&hasUsers)
+	if err != nil {
+		return err
+	}
+	if hasUsers {
+		return errors.New("database already has users, refusing to seed")
+	}
+
+	return runMigrationTx(ctx, conn, seedSQL, "")
+}
+
+// withMigrationLock runs fn holding the migration lock, after checking the database isn't ahead of this build
+func withMigrationLock(ctx context.Context, fn func(conn *pgxpool.Conn, migrations []Migration, applied map[int]time.Time) error) error {
+	conn := AcquireConnection(ctx)
+	if conn == nil {
+		return errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockId); err != nil {
+		return err
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockId)
+
+	_, err := conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+
+	migrations, applied, err := loadMigrationState(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn, migrations, applied)
+}
+
+// loadMigrationState returns the embedded migrations and the applied versions,
+// refusing with ErrDatabaseAhead when a version was applied by a newer build
+func loadMigrationState(ctx context.Context, conn *pgxpool.Conn) ([]Migration, map[int]time.Time, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	known := map[int]bool{}
+	for _, migration := range migrations {
+		known[migration.Version] = true
+	}
+	for version := range applied {
+		if !known[version] {
+			return nil, nil, fmt.Errorf("%w: migration %d is applied but unknown", ErrDatabaseAhead, version)
+		}
+	}
+
+	return migrations, applied, nil
+}
+
+// appliedMigrations returns the applied versions, treating a missing schema_migrations table as none
+func appliedMigrations(ctx context.Context, conn *pgxpool.Conn) (map[int]time.Time, error) {
+	var exists bool
+	err := conn.QueryRow(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := map[int]time.Time{}
+	if !exists {
+		return applied, nil
+	}
+
+	query := `SELECT version, applied_at FROM schema_migrations`
+
+	rows, err := conn.Query(ctx, query)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// runMigrationTx runs a migration script and its bookkeeping statement in one transaction
+func runMigrationTx(ctx context.Context, conn *pgxpool.Conn, script string, bookkeeping string, args ...any) (err error) {
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		log.Printf("Begin Transation Failure: %v", err)
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			err = tx.Commit(ctx)
+		}
+	}()
+
+	// without arguments pgx uses the simple protocol, which allows several statements
+	if _, err = tx.Exec(ctx, script); err != nil {
+		return err
+	}
+
+	if bookkeeping != "" {
+		if _, err = tx.Exec(ctx, bookkeeping, args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
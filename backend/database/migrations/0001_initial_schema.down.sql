@@ -0,0 +1,10 @@
+DROP FUNCTION IF EXISTS get_clothes_by_user_and_id(INT, INT);
+DROP FUNCTION IF EXISTS get_clothes_by_user(INT);
+
+DROP TABLE IF EXISTS clothing_item_tags;
+DROP TABLE IF EXISTS sandbox_positions;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS sandbox;
+DROP TABLE IF EXISTS clothing_items;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS users;
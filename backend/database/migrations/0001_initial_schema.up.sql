@@ -1,5 +1,7 @@
--- Users table
-CREATE TABLE users (
+-- The schema as it was first shipped in psql_dump.sql. IF NOT EXISTS lets databases
+-- that were created from that script adopt the migrations without changes.
+
+CREATE TABLE IF NOT EXISTS users (
   id SERIAL PRIMARY KEY,
   username VARCHAR(255),
   email VARCHAR(255) UNIQUE,
@@ -8,8 +10,7 @@ CREATE TABLE users (
   updated_at TIMESTAMP
 );
 
--- Categories table
-CREATE TABLE categories (
+CREATE TABLE IF NOT EXISTS categories (
   id SERIAL PRIMARY KEY,
   user_id INT,
   name VARCHAR(255),
@@ -18,8 +19,7 @@ CREATE TABLE categories (
   FOREIGN KEY (user_id) REFERENCES users(id)
 );
 
--- Clothing Items table
-CREATE TABLE clothing_items (
+CREATE TABLE IF NOT EXISTS clothing_items (
   id SERIAL PRIMARY KEY,
   user_id INT,
   category_id INT,
@@ -30,8 +30,7 @@ CREATE TABLE clothing_items (
   FOREIGN KEY (category_id) REFERENCES categories(id)
 );
 
--- Sandbox table
-CREATE TABLE sandbox (
+CREATE TABLE IF NOT EXISTS sandbox (
   id SERIAL PRIMARY KEY,
   user_id INT,
   created_at TIMESTAMP,
@@ -39,16 +38,14 @@ CREATE TABLE sandbox (
   FOREIGN KEY (user_id) REFERENCES users(id)
 );
 
--- Tags table
-CREATE TABLE tags (
+CREATE TABLE IF NOT EXISTS tags (
   id SERIAL PRIMARY KEY,
   name VARCHAR(255),
   created_at TIMESTAMP,
   updated_at TIMESTAMP
 );
 
--- Sandbox Positions table
-CREATE TABLE sandbox_positions (
+CREATE TABLE IF NOT EXISTS sandbox_positions (
   id SERIAL PRIMARY KEY,
   sandbox_id INT,
   clothing_item_id INT,
@@ -60,8 +57,7 @@ CREATE TABLE sandbox_positions (
   FOREIGN KEY (clothing_item_id) REFERENCES clothing_items(id)
 );
 
--- Clothing Item Tags Junction Table
-CREATE TABLE clothing_item_tags (
+CREATE TABLE IF NOT EXISTS clothing_item_tags (
   clothing_item_id INT,
   tag_id INT,
   PRIMARY KEY (clothing_item_id, tag_id),
@@ -69,49 +65,11 @@ CREATE TABLE clothing_item_tags (
   FOREIGN KEY (tag_id) REFERENCES tags(id)
 );
 
--- Insert users
-INSERT INTO users (username, email, google_id, created_at, updated_at) VALUES
-('user1', 'user1@example.com', 'google1', '2024-07-01 12:00:00', '2024-07-01 12:00:00'),
-('user2', 'user2@example.com', 'google2', '2024-07-01 12:00:00', '2024-07-01 12:00:00');
-
--- Insert categories
-INSERT INTO categories (user_id, name, created_at, updated_at) VALUES
-(1, 'Tops', '2024-07-01 12:00:00', '2024-07-01 12:00:00'),
-(1, 'Bottoms', '2024-07-01 12:00:00', '2024-07-01 12:00:00'),
-(2, 'Accessories', '2024-07-01 12:00:00', '2024-07-01 12:00:00');
-
--- Insert clothing items
-INSERT INTO clothing_items (user_id, category_id, image_url, created_at, updated_at) VALUES
-(1, 1, 'http://example.com/clothing1.jpg', '2024-07-01 12:00:00', '2024-07-01 12:00:00'),
-(1, 2, 'http://example.com/clothing2.jpg', '2024-07-01 12:00:00', '2024-07-01 12:00:00'),
-(2, 3, 'http://example.com/clothing3.jpg', '2024-07-01 12:00:00', '2024-07-01 12:00:00');
-
--- Insert sandbox
-INSERT INTO sandbox (user_id, created_at, updated_at) VALUES
-(1, '2024-07-01 12:00:00', '2024-07-01 12:00:00'),
-(2, '2024-07-01 12:00:00', '2024-07-01 12:00:00');
-
--- Insert tags
-INSERT INTO tags (name, created_at, updated_at) VALUES
-('Summer', '2024-07-01 12:00:00', '2024-07-01 12:00:00'),
-('Winter', '2024-07-01 12:00:00', '2024-07-01 12:00:00');
+-- dropped first as databases created from a newer psql_dump.sql return more columns
+DROP FUNCTION IF EXISTS get_clothes_by_user(INT);
+DROP FUNCTION IF EXISTS get_clothes_by_user_and_id(INT, INT);
 
--- Insert sandbox positions
-INSERT INTO sandbox_positions (sandbox_id, clothing_item_id, position_x, position_y, created_at, updated_at) VALUES
-(1, 1, 50.0, 50.0, '2024-07-01 12:00:00', '2024-07-01 12:00:00'),
-(1, 2, 100.0, 150.0, '2024-07-01 12:00:00', '2024-07-01 12:00:00'),
-(2, 3, 200.0, 250.0, '2024-07-01 12:00:00', '2024-07-01 12:00:00');
-
--- Insert clothing item tags
-INSERT INTO clothing_item_tags (clothing_item_id, tag_id) VALUES
-(1, 1),
-(2, 2),
-(3, 1);
-
-
--- Functions
-
-CREATE OR REPLACE FUNCTION get_clothes_by_user(_user_id INT)
+CREATE FUNCTION get_clothes_by_user(_user_id INT)
 	returns TABLE (
     id INT, 
     user_id INT, 
@@ -139,7 +97,7 @@ as $$
   GROUP BY c.id
 $$;
 
-CREATE OR REPLACE FUNCTION get_clothes_by_user_and_id(_user_id INT, _id INT)
+CREATE FUNCTION get_clothes_by_user_and_id(_user_id INT, _id INT)
 	returns TABLE (
     id INT, 
     user_id INT, 
@@ -166,4 +124,4 @@ as $$
   WHERE c.user_id = _user_id
     AND c.id = _id
   GROUP BY c.id
-$$;
\ No newline at end of file
+$$;
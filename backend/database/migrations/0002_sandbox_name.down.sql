@@ -0,0 +1 @@
+ALTER TABLE sandbox DROP COLUMN IF EXISTS name;
@@ -0,0 +1 @@
+ALTER TABLE sandbox ADD COLUMN IF NOT EXISTS name VARCHAR(255);
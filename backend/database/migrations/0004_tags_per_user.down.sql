@@ -0,0 +1,3 @@
+-- tags stay split per user, they just stop recording who owns them
+DROP INDEX IF EXISTS tags_user_id_idx;
+ALTER TABLE tags DROP COLUMN IF EXISTS user_id;
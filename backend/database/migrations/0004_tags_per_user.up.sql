@@ -0,0 +1,51 @@
+-- Scopes tags to their owner. Every shared tag is split so each user that tagged
+-- an item with it gets their own copy, and their items are repointed at that copy.
+-- Tags nobody has used yet are copied to every user so none of them lose a tag
+-- they created.
+
+ALTER TABLE tags ADD COLUMN IF NOT EXISTS user_id INT REFERENCES users(id);
+
+DO $$
+DECLARE
+  owner RECORD;
+  copy_id INT;
+BEGIN
+  FOR owner IN
+    SELECT t.id AS tag_id, u.user_id,
+           row_number() OVER (PARTITION BY t.id ORDER BY u.user_id) AS n
+    FROM tags t
+    CROSS JOIN LATERAL (
+      SELECT DISTINCT c.user_id
+      FROM clothing_item_tags cit
+      JOIN clothing_items c ON c.id = cit.clothing_item_id
+      WHERE cit.tag_id = t.id AND c.user_id IS NOT NULL
+      UNION
+      SELECT users.id
+      FROM users
+      WHERE NOT EXISTS (SELECT 1 FROM clothing_item_tags WHERE tag_id = t.id)
+    ) u
+    WHERE t.user_id IS NULL
+  LOOP
+    -- the first owner keeps the original row, everyone else gets a copy
+    IF owner.n = 1 THEN
+      UPDATE tags SET user_id = owner.user_id WHERE id = owner.tag_id;
+    ELSE
+      INSERT INTO tags (user_id, name, created_at, updated_at)
+      SELECT owner.user_id, name, created_at, now() FROM tags WHERE id = owner.tag_id
+      RETURNING id INTO copy_id;
+
+      UPDATE clothing_item_tags cit SET tag_id = copy_id
+      FROM clothing_items c
+      WHERE cit.clothing_item_id = c.id
+        AND cit.tag_id = owner.tag_id
+        AND c.user_id = owner.user_id;
+    END IF;
+  END LOOP;
+END $$;
+
+-- only left when there are no users at all, or on items without an owner
+DELETE FROM clothing_item_tags WHERE tag_id IN (SELECT id FROM tags WHERE user_id IS NULL);
+DELETE FROM tags WHERE user_id IS NULL;
+
+ALTER TABLE tags ALTER COLUMN user_id SET NOT NULL;
+CREATE INDEX IF NOT EXISTS tags_user_id_idx ON tags (user_id);
@@ -0,0 +1,74 @@
+CREATE OR REPLACE FUNCTION get_clothes_by_user_and_id(_user_id INT, _id INT)
+	returns TABLE (
+    id INT, 
+    user_id INT, 
+    category_id INT, 
+    image_url VARCHAR(255), 
+    cutout_url VARCHAR(255), 
+    image_variants JSONB, 
+    created_at TIMESTAMP, 
+    updated_at TIMESTAMP, 
+    tags text)
+	language sql
+	security definer
+as $$
+  SELECT c.id,
+    c.user_id,
+    c.category_id,
+    c.image_url,
+    c.cutout_url,
+    c.image_variants,
+    c.created_at,
+    c.updated_at,
+     array_to_json(array_agg(tags)) as tags_list
+  FROM clothing_items c
+  LEFT JOIN clothing_item_tags cit
+    ON cit.clothing_item_id = c.id
+  LEFT JOIN tags
+    ON tags.id = cit.tag_id
+  WHERE c.user_id = _user_id
+    AND c.id = _id
+  GROUP BY c.id
+$$;
+
+CREATE FUNCTION get_clothes_by_user(_user_id INT)
+	returns TABLE (
+    id INT, 
+    user_id INT, 
+    category_id INT, 
+    image_url VARCHAR(255), 
+    cutout_url VARCHAR(255), 
+    image_variants JSONB, 
+    created_at TIMESTAMP, 
+    updated_at TIMESTAMP, 
+    tags text)
+	language sql
+	security definer
+as $$
+  SELECT c.id,
+    c.user_id,
+    c.category_id,
+    c.image_url,
+    c.cutout_url,
+    c.image_variants,
+    c.created_at,
+    c.updated_at,
+     array_to_json(array_agg(tags)) as tags_list
+  FROM clothing_items c
+  LEFT JOIN clothing_item_tags cit
+    ON cit.clothing_item_id = c.id
+  LEFT JOIN tags
+    ON tags.id = cit.tag_id
+  WHERE c.user_id = _user_id
+  GROUP BY c.id
+$$;
+
+DROP INDEX IF EXISTS clothing_item_tags_tag_id_idx;
+DROP INDEX IF EXISTS clothing_items_user_updated_idx;
+DROP INDEX IF EXISTS clothing_items_user_created_idx;
+
+ALTER TABLE clothing_items
+  ALTER COLUMN created_at DROP NOT NULL,
+  ALTER COLUMN created_at DROP DEFAULT,
+  ALTER COLUMN updated_at DROP NOT NULL,
+  ALTER COLUMN updated_at DROP DEFAULT;
@@ -0,0 +1,53 @@
+-- Keyset pagination over clothes needs a non-null sort key and an index per sort order
+
+UPDATE clothing_items
+SET created_at = COALESCE(created_at, updated_at, now()),
+    updated_at = COALESCE(updated_at, created_at, now())
+WHERE created_at IS NULL OR updated_at IS NULL;
+
+ALTER TABLE clothing_items
+  ALTER COLUMN created_at SET DEFAULT now(),
+  ALTER COLUMN created_at SET NOT NULL,
+  ALTER COLUMN updated_at SET DEFAULT now(),
+  ALTER COLUMN updated_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS clothing_items_user_created_idx ON clothing_items (user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS clothing_items_user_updated_idx ON clothing_items (user_id, updated_at, id);
+CREATE INDEX IF NOT EXISTS clothing_item_tags_tag_id_idx ON clothing_item_tags (tag_id);
+
+-- listing now queries the tables directly so filters reach the indexes
+DROP FUNCTION IF EXISTS get_clothes_by_user(INT);
+
+-- items without tags returned [null] rather than an empty list
+CREATE OR REPLACE FUNCTION get_clothes_by_user_and_id(_user_id INT, _id INT)
+	returns TABLE (
+    id INT, 
+    user_id INT, 
+    category_id INT, 
+    image_url VARCHAR(255), 
+    cutout_url VARCHAR(255), 
+    image_variants JSONB, 
+    created_at TIMESTAMP, 
+    updated_at TIMESTAMP, 
+    tags text)
+	language sql
+	security definer
+as $$
+  SELECT c.id,
+    c.user_id,
+    c.category_id,
+    c.image_url,
+    c.cutout_url,
+    c.image_variants,
+    c.created_at,
+    c.updated_at,
+    COALESCE(json_agg(tags ORDER BY tags.id) FILTER (WHERE tags.id IS NOT NULL), '[]')::text as tags_list
+  FROM clothing_items c
+  LEFT JOIN clothing_item_tags cit
+    ON cit.clothing_item_id = c.id
+  LEFT JOIN tags
+    ON tags.id = cit.tag_id
+  WHERE c.user_id = _user_id
+    AND c.id = _id
+  GROUP BY c.id
+$$;
@@ -0,0 +1,44 @@
+DROP FUNCTION IF EXISTS get_clothes_by_user_and_id(INT, INT);
+
+CREATE FUNCTION get_clothes_by_user_and_id(_user_id INT, _id INT)
+	returns TABLE (
+    id INT, 
+    user_id INT, 
+    category_id INT, 
+    image_url VARCHAR(255), 
+    cutout_url VARCHAR(255), 
+    image_variants JSONB, 
+    created_at TIMESTAMP, 
+    updated_at TIMESTAMP, 
+    tags text)
+	language sql
+	security definer
+as $$
+  SELECT c.id,
+    c.user_id,
+    c.category_id,
+    c.image_url,
+    c.cutout_url,
+    c.image_variants,
+    c.created_at,
+    c.updated_at,
+    COALESCE(json_agg(tags ORDER BY tags.id) FILTER (WHERE tags.id IS NOT NULL), '[]')::text as tags_list
+  FROM clothing_items c
+  LEFT JOIN clothing_item_tags cit
+    ON cit.clothing_item_id = c.id
+  LEFT JOIN tags
+    ON tags.id = cit.tag_id
+  WHERE c.user_id = _user_id
+    AND c.id = _id
+  GROUP BY c.id
+$$;
+
+DROP INDEX IF EXISTS tags_search_idx;
+DROP INDEX IF EXISTS categories_search_idx;
+DROP INDEX IF EXISTS clothing_items_search_idx;
+
+ALTER TABLE tags DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE categories DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE clothing_items DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE clothing_items DROP COLUMN IF EXISTS notes;
+ALTER TABLE clothing_items DROP COLUMN IF EXISTS name;
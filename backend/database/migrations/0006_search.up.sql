@@ -0,0 +1,63 @@
+-- Names and notes for clothing items, and full-text search over items, categories and tags
+
+ALTER TABLE clothing_items ADD COLUMN IF NOT EXISTS name VARCHAR(255);
+ALTER TABLE clothing_items ADD COLUMN IF NOT EXISTS notes TEXT;
+
+ALTER TABLE clothing_items ADD COLUMN IF NOT EXISTS search_vector tsvector
+  GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', COALESCE(name, '')), 'A') ||
+    setweight(to_tsvector('english', COALESCE(notes, '')), 'B')
+  ) STORED;
+
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS search_vector tsvector
+  GENERATED ALWAYS AS (to_tsvector('english', COALESCE(name, ''))) STORED;
+
+ALTER TABLE tags ADD COLUMN IF NOT EXISTS search_vector tsvector
+  GENERATED ALWAYS AS (to_tsvector('english', COALESCE(name, ''))) STORED;
+
+CREATE INDEX IF NOT EXISTS clothing_items_search_idx ON clothing_items USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS categories_search_idx ON categories USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS tags_search_idx ON tags USING GIN (search_vector);
+
+-- the result columns change, which CREATE OR REPLACE can't do
+DROP FUNCTION IF EXISTS get_clothes_by_user_and_id(INT, INT);
+
+CREATE FUNCTION get_clothes_by_user_and_id(_user_id INT, _id INT)
+	returns TABLE (
+    id INT, 
+    user_id INT, 
+    category_id INT, 
+    name VARCHAR(255), 
+    notes TEXT, 
+    image_url VARCHAR(255), 
+    cutout_url VARCHAR(255), 
+    image_variants JSONB, 
+    created_at TIMESTAMP, 
+    updated_at TIMESTAMP, 
+    tags text)
+	language sql
+	security definer
+as $$
+  SELECT c.id,
+    c.user_id,
+    c.category_id,
+    c.name,
+    c.notes,
+    c.image_url,
+    c.cutout_url,
+    c.image_variants,
+    c.created_at,
+    c.updated_at,
+    COALESCE(
+      json_agg(json_build_object('id', tags.id, 'name', tags.name) ORDER BY tags.id) FILTER (WHERE tags.id IS NOT NULL),
+      '[]'
+    )::text as tags_list
+  FROM clothing_items c
+  LEFT JOIN clothing_item_tags cit
+    ON cit.clothing_item_id = c.id
+  LEFT JOIN tags
+    ON tags.id = cit.tag_id
+  WHERE c.user_id = _user_id
+    AND c.id = _id
+  GROUP BY c.id
+$$;
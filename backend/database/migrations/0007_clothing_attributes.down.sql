@@ -0,0 +1,52 @@
+DROP FUNCTION IF EXISTS get_clothes_by_user_and_id(INT, INT);
+
+CREATE FUNCTION get_clothes_by_user_and_id(_user_id INT, _id INT)
+	returns TABLE (
+    id INT, 
+    user_id INT, 
+    category_id INT, 
+    name VARCHAR(255), 
+    notes TEXT, 
+    image_url VARCHAR(255), 
+    cutout_url VARCHAR(255), 
+    image_variants JSONB, 
+    created_at TIMESTAMP, 
+    updated_at TIMESTAMP, 
+    tags text)
+	language sql
+	security definer
+as $$
+  SELECT c.id,
+    c.user_id,
+    c.category_id,
+    c.name,
+    c.notes,
+    c.image_url,
+    c.cutout_url,
+    c.image_variants,
+    c.created_at,
+    c.updated_at,
+    COALESCE(
+      json_agg(json_build_object('id', tags.id, 'name', tags.name) ORDER BY tags.id) FILTER (WHERE tags.id IS NOT NULL),
+      '[]'
+    )::text as tags_list
+  FROM clothing_items c
+  LEFT JOIN clothing_item_tags cit
+    ON cit.clothing_item_id = c.id
+  LEFT JOIN tags
+    ON tags.id = cit.tag_id
+  WHERE c.user_id = _user_id
+    AND c.id = _id
+  GROUP BY c.id
+$$;
+
+ALTER TABLE clothing_items
+  DROP COLUMN IF EXISTS purchase_date,
+  DROP COLUMN IF EXISTS currency,
+  DROP COLUMN IF EXISTS purchase_price,
+  DROP COLUMN IF EXISTS season,
+  DROP COLUMN IF EXISTS material,
+  DROP COLUMN IF EXISTS secondary_color,
+  DROP COLUMN IF EXISTS primary_color,
+  DROP COLUMN IF EXISTS size,
+  DROP COLUMN IF EXISTS brand;
@@ -0,0 +1,73 @@
+-- Descriptive attributes of clothing items; allowed values are validated by the api
+
+ALTER TABLE clothing_items
+  ADD COLUMN IF NOT EXISTS brand VARCHAR(255),
+  ADD COLUMN IF NOT EXISTS size VARCHAR(32),
+  ADD COLUMN IF NOT EXISTS primary_color VARCHAR(32),
+  ADD COLUMN IF NOT EXISTS secondary_color VARCHAR(32),
+  ADD COLUMN IF NOT EXISTS material VARCHAR(64),
+  ADD COLUMN IF NOT EXISTS season VARCHAR(16),
+  ADD COLUMN IF NOT EXISTS purchase_price NUMERIC(12, 2),
+  ADD COLUMN IF NOT EXISTS currency CHAR(3),
+  ADD COLUMN IF NOT EXISTS purchase_date DATE;
+
+-- the result columns change, which CREATE OR REPLACE can't do
+DROP FUNCTION IF EXISTS get_clothes_by_user_and_id(INT, INT);
+
+CREATE FUNCTION get_clothes_by_user_and_id(_user_id INT, _id INT)
+	returns TABLE (
+    id INT, 
+    user_id INT, 
+    category_id INT, 
+    name VARCHAR(255), 
+    brand VARCHAR(255), 
+    size VARCHAR(32), 
+    primary_color VARCHAR(32), 
+    secondary_color VARCHAR(32), 
+    material VARCHAR(64), 
+    season VARCHAR(16), 
+    purchase_price NUMERIC(12, 2), 
+    currency CHAR(3), 
+    purchase_date DATE, 
+    notes TEXT, 
+    image_url VARCHAR(255), 
+    cutout_url VARCHAR(255), 
+    image_variants JSONB, 
+    created_at TIMESTAMP, 
+    updated_at TIMESTAMP, 
+    tags text)
+	language sql
+	security definer
+as $$
+  SELECT c.id,
+    c.user_id,
+    c.category_id,
+    c.name,
+    c.brand,
+    c.size,
+    c.primary_color,
+    c.secondary_color,
+    c.material,
+    c.season,
+    c.purchase_price,
+    c.currency,
+    c.purchase_date,
+    c.notes,
+    c.image_url,
+    c.cutout_url,
+    c.image_variants,
+    c.created_at,
+    c.updated_at,
+    COALESCE(
+      json_agg(json_build_object('id', tags.id, 'name', tags.name) ORDER BY tags.id) FILTER (WHERE tags.id IS NOT NULL),
+      '[]'
+    )::text as tags_list
+  FROM clothing_items c
+  LEFT JOIN clothing_item_tags cit
+    ON cit.clothing_item_id = c.id
+  LEFT JOIN tags
+    ON tags.id = cit.tag_id
+  WHERE c.user_id = _user_id
+    AND c.id = _id
+  GROUP BY c.id
+$$;
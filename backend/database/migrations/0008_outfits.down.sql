@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS outfit_tags;
+DROP TABLE IF EXISTS outfit_items;
+DROP TABLE IF EXISTS outfits;
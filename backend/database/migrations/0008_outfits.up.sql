@@ -0,0 +1,33 @@
+-- Outfits are named sets of clothing items saved apart from any sandbox. An item can
+-- have a layer, lower layers are worn underneath higher ones.
+
+CREATE TABLE IF NOT EXISTS outfits (
+  id SERIAL PRIMARY KEY,
+  user_id INT NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  notes TEXT,
+  created_at TIMESTAMP,
+  updated_at TIMESTAMP,
+  FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS outfit_items (
+  outfit_id INT,
+  clothing_item_id INT,
+  layer INT,
+  PRIMARY KEY (outfit_id, clothing_item_id),
+  FOREIGN KEY (outfit_id) REFERENCES outfits(id),
+  FOREIGN KEY (clothing_item_id) REFERENCES clothing_items(id)
+);
+
+CREATE TABLE IF NOT EXISTS outfit_tags (
+  outfit_id INT,
+  tag_id INT,
+  PRIMARY KEY (outfit_id, tag_id),
+  FOREIGN KEY (outfit_id) REFERENCES outfits(id),
+  FOREIGN KEY (tag_id) REFERENCES tags(id)
+);
+
+CREATE INDEX IF NOT EXISTS outfits_user_id_idx ON outfits (user_id);
+-- deleting a clothing item looks up the outfits it is part of
+CREATE INDEX IF NOT EXISTS outfit_items_clothing_item_id_idx ON outfit_items (clothing_item_id);
@@ -0,0 +1,70 @@
+CREATE OR REPLACE FUNCTION get_clothes_by_user_and_id(_user_id INT, _id INT)
+	returns TABLE (
+    id INT, 
+    user_id INT, 
+    category_id INT, 
+    name VARCHAR(255), 
+    brand VARCHAR(255), 
+    size VARCHAR(32), 
+    primary_color VARCHAR(32), 
+    secondary_color VARCHAR(32), 
+    material VARCHAR(64), 
+    season VARCHAR(16), 
+    purchase_price NUMERIC(12, 2), 
+    currency CHAR(3), 
+    purchase_date DATE, 
+    notes TEXT, 
+    image_url VARCHAR(255), 
+    cutout_url VARCHAR(255), 
+    image_variants JSONB, 
+    created_at TIMESTAMP, 
+    updated_at TIMESTAMP, 
+    tags text, 
+    times_worn INT, 
+    last_worn_at DATE)
+	language sql
+	security definer
+as $$
+  SELECT c.id,
+    c.user_id,
+    c.category_id,
+    c.name,
+    c.brand,
+    c.size,
+    c.primary_color,
+    c.secondary_color,
+    c.material,
+    c.season,
+    c.purchase_price,
+    c.currency,
+    c.purchase_date,
+    c.notes,
+    c.image_url,
+    c.cutout_url,
+    c.image_variants,
+    c.created_at,
+    c.updated_at,
+    COALESCE(
+      json_agg(json_build_object('id', tags.id, 'name', tags.name) ORDER BY tags.id) FILTER (WHERE tags.id IS NOT NULL),
+      '[]'
+    )::text as tags_list,
+    (SELECT count(*) FROM wear_event_items wi WHERE wi.clothing_item_id = c.id)::int as times_worn,
+    (SELECT max(w.worn_on)
+     FROM wear_event_items wi
+     JOIN wear_events w ON w.id = wi.wear_event_id
+     WHERE wi.clothing_item_id = c.id) as last_worn_at
+  FROM clothing_items c
+  LEFT JOIN clothing_item_tags cit
+    ON cit.clothing_item_id = c.id
+  LEFT JOIN tags
+    ON tags.id = cit.tag_id
+  WHERE c.user_id = _user_id
+    AND c.id = _id
+  GROUP BY c.id
+$$;
+
+DROP INDEX IF EXISTS categories_deleted_at_idx;
+DROP INDEX IF EXISTS clothing_items_deleted_at_idx;
+
+ALTER TABLE categories DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE clothing_items DROP COLUMN IF EXISTS deleted_at;
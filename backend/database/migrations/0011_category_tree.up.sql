@@ -0,0 +1,6 @@
+-- Categories nest: a category with a parent_id is a subcategory of it. The api keeps the tree
+-- free of cycles and moves the subcategories of a trashed category up to its parent.
+
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS parent_id INT REFERENCES categories(id);
+
+CREATE INDEX IF NOT EXISTS categories_parent_id_idx ON categories (parent_id);
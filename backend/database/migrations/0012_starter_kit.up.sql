@@ -0,0 +1,6 @@
+-- New users get a starter set of categories and tags; starter_applied_at records that they did,
+-- so it is applied only once. Users from before starter kits keep the closet they built.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS starter_applied_at TIMESTAMP;
+
+UPDATE users SET starter_applied_at = now() WHERE starter_applied_at IS NULL;
@@ -0,0 +1,70 @@
+-- Demo data for local development, loaded into an empty database with `migrate seed`
+
+-- Insert users
+INSERT INTO users (username, email, google_id, created_at, updated_at, starter_applied_at) VALUES
+('user1', 'user1@example.com', 'google1', '2024-07-01 12:00:00', '2024-07-01 12:00:00', '2024-07-01 12:00:00'),
+('user2', 'user2@example.com', 'google2', '2024-07-01 12:00:00', '2024-07-01 12:00:00', '2024-07-01 12:00:00');
+
+-- Insert categories
+INSERT INTO categories (user_id, name, created_at, updated_at) VALUES
+(1, 'Tops', '2024-07-01 12:00:00', '2024-07-01 12:00:00'),
+(1, 'Bottoms', '2024-07-01 12:00:00', '2024-07-01 12:00:00'),
+(2, 'Accessories', '2024-07-01 12:00:00', '2024-07-01 12:00:00');
+
+-- Insert clothing items
+INSERT INTO clothing_items (user_id, category_id, name, brand, size, primary_color, secondary_color, material, season, purchase_price, currency, purchase_date, notes, image_url, created_at, updated_at) VALUES
+(1, 1, 'White linen shirt', 'Uniqlo', 'M', 'white', NULL, 'linen', 'summer', 29.90, 'EUR', '2024-05-18', 'Breathable, wrinkles easily', 'http://example.com/clothing1.jpg', '2024-07-01 12:00:00', '2024-07-01 12:00:00'),
+(1, 2, 'Wool trousers', 'COS', '32', 'grey', 'black', 'wool', 'winter', 89.00, 'EUR', '2023-11-02', 'Dry clean only', 'http://example.com/clothing2.jpg', '2024-07-01 12:00:00', '2024-07-01 12:00:00'),
+(2, 3, 'Leather belt', NULL, NULL, 'brown', NULL, 'leather', 'all_season', NULL, NULL, NULL, NULL, 'http://example.com/clothing3.jpg', '2024-07-01 12:00:00', '2024-07-01 12:00:00');
+
+-- Insert sandbox
+INSERT INTO sandbox (user_id, name, created_at, updated_at) VALUES
+(1, 'Weekend', '2024-07-01 12:00:00', '2024-07-01 12:00:00'),
+(2, 'Office', '2024-07-01 12:00:00', '2024-07-01 12:00:00');
+
+-- Insert tags
+INSERT INTO tags (user_id, name, created_at, updated_at) VALUES
+(1, 'Summer', '2024-07-01 12:00:00', '2024-07-01 12:00:00'),
+(1, 'Winter', '2024-07-01 12:00:00', '2024-07-01 12:00:00'),
+(2, 'Summer', '2024-07-01 12:00:00', '2024-07-01 12:00:00');
+
+-- Insert sandbox positions
+INSERT INTO sandbox_positions (sandbox_id, clothing_item_id, position_x, position_y, created_at, updated_at) VALUES
+(1, 1, 50.0, 50.0, '2024-07-01 12:00:00', '2024-07-01 12:00:00'),
+(1, 2, 100.0, 150.0, '2024-07-01 12:00:00', '2024-07-01 12:00:00'),
+(2, 3, 200.0, 250.0, '2024-07-01 12:00:00', '2024-07-01 12:00:00');
+
+-- Insert clothing item tags
+INSERT INTO clothing_item_tags (clothing_item_id, tag_id) VALUES
+(1, 1),
+(2, 2),
+(3, 3);
+
+-- Insert outfits
+INSERT INTO outfits (user_id, name, notes, created_at, updated_at) VALUES
+(1, 'Smart casual', 'Roll up the sleeves', '2024-07-01 12:00:00', '2024-07-01 12:00:00'),
+(2, 'Belted', NULL, '2024-07-01 12:00:00', '2024-07-01 12:00:00');
+
+-- Insert outfit items
+INSERT INTO outfit_items (outfit_id, clothing_item_id, layer) VALUES
+(1, 1, 1),
+(1, 2, 0),
+(2, 3, NULL);
+
+-- Insert outfit tags
+INSERT INTO outfit_tags (outfit_id, tag_id) VALUES
+(1, 1),
+(2, 3);
+
+-- Insert wear events
+INSERT INTO wear_events (user_id, worn_on, outfit_id, note, created_at) VALUES
+(1, '2024-07-02', 1, 'Team lunch', '2024-07-02 20:00:00'),
+(1, '2024-07-05', NULL, NULL, '2024-07-05 20:00:00'),
+(2, '2024-07-02', 2, NULL, '2024-07-02 20:00:00');
+
+-- Insert wear event items
+INSERT INTO wear_event_items (wear_event_id, clothing_item_id) VALUES
+(1, 1),
+(1, 2),
+(2, 1),
+(3, 3);
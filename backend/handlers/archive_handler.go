@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"time"
+
+	"com.fukubox/middleware"
+	"com.fukubox/problem"
+	"com.fukubox/repository"
+	"com.fukubox/storage"
+)
+
+// archiveVersion is the manifest version ExportCloset writes and ImportCloset reads
+const archiveVersion = 1
+
+const archiveManifestName = "manifest.json"
+const archiveImageDir = "images/"
+
+const defaultMaxImportBytes = 256 << 20
+const maxManifestBytes = 64 << 20
+
+const csvMediaType = "text/csv"
+
+// importMediaTypes are the content types of imports; some browsers send zips as application/x-zip-compressed
+var importMediaTypes = []string{"application/zip", "application/x-zip-compressed", csvMediaType}
+
+// ArchiveManifest is manifest.json of a closet archive. Its ids only link the entries to each
+// other, importing gives everything new ids.
+type ArchiveManifest struct {
+	Version    int               `json:"version"`
+	ExportedAt time.Time         `json:"exported_at"`
+	Categories []ArchiveCategory `json:"categories"`
+	Tags       []Tag             `json:"tags"`
+	Clothes    []ArchiveCloth    `json:"clothes"`
+	Outfits    []OutfitEdit      `json:"outfits"`
+	Sandboxes  []ArchiveSandbox  `json:"sandboxes"`
+}
+
+type ArchiveCategory struct {
+	Id       int    `json:"id" validate:"required,gt=0"`
+	ParentId *int   `json:"parent_id"`
+	Name     string `json:"name" validate:"required,max=255"`
+}
+
+// ArchiveCloth is a clothing item in an archive. Image is the path of its original image in the
+// archive; only items with an external image keep ImageUrl.
+type ArchiveCloth struct {
+	Id         int `json:"id" validate:"required,gt=0"`
+	CategoryId int `json:"category_id" validate:"required,gt=0"`
+	ClothAttributes
+	Image    *string `json:"image"`
+	ImageUrl *string `json:"image_url"`
+	TagIds   []int   `json:"tag_ids"`
+}
+
+// ArchiveSandbox is a sandbox in an archive together with its layout
+type ArchiveSandbox struct {
+	SandboxEdit
+	Positions []SandboxPositionEdit `json:"positions" validate:"dive"`
+}
+
+// ImportReport is what an import created, and why the entries it skipped weren't imported
+type ImportReport struct {
+	Created ImportCounts `json:"created"`
+	// Reused counts the categories and tags the user had before the import that it matched by
+	// name, each once
+	Reused ImportReuse   `json:"reused"`
+	Errors []ImportError `json:"errors"`
+}
+
+type ImportCounts struct {
+	Categories int `json:"categories"`
+	Tags       int `json:"tags"`
+	Clothes    int `json:"clothes"`
+	Outfits    int `json:"outfits"`
+	Sandboxes  int `json:"sandboxes"`
+}
+
+type ImportReuse struct {
+	Categories int `json:"categories"`
+	Tags       int `json:"tags"`
+}
+
+// ImportError is an entry that wasn't imported: an entry of the manifest like clothes[2], or the
+// line of a CSV a row starts on. Errors are keyed by field, the empty key is about the entry as a whole.
+type ImportError struct {
+	Entry  string      `json:"entry"`
+	Errors fieldErrors `json:"errors"`
+}
+
+// maxImportBytes reads the size limit of an import from IMPORT_MAX_BYTES
+func maxImportBytes() int64 {
+	if value := os.Getenv("IMPORT_MAX_BYTES"); value != "" {
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err == nil && limit > 0 {
+			return limit
+		}
+		log.Printf("Ignoring invalid IMPORT_MAX_BYTES %q", value)
+	}
+	return defaultMaxImportBytes
+}
+
+// ExportCloset streams a zip of the user's closet: manifest.json and the original image of
+// every clothing item stored here. Items with an external image_url keep pointing at it, the
+// URLs of stored images are left out, so no import can point a second item at the same file.
+func (s *Server) ExportCloset(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	manifest, err := s.closetManifest(ctx, userId)
+	if err != nil {
+		log.Printf("Failed to read closet for export: %v", err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": "fukubox-" + manifest.ExportedAt.Format(dateLayout) + ".zip",
+	}))
+	w.WriteHeader(http.StatusOK)
+
+	// the headers are out, from here on a failure can only cut the archive short
+	archive := zip.NewWriter(w)
+
+	// images go first, so the manifest only points at those that could be read
+	store := storage.GetStorage()
+	for i := range manifest.Clothes {
+		cloth := &manifest.Clothes[i]
+		key, ok := store.Key(*cloth.ImageUrl)
+		if !ok {
+			continue
+		}
+		cloth.ImageUrl = nil
+
+		name, image, err := openExportImage(ctx, userId, cloth.Id, key)
+		if err != nil {
+			// the item is still exported, importing it reports that it has no image
+			log.Printf("Leaving image of clothing item %v out of export for user %v: %v", cloth.Id, userId, err)
+			continue
+		}
+
+		// images are compressed already, and copied over as they are read so none is held in memory
+		file, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: manifest.ExportedAt})
+		if err == nil {
+			_, err = io.Copy(file, image)
+		}
+		image.Close()
+		if err != nil {
+			log.Printf("Failed to write export for user %v: %v", userId, err)
+			return
+		}
+		cloth.Image = &name
+	}
+
+	file, err := archive.CreateHeader(&zip.FileHeader{Name: archiveManifestName, Method: zip.Deflate, Modified: manifest.ExportedAt})
+	if err == nil {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(manifest)
+	}
+	if err == nil {
+		err = archive.Close()
+	}
+	if err != nil {
+		log.Printf("Failed to write export for user %v: %v", userId, err)
+	}
+}
+
+// openExportImage opens the original image of a clothing item in storage and returns its path in
+// the archive. The caller closes the image.
+func openExportImage(ctx context.Context, userId int, clothId int, key string) (string, io.ReadCloser, error) {
+	// image_url is set by clients, it can't be trusted to point at the user's own files
+	if !storage.OwnsKey(userId, key) {
+		return "", nil, fmt.Errorf("%v isn't an image of the user", key)
+	}
+
+	file, err := storage.GetStorage().Get(ctx, key)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return archiveImageDir + strconv.Itoa(clothId) + path.Ext(key), file, nil
+}
+
+// closetManifest collects the user's closet for an export. Outfits and sandboxes only keep
+// the clothing items the export has, the others are in the trash.
+func (s *Server) closetManifest(ctx context.Context, userId int) (ArchiveManifest, error) {
+	manifest := ArchiveManifest{
+		Version:    archiveVersion,
+		ExportedAt: time.Now().UTC().Truncate(time.Second),
+		Categories: []ArchiveCategory{},
+		Tags:       []Tag{},
+		Clothes:    []ArchiveCloth{},
+		Outfits:    []OutfitEdit{},
+		Sandboxes:  []ArchiveSandbox{},
+	}
+
+	categoriesDto, err := s.Categories.GetCategoriesByUser(ctx, userId)
+	if err != nil {
+		return ArchiveManifest{}, err
+	}
+	for _, categoryDto := range categoriesDto {
+		manifest.Categories = append(manifest.Categories, ArchiveCategory{Id: categoryDto.Id, ParentId: categoryDto.ParentId, Name: categoryDto.Name})
+	}
+
+	tagsDto, err := s.Tags.GetTagsByUser(ctx, userId)
+	if err != nil {
+		return ArchiveManifest{}, err
+	}
+	for _, tagDto := range tagsDto {
+		manifest.Tags = append(manifest.Tags, Tag{Id: tagDto.Id, Name: tagDto.Name})
+	}
+
+	exported := map[int]bool{}
+	filter := repository.ClothFilterDto{SortBy: repository.ClothSortCreatedAt, Limit: maxClothesLimit}
+	for {
+		pageDto, err := s.Clothes.GetClothesPageByUser(ctx, userId, filter)
+		if err != nil {
+			return ArchiveManifest{}, err
+		}
+
+		for _, clothDto := range pageDto.Items {
+			cloth := toCloth(clothDto)
+			manifest.Clothes = append(manifest.Clothes, ArchiveCloth{
+				Id:              cloth.Id,
+				CategoryId:      cloth.CategoryId,
+				ClothAttributes: cloth.ClothAttributes,
+				ImageUrl:        &clothDto.ImageUrl,
+				TagIds:          tagIds(cloth.Tags),
+			})
+			exported[cloth.Id] = true
+		}
+
+		if pageDto.Next == nil {
+			break
+		}
+		filter.After = pageDto.Next
+	}
+
+	outfitsDto, err := s.Outfits.GetOutfitsByUser(ctx, userId, nil)
+	if err != nil {
+		return ArchiveManifest{}, err
+	}
+	for _, outfitDto := range outfitsDto {
+		outfit := toOutfit(outfitDto)
+
+		items := []OutfitItem{}
+		for _, item := range outfit.Items {
+			if exported[item.ClothingItemId] {
+				items = append(items, item)
+			}
+		}
+
+		manifest.Outfits = append(manifest.Outfits, OutfitEdit{
+			Name:           outfit.Name,
+			OutfitContents: OutfitContents{Notes: outfit.Notes, Items: items, TagIds: tagIds(outfit.Tags)},
+		})
+	}
+
+	sandboxesDto, err := s.Sandboxes.GetSandboxesByUser(ctx, userId)
+	if err != nil {
+		return ArchiveManifest{}, err
+	}
+	for _, sandboxDto := range sandboxesDto {
+		positionsDto, err := s.Sandboxes.GetSandboxPositions(ctx, userId, sandboxDto.Id)
+		if err != nil {
+			return ArchiveManifest{}, err
+		}
+
+		sandbox := ArchiveSandbox{SandboxEdit: SandboxEdit{Name: sandboxDto.Name}, Positions: []SandboxPositionEdit{}}
+		for _, positionDto := range positionsDto {
+			if exported[positionDto.ClothingItemId] {
+				sandbox.Positions = append(sandbox.Positions, SandboxPositionEdit{
+					ClothingItemId: positionDto.ClothingItemId,
+					PositionX:      &positionDto.PositionX,
+					PositionY:      &positionDto.PositionY,
+				})
+			}
+		}
+		manifest.Sandboxes = append(manifest.Sandboxes, sandbox)
+	}
+
+	return manifest, nil
+}
+
+func tagIds(tags []Tag) []int {
+	ids := []int{}
+	for _, tag := range tags {
+		ids = append(ids, tag.Id)
+	}
+	return ids
+}
+
+// ImportCloset adds an archive from ExportCloset, or a CSV of clothing items, to the user's
+// closet. Entries that can't be imported are reported and skipped, the rest still is.
+func (s *Server) ImportCloset(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if !slices.Contains(importMediaTypes, mediaType) {
+		problem.Write(w, r, problem.UnsupportedMediaType("Imports must be an archive from GET /export sent as application/zip, or a CSV of clothing items sent as text/csv"))
+		return
+	}
+
+	importer, err := s.newClosetImporter(ctx, userId)
+	if err != nil {
+		log.Printf("Failed to start import: %v", err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	limit := maxImportBytes()
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+
+	if mediaType == csvMediaType {
+		err = importer.importCSV(ctx, r.Body)
+	} else {
+		err = importer.importArchive(ctx, r.Body)
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		problem.Write(w, r, problem.PayloadTooLarge(fmt.Sprintf("Imports must be at most %d bytes", limit)))
+		return
+	}
+	var importProblem *problem.Problem
+	if errors.As(err, &importProblem) {
+		problem.Write(w, r, importProblem)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to import: %v", err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, importer.report)
+}
@@ -0,0 +1,749 @@
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"com.fukubox/imaging"
+	"com.fukubox/problem"
+	"com.fukubox/repository"
+)
+
+const (
+	csvCategoryColumn = "category"
+	csvTagsColumn     = "tags"
+	csvImageUrlColumn = "image_url"
+)
+
+// csvColumns are the columns a CSV import understands. category is the name of the item's
+// category, or the names of its ancestors and its own separated by /, and tags are tag names
+// separated by ;. Either is created when the user doesn't have it yet.
+var csvColumns = []string{
+	csvCategoryColumn, csvTagsColumn, csvImageUrlColumn,
+	"name", "brand", "size", "primary_color", "secondary_color", "material", "season",
+	"purchase_price", "currency", "purchase_date", "notes",
+}
+
+// archiveEntries is ArchiveManifest with its entries left undecoded, so an entry that doesn't
+// decode is reported on its own instead of failing the whole import
+type archiveEntries struct {
+	Version    int               `json:"version"`
+	Categories []json.RawMessage `json:"categories"`
+	Tags       []json.RawMessage `json:"tags"`
+	Clothes    []json.RawMessage `json:"clothes"`
+	Outfits    []json.RawMessage `json:"outfits"`
+	Sandboxes  []json.RawMessage `json:"sandboxes"`
+}
+
+// categoryName identifies a category by its parent, 0 at the top level, and its name
+type categoryName struct {
+	ParentId int
+	Name     string
+}
+
+// closetImporter adds the entries of one import to a user's closet
+type closetImporter struct {
+	s      *Server
+	userId int
+	report ImportReport
+
+	// categoryIds and tagIds hold the user's categories and tags by name, so archive entries reuse them
+	categoryIds map[categoryName]int
+	tagIds      map[string]int
+	// existingCategories and existingTags are the ids of those the user had before the import
+	// that it hasn't reused yet, so each counts as reused once and none it created do
+	existingCategories map[int]bool
+	existingTags       map[int]bool
+
+	// images are the files of the archive being imported, by name
+	images map[string]*zip.File
+	// categories, tags and clothes map the ids in the archive to the ids their entries were
+	// imported as, 0 for entries that weren't imported
+	categories map[int]int
+	tags       map[int]int
+	clothes    map[int]int
+}
+
+func (s *Server) newClosetImporter(ctx context.Context, userId int) (*closetImporter, error) {
+	importer := &closetImporter{
+		s:                  s,
+		userId:             userId,
+		report:             ImportReport{Errors: []ImportError{}},
+		categoryIds:        map[categoryName]int{},
+		tagIds:             map[string]int{},
+		existingCategories: map[int]bool{},
+		existingTags:       map[int]bool{},
+		images:             map[string]*zip.File{},
+		categories:         map[int]int{},
+		tags:               map[int]int{},
+		clothes:            map[int]int{},
+	}
+
+	categoriesDto, err := s.Categories.GetCategoriesByUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	for _, categoryDto := range categoriesDto {
+		key := categoryName{Name: categoryDto.Name}
+		if categoryDto.ParentId != nil {
+			key.ParentId = *categoryDto.ParentId
+		}
+		if _, ok := importer.categoryIds[key]; !ok {
+			importer.categoryIds[key] = categoryDto.Id
+		}
+		importer.existingCategories[categoryDto.Id] = true
+	}
+
+	tagsDto, err := s.Tags.GetTagsByUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	for _, tagDto := range tagsDto {
+		if _, ok := importer.tagIds[tagDto.Name]; !ok {
+			importer.tagIds[tagDto.Name] = tagDto.Id
+		}
+		importer.existingTags[tagDto.Id] = true
+	}
+
+	return importer, nil
+}
+
+// fail reports that entry wasn't imported
+func (importer *closetImporter) fail(entry string, errs fieldErrors) {
+	importer.report.Errors = append(importer.report.Errors, ImportError{Entry: entry, Errors: errs})
+}
+
+// failed reports that entry wasn't imported because storing it failed, which isn't the fault of
+// what was imported, so the cause is only logged
+func (importer *closetImporter) failed(entry string, err error) {
+	log.Printf("Failed to import %v for user %v: %v", entry, importer.userId, err)
+	importer.fail(entry, fieldErrors{"": "could not be imported"})
+}
+
+// countCategory reports a category the import looked up by name as created, or as reused the
+// first time it finds one the user already had
+func (importer *closetImporter) countCategory(category repository.NamedIdDto) {
+	switch {
+	case category.Created:
+		importer.report.Created.Categories++
+	case importer.existingCategories[category.Id]:
+		importer.report.Reused.Categories++
+		delete(importer.existingCategories, category.Id)
+	}
+}
+
+// countTag reports a tag the import looked up by name as created, or as reused the first time
+// it finds one the user already had
+func (importer *closetImporter) countTag(tag repository.NamedIdDto) {
+	switch {
+	case tag.Created:
+		importer.report.Created.Tags++
+	case importer.existingTags[tag.Id]:
+		importer.report.Reused.Tags++
+		delete(importer.existingTags, tag.Id)
+	}
+}
+
+// category returns the user's category called name under parentId, creating it if they don't have one
+func (importer *closetImporter) category(ctx context.Context, parentId *int, name string) (int, error) {
+	key := categoryName{Name: name}
+	if parentId != nil {
+		key.ParentId = *parentId
+	}
+	if id, ok := importer.categoryIds[key]; ok {
+		importer.countCategory(repository.NamedIdDto{Id: id})
+		return id, nil
+	}
+
+	categoryDto, err := importer.s.Categories.CreateCategory(ctx, importer.userId, name, parentId)
+	if err != nil {
+		return 0, err
+	}
+	importer.categoryIds[key] = categoryDto.Id
+	importer.countCategory(repository.NamedIdDto{Id: categoryDto.Id, Created: true})
+
+	return categoryDto.Id, nil
+}
+
+// tag returns the user's tag called name, creating it if they don't have one
+func (importer *closetImporter) tag(ctx context.Context, name string) (int, error) {
+	if id, ok := importer.tagIds[name]; ok {
+		importer.countTag(repository.NamedIdDto{Id: id})
+		return id, nil
+	}
+
+	tagDto, err := importer.s.Tags.CreateTag(ctx, importer.userId, name)
+	if err != nil {
+		return 0, err
+	}
+	importer.tagIds[name] = tagDto.Id
+	importer.countTag(repository.NamedIdDto{Id: tagDto.Id, Created: true})
+
+	return tagDto.Id, nil
+}
+
+// importArchive imports an archive written by ExportCloset. Entries are imported in order, each
+// kind after the kinds it refers to.
+func (importer *closetImporter) importArchive(ctx context.Context, body io.Reader) error {
+	// zip needs to seek, so the archive is spooled to disk rather than kept in memory
+	file, err := os.CreateTemp("", "fukubox-import-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	size, err := io.Copy(file, body)
+	if err != nil {
+		return err
+	}
+
+	archive, err := zip.NewReader(file, size)
+	if err != nil {
+		return problem.BadRequest("The import is not a zip archive")
+	}
+	for _, archived := range archive.File {
+		importer.images[archived.Name] = archived
+	}
+
+	manifestFile, ok := importer.images[archiveManifestName]
+	if !ok {
+		return problem.BadRequest("The archive has no " + archiveManifestName)
+	}
+	delete(importer.images, archiveManifestName)
+	if manifestFile.UncompressedSize64 > maxManifestBytes {
+		return problem.PayloadTooLarge(fmt.Sprintf("The archive's %v must be at most %d bytes", archiveManifestName, maxManifestBytes))
+	}
+
+	manifest, err := manifestFile.Open()
+	if err != nil {
+		return problem.BadRequest("The archive's " + archiveManifestName + " can't be read")
+	}
+	defer manifest.Close()
+
+	var entries archiveEntries
+	// the size in the zip header is only a claim, don't inflate more than it allows
+	if err := json.NewDecoder(io.LimitReader(manifest, maxManifestBytes)).Decode(&entries); err != nil {
+		return problem.BadRequest(fmt.Sprintf("The archive's %v is invalid: %v", archiveManifestName, err))
+	}
+	if entries.Version != archiveVersion {
+		return problem.BadRequest(fmt.Sprintf("Archives of version %d can't be imported, only version %d", entries.Version, archiveVersion))
+	}
+
+	importer.importCategories(ctx, entries.Categories)
+	for i, raw := range entries.Tags {
+		importer.importTag(ctx, fmt.Sprintf("tags[%d]", i), raw)
+	}
+	for i, raw := range entries.Clothes {
+		importer.importCloth(ctx, fmt.Sprintf("clothes[%d]", i), raw)
+	}
+	for i, raw := range entries.Outfits {
+		importer.importOutfit(ctx, fmt.Sprintf("outfits[%d]", i), raw)
+	}
+	for i, raw := range entries.Sandboxes {
+		importer.importSandbox(ctx, fmt.Sprintf("sandboxes[%d]", i), raw)
+	}
+
+	return nil
+}
+
+// decodeEntry decodes one entry of the manifest, reporting it if it doesn't decode
+func decodeEntry[T any](importer *closetImporter, entry string, raw json.RawMessage) (T, bool) {
+	var value T
+	err := json.Unmarshal(raw, &value)
+	if err == nil {
+		return value, true
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		importer.fail(entry, fieldErrors{typeErr.Field: decodeErrorMessage(err)})
+	} else {
+		importer.fail(entry, fieldErrors{"": decodeErrorMessage(err)})
+	}
+	return value, false
+}
+
+// archiveId checks the id of a manifest entry, which has to be unique among the entries of its
+// kind, adding what is wrong with it to errs. Valid ids are recorded in ids as not imported yet.
+func archiveId(ids map[int]int, id int, errs fieldErrors) {
+	if _, taken := ids[id]; taken {
+		errs["id"] = "is used by an earlier entry"
+		return
+	}
+	if id > 0 {
+		ids[id] = 0
+	}
+}
+
+// archiveRef maps the id of an entry the imported entry refers to to the id it was imported as,
+// returning what is wrong with the reference when it can't be
+func archiveRef(ids map[int]int, id int, kind string) (int, string) {
+	importedId, ok := ids[id]
+	if !ok {
+		return 0, "is not " + kind + " in the archive"
+	}
+	if importedId == 0 {
+		return 0, "is " + kind + " that wasn't imported"
+	}
+	return importedId, ""
+}
+
+// archiveTagRefs maps the tag ids of an entry to the ids the tags were imported as, adding
+// what is wrong with them to errs
+func (importer *closetImporter) archiveTagRefs(ids []int, errs fieldErrors) []int {
+	tagIds := []int{}
+	for _, id := range ids {
+		tagId, ok := importer.tags[id]
+		switch {
+		case !ok:
+			errs["tag_ids"] = "contains a tag that is not in the archive"
+		case tagId == 0:
+			errs["tag_ids"] = "contains a tag that wasn't imported"
+		default:
+			tagIds = append(tagIds, tagId)
+		}
+	}
+	return tagIds
+}
+
+// importCategories imports the categories of a manifest, each after its parent, which may come
+// later in the manifest
+func (importer *closetImporter) importCategories(ctx context.Context, raws []json.RawMessage) {
+	categories := make([]*ArchiveCategory, len(raws))
+	indexes := map[int]int{}
+
+	for i, raw := range raws {
+		entry := fmt.Sprintf("categories[%d]", i)
+		category, ok := decodeEntry[ArchiveCategory](importer, entry, raw)
+		if !ok {
+			continue
+		}
+
+		errs := fieldErrors{}
+		if err := newValidator().Struct(category); err != nil {
+			addValidationErrors(errs, err)
+		}
+		archiveId(importer.categories, category.Id, errs)
+		if len(errs) > 0 {
+			importer.fail(entry, errs)
+			continue
+		}
+
+		categories[i] = &category
+		indexes[category.Id] = i
+	}
+
+	// place imports the category at index i after its ancestors, which are the categories
+	// placed further up the call stack
+	placed := map[int]bool{}
+	var place func(i int, ancestors map[int]bool)
+	place = func(i int, ancestors map[int]bool) {
+		category := categories[i]
+		if placed[category.Id] {
+			return
+		}
+		placed[category.Id] = true
+		entry := fmt.Sprintf("categories[%d]", i)
+
+		var parentId *int
+		if category.ParentId != nil {
+			if *category.ParentId == category.Id || ancestors[*category.ParentId] {
+				importer.fail(entry, fieldErrors{"parent_id": "makes the category its own ancestor"})
+				return
+			}
+			if parentIndex, ok := indexes[*category.ParentId]; ok {
+				ancestors[category.Id] = true
+				place(parentIndex, ancestors)
+			}
+
+			id, message := archiveRef(importer.categories, *category.ParentId, "a category")
+			if message != "" {
+				importer.fail(entry, fieldErrors{"parent_id": message})
+				return
+			}
+			parentId = &id
+		}
+
+		id, err := importer.category(ctx, parentId, category.Name)
+		if err != nil {
+			importer.failed(entry, err)
+			return
+		}
+		importer.categories[category.Id] = id
+	}
+
+	for i := range categories {
+		if categories[i] != nil {
+			place(i, map[int]bool{})
+		}
+	}
+}
+
+func (importer *closetImporter) importTag(ctx context.Context, entry string, raw json.RawMessage) {
+	tag, ok := decodeEntry[Tag](importer, entry, raw)
+	if !ok {
+		return
+	}
+
+	errs := fieldErrors{}
+	if tag.Id <= 0 {
+		errs["id"] = "must be greater than 0"
+	}
+	if err := newValidator().Struct(TagEdit{Name: tag.Name}); err != nil {
+		addValidationErrors(errs, err)
+	}
+	archiveId(importer.tags, tag.Id, errs)
+	if len(errs) > 0 {
+		importer.fail(entry, errs)
+		return
+	}
+
+	id, err := importer.tag(ctx, tag.Name)
+	if err != nil {
+		importer.failed(entry, err)
+		return
+	}
+	importer.tags[tag.Id] = id
+}
+
+func (importer *closetImporter) importCloth(ctx context.Context, entry string, raw json.RawMessage) {
+	cloth, ok := decodeEntry[ArchiveCloth](importer, entry, raw)
+	if !ok {
+		return
+	}
+
+	errs := fieldErrors{}
+	if err := newClothValidator().Struct(cloth); err != nil {
+		addValidationErrors(errs, err)
+	}
+	cloth.ClothAttributes.check(errs)
+	if cloth.Image == nil && (cloth.ImageUrl == nil || *cloth.ImageUrl == "") {
+		errs["image_url"] = "is required without image"
+	} else if cloth.Image == nil {
+		checkImageUrl(errs, "image_url", importer.userId, *cloth.ImageUrl)
+	}
+	archiveId(importer.clothes, cloth.Id, errs)
+
+	categoryId, message := archiveRef(importer.categories, cloth.CategoryId, "a category")
+	if message != "" {
+		errs["category_id"] = message
+	}
+	tagIds := importer.archiveTagRefs(cloth.TagIds, errs)
+
+	var upload imageUpload
+	if cloth.Image != nil {
+		upload = importer.archiveImage(*cloth.Image, errs)
+	}
+	if len(errs) > 0 {
+		importer.fail(entry, errs)
+		return
+	}
+
+	dto := repository.ClothEditDto{CategoryId: categoryId, ClothAttributesDto: cloth.ClothAttributes.toDto()}
+	images := repository.ClothImagesDto{}
+	if cloth.Image != nil {
+		var err error
+		images, err = storeClothImages(ctx, importer.userId, upload)
+		if err != nil {
+			importer.failed(entry, err)
+			return
+		}
+		dto.ImageUrl, dto.CutoutUrl, dto.Variants = images.ImageUrl, images.CutoutUrl, images.Variants
+	} else {
+		dto.ImageUrl = *cloth.ImageUrl
+	}
+
+	clothId, err := importer.s.Clothes.CreateClothWithTags(ctx, importer.userId, dto, tagIds)
+	if err != nil {
+		deleteStoredImages(ctx, importer.userId, images)
+		importer.failed(entry, err)
+		return
+	}
+	importer.clothes[cloth.Id] = clothId
+	importer.report.Created.Clothes++
+}
+
+// archiveImage reads and checks the image at name in the archive, adding what is wrong with it to errs
+func (importer *closetImporter) archiveImage(name string, errs fieldErrors) imageUpload {
+	file, ok := importer.images[name]
+	if !ok {
+		errs["image"] = "is not in the archive"
+		return imageUpload{}
+	}
+
+	limit := maxUploadBytes()
+	if file.UncompressedSize64 > uint64(limit) {
+		errs["image"] = fmt.Sprintf("must be at most %d bytes", limit)
+		return imageUpload{}
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		errs["image"] = "can't be read from the archive"
+		return imageUpload{}
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		errs["image"] = "can't be read from the archive"
+		return imageUpload{}
+	}
+	if int64(len(data)) > limit {
+		errs["image"] = fmt.Sprintf("must be at most %d bytes", limit)
+		return imageUpload{}
+	}
+
+	upload, err := decodeImageUpload(data)
+	var imageProblem *problem.Problem
+	if errors.As(err, &imageProblem) && imageProblem.Status == http.StatusRequestEntityTooLarge {
+		errs["image"] = fmt.Sprintf("must be at most %d pixels", imaging.MaxPixels)
+		return imageUpload{}
+	}
+	if err != nil {
+		errs["image"] = "is not a supported image"
+		return imageUpload{}
+	}
+
+	return upload
+}
+
+func (importer *closetImporter) importOutfit(ctx context.Context, entry string, raw json.RawMessage) {
+	outfit, ok := decodeEntry[OutfitEdit](importer, entry, raw)
+	if !ok {
+		return
+	}
+
+	errs := fieldErrors{}
+	if err := newValidator().Struct(outfit); err != nil {
+		addValidationErrors(errs, err)
+	}
+	outfit.OutfitContents.check(errs)
+
+	items := []repository.OutfitItemDto{}
+	for i, item := range outfit.Items {
+		clothId, message := archiveRef(importer.clothes, item.ClothingItemId, "a clothing item")
+		if message != "" {
+			errs[fmt.Sprintf("items[%d].clothing_item_id", i)] = message
+			continue
+		}
+		items = append(items, repository.OutfitItemDto{ClothingItemId: clothId, Layer: item.Layer})
+	}
+	tagIds := importer.archiveTagRefs(outfit.TagIds, errs)
+
+	if len(errs) > 0 {
+		importer.fail(entry, errs)
+		return
+	}
+
+	_, err := importer.s.Outfits.CreateOutfit(ctx, importer.userId, repository.OutfitEditDto{
+		Name:   outfit.Name,
+		Notes:  outfit.Notes,
+		Items:  items,
+		TagIds: tagIds,
+	})
+	if err != nil {
+		importer.failed(entry, err)
+		return
+	}
+	importer.report.Created.Outfits++
+}
+
+func (importer *closetImporter) importSandbox(ctx context.Context, entry string, raw json.RawMessage) {
+	sandbox, ok := decodeEntry[ArchiveSandbox](importer, entry, raw)
+	if !ok {
+		return
+	}
+
+	errs := fieldErrors{}
+	if err := newValidator().Struct(sandbox); err != nil {
+		addValidationErrors(errs, err)
+	}
+
+	layout := []repository.SandboxPositionEditDto{}
+	for i, position := range sandbox.Positions {
+		clothId, message := archiveRef(importer.clothes, position.ClothingItemId, "a clothing item")
+		if message != "" {
+			errs[fmt.Sprintf("positions[%d].clothing_item_id", i)] = message
+			continue
+		}
+		if position.PositionX != nil && position.PositionY != nil {
+			layout = append(layout, repository.SandboxPositionEditDto{ClothingItemId: clothId, PositionX: *position.PositionX, PositionY: *position.PositionY})
+		}
+	}
+
+	if len(errs) > 0 {
+		importer.fail(entry, errs)
+		return
+	}
+
+	sandboxDto, err := importer.s.Sandboxes.CreateSandbox(ctx, importer.userId, repository.SandboxEditDto{Name: sandbox.Name})
+	if err != nil {
+		importer.failed(entry, err)
+		return
+	}
+
+	_, err = importer.s.Sandboxes.ReplaceSandboxPositions(ctx, importer.userId, sandboxDto.Id, layout)
+	if err != nil {
+		// don't leave half a sandbox behind
+		if err := importer.s.Sandboxes.DeleteSandbox(ctx, importer.userId, sandboxDto.Id); err != nil {
+			log.Printf("Failed to delete sandbox %v after a failed import: %v", sandboxDto.Id, err)
+		}
+		importer.failed(entry, err)
+		return
+	}
+	importer.report.Created.Sandboxes++
+}
+
+// importCSV imports a CSV of clothing items with a header row naming csvColumns. Rows are
+// reported by the line they start on.
+func (importer *closetImporter) importCSV(ctx context.Context, body io.Reader) error {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+
+	// read the whole file first, so a malformed one imports nothing
+	records, lines := [][]string{}, []int{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return problem.BadRequest(fmt.Sprintf("The CSV is invalid: %v", err))
+		}
+		if err != nil {
+			return err
+		}
+
+		line, _ := reader.FieldPos(0)
+		records, lines = append(records, record), append(lines, line)
+	}
+	if len(records) == 0 {
+		return problem.BadRequest("The CSV is empty, it needs at least a header row")
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		// spreadsheets like to start UTF-8 files with a byte order mark
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		if !slices.Contains(csvColumns, name) {
+			return problem.BadRequest(fmt.Sprintf("The CSV has an unknown column %q, the columns are %v", name, strings.Join(csvColumns, ", ")))
+		}
+		if _, ok := columns[name]; ok {
+			return problem.BadRequest(fmt.Sprintf("The CSV has the column %q twice", name))
+		}
+		columns[name] = i
+	}
+	for _, name := range []string{csvCategoryColumn, csvImageUrlColumn} {
+		if _, ok := columns[name]; !ok {
+			return problem.BadRequest(fmt.Sprintf("The CSV needs a %q column", name))
+		}
+	}
+
+	for i := 1; i < len(records); i++ {
+		importer.importRow(ctx, fmt.Sprintf("line %d", lines[i]), columns, records[i])
+	}
+
+	return nil
+}
+
+// importRow imports one row of a CSV as a clothing item, skipping blank rows
+func (importer *closetImporter) importRow(ctx context.Context, entry string, columns map[string]int, record []string) {
+	values := map[string][]string{}
+	for name, i := range columns {
+		if i < len(record) {
+			if value := strings.TrimSpace(record[i]); value != "" {
+				values[name] = []string{value}
+			}
+		}
+	}
+	if len(values) == 0 {
+		return
+	}
+
+	errs := fieldErrors{}
+	attributes, err := parseClothAttributesForm(&multipart.Form{Value: values})
+	if err != nil {
+		errs[""] = err.Error()
+	} else {
+		if err := newClothValidator().Struct(attributes); err != nil {
+			addValidationErrors(errs, err)
+		}
+		attributes.check(errs)
+	}
+
+	categoryPath := []string{}
+	for _, name := range strings.Split(firstValue(values[csvCategoryColumn]), "/") {
+		categoryPath = append(categoryPath, strings.TrimSpace(name))
+	}
+	if slices.Contains(categoryPath, "") {
+		errs[csvCategoryColumn] = "is required, and every category in its path needs a name"
+	} else if slices.ContainsFunc(categoryPath, func(name string) bool { return newValidator().Struct(CategoryEdit{Name: name}) != nil }) {
+		errs[csvCategoryColumn] = "can't have category names over 255 characters"
+	}
+
+	imageUrl := firstValue(values[csvImageUrlColumn])
+	if imageUrl == "" {
+		errs[csvImageUrlColumn] = "is required"
+	} else {
+		checkImageUrl(errs, csvImageUrlColumn, importer.userId, imageUrl)
+	}
+
+	tagNames := []string{}
+	for _, name := range strings.Split(firstValue(values[csvTagsColumn]), ";") {
+		if name = strings.TrimSpace(name); name != "" && !slices.Contains(tagNames, name) {
+			tagNames = append(tagNames, name)
+		}
+	}
+	if slices.ContainsFunc(tagNames, func(name string) bool { return newValidator().Struct(TagEdit{Name: name}) != nil }) {
+		errs[csvTagsColumn] = "can't have tag names over 255 characters"
+	}
+
+	if len(errs) > 0 {
+		importer.fail(entry, errs)
+		return
+	}
+
+	// the row's new categories and tags are created with the item, so a row that fails leaves none behind
+	created, err := importer.s.Clothes.CreateClothByNames(ctx, importer.userId, repository.ClothByNamesDto{
+		ClothAttributesDto: attributes.toDto(),
+		ImageUrl:           imageUrl,
+		CategoryPath:       categoryPath,
+		TagNames:           tagNames,
+	})
+	if err != nil {
+		importer.failed(entry, err)
+		return
+	}
+	for _, category := range created.Categories {
+		importer.countCategory(category)
+	}
+	for _, tag := range created.Tags {
+		importer.countTag(tag)
+	}
+	importer.report.Created.Clothes++
+}
+
+func firstValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
@@ -0,0 +1,89 @@
+package handlers_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"com.fukubox/apptest"
+	"com.fukubox/repository"
+	"com.fukubox/repository/memory"
+)
+
+type importCounts struct {
+	Created struct {
+		Categories int `json:"categories"`
+		Tags       int `json:"tags"`
+		Clothes    int `json:"clothes"`
+	} `json:"created"`
+	Reused struct {
+		Categories int `json:"categories"`
+		Tags       int `json:"tags"`
+	} `json:"reused"`
+	Errors []struct {
+		Entry string `json:"entry"`
+	} `json:"errors"`
+}
+
+func TestImportCountsReuseOnce(t *testing.T) {
+	store := memory.NewStore()
+	userId := newUser(t, store, "ines")
+	h := apptest.NewInMemory(t, store)
+	newCategory(t, h, userId, "Shoes")
+	h.Do(t, userId, http.MethodPost, "/tags", map[string]any{"name": "Summer"}).ExpectStatus(t, http.StatusCreated)
+
+	// names an import created itself aren't reused, and names the user had count once
+	csv := "name,category,tags,image_url\n" +
+		"Shirt,Tops,Summer;Linen,http://example.com/1.jpg\n" +
+		"Blouse,Tops,Summer;Linen,http://example.com/2.jpg\n" +
+		"Tee,Tops/Tees,Linen,http://example.com/3.jpg\n" +
+		"Boots,Shoes,Summer,http://example.com/4.jpg\n" +
+		"Sandals,Shoes,,http://example.com/5.jpg\n"
+	var report importCounts
+	h.DoRaw(t, userId, http.MethodPost, "/import", "text/csv", strings.NewReader(csv)).
+		ExpectStatus(t, http.StatusOK).Decode(t, &report)
+	if report.Created.Clothes != 5 || report.Created.Categories != 2 || report.Created.Tags != 1 ||
+		report.Reused.Categories != 1 || report.Reused.Tags != 1 || len(report.Errors) != 0 {
+		t.Fatalf("unexpected import report %+v", report)
+	}
+}
+
+// failingClothes is a store whose clothing items can't be created
+type failingClothes struct {
+	*memory.Store
+}
+
+func (failingClothes) CreateClothByNames(ctx context.Context, userId int, newCloth repository.ClothByNamesDto) (repository.ClothByNamesCreatedDto, error) {
+	return repository.ClothByNamesCreatedDto{}, errors.New("disk full")
+}
+
+func TestFailedImportRowLeavesNothing(t *testing.T) {
+	store := memory.NewStore()
+	userId := newUser(t, store, "ines")
+	h := apptest.NewInMemory(t, failingClothes{store})
+
+	csv := "name,category,tags,image_url\n" +
+		"Shirt,Tops/Shirts,Summer,http://example.com/1.jpg\n"
+	var report importCounts
+	h.DoRaw(t, userId, http.MethodPost, "/import", "text/csv", strings.NewReader(csv)).
+		ExpectStatus(t, http.StatusOK).Decode(t, &report)
+	if report.Created.Clothes != 0 || report.Created.Categories != 0 || report.Created.Tags != 0 ||
+		len(report.Errors) != 1 || report.Errors[0].Entry != "line 2" {
+		t.Fatalf("unexpected import report %+v", report)
+	}
+
+	// the row's category and tag went with it
+	categories, err := store.GetCategoriesByUser(context.Background(), userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags, err := store.GetTagsByUser(context.Background(), userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(categories) != 0 || len(tags) != 0 {
+		t.Fatalf("a failed row left categories %+v and tags %+v behind", categories, tags)
+	}
+}
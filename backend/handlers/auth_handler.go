@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"com.fukubox/auth"
+	"com.fukubox/middleware"
+	"com.fukubox/problem"
+	"com.fukubox/repository"
+)
+
+const stateCookieName = "oauth_state"
+const nonceCookieName = "oauth_nonce"
+const loginCookieTTL = 10 * time.Minute
+
+type Session struct {
+	UserId    int       `json:"user_id"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (s *Server) Login(w http.ResponseWriter, r *http.Request) {
+	state, err := randomString()
+	if err != nil {
+		log.Printf("Failed to generate oauth state: %v", err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	nonce, err := randomString()
+	if err != nil {
+		log.Printf("Failed to generate oauth nonce: %v", err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	setCookie(w, r, stateCookieName, state, time.Now().Add(loginCookieTTL))
+	setCookie(w, r, nonceCookieName, nonce, time.Now().Add(loginCookieTTL))
+
+	http.Redirect(w, r, auth.AuthCodeURL(state, nonce), http.StatusFound)
+}
+
+func (s *Server) Callback(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if errParam := r.URL.Query().Get("error"); errParam != "" {
+		log.Printf("Identity provider returned an error: %v", errParam)
+		problem.Write(w, r, problem.Unauthorized("Login failed"))
+		return
+	}
+
+	stateCookie, err := r.Cookie(stateCookieName)
+	if err != nil || stateCookie.Value == "" || stateCookie.Value != r.URL.Query().Get("state") {
+		log.Printf("OAuth state mismatch")
+		problem.Write(w, r, problem.BadRequest("Invalid login state"))
+		return
+	}
+
+	nonceCookie, err := r.Cookie(nonceCookieName)
+	if err != nil || nonceCookie.Value == "" {
+		log.Printf("OAuth nonce cookie missing")
+		problem.Write(w, r, problem.BadRequest("Invalid login state"))
+		return
+	}
+
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		problem.Write(w, r, problem.BadRequest("Missing authorization code"))
+		return
+	}
+
+	identity, err := auth.Exchange(ctx, code, nonceCookie.Value)
+	if err != nil {
+		log.Printf("Failed to verify login: %v", err)
+		problem.Write(w, r, problem.Unauthorized("Login failed"))
+		return
+	}
+
+	userId, err := s.Users.UpsertGoogleUser(ctx, identity.Subject, identity.Email, identity.EmailVerified, identity.Name)
+	if errors.Is(err, repository.ErrDuplicate) {
+		log.Printf("Email of google_id %v belongs to a user it can't be linked to", identity.Subject)
+		problem.Write(w, r, problem.Conflict("Another account already uses this email"))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to upsert user: %v", err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	// the locale the provider knows the user by comes before the browser's
+	s.applyStarterKit(ctx, userId, identity.Locale, r.Header.Get("Accept-Language"))
+
+	token, expiresAt, err := auth.IssueSession(userId)
+	if err != nil {
+		log.Printf("Failed to issue session: %v", err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	clearCookie(w, r, stateCookieName)
+	clearCookie(w, r, nonceCookieName)
+	setCookie(w, r, middleware.SessionCookieName, token, expiresAt)
+
+	writeJSON(w, http.StatusOK, Session{UserId: userId, Token: token, ExpiresAt: expiresAt})
+}
+
+func (s *Server) Logout(w http.ResponseWriter, r *http.Request) {
+	clearCookie(w, r, middleware.SessionCookieName)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func setCookie(w http.ResponseWriter, r *http.Request, name string, value string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func clearCookie(w http.ResponseWriter, r *http.Request, name string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,370 @@
+package handlers_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"com.fukubox/apptest"
+	"com.fukubox/auth"
+	"com.fukubox/repository/memory"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const mockClientId = "fukubox-test"
+
+// mockIssuer is an OpenID provider that signs in whoever Identity returns without asking. Its
+// authorize endpoint redirects straight back with a code, and its token endpoint trades the code
+// for an ID token signed with a key it publishes.
+type mockIssuer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	// Identity returns the claims of the next login, given the nonce the app sent
+	Identity func(nonce string) jwt.MapClaims
+
+	mu    sync.Mutex
+	codes map[string]jwt.MapClaims
+}
+
+// newMockIssuer starts a mock issuer and points the app's login at it, with h's callback as
+// the redirect URL
+func newMockIssuer(t *testing.T, h *apptest.Harness) *mockIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &mockIssuer{key: key, codes: map[string]jwt.MapClaims{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", issuer.discovery)
+	mux.HandleFunc("/jwks", issuer.jwks)
+	mux.HandleFunc("/authorize", issuer.authorize)
+	mux.HandleFunc("/token", issuer.token)
+	issuer.Server = httptest.NewServer(mux)
+	t.Cleanup(issuer.Close)
+
+	t.Setenv("OIDC_ISSUER_URL", issuer.URL)
+	t.Setenv("OIDC_CLIENT_ID", mockClientId)
+	t.Setenv("OIDC_CLIENT_SECRET", "secret")
+	t.Setenv("OIDC_REDIRECT_URL", h.URL+"/auth/callback")
+	if err := auth.StartOIDC(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	return issuer
+}
+
+func (issuer *mockIssuer) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]any{
+		"issuer":                                issuer.URL,
+		"authorization_endpoint":                issuer.URL + "/authorize",
+		"token_endpoint":                        issuer.URL + "/token",
+		"jwks_uri":                              issuer.URL + "/jwks",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (issuer *mockIssuer) jwks(w http.ResponseWriter, r *http.Request) {
+	public := issuer.key.PublicKey
+	json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"alg": "RS256",
+		"use": "sig",
+		"kid": "test",
+		"n":   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+	}}})
+}
+
+func (issuer *mockIssuer) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != mockClientId {
+		http.Error(w, "unknown client", http.StatusBadRequest)
+		return
+	}
+
+	// claims the test set itself win over the defaults of a valid token
+	claims := jwt.MapClaims{
+		"iss": issuer.URL,
+		"aud": mockClientId,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for name, value := range issuer.Identity(query.Get("nonce")) {
+		claims[name] = value
+	}
+
+	issuer.mu.Lock()
+	code := fmt.Sprintf("code-%d", len(issuer.codes)+1)
+	issuer.codes[code] = claims
+	issuer.mu.Unlock()
+
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	redirect.RawQuery = url.Values{"code": {code}, "state": {query.Get("state")}}.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (issuer *mockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	issuer.mu.Lock()
+	claims, ok := issuer.codes[r.FormValue("code")]
+	delete(issuer.codes, r.FormValue("code"))
+	issuer.mu.Unlock()
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"error": "invalid_grant"}`)
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test"
+	idToken, err := token.SignedString(issuer.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+// browser is a client keeping cookies, following redirects unless follow is false
+func browser(t *testing.T, follow bool) *http.Client {
+	t.Helper()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Jar: jar}
+	if !follow {
+		client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	}
+	return client
+}
+
+func get(t *testing.T, client *http.Client, url string) *http.Response {
+	t.Helper()
+
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// loginSession is the part of the callback's Session response the tests look at
+type loginSession struct {
+	UserId int    `json:"user_id"`
+	Token  string `json:"token"`
+}
+
+// login signs in through the app and the mock issuer in one go, as a browser would
+func login(t *testing.T, h *apptest.Harness, client *http.Client, status int) loginSession {
+	t.Helper()
+
+	resp := get(t, client, h.URL+"/auth/login")
+	if resp.StatusCode != status {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("expected the login to end with status %d, got %d: %s", status, resp.StatusCode, body)
+	}
+
+	var session loginSession
+	if status == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&session); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return session
+}
+
+func TestLogin(t *testing.T) {
+	store := memory.NewStore()
+	h := apptest.NewInMemory(t, store)
+	issuer := newMockIssuer(t, h)
+	issuer.Identity = func(nonce string) jwt.MapClaims {
+		return jwt.MapClaims{"sub": "google-42", "email": "noemie@example.com", "email_verified": true, "name": "Noémie", "locale": "fr", "nonce": nonce}
+	}
+
+	// the login redirects to the issuer, with the state and nonce kept in cookies
+	client := browser(t, false)
+	resp := get(t, client, h.URL+"/auth/login")
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("expected a redirect, got status %d", resp.StatusCode)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || location.Host != issuer.Listener.Addr().String() || location.Path != "/authorize" {
+		t.Fatalf("expected a redirect to the issuer, got %v", resp.Header.Get("Location"))
+	}
+	if location.Query().Get("state") == "" || location.Query().Get("nonce") == "" {
+		t.Fatalf("expected a state and a nonce, got %v", location)
+	}
+
+	// the whole round trip signs the user up and hands out a session
+	client = browser(t, true)
+	session := login(t, h, client, http.StatusOK)
+	if session.UserId == 0 || session.Token == "" {
+		t.Fatalf("unexpected session %+v", session)
+	}
+
+	// the session cookie set by the callback is enough to use the api
+	var me struct {
+		Id       int     `json:"id"`
+		Username string  `json:"username"`
+		Email    *string `json:"email"`
+	}
+	resp = get(t, client, h.URL+"/me")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("session cookie wasn't accepted: status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&me); err != nil {
+		t.Fatal(err)
+	}
+	if me.Id != session.UserId || me.Username != "Noémie" || me.Email == nil || *me.Email != "noemie@example.com" {
+		t.Fatalf("unexpected user %+v", me)
+	}
+
+	// a new user starts with the kit for the locale the issuer knows them by
+	var categories []namedBody
+	h.Do(t, session.UserId, http.MethodGet, "/categories", nil).ExpectStatus(t, http.StatusOK).Decode(t, &categories)
+	if len(categories) == 0 || categories[0].Name != "Hauts" {
+		t.Fatalf("expected the French kit, got %+v", categories)
+	}
+
+	// signing in again is the same user
+	if again := login(t, h, browser(t, true), http.StatusOK); again.UserId != session.UserId {
+		t.Fatalf("second login signed in as %v, expected %v", again.UserId, session.UserId)
+	}
+
+	// a logged out browser has no session left
+	req, err := http.NewRequest(http.MethodPost, h.URL+"/auth/logout", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp := get(t, client, h.URL+"/me"); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected no session after logging out, got status %d", resp.StatusCode)
+	}
+}
+
+func TestLoginLinksExistingEmail(t *testing.T) {
+	store := memory.NewStore()
+	admin := newUser(t, store, "admin")
+	h := apptest.NewInMemory(t, store)
+	issuer := newMockIssuer(t, h)
+
+	var existing struct {
+		Id int `json:"id"`
+	}
+	h.Do(t, admin, http.MethodPost, "/users", map[string]any{"username": "camille", "email": "camille@example.com"}).
+		ExpectStatus(t, http.StatusCreated).Decode(t, &existing)
+
+	// an email the issuer hasn't verified can't take over the account
+	issuer.Identity = func(nonce string) jwt.MapClaims {
+		return jwt.MapClaims{"sub": "google-camille", "email": "camille@example.com", "email_verified": false, "nonce": nonce}
+	}
+	login(t, h, browser(t, true), http.StatusConflict)
+
+	issuer.Identity = func(nonce string) jwt.MapClaims {
+		return jwt.MapClaims{"sub": "google-camille", "email": "camille@example.com", "email_verified": true, "nonce": nonce}
+	}
+	if session := login(t, h, browser(t, true), http.StatusOK); session.UserId != existing.Id {
+		t.Fatalf("expected to sign in as the existing user %v, got %v", existing.Id, session.UserId)
+	}
+
+	// the account is linked now, another Google account with the email is refused
+	issuer.Identity = func(nonce string) jwt.MapClaims {
+		return jwt.MapClaims{"sub": "google-impostor", "email": "camille@example.com", "email_verified": true, "nonce": nonce}
+	}
+	login(t, h, browser(t, true), http.StatusConflict)
+}
+
+func TestLoginIsRejected(t *testing.T) {
+	store := memory.NewStore()
+	h := apptest.NewInMemory(t, store)
+	issuer := newMockIssuer(t, h)
+	issuer.Identity = func(nonce string) jwt.MapClaims {
+		return jwt.MapClaims{"sub": "google-42", "email": "noemie@example.com", "email_verified": true, "nonce": nonce}
+	}
+
+	// a callback carrying another state than the one the browser started with
+	client := browser(t, false)
+	resp := get(t, client, h.URL+"/auth/login")
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp = get(t, client, location.String())
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := callback.Query()
+	tampered.Set("state", "forged")
+	callback.RawQuery = tampered.Encode()
+	if resp := get(t, client, callback.String()); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected a forged state to be refused, got status %d", resp.StatusCode)
+	}
+
+	// a callback without the cookies of the browser that started the login
+	if resp := get(t, browser(t, false), h.URL+"/auth/callback?code=code-1&state=forged"); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected a login started elsewhere to be refused, got status %d", resp.StatusCode)
+	}
+
+	// an ID token issued for another login's nonce
+	issuer.Identity = func(nonce string) jwt.MapClaims {
+		return jwt.MapClaims{"sub": "google-42", "email": "noemie@example.com", "email_verified": true, "nonce": "replayed"}
+	}
+	login(t, h, browser(t, true), http.StatusUnauthorized)
+
+	// an ID token for another client
+	issuer.Identity = func(nonce string) jwt.MapClaims {
+		return jwt.MapClaims{"sub": "google-42", "nonce": nonce, "aud": "someone-else"}
+	}
+	login(t, h, browser(t, true), http.StatusUnauthorized)
+
+	// an ID token that has expired
+	issuer.Identity = func(nonce string) jwt.MapClaims {
+		return jwt.MapClaims{"sub": "google-42", "nonce": nonce, "exp": time.Now().Add(-time.Hour).Unix()}
+	}
+	login(t, h, browser(t, true), http.StatusUnauthorized)
+
+	// the issuer turning the login down
+	if resp := get(t, browser(t, false), h.URL+"/auth/callback?error=access_denied&code=x&state=x"); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected a declined login to be refused, got status %d", resp.StatusCode)
+	}
+
+	// none of it signed anyone up
+	if exists, _ := store.UserExists(context.Background(), 1); exists {
+		t.Fatal("a rejected login created a user")
+	}
+}
@@ -2,264 +2,348 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
 	"time"
 
-	"com.fukubox/database"
-	"github.com/go-chi/chi"
+	"com.fukubox/middleware"
+	"com.fukubox/problem"
+	"com.fukubox/repository"
+	"github.com/jackc/pgx/v5"
 )
 
 type Category struct {
 	Id        int       `json:"id"`
 	UserId    int       `json:"user_id"`
+	ParentId  *int      `json:"parent_id"`
 	Name      string    `json:"name"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
-type CategoryName struct {
-	Name string `json:"name"`
+// CategoryNode is a category in GET /categories/tree, with its subcategories
+type CategoryNode struct {
+	Category
+	Children []CategoryNode `json:"children"`
 }
 
-func GetCategories(w http.ResponseWriter, r *http.Request) {
-	dbpool := database.GetDB()
+// CategoryEdit is the body of POST /categories, a top level category unless ParentId is set
+type CategoryEdit struct {
+	Name     string `json:"name" validate:"required,max=255"`
+	ParentId *int   `json:"parent_id"`
+}
+
+// CategoryMove is the body of POST /categories/{id}/move, a null ParentId moves the category to the top level
+type CategoryMove struct {
+	ParentId *int `json:"parent_id"`
+}
+
+// CategoryPatch is the merge patch body of PATCH /categories/{id}
+type CategoryPatch struct {
+	Name Optional[string]
+}
+
+// CategoryDeletion reports what DELETE /categories/{id} did with the clothing items of the category
+type CategoryDeletion struct {
+	OnItems       string      `json:"on_items"`
+	ItemCount     int         `json:"item_count"`
+	ReassignedTo  *int        `json:"reassigned_to"`
+	BrokenOutfits []OutfitRef `json:"broken_outfits"`
+}
+
+func (patch *CategoryPatch) fields() map[string]patchField {
+	return map[string]patchField{"name": &patch.Name}
+}
+
+func (patch CategoryPatch) check(errs fieldErrors) {
+	checkNotEmpty(errs, "name", patch.Name)
+	if patch.Name.Value != nil && *patch.Name.Value != "" {
+		if err := newValidator().Struct(CategoryEdit{Name: *patch.Name.Value}); err != nil {
+			addValidationErrors(errs, err)
+		}
+	}
+}
+
+func (s *Server) GetCategories(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	userIdStr := r.Header.Get("userId")
+	userId := middleware.GetUserId(ctx)
 
-	userId, err := strconv.Atoi(userIdStr)
+	categoryDtos, err := s.Categories.GetCategoriesByUser(ctx, userId)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		log.Printf("Failed to get categories for user %v: %v", userId, err)
+		problem.Write(w, r, problem.Internal())
 		return
 	}
 
-	conn, err := dbpool.Acquire(ctx)
-	if err != nil {
-		log.Printf("Failed to acquire a database connection: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+	categories := []Category{}
+	for _, categoryDto := range categoryDtos {
+		categories = append(categories, toCategory(categoryDto))
 	}
-	defer conn.Release()
 
-	rows, err := conn.Query(ctx, "SELECT * FROM categories WHERE user_id = $1", userId)
+	writeJSON(w, http.StatusOK, categories)
+}
+
+// GetCategoryTree returns the user's top level categories with their subcategories nested in them
+func (s *Server) GetCategoryTree(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	categoryDtos, err := s.Categories.GetCategoriesByUser(ctx, userId)
 	if err != nil {
-		log.Printf("Query failed: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("Failed to get categories for user %v: %v", userId, err)
+		problem.Write(w, r, problem.Internal())
 		return
 	}
-	defer rows.Close()
 
-	categories := []Category{}
+	// categories come ordered by id, and so do the children of each one
+	children := map[int][]repository.CategoryDto{}
+	roots := []repository.CategoryDto{}
+	for _, categoryDto := range categoryDtos {
+		if categoryDto.ParentId == nil {
+			roots = append(roots, categoryDto)
+		} else {
+			children[*categoryDto.ParentId] = append(children[*categoryDto.ParentId], categoryDto)
+		}
+	}
 
-	for rows.Next() {
-		var category Category
-		if err := rows.Scan(&category.Id, &category.UserId, &category.Name, &category.CreatedAt, &category.UpdatedAt); err != nil {
-			log.Printf("Failed to scan row: %v", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return
+	var toNodes func(categoryDtos []repository.CategoryDto) []CategoryNode
+	toNodes = func(categoryDtos []repository.CategoryDto) []CategoryNode {
+		nodes := []CategoryNode{}
+		for _, categoryDto := range categoryDtos {
+			nodes = append(nodes, CategoryNode{Category: toCategory(categoryDto), Children: toNodes(children[categoryDto.Id])})
 		}
-		categories = append(categories, category)
+		return nodes
 	}
 
-	if err := rows.Err(); err != nil {
-		log.Printf("Error after iterating rows: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	writeJSON(w, http.StatusOK, toNodes(roots))
+}
+
+func (s *Server) GetCategoriesById(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	categoryId, ok := urlParamId(r, "id")
+	if !ok {
+		problem.Write(w, r, problem.BadRequest("Invalid category ID"))
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(categories); err != nil {
-		log.Printf("Failed to encode response as JSON: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	categoryDto, err := s.Categories.GetCategoryByUserAndId(ctx, userId, categoryId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		problem.Write(w, r, problem.NotFound("Category not found"))
 		return
 	}
+	if err != nil {
+		log.Printf("Failed to get category %v: %v", categoryId, err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toCategory(categoryDto))
 }
 
-func GetCategoriesById(w http.ResponseWriter, r *http.Request) {
-	dbpool := database.GetDB()
+func (s *Server) CreateCategory(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	userIdStr := r.Header.Get("userId")
+	userId := middleware.GetUserId(ctx)
 
-	userId, err := strconv.Atoi(userIdStr)
-	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+	var req CategoryEdit
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, problem.BadRequest("Invalid request body"))
 		return
 	}
-
-	categoryIdStr := chi.URLParam(r, "id")
-	categoryId, err := strconv.Atoi(categoryIdStr)
-	if err != nil {
-		http.Error(w, "Invalid category ID", http.StatusBadRequest)
+	if err := newValidator().Struct(req); err != nil {
+		problem.Write(w, r, validationProblem(err))
 		return
 	}
 
-	conn, err := dbpool.Acquire(ctx)
-	if err != nil {
-		log.Printf("Failed to acquire a database connection: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	categoryDto, err := s.Categories.CreateCategory(ctx, userId, req.Name, req.ParentId)
+	if errors.Is(err, repository.ErrCategoryNotOwned) {
+		problem.Write(w, r, problem.InvalidFields(fieldErrors{"parent_id": "is not a category of yours"}))
 		return
 	}
-	defer conn.Release()
-
-	var category Category
-	err = dbpool.QueryRow(ctx, "SELECT id, user_id, name, created_at, updated_at FROM categories WHERE id = $1 AND user_id = $2", categoryId, userId).
-		Scan(&category.Id, &category.UserId, &category.Name, &category.CreatedAt, &category.UpdatedAt)
 	if err != nil {
-		log.Printf("Failed to query row: %v", err)
-		http.Error(w, "Category not found", http.StatusNotFound)
+		log.Printf("Failed to create category: %v", err)
+		problem.Write(w, r, problem.Internal())
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(category); err != nil {
-		log.Printf("Failed to encode response as JSON: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
+	writeJSON(w, http.StatusCreated, toCategory(categoryDto))
 }
 
-func CreateCategory(w http.ResponseWriter, r *http.Request) {
-	dbpool := database.GetDB()
+func (s *Server) UpdateCategory(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	userIdStr := r.Header.Get("userId")
+	userId := middleware.GetUserId(ctx)
 
-	userId, err := strconv.Atoi(userIdStr)
-	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+	categoryId, ok := urlParamId(r, "id")
+	if !ok {
+		problem.Write(w, r, problem.BadRequest("Invalid category ID"))
 		return
 	}
 
-	var req CategoryName
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	var patch CategoryPatch
+	errs, decodeProblem := decodeMergePatch(r, patch.fields())
+	if decodeProblem != nil {
+		problem.Write(w, r, decodeProblem)
 		return
 	}
-	if req.Name == "" {
-		http.Error(w, "Category name is required", http.StatusBadRequest)
+	patch.check(errs)
+	if len(errs) > 0 {
+		problem.Write(w, r, problem.InvalidFields(errs))
 		return
 	}
 
-	conn, err := dbpool.Acquire(ctx)
-	if err != nil {
-		log.Printf("Failed to acquire a database connection: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	categoryDto, err := s.Categories.UpdateCategory(ctx, userId, categoryId, patch.Name.Value)
+	if errors.Is(err, pgx.ErrNoRows) {
+		problem.Write(w, r, problem.NotFound("Category not found or not authorized to update"))
 		return
 	}
-	defer conn.Release()
-
-	var newCategory Category
-	err = conn.QueryRow(ctx, "INSERT INTO categories (user_id, name, created_at, updated_at) VALUES ($1, $2, now(), now()) RETURNING id, user_id, name, created_at, updated_at",
-		userId, req.Name).Scan(&newCategory.Id, &newCategory.UserId, &newCategory.Name, &newCategory.CreatedAt, &newCategory.UpdatedAt)
 	if err != nil {
-		log.Printf("Failed to insert new category: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("Failed to update category %v: %v", categoryId, err)
+		problem.Write(w, r, problem.Internal())
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(newCategory); err != nil {
-		log.Printf("Failed to encode response as JSON: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
+	writeJSON(w, http.StatusOK, toCategory(categoryDto))
 }
 
-func UpdateCategory(w http.ResponseWriter, r *http.Request) {
-	dbpool := database.GetDB()
+// MoveCategory moves a category, and with it every category under it, to another parent
+func (s *Server) MoveCategory(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	userIdStr := r.Header.Get("userId")
+	userId := middleware.GetUserId(ctx)
 
-	userId, err := strconv.Atoi(userIdStr)
-	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+	categoryId, ok := urlParamId(r, "id")
+	if !ok {
+		problem.Write(w, r, problem.BadRequest("Invalid category ID"))
 		return
 	}
 
-	categoryIdStr := chi.URLParam(r, "id")
-	categoryId, err := strconv.Atoi(categoryIdStr)
-	if err != nil {
-		http.Error(w, "Invalid category ID", http.StatusBadRequest)
+	var req CategoryMove
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, problem.BadRequest("Invalid request body"))
 		return
 	}
 
-	var req CategoryName
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	categoryDto, err := s.Categories.MoveCategory(ctx, userId, categoryId, req.ParentId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		problem.Write(w, r, problem.NotFound("Category not found or not authorized to move"))
 		return
 	}
-	if req.Name == "" {
-		http.Error(w, "Category name is required", http.StatusBadRequest)
+	if errors.Is(err, repository.ErrCategoryNotOwned) {
+		problem.Write(w, r, problem.InvalidFields(fieldErrors{"parent_id": "is not a category of yours"}))
 		return
 	}
-
-	conn, err := dbpool.Acquire(ctx)
-	if err != nil {
-		log.Printf("Failed to acquire a database connection: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	if errors.Is(err, repository.ErrCategoryCycle) {
+		problem.Write(w, r, problem.InvalidFields(fieldErrors{"parent_id": "can't be the category itself or one of its subcategories"}))
 		return
 	}
-	defer conn.Release()
-
-	var updatedCategory Category
-	err = conn.QueryRow(ctx,
-		"UPDATE categories SET name = $1, updated_at = now() WHERE id = $2 AND user_id = $3 RETURNING id, user_id, name, created_at, updated_at",
-		req.Name, categoryId, userId).Scan(&updatedCategory.Id, &updatedCategory.UserId, &updatedCategory.Name, &updatedCategory.CreatedAt, &updatedCategory.UpdatedAt)
 	if err != nil {
-		log.Printf("Failed to update category: %v", err)
-		http.Error(w, "Category not found or not authorized to update", http.StatusNotFound)
+		log.Printf("Failed to move category %v: %v", categoryId, err)
+		problem.Write(w, r, problem.Internal())
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(updatedCategory); err != nil {
-		log.Printf("Failed to encode response as JSON: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
+	writeJSON(w, http.StatusOK, toCategory(categoryDto))
 }
 
-func DeleteCategory(w http.ResponseWriter, r *http.Request) {
-	dbpool := database.GetDB()
+func (s *Server) DeleteCategory(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	userIdStr := r.Header.Get("userId")
+	userId := middleware.GetUserId(ctx)
 
-	userId, err := strconv.Atoi(userIdStr)
-	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+	categoryId, ok := urlParamId(r, "id")
+	if !ok {
+		problem.Write(w, r, problem.BadRequest("Invalid category ID"))
 		return
 	}
 
-	categoryIdStr := chi.URLParam(r, "id")
-	categoryId, err := strconv.Atoi(categoryIdStr)
+	deletion, err := parseCategoryDeletion(r)
 	if err != nil {
-		http.Error(w, "Invalid category ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.BadRequest(err.Error()))
 		return
 	}
 
-	conn, err := dbpool.Acquire(ctx)
-	if err != nil {
-		log.Printf("Failed to acquire a database connection: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	deletedDto, err := s.Categories.DeleteCategory(ctx, userId, categoryId, deletion)
+	if errors.Is(err, pgx.ErrNoRows) {
+		problem.Write(w, r, problem.NotFound("Category not found or not authorized to delete"))
 		return
 	}
-	defer conn.Release()
-
-	commandTag, err := conn.Exec(ctx, "DELETE FROM categories WHERE id = $1 AND user_id = $2", categoryId, userId)
-	if err != nil {
-		log.Printf("Failed to delete category: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	if errors.Is(err, repository.ErrCategoryInUse) {
+		problem.Write(w, r, problem.InUse(fmt.Sprintf("The category still has %d clothing items, reassign or trash them", deletedDto.ItemCount), deletedDto.ItemCount))
 		return
 	}
-	if commandTag.RowsAffected() == 0 {
-		http.Error(w, "Category not found or not authorized to delete", http.StatusNotFound)
+	if errors.Is(err, repository.ErrCategoryNotOwned) {
+		problem.Write(w, r, problem.BadRequest("reassign_to must be another category of yours"))
 		return
 	}
+	if err != nil {
+		log.Printf("Failed to delete category %v: %v", categoryId, err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	result := CategoryDeletion{OnItems: deletion.Items, ItemCount: deletedDto.ItemCount, BrokenOutfits: []OutfitRef{}}
+	if deletion.Items == repository.CategoryItemsReassign {
+		result.ReassignedTo = &deletion.ReassignTo
+	}
+	for _, outfitDto := range deletedDto.BrokenOutfits {
+		result.BrokenOutfits = append(result.BrokenOutfits, OutfitRef{Id: outfitDto.Id, Name: outfitDto.Name})
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// parseCategoryDeletion reads what to do with the items of a deleted category from the on_items and
+// reassign_to query parameters. Without on_items the items are reassigned if reassign_to is given,
+// otherwise the deletion is refused while there are any.
+func parseCategoryDeletion(r *http.Request) (repository.CategoryDeletionDto, error) {
+	query := r.URL.Query()
+	deletion := repository.CategoryDeletionDto{Items: query.Get("on_items")}
+
+	if value := query.Get("reassign_to"); value != "" {
+		reassignTo, err := strconv.Atoi(value)
+		if err != nil || reassignTo <= 0 {
+			return deletion, fmt.Errorf("Invalid reassign_to %q", value)
+		}
+		deletion.ReassignTo = reassignTo
+	}
+
+	switch deletion.Items {
+	case "":
+		deletion.Items = repository.CategoryItemsRefuse
+		if deletion.ReassignTo != 0 {
+			deletion.Items = repository.CategoryItemsReassign
+		}
+	case repository.CategoryItemsReassign:
+		if deletion.ReassignTo == 0 {
+			return deletion, errors.New("reassign_to is required with on_items=reassign")
+		}
+	case repository.CategoryItemsRefuse, repository.CategoryItemsTrash:
+		if deletion.ReassignTo != 0 {
+			return deletion, errors.New("reassign_to only goes with on_items=reassign")
+		}
+	default:
+		return deletion, fmt.Errorf("on_items must be one of refuse, reassign or trash, not %q", deletion.Items)
+	}
 
-	w.WriteHeader(http.StatusNoContent)
+	return deletion, nil
+}
+
+func toCategory(categoryDto repository.CategoryDto) Category {
+	return Category{
+		Id:        categoryDto.Id,
+		UserId:    categoryDto.UserId,
+		ParentId:  categoryDto.ParentId,
+		Name:      categoryDto.Name,
+		CreatedAt: categoryDto.CreatedAt,
+		UpdatedAt: categoryDto.UpdatedAt,
+	}
 }
@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"com.fukubox/repository"
+	"github.com/go-playground/validator"
+)
+
+// clothColors is the palette items are described with, so colors can be filtered on
+var clothColors = []string{
+	"black", "white", "grey", "beige", "brown", "red", "orange", "yellow",
+	"green", "blue", "navy", "purple", "pink", "gold", "silver", "multicolor",
+}
+
+var clothSeasons = []string{"spring", "summer", "autumn", "winter", "all_season"}
+
+const dateLayout = "2006-01-02"
+
+// Date is a calendar day, written as YYYY-MM-DD in JSON
+type Date struct {
+	time.Time
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Format(dateLayout))
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	parsed, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return fmt.Errorf("date %q must be formatted as YYYY-MM-DD", value)
+	}
+
+	d.Time = parsed
+	return nil
+}
+
+// latestTimezone is UTC+14, where every day starts first
+const latestTimezone = 14 * time.Hour
+
+// inFuture reports whether d is after today. A date doesn't say where its day was, so d is only
+// in the future once it hasn't started in any timezone yet.
+func (d Date) inFuture() bool {
+	today, _ := time.Parse(dateLayout, time.Now().UTC().Add(latestTimezone).Format(dateLayout))
+	return d.After(today)
+}
+
+// ClothAttributes are the optional descriptive fields of a clothing item
+type ClothAttributes struct {
+	Name           *string  `json:"name" validate:"omitempty,max=255"`
+	Brand          *string  `json:"brand" validate:"omitempty,max=255"`
+	Size           *string  `json:"size" validate:"omitempty,max=32"`
+	PrimaryColor   *string  `json:"primary_color" validate:"omitempty,cloth_color"`
+	SecondaryColor *string  `json:"secondary_color" validate:"omitempty,cloth_color"`
+	Material       *string  `json:"material" validate:"omitempty,max=64"`
+	Season         *string  `json:"season" validate:"omitempty,cloth_season"`
+	PurchasePrice  *float64 `json:"purchase_price" validate:"omitempty,gte=0,lt=10000000000"`
+	Currency       *string  `json:"currency" validate:"omitempty,len=3,alpha"`
+	PurchaseDate   *Date    `json:"purchase_date"`
+	Notes          *string  `json:"notes" validate:"omitempty,max=4000"`
+}
+
+// newClothValidator returns a validator that also knows the cloth_color and cloth_season rules
+func newClothValidator() *validator.Validate {
+	validate := newValidator()
+	validate.RegisterValidation("cloth_color", func(fl validator.FieldLevel) bool {
+		return slices.Contains(clothColors, fl.Field().String())
+	})
+	validate.RegisterValidation("cloth_season", func(fl validator.FieldLevel) bool {
+		return slices.Contains(clothSeasons, fl.Field().String())
+	})
+	return validate
+}
+
+// check adds the broken rules that span several attributes, which struct tags can't express, to errs
+func (attributes ClothAttributes) check(errs fieldErrors) {
+	if (attributes.PurchasePrice == nil) != (attributes.Currency == nil) {
+		errs["purchase_price"] = "must be set together with currency"
+		errs["currency"] = "must be set together with purchase_price"
+	}
+	if attributes.PurchaseDate != nil && attributes.PurchaseDate.inFuture() {
+		errs["purchase_date"] = "can't be in the future"
+	}
+}
+
+func (attributes ClothAttributes) toDto() repository.ClothAttributesDto {
+	dto := repository.ClothAttributesDto{
+		Name:           attributes.Name,
+		Brand:          attributes.Brand,
+		Size:           attributes.Size,
+		PrimaryColor:   attributes.PrimaryColor,
+		SecondaryColor: attributes.SecondaryColor,
+		Material:       attributes.Material,
+		Season:         attributes.Season,
+		PurchasePrice:  attributes.PurchasePrice,
+		Notes:          attributes.Notes,
+	}
+	if attributes.Currency != nil {
+		currency := strings.ToUpper(*attributes.Currency)
+		dto.Currency = &currency
+	}
+	if attributes.PurchaseDate != nil {
+		dto.PurchaseDate = &attributes.PurchaseDate.Time
+	}
+	return dto
+}
+
+func toClothAttributes(dto repository.ClothAttributesDto) ClothAttributes {
+	attributes := ClothAttributes{
+		Name:           dto.Name,
+		Brand:          dto.Brand,
+		Size:           dto.Size,
+		PrimaryColor:   dto.PrimaryColor,
+		SecondaryColor: dto.SecondaryColor,
+		Material:       dto.Material,
+		Season:         dto.Season,
+		PurchasePrice:  dto.PurchasePrice,
+		Currency:       dto.Currency,
+		Notes:          dto.Notes,
+	}
+	if dto.PurchaseDate != nil {
+		attributes.PurchaseDate = &Date{*dto.PurchaseDate}
+	}
+	return attributes
+}
+
+// ClothAttributesPatch changes the attributes a merge patch mentions, null clears one
+type ClothAttributesPatch struct {
+	Name           Optional[string]
+	Brand          Optional[string]
+	Size           Optional[string]
+	PrimaryColor   Optional[string]
+	SecondaryColor Optional[string]
+	Material       Optional[string]
+	Season         Optional[string]
+	PurchasePrice  Optional[float64]
+	Currency       Optional[string]
+	PurchaseDate   Optional[Date]
+	Notes          Optional[string]
+}
+
+func (patch *ClothAttributesPatch) fields() map[string]patchField {
+	return map[string]patchField{
+		"name":            &patch.Name,
+		"brand":           &patch.Brand,
+		"size":            &patch.Size,
+		"primary_color":   &patch.PrimaryColor,
+		"secondary_color": &patch.SecondaryColor,
+		"material":        &patch.Material,
+		"season":          &patch.Season,
+		"purchase_price":  &patch.PurchasePrice,
+		"currency":        &patch.Currency,
+		"purchase_date":   &patch.PurchaseDate,
+		"notes":           &patch.Notes,
+	}
+}
+
+// check adds what is wrong with the values the patch sets to errs. Whether purchase_price and
+// currency stay paired depends on the stored item, so the repository checks that.
+func (patch ClothAttributesPatch) check(errs fieldErrors) {
+	attributes := ClothAttributes{
+		Name:           patch.Name.Value,
+		Brand:          patch.Brand.Value,
+		Size:           patch.Size.Value,
+		PrimaryColor:   patch.PrimaryColor.Value,
+		SecondaryColor: patch.SecondaryColor.Value,
+		Material:       patch.Material.Value,
+		Season:         patch.Season.Value,
+		PurchasePrice:  patch.PurchasePrice.Value,
+		Currency:       patch.Currency.Value,
+		PurchaseDate:   patch.PurchaseDate.Value,
+		Notes:          patch.Notes.Value,
+	}
+	if err := newClothValidator().Struct(attributes); err != nil {
+		addValidationErrors(errs, err)
+	}
+
+	if attributes.PurchaseDate != nil && attributes.PurchaseDate.inFuture() {
+		errs["purchase_date"] = "can't be in the future"
+	}
+}
+
+func (patch ClothAttributesPatch) toDto() repository.ClothAttributesPatchDto {
+	dto := repository.ClothAttributesPatchDto{
+		Name:           patch.Name.dto(),
+		Brand:          patch.Brand.dto(),
+		Size:           patch.Size.dto(),
+		PrimaryColor:   patch.PrimaryColor.dto(),
+		SecondaryColor: patch.SecondaryColor.dto(),
+		Material:       patch.Material.dto(),
+		Season:         patch.Season.dto(),
+		PurchasePrice:  patch.PurchasePrice.dto(),
+		Currency:       patch.Currency.dto(),
+		Notes:          patch.Notes.dto(),
+	}
+	if patch.Currency.Value != nil {
+		currency := strings.ToUpper(*patch.Currency.Value)
+		dto.Currency.Value = &currency
+	}
+	dto.PurchaseDate.Set = patch.PurchaseDate.Set
+	if patch.PurchaseDate.Value != nil {
+		dto.PurchaseDate.Value = &patch.PurchaseDate.Value.Time
+	}
+	return dto
+}
+
+// parseClothAttributesForm reads the attributes sent as multipart form fields
+func parseClothAttributesForm(form *multipart.Form) (ClothAttributes, error) {
+	var attributes ClothAttributes
+
+	text := map[string]**string{
+		"name":            &attributes.Name,
+		"brand":           &attributes.Brand,
+		"size":            &attributes.Size,
+		"primary_color":   &attributes.PrimaryColor,
+		"secondary_color": &attributes.SecondaryColor,
+		"material":        &attributes.Material,
+		"season":          &attributes.Season,
+		"currency":        &attributes.Currency,
+		"notes":           &attributes.Notes,
+	}
+	for field, target := range text {
+		if values := form.Value[field]; len(values) > 0 {
+			*target = &values[0]
+		}
+	}
+
+	if values := form.Value["purchase_price"]; len(values) > 0 {
+		price, err := strconv.ParseFloat(values[0], 64)
+		if err != nil {
+			return ClothAttributes{}, fmt.Errorf("Invalid purchase_price %q", values[0])
+		}
+		attributes.PurchasePrice = &price
+	}
+
+	if values := form.Value["purchase_date"]; len(values) > 0 {
+		date, err := time.Parse(dateLayout, values[0])
+		if err != nil {
+			return ClothAttributes{}, fmt.Errorf("Invalid purchase_date %q, expected YYYY-MM-DD", values[0])
+		}
+		attributes.PurchaseDate = &Date{date}
+	}
+
+	return attributes, nil
+}
+
+// parseClothAttributeFilters reads the attribute filters of GET /clothes into filter
+func parseClothAttributeFilters(query url.Values, filter *repository.ClothFilterDto) error {
+	text := map[string]**string{
+		"name":     &filter.Name,
+		"notes":    &filter.Notes,
+		"brand":    &filter.Brand,
+		"size":     &filter.Size,
+		"material": &filter.Material,
+		"currency": &filter.Currency,
+	}
+	for param, target := range text {
+		if value := query.Get(param); value != "" {
+			*target = &value
+		}
+	}
+
+	choices := []struct {
+		param   string
+		allowed []string
+		target  **string
+	}{
+		{"color", clothColors, &filter.Color},
+		{"primary_color", clothColors, &filter.PrimaryColor},
+		{"secondary_color", clothColors, &filter.SecondaryColor},
+		{"season", clothSeasons, &filter.Season},
+	}
+	for _, choice := range choices {
+		if value := query.Get(choice.param); value != "" {
+			if !slices.Contains(choice.allowed, value) {
+				return fmt.Errorf("%v must be one of %v", choice.param, strings.Join(choice.allowed, ", "))
+			}
+			*choice.target = &value
+		}
+	}
+
+	prices := map[string]**float64{"min_price": &filter.MinPrice, "max_price": &filter.MaxPrice}
+	for param, target := range prices {
+		if value := query.Get(param); value != "" {
+			price, err := strconv.ParseFloat(value, 64)
+			if err != nil || price < 0 {
+				return fmt.Errorf("%v must be a non-negative number", param)
+			}
+			*target = &price
+		}
+	}
+
+	dates := map[string]**time.Time{"purchased_from": &filter.PurchasedFrom, "purchased_to": &filter.PurchasedTo}
+	for param, target := range dates {
+		if value := query.Get(param); value != "" {
+			date, err := time.Parse(dateLayout, value)
+			if err != nil {
+				return fmt.Errorf("%v must be formatted as YYYY-MM-DD", param)
+			}
+			*target = &date
+		}
+	}
+
+	return nil
+}
@@ -2,27 +2,35 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"mime"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
-	"com.fukubox/database"
+	"com.fukubox/middleware"
+	"com.fukubox/problem"
 	"com.fukubox/repository"
-	"github.com/go-chi/chi"
-	"github.com/go-playground/validator"
+	"github.com/jackc/pgx/v5"
 )
 
 type Cloth struct {
-	Id         int       `json:"id"`
-	UserId     int       `json:"user_id"`
-	CategoryId int       `json:"category_id"`
-	ImageUrl   string    `json:"image_url"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
-	Tags       []Tag     `json:"tags"`
+	Id         int `json:"id"`
+	UserId     int `json:"user_id"`
+	CategoryId int `json:"category_id"`
+	ClothAttributes
+	ImageUrl  string            `json:"image_url"`
+	CutoutUrl *string           `json:"cutout_url"`
+	Images    map[string]string `json:"images"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	Tags      []Tag             `json:"tags"`
+	// TimesWorn and LastWornAt are derived from the wear log
+	TimesWorn  int   `json:"times_worn"`
+	LastWornAt *Date `json:"last_worn_at"`
 }
 
 type Tag struct {
@@ -30,276 +38,547 @@ type Tag struct {
 	Name string `json:"name"`
 }
 
+// ClothDeletion is the response to deleting a clothing item, listing the outfits that lost it
+type ClothDeletion struct {
+	BrokenOutfits []OutfitRef `json:"broken_outfits"`
+}
+
 type ClothEdit struct {
-	CategoryId int    `json:"category_id" validate:"required,gt=0"`
-	ImageUrl   string `json:"image_url" validate:"required"`
-	TagIds     []int  `json:"tag_ids"`
+	CategoryId int `json:"category_id" validate:"required,gt=0"`
+	ClothAttributes
+	ImageUrl string `json:"image_url" validate:"required"`
+	TagIds   []int  `json:"tag_ids"`
 }
 
-func GetClothes(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+// ClothPatch is the merge patch body of PATCH /clothes/{id}
+type ClothPatch struct {
+	CategoryId Optional[int]
+	ClothAttributesPatch
+	ImageUrl Optional[string]
+	TagIds   Optional[[]int]
+}
 
-	userId := r.Header.Get("userId")
+func (patch *ClothPatch) fields() map[string]patchField {
+	fields := patch.ClothAttributesPatch.fields()
+	fields["category_id"] = &patch.CategoryId
+	fields["image_url"] = &patch.ImageUrl
+	fields["tag_ids"] = &patch.TagIds
+	return fields
+}
 
-	clothesDto, err := repository.GetClothesByUser(ctx, userId)
-	if err != nil {
-		log.Printf("Failed to get clothes: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-	}
-
-	clothes := []Cloth{}
-	for _, cloth := range clothesDto {
-		newCloth := Cloth{
-			Id:         cloth.Id,
-			UserId:     cloth.UserId,
-			CategoryId: cloth.CategoryId,
-			ImageUrl:   cloth.ImageUrl,
-			CreatedAt:  cloth.CreatedAt,
-			UpdatedAt:  cloth.UpdatedAt,
-		}
-		err := json.Unmarshal([]byte(cloth.TagsJson), &newCloth.Tags)
-		if err != nil {
-			log.Printf("Failed to unmarshall ClothDto.TagsJson: \n\ttagString:%v \n\tErr:%v", cloth.TagsJson, err)
-		}
+func (patch ClothPatch) check(errs fieldErrors) {
+	patch.ClothAttributesPatch.check(errs)
 
-		clothes = append(clothes, newCloth)
+	if patch.CategoryId.null() {
+		errs["category_id"] = "can't be null"
+	} else if patch.CategoryId.Value != nil && *patch.CategoryId.Value <= 0 {
+		errs["category_id"] = "must be greater than 0"
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(clothes); err != nil {
-		log.Printf("Failed to encode response as JSON: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
+	checkNotEmpty(errs, "image_url", patch.ImageUrl)
+}
+
+// ClothUpload holds the form fields sent alongside a multipart image upload
+type ClothUpload struct {
+	CategoryId int `validate:"required,gt=0"`
+	ClothAttributes
+	TagIds []int
+}
+
+// ClothPage is one page of a clothes listing; NextCursor is null on the last page
+type ClothPage struct {
+	Items      []Cloth `json:"items"`
+	NextCursor *string `json:"next_cursor"`
+	Total      int     `json:"total"`
 }
 
-func GetClothesById(w http.ResponseWriter, r *http.Request) {
+const defaultClothesLimit = 50
+const maxClothesLimit = 100
+
+func (s *Server) GetClothes(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	userId := r.Header.Get("userId")
-	clothIdStr := chi.URLParam(r, "id")
+	userId := middleware.GetUserId(ctx)
 
-	_, err := strconv.Atoi(clothIdStr)
+	filter, err := parseClothFilter(r)
 	if err != nil {
-		log.Printf("Invalid or empty clothing id: %v", clothIdStr)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		problem.Write(w, r, problem.BadRequest(err.Error()))
 		return
 	}
 
-	clothDto, err := repository.GetClothesByUserAndId(ctx, userId, clothIdStr)
+	pageDto, err := s.Clothes.GetClothesPageByUser(ctx, userId, filter)
 	if err != nil {
-		log.Printf("Failed to get cloth by id: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("Failed to get clothes: %v", err)
+		problem.Write(w, r, problem.Internal())
+		return
 	}
 
-	cloth := Cloth{
-		Id:         clothDto.Id,
-		UserId:     clothDto.UserId,
-		CategoryId: clothDto.CategoryId,
-		ImageUrl:   clothDto.ImageUrl,
-		CreatedAt:  clothDto.CreatedAt,
-		UpdatedAt:  clothDto.UpdatedAt,
+	page := ClothPage{Items: []Cloth{}, Total: pageDto.Total}
+	for _, cloth := range pageDto.Items {
+		page.Items = append(page.Items, toCloth(cloth))
+	}
+	if pageDto.Next != nil {
+		nextCursor := encodeClothCursor(*pageDto.Next)
+		page.NextCursor = &nextCursor
 	}
 
-	err = json.Unmarshal([]byte(clothDto.TagsJson), &cloth.Tags)
-	if err != nil {
-		log.Printf("Failed to unmarshall ClothDto.TagsJson: \n\ttagString:%v \n\tErr:%v", clothDto.TagsJson, err)
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (s *Server) GetClothesById(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	clothId, ok := urlParamId(r, "id")
+	if !ok {
+		problem.Write(w, r, problem.BadRequest("Invalid clothing item ID"))
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(cloth); err != nil {
-		log.Printf("Failed to encode response as JSON: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	clothDto, err := s.Clothes.GetClothesByUserAndId(ctx, userId, clothId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		problem.Write(w, r, problem.NotFound("Clothing item not found"))
 		return
 	}
+	if err != nil {
+		log.Printf("Failed to get cloth by id: %v", err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toCloth(clothDto))
 }
 
-func CreateClothes(w http.ResponseWriter, r *http.Request) {
+func (s *Server) CreateClothes(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	userId := r.Header.Get("userId")
+	userId := middleware.GetUserId(ctx)
+
+	if isMultipartForm(r) {
+		s.createClothesFromUpload(w, r)
+		return
+	}
 
 	var req ClothEdit
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		log.Printf("Failed to decode request as handlers.ClothEdit: %v", err)
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+		problem.Write(w, r, problem.BadRequest("Invalid request"))
 		return
 	}
 
-	validate := validator.New()
+	validate := newClothValidator()
 
-	err := validate.Struct(req)
-	if err != nil {
-		var validationErrors strings.Builder
-		for _, err := range err.(validator.ValidationErrors) {
-			validationErrors.WriteString(fmt.Sprintf("%s is %s with type %s\n", err.StructField(), err.Tag(), err.Type()))
-		}
-
-		http.Error(w, validationErrors.String(), http.StatusBadRequest)
+	errs := fieldErrors{}
+	if err := validate.Struct(req); err != nil {
+		addValidationErrors(errs, err)
+	}
+	req.ClothAttributes.check(errs)
+	checkImageUrl(errs, "image_url", userId, req.ImageUrl)
+	if len(errs) > 0 {
+		problem.Write(w, r, problem.InvalidFields(errs))
 		return
 	}
 
-	clothId, err := repository.CreateClothWithTags(ctx, userId, repository.ClothEditDto{
-		CategoryId: req.CategoryId,
-		ImageUrl:   req.ImageUrl,
+	clothId, err := s.Clothes.CreateClothWithTags(ctx, userId, repository.ClothEditDto{
+		CategoryId:         req.CategoryId,
+		ClothAttributesDto: req.ClothAttributes.toDto(),
+		ImageUrl:           req.ImageUrl,
 	}, req.TagIds)
+	if errors.Is(err, repository.ErrCategoryNotOwned) {
+		problem.Write(w, r, problem.InvalidFields(fieldErrors{"category_id": "is not a category of yours"}))
+		return
+	}
+	if errors.Is(err, repository.ErrTagNotOwned) {
+		problem.Write(w, r, problem.InvalidFields(fieldErrors{"tag_ids": "contains an unknown tag id"}))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to create cloth: %v", err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
 
-	clothDto, err := repository.GetClothesByUserAndId(ctx, userId, strconv.Itoa(clothId))
+	clothDto, err := s.Clothes.GetClothesByUserAndId(ctx, userId, clothId)
 	if err != nil {
 		log.Printf("Failed to get cloth by id %v: %v", clothId, err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		problem.Write(w, r, problem.Internal())
 		return
 	}
 
-	cloth := Cloth{
-		Id:         clothDto.Id,
-		UserId:     clothDto.UserId,
-		CategoryId: clothDto.CategoryId,
-		ImageUrl:   clothDto.ImageUrl,
-		CreatedAt:  clothDto.CreatedAt,
-		UpdatedAt:  clothDto.UpdatedAt,
+	writeJSON(w, http.StatusCreated, toCloth(clothDto))
+}
+
+func (s *Server) UpdateClothes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	clothId, ok := urlParamId(r, "id")
+	if !ok {
+		problem.Write(w, r, problem.BadRequest("Invalid clothing item ID"))
+		return
 	}
 
-	err = json.Unmarshal([]byte(clothDto.TagsJson), &cloth.Tags)
-	if err != nil {
-		log.Printf("Failed to unmarshall ClothDto.TagsJson: \n\ttagString:%v \n\tErr:%v", clothDto.TagsJson, err)
+	var patch ClothPatch
+	errs, decodeProblem := decodeMergePatch(r, patch.fields())
+	if decodeProblem != nil {
+		problem.Write(w, r, decodeProblem)
+		return
+	}
+	patch.check(errs)
+	if patch.ImageUrl.Value != nil {
+		checkImageUrl(errs, "image_url", userId, *patch.ImageUrl.Value)
+	}
+	if len(errs) > 0 {
+		problem.Write(w, r, problem.InvalidFields(errs))
+		return
 	}
 
-	// Query for new item to return
+	dto := repository.ClothPatchDto{
+		CategoryId:              patch.CategoryId.Value,
+		ClothAttributesPatchDto: patch.ClothAttributesPatch.toDto(),
+		ImageUrl:                patch.ImageUrl.Value,
+	}
+	// a null tag_ids removes every tag
+	if patch.TagIds.Set {
+		dto.TagIds = &[]int{}
+		if patch.TagIds.Value != nil {
+			dto.TagIds = patch.TagIds.Value
+		}
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(cloth); err != nil {
-		log.Printf("Failed to encode response as JSON: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	clothDto, replaced, err := s.Clothes.UpdateCloth(ctx, userId, clothId, dto)
+	if errors.Is(err, pgx.ErrNoRows) {
+		problem.Write(w, r, problem.NotFound("Clothing item not found or not authorized to update"))
+		return
+	}
+	if errors.Is(err, repository.ErrCategoryNotOwned) {
+		problem.Write(w, r, problem.InvalidFields(fieldErrors{"category_id": "is not a category of yours"}))
+		return
+	}
+	if errors.Is(err, repository.ErrTagNotOwned) {
+		problem.Write(w, r, problem.InvalidFields(fieldErrors{"tag_ids": "contains an unknown tag id"}))
+		return
+	}
+	if errors.Is(err, repository.ErrPriceWithoutCurrency) {
+		problem.Write(w, r, problem.InvalidFields(fieldErrors{
+			"purchase_price": "must be set together with currency",
+			"currency":       "must be set together with purchase_price",
+		}))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to update cloth %v: %v", clothId, err)
+		problem.Write(w, r, problem.Internal())
 		return
 	}
+	deleteStoredImages(ctx, userId, replaced)
+
+	writeJSON(w, http.StatusOK, toCloth(clothDto))
 }
 
-func UpdateClothes(w http.ResponseWriter, r *http.Request) {
+func (s *Server) DeleteClothes(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	userId := r.Header.Get("userId")
+	userId := middleware.GetUserId(ctx)
 
-	clothIdStr := chi.URLParam(r, "id")
-	clothId, err := strconv.Atoi(clothIdStr)
-	if err != nil {
-		http.Error(w, "Invalid clothing item ID", http.StatusBadRequest)
+	clothId, ok := urlParamId(r, "id")
+	if !ok {
+		problem.Write(w, r, problem.BadRequest("Invalid clothing item ID"))
 		return
 	}
 
-	var req ClothEdit
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	brokenOutfitsDto, err := s.Clothes.DeleteCloth(ctx, userId, clothId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		problem.Write(w, r, problem.NotFound("Clothing item not found or not authorized to delete"))
 		return
 	}
-	if req.CategoryId == 0 || req.ImageUrl == "" {
-		http.Error(w, "Category ID and Image URL are required", http.StatusBadRequest)
+	if err != nil {
+		log.Printf("Failed to delete cloth %v: %v", clothId, err)
+		problem.Write(w, r, problem.Internal())
 		return
 	}
 
-	conn := database.AcquireConnection(ctx)
-	if conn == nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+	deletion := ClothDeletion{BrokenOutfits: []OutfitRef{}}
+	for _, outfitDto := range brokenOutfitsDto {
+		deletion.BrokenOutfits = append(deletion.BrokenOutfits, OutfitRef{Id: outfitDto.Id, Name: outfitDto.Name})
 	}
-	defer conn.Release()
 
-	var updatedCloth Cloth
-	err = conn.QueryRow(ctx,
-		`UPDATE clothing_items SET category_id = $1, image_url = $2,
-		updated_at = now() WHERE id = $3 AND user_id = $4
-		RETURNING id, user_id, category_id, image_url, created_at, updated_at`,
-		req.CategoryId, req.ImageUrl, clothId, userId).Scan(
-		&updatedCloth.Id, &updatedCloth.UserId, &updatedCloth.CategoryId, &updatedCloth.ImageUrl,
-		&updatedCloth.CreatedAt, &updatedCloth.UpdatedAt)
+	writeJSON(w, http.StatusOK, deletion)
+}
+
+// createClothesFromUpload creates a clothing item from a multipart form, storing the image and filling image_url
+func (s *Server) createClothesFromUpload(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	upload, err := parseImageUpload(w, r)
 	if err != nil {
-		log.Printf("Failed to update clothing item: %v", err)
-		http.Error(w, "Clothing item not found or not authorized to update", http.StatusNotFound)
+		writeUploadError(w, r, err)
 		return
 	}
 
-	_, err = conn.Exec(ctx, "DELETE FROM clothing_item_tags WHERE clothing_item_id = $1", clothId)
+	req, err := parseClothUploadForm(r)
 	if err != nil {
-		log.Printf("Failed to delete existing tags: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		problem.Write(w, r, problem.BadRequest(err.Error()))
 		return
 	}
 
-	for _, tagId := range req.TagIds {
-		_, err := conn.Exec(ctx, "INSERT INTO clothing_item_tags (clothing_item_id, tag_id) VALUES ($1, $2)", clothId, tagId)
-		if err != nil {
-			log.Printf("Failed to insert new tag associations: %v", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return
-		}
+	validate := newClothValidator()
+
+	errs := fieldErrors{}
+	if err := validate.Struct(req); err != nil {
+		addValidationErrors(errs, err)
+	}
+	req.ClothAttributes.check(errs)
+	if len(errs) > 0 {
+		problem.Write(w, r, problem.InvalidFields(errs))
+		return
 	}
 
-	tagRows, err := conn.Query(ctx, `SELECT t.id, t.name FROM tags t
-		JOIN clothing_item_tags cit ON t.id = cit.tag_id
-		WHERE cit.clothing_item_id = $1`, clothId)
+	images, err := storeClothImages(ctx, userId, upload)
 	if err != nil {
-		log.Printf("Failed to query tags: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("Failed to store clothing images: %v", err)
+		problem.Write(w, r, problem.Internal())
 		return
 	}
-	defer tagRows.Close()
 
-	var tags []Tag
-	for tagRows.Next() {
-		var tag Tag
-		if err := tagRows.Scan(&tag.Id, &tag.Name); err != nil {
-			log.Printf("Failed to scan tag: %v", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return
-		}
-		tags = append(tags, tag)
+	clothId, err := s.Clothes.CreateClothWithTags(ctx, userId, repository.ClothEditDto{
+		CategoryId:         req.CategoryId,
+		ClothAttributesDto: req.ClothAttributes.toDto(),
+		ImageUrl:           images.ImageUrl,
+		CutoutUrl:          images.CutoutUrl,
+		Variants:           images.Variants,
+	}, req.TagIds)
+	if errors.Is(err, repository.ErrCategoryNotOwned) {
+		deleteStoredImages(ctx, userId, images)
+		problem.Write(w, r, problem.InvalidFields(fieldErrors{"category_id": "is not a category of yours"}))
+		return
+	}
+	if errors.Is(err, repository.ErrTagNotOwned) {
+		deleteStoredImages(ctx, userId, images)
+		problem.Write(w, r, problem.InvalidFields(fieldErrors{"tag_ids": "contains an unknown tag id"}))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to create cloth: %v", err)
+		deleteStoredImages(ctx, userId, images)
+		problem.Write(w, r, problem.Internal())
+		return
 	}
-	updatedCloth.Tags = tags
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(updatedCloth); err != nil {
-		log.Printf("Failed to encode response as JSON: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	clothDto, err := s.Clothes.GetClothesByUserAndId(ctx, userId, clothId)
+	if err != nil {
+		log.Printf("Failed to get cloth by id %v: %v", clothId, err)
+		problem.Write(w, r, problem.Internal())
 		return
 	}
+
+	writeJSON(w, http.StatusCreated, toCloth(clothDto))
 }
 
-func DeleteClothes(w http.ResponseWriter, r *http.Request) {
+// UploadClothImage replaces the image (and cutout) of an existing clothing item with a multipart upload
+func (s *Server) UploadClothImage(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	userId := r.Header.Get("userId")
+	userId := middleware.GetUserId(ctx)
 
-	clothIdStr := chi.URLParam(r, "id")
-	clothId, err := strconv.Atoi(clothIdStr)
-	if err != nil {
-		http.Error(w, "Invalid clothing item ID", http.StatusBadRequest)
+	clothId, ok := urlParamId(r, "id")
+	if !ok {
+		problem.Write(w, r, problem.BadRequest("Invalid clothing item ID"))
 		return
 	}
 
-	conn := database.AcquireConnection(ctx)
-	if conn == nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	upload, err := parseImageUpload(w, r)
+	if err != nil {
+		writeUploadError(w, r, err)
 		return
 	}
-	defer conn.Release()
 
-	_, err = conn.Exec(ctx, "DELETE FROM clothing_item_tags WHERE clothing_item_id = $1", clothId)
+	images, err := storeClothImages(ctx, userId, upload)
 	if err != nil {
-		log.Printf("Failed to delete associated tags: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("Failed to store clothing images: %v", err)
+		problem.Write(w, r, problem.Internal())
 		return
 	}
 
-	commandTag, err := conn.Exec(ctx, "DELETE FROM clothing_items WHERE id = $1 AND user_id = $2", clothId, userId)
+	previous, err := s.Clothes.UpdateClothImages(ctx, userId, clothId, images)
 	if err != nil {
-		log.Printf("Failed to delete clothing item: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		deleteStoredImages(ctx, userId, images)
+		if errors.Is(err, pgx.ErrNoRows) {
+			problem.Write(w, r, problem.NotFound("Clothing item not found or not authorized to update"))
+			return
+		}
+		log.Printf("Failed to update clothing image: %v", err)
+		problem.Write(w, r, problem.Internal())
 		return
 	}
-	if commandTag.RowsAffected() == 0 {
-		http.Error(w, "Clothing item not found or not authorized to delete", http.StatusNotFound)
+	deleteStoredImages(ctx, userId, previous)
+
+	clothDto, err := s.Clothes.GetClothesByUserAndId(ctx, userId, clothId)
+	if err != nil {
+		log.Printf("Failed to get cloth by id %v: %v", clothId, err)
+		problem.Write(w, r, problem.Internal())
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	writeJSON(w, http.StatusOK, toCloth(clothDto))
+}
+
+func parseClothUploadForm(r *http.Request) (ClothUpload, error) {
+	var req ClothUpload
+
+	if value := r.FormValue("category_id"); value != "" {
+		categoryId, err := strconv.Atoi(value)
+		if err != nil {
+			return ClothUpload{}, fmt.Errorf("Invalid category_id %q", value)
+		}
+		req.CategoryId = categoryId
+	}
+
+	attributes, err := parseClothAttributesForm(r.MultipartForm)
+	if err != nil {
+		return ClothUpload{}, err
+	}
+	req.ClothAttributes = attributes
+
+	for _, value := range r.MultipartForm.Value["tag_ids"] {
+		tagId, err := strconv.Atoi(value)
+		if err != nil {
+			return ClothUpload{}, fmt.Errorf("Invalid tag_ids value %q", value)
+		}
+		req.TagIds = append(req.TagIds, tagId)
+	}
+
+	return req, nil
+}
+
+func isMultipartForm(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "multipart/form-data"
+}
+
+// parseClothFilter reads the GET /clothes query parameters:
+// category_id, include_subcategories (true or false), tag_ids (comma separated), tag_match (any or all), the attribute filters,
+// sort (created_at or updated_at), order (asc or desc), limit and cursor
+func parseClothFilter(r *http.Request) (repository.ClothFilterDto, error) {
+	query := r.URL.Query()
+
+	filter := repository.ClothFilterDto{
+		SortBy:     repository.ClothSortCreatedAt,
+		Descending: true,
+		Limit:      defaultClothesLimit,
+	}
+
+	if value := query.Get("category_id"); value != "" {
+		categoryId, err := strconv.Atoi(value)
+		if err != nil || categoryId <= 0 {
+			return filter, errors.New("category_id must be a positive integer")
+		}
+		filter.CategoryId = &categoryId
+	}
+
+	switch query.Get("include_subcategories") {
+	case "", "false":
+	case "true":
+		filter.IncludeSubcategories = true
+	default:
+		return filter, errors.New("include_subcategories must be true or false")
+	}
+
+	for _, value := range query["tag_ids"] {
+		for _, field := range strings.Split(value, ",") {
+			tagId, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil || tagId <= 0 {
+				return filter, errors.New("tag_ids must be a comma separated list of positive integers")
+			}
+			filter.TagIds = append(filter.TagIds, tagId)
+		}
+	}
+
+	if err := parseClothAttributeFilters(query, &filter); err != nil {
+		return filter, err
+	}
+
+	switch query.Get("tag_match") {
+	case "", "any":
+	case "all":
+		filter.MatchAllTags = true
+	default:
+		return filter, errors.New("tag_match must be any or all")
+	}
+
+	switch sortBy := query.Get("sort"); sortBy {
+	case "":
+	case repository.ClothSortCreatedAt, repository.ClothSortUpdatedAt:
+		filter.SortBy = sortBy
+	default:
+		return filter, errors.New("sort must be created_at or updated_at")
+	}
+
+	switch query.Get("order") {
+	case "", "desc":
+	case "asc":
+		filter.Descending = false
+	default:
+		return filter, errors.New("order must be asc or desc")
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxClothesLimit {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxClothesLimit)
+		}
+		filter.Limit = limit
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := decodeClothCursor(value)
+		if err != nil {
+			return filter, err
+		}
+		// a cursor only marks a position within the ordering it was issued for
+		if cursor.SortBy != filter.SortBy {
+			return filter, errors.New("cursor does not match sort")
+		}
+		filter.After = &cursor
+	}
+
+	return filter, nil
+}
+
+func toCloth(clothDto repository.ClothDto) Cloth {
+	cloth := Cloth{
+		Id:              clothDto.Id,
+		UserId:          clothDto.UserId,
+		CategoryId:      clothDto.CategoryId,
+		ClothAttributes: toClothAttributes(clothDto.ClothAttributesDto),
+		ImageUrl:        clothDto.ImageUrl,
+		CutoutUrl:       clothDto.CutoutUrl,
+		Images:          clothDto.Variants,
+		CreatedAt:       clothDto.CreatedAt,
+		UpdatedAt:       clothDto.UpdatedAt,
+		TimesWorn:       clothDto.TimesWorn,
+	}
+	if clothDto.LastWornAt != nil {
+		cloth.LastWornAt = &Date{*clothDto.LastWornAt}
+	}
+
+	// items created from an external image_url only have the one size
+	if len(cloth.Images) == 0 {
+		full := cloth.ImageUrl
+		if cloth.CutoutUrl != nil {
+			full = *cloth.CutoutUrl
+		}
+		cloth.Images = map[string]string{fullVariant: full}
+	}
+
+	err := json.Unmarshal([]byte(clothDto.TagsJson), &cloth.Tags)
+	if err != nil {
+		log.Printf("Failed to unmarshall ClothDto.TagsJson: \n\ttagString:%v \n\tErr:%v", clothDto.TagsJson, err)
+	}
+
+	return cloth
 }
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"com.fukubox/repository"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// clothCursor is the JSON behind the opaque cursor handed to clients
+type clothCursor struct {
+	SortBy    string    `json:"s"`
+	SortValue time.Time `json:"v"`
+	Id        int       `json:"i"`
+}
+
+func encodeClothCursor(cursor repository.ClothCursorDto) string {
+	data, _ := json.Marshal(clothCursor{SortBy: cursor.SortBy, SortValue: cursor.SortValue, Id: cursor.Id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeClothCursor(value string) (repository.ClothCursorDto, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return repository.ClothCursorDto{}, errInvalidCursor
+	}
+
+	var cursor clothCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Id <= 0 {
+		return repository.ClothCursorDto{}, errInvalidCursor
+	}
+
+	return repository.ClothCursorDto{SortBy: cursor.SortBy, SortValue: cursor.SortValue, Id: cursor.Id}, nil
+}
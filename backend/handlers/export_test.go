@@ -0,0 +1,4 @@
+package handlers
+
+// ApplyStarterKit gives the tests in handlers_test access to what login does for a new user
+var ApplyStarterKit = (*Server).applyStarterKit
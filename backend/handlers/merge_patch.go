@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+
+	"com.fukubox/problem"
+	"com.fukubox/repository"
+)
+
+// mergePatchContentType is the media type of RFC 7396 JSON merge patches, plain application/json is accepted too
+const mergePatchContentType = "application/merge-patch+json"
+
+// Optional is a field of a merge patch: Set when the patch mentions it, with a nil Value when the patch sets it to null
+type Optional[T any] struct {
+	Set   bool
+	Value *T
+}
+
+// patchField is a field decodeMergePatch can fill in
+type patchField interface {
+	decode(data json.RawMessage) error
+}
+
+func (field *Optional[T]) decode(data json.RawMessage) error {
+	if string(data) == "null" {
+		field.Set, field.Value = true, nil
+		return nil
+	}
+
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	field.Set, field.Value = true, &value
+	return nil
+}
+
+// null reports whether the patch explicitly clears the field
+func (field Optional[T]) null() bool {
+	return field.Set && field.Value == nil
+}
+
+func (field Optional[T]) dto() repository.Optional[T] {
+	return repository.Optional[T](field)
+}
+
+// fieldErrors maps the json name of each invalid field to what is wrong with it
+type fieldErrors map[string]string
+
+// decodeMergePatch reads the merge patch in the request body into fields, keyed by json name.
+// Fields the patch leaves out stay unset; fields it can't decode, or doesn't know, end up in the
+// returned fieldErrors. The problem is only set when the body isn't a JSON object at all.
+func decodeMergePatch(r *http.Request, fields map[string]patchField) (fieldErrors, *problem.Problem) {
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mediaType != mergePatchContentType && mediaType != "application/json") {
+			return nil, problem.UnsupportedMediaType("Patches must be sent as " + mergePatchContentType + " or application/json")
+		}
+	}
+
+	var patch map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil || patch == nil {
+		return nil, problem.BadRequest("A merge patch must be a JSON object")
+	}
+
+	errs := fieldErrors{}
+	for name, data := range patch {
+		field, ok := fields[name]
+		if !ok {
+			errs[name] = "is not a field that can be changed"
+			continue
+		}
+
+		if err := field.decode(data); err != nil {
+			errs[name] = decodeErrorMessage(err)
+		}
+	}
+
+	return errs, nil
+}
+
+func decodeErrorMessage(err error) string {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return fmt.Sprintf("must be %v, not %v", jsonTypeName(typeErr.Type), typeErr.Value)
+	}
+	return err.Error()
+}
+
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice:
+		return "an array"
+	default:
+		return "a " + t.Name()
+	}
+}
+
+// checkNotEmpty adds an error when the patch clears a text field that must have a value
+func checkNotEmpty(errs fieldErrors, name string, field Optional[string]) {
+	if field.null() {
+		errs[name] = "can't be null"
+	} else if field.Value != nil && *field.Value == "" {
+		errs[name] = "can't be empty"
+	}
+}
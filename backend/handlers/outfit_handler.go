@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"com.fukubox/middleware"
+	"com.fukubox/problem"
+	"com.fukubox/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+type Outfit struct {
+	Id        int          `json:"id"`
+	UserId    int          `json:"user_id"`
+	Name      string       `json:"name"`
+	Notes     *string      `json:"notes"`
+	Items     []OutfitItem `json:"items"`
+	Tags      []Tag        `json:"tags"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// OutfitItem is a clothing item worn in an outfit. Items with a lower layer are worn
+// underneath those with a higher one, items without a layer can go anywhere.
+type OutfitItem struct {
+	ClothingItemId int  `json:"clothing_item_id" validate:"required,gt=0"`
+	Layer          *int `json:"layer" validate:"omitempty,gte=0"`
+}
+
+// OutfitRef names an outfit a change to one of its clothing items affected
+type OutfitRef struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type OutfitEdit struct {
+	Name string `json:"name" validate:"required,max=255"`
+	OutfitContents
+}
+
+// OutfitContents are the fields of an outfit besides its name
+type OutfitContents struct {
+	Notes  *string      `json:"notes" validate:"omitempty,max=4000"`
+	Items  []OutfitItem `json:"items" validate:"dive"`
+	TagIds []int        `json:"tag_ids"`
+}
+
+// check adds clothing items listed more than once to errs, which struct tags can't express
+func (contents OutfitContents) check(errs fieldErrors) {
+	seen := map[int]bool{}
+	for i, item := range contents.Items {
+		if seen[item.ClothingItemId] {
+			errs[fmt.Sprintf("items[%d].clothing_item_id", i)] = "is already in the outfit"
+		}
+		seen[item.ClothingItemId] = true
+	}
+}
+
+// OutfitPatch is the merge patch body of PATCH /outfits/{id}
+type OutfitPatch struct {
+	Name   Optional[string]
+	Notes  Optional[string]
+	Items  Optional[[]OutfitItem]
+	TagIds Optional[[]int]
+}
+
+func (patch *OutfitPatch) fields() map[string]patchField {
+	return map[string]patchField{
+		"name":    &patch.Name,
+		"notes":   &patch.Notes,
+		"items":   &patch.Items,
+		"tag_ids": &patch.TagIds,
+	}
+}
+
+func (patch OutfitPatch) check(errs fieldErrors) {
+	checkNotEmpty(errs, "name", patch.Name)
+	if patch.Name.Value != nil && *patch.Name.Value != "" {
+		if err := newValidator().Struct(OutfitEdit{Name: *patch.Name.Value}); err != nil {
+			addValidationErrors(errs, err)
+		}
+	}
+
+	contents := OutfitContents{Notes: patch.Notes.Value}
+	if patch.Items.Value != nil {
+		contents.Items = *patch.Items.Value
+	}
+	if err := newValidator().Struct(contents); err != nil {
+		addValidationErrors(errs, err)
+	}
+	contents.check(errs)
+}
+
+func (s *Server) GetOutfits(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	var clothingItemId *int
+	if value := r.URL.Query().Get("clothing_item_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			problem.Write(w, r, problem.BadRequest("Invalid clothing_item_id"))
+			return
+		}
+		clothingItemId = &id
+	}
+
+	outfitsDto, err := s.Outfits.GetOutfitsByUser(ctx, userId, clothingItemId)
+	if err != nil {
+		log.Printf("Failed to get outfits: %v", err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	outfits := []Outfit{}
+	for _, outfitDto := range outfitsDto {
+		outfits = append(outfits, toOutfit(outfitDto))
+	}
+
+	writeJSON(w, http.StatusOK, outfits)
+}
+
+func (s *Server) GetOutfitById(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	outfitId, ok := urlParamId(r, "id")
+	if !ok {
+		problem.Write(w, r, problem.BadRequest("Invalid outfit ID"))
+		return
+	}
+
+	outfitDto, err := s.Outfits.GetOutfitByUserAndId(ctx, userId, outfitId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		problem.Write(w, r, problem.NotFound("Outfit not found"))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to get outfit by id: %v", err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toOutfit(outfitDto))
+}
+
+func (s *Server) CreateOutfit(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	var req OutfitEdit
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("Failed to decode request as handlers.OutfitEdit: %v", err)
+		problem.Write(w, r, problem.BadRequest("Invalid request"))
+		return
+	}
+
+	errs := fieldErrors{}
+	if err := newValidator().Struct(req); err != nil {
+		addValidationErrors(errs, err)
+	}
+	req.OutfitContents.check(errs)
+	if len(errs) > 0 {
+		problem.Write(w, r, problem.InvalidFields(errs))
+		return
+	}
+
+	outfitDto, err := s.Outfits.CreateOutfit(ctx, userId, repository.OutfitEditDto{
+		Name:   req.Name,
+		Notes:  req.Notes,
+		Items:  toOutfitItemsDto(req.Items),
+		TagIds: req.TagIds,
+	})
+	if writeOutfitContentsError(w, r, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to create outfit: %v", err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, toOutfit(outfitDto))
+}
+
+func (s *Server) UpdateOutfit(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	outfitId, ok := urlParamId(r, "id")
+	if !ok {
+		problem.Write(w, r, problem.BadRequest("Invalid outfit ID"))
+		return
+	}
+
+	var patch OutfitPatch
+	errs, decodeProblem := decodeMergePatch(r, patch.fields())
+	if decodeProblem != nil {
+		problem.Write(w, r, decodeProblem)
+		return
+	}
+	patch.check(errs)
+	if len(errs) > 0 {
+		problem.Write(w, r, problem.InvalidFields(errs))
+		return
+	}
+
+	dto := repository.OutfitPatchDto{
+		Name:  patch.Name.Value,
+		Notes: patch.Notes.dto(),
+	}
+	// a null items or tag_ids empties the outfit of them
+	if patch.Items.Set {
+		items := []repository.OutfitItemDto{}
+		if patch.Items.Value != nil {
+			items = toOutfitItemsDto(*patch.Items.Value)
+		}
+		dto.Items = &items
+	}
+	if patch.TagIds.Set {
+		dto.TagIds = &[]int{}
+		if patch.TagIds.Value != nil {
+			dto.TagIds = patch.TagIds.Value
+		}
+	}
+
+	outfitDto, err := s.Outfits.UpdateOutfit(ctx, userId, outfitId, dto)
+	if errors.Is(err, pgx.ErrNoRows) {
+		problem.Write(w, r, problem.NotFound("Outfit not found or not authorized to update"))
+		return
+	}
+	if writeOutfitContentsError(w, r, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to update outfit %v: %v", outfitId, err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toOutfit(outfitDto))
+}
+
+func (s *Server) DeleteOutfit(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	outfitId, ok := urlParamId(r, "id")
+	if !ok {
+		problem.Write(w, r, problem.BadRequest("Invalid outfit ID"))
+		return
+	}
+
+	err := s.Outfits.DeleteOutfit(ctx, userId, outfitId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		problem.Write(w, r, problem.NotFound("Outfit not found or not authorized to delete"))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to delete outfit %v: %v", outfitId, err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeOutfitContentsError reports clothing items and tags of an outfit that aren't the user's,
+// returning whether err was one of those
+func writeOutfitContentsError(w http.ResponseWriter, r *http.Request, err error) bool {
+	switch {
+	case errors.Is(err, repository.ErrItemNotOwned):
+		problem.Write(w, r, problem.InvalidFields(fieldErrors{"items": "contains an unknown clothing item id"}))
+	case errors.Is(err, repository.ErrTagNotOwned):
+		problem.Write(w, r, problem.InvalidFields(fieldErrors{"tag_ids": "contains an unknown tag id"}))
+	default:
+		return false
+	}
+	return true
+}
+
+func toOutfitItemsDto(items []OutfitItem) []repository.OutfitItemDto {
+	dto := []repository.OutfitItemDto{}
+	for _, item := range items {
+		dto = append(dto, repository.OutfitItemDto{ClothingItemId: item.ClothingItemId, Layer: item.Layer})
+	}
+	return dto
+}
+
+func toOutfit(outfitDto repository.OutfitDto) Outfit {
+	outfit := Outfit{
+		Id:        outfitDto.Id,
+		UserId:    outfitDto.UserId,
+		Name:      outfitDto.Name,
+		Notes:     outfitDto.Notes,
+		CreatedAt: outfitDto.CreatedAt,
+		UpdatedAt: outfitDto.UpdatedAt,
+	}
+
+	err := json.Unmarshal([]byte(outfitDto.ItemsJson), &outfit.Items)
+	if err != nil {
+		log.Printf("Failed to unmarshall OutfitDto.ItemsJson: \n\titemsString:%v \n\tErr:%v", outfitDto.ItemsJson, err)
+	}
+
+	err = json.Unmarshal([]byte(outfitDto.TagsJson), &outfit.Tags)
+	if err != nil {
+		log.Printf("Failed to unmarshall OutfitDto.TagsJson: \n\ttagString:%v \n\tErr:%v", outfitDto.TagsJson, err)
+	}
+
+	return outfit
+}
@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+)
+
+// urlParamId parses a positive integer id from the named chi URL parameter
+func urlParamId(r *http.Request, name string) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, name))
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+	return id, true
+}
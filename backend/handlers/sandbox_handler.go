@@ -0,0 +1,470 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"com.fukubox/middleware"
+	"com.fukubox/problem"
+	"com.fukubox/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+type Sandbox struct {
+	Id        int       `json:"id"`
+	UserId    int       `json:"user_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type SandboxDetail struct {
+	Sandbox
+	Positions []SandboxPosition `json:"positions"`
+}
+
+type SandboxEdit struct {
+	Name string `json:"name" validate:"required,max=255"`
+}
+
+// SandboxPatch is the merge patch body of PATCH /sandboxes/{id}
+type SandboxPatch struct {
+	Name Optional[string]
+}
+
+func (patch *SandboxPatch) fields() map[string]patchField {
+	return map[string]patchField{"name": &patch.Name}
+}
+
+func (patch SandboxPatch) check(errs fieldErrors) {
+	if patch.Name.null() {
+		errs["name"] = "can't be null"
+	}
+	if patch.Name.Value != nil {
+		if err := newValidator().Struct(SandboxEdit{Name: *patch.Name.Value}); err != nil {
+			addValidationErrors(errs, err)
+		}
+	}
+}
+
+type SandboxPosition struct {
+	Id             int       `json:"id"`
+	SandboxId      int       `json:"sandbox_id"`
+	ClothingItemId int       `json:"clothing_item_id"`
+	PositionX      float64   `json:"position_x"`
+	PositionY      float64   `json:"position_y"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type SandboxPositionEdit struct {
+	ClothingItemId int      `json:"clothing_item_id" validate:"required,gt=0"`
+	PositionX      *float64 `json:"position_x" validate:"required"`
+	PositionY      *float64 `json:"position_y" validate:"required"`
+}
+
+// SandboxPositionPatch is the merge patch body of PATCH /sandboxes/{id}/positions/{positionId}
+type SandboxPositionPatch struct {
+	PositionX Optional[float64]
+	PositionY Optional[float64]
+}
+
+func (patch *SandboxPositionPatch) fields() map[string]patchField {
+	return map[string]patchField{"position_x": &patch.PositionX, "position_y": &patch.PositionY}
+}
+
+type SandboxLayout struct {
+	Positions []SandboxPositionEdit `json:"positions" validate:"dive"`
+}
+
+func (s *Server) GetSandboxes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	sandboxesDto, err := s.Sandboxes.GetSandboxesByUser(ctx, userId)
+	if err != nil {
+		log.Printf("Failed to get sandboxes: %v", err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	sandboxes := []Sandbox{}
+	for _, sandboxDto := range sandboxesDto {
+		sandboxes = append(sandboxes, toSandbox(sandboxDto))
+	}
+
+	writeJSON(w, http.StatusOK, sandboxes)
+}
+
+func (s *Server) GetSandboxById(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	sandboxId, ok := urlParamId(r, "id")
+	if !ok {
+		problem.Write(w, r, problem.BadRequest("Invalid sandbox ID"))
+		return
+	}
+
+	sandboxDto, err := s.Sandboxes.GetSandboxByUserAndId(ctx, userId, sandboxId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		problem.Write(w, r, problem.NotFound("Sandbox not found"))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to get sandbox by id: %v", err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	positionsDto, err := s.Sandboxes.GetSandboxPositions(ctx, userId, sandboxId)
+	if err != nil {
+		log.Printf("Failed to get sandbox positions: %v", err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	sandbox := SandboxDetail{
+		Sandbox:   toSandbox(sandboxDto),
+		Positions: toSandboxPositions(positionsDto),
+	}
+
+	writeJSON(w, http.StatusOK, sandbox)
+}
+
+func (s *Server) CreateSandbox(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	var req SandboxEdit
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("Failed to decode request as handlers.SandboxEdit: %v", err)
+		problem.Write(w, r, problem.BadRequest("Invalid request"))
+		return
+	}
+
+	validate := newValidator()
+
+	if err := validate.Struct(req); err != nil {
+		problem.Write(w, r, validationProblem(err))
+		return
+	}
+
+	sandboxDto, err := s.Sandboxes.CreateSandbox(ctx, userId, repository.SandboxEditDto{Name: req.Name})
+	if err != nil {
+		log.Printf("Failed to create sandbox: %v", err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, toSandbox(sandboxDto))
+}
+
+func (s *Server) UpdateSandbox(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	sandboxId, ok := urlParamId(r, "id")
+	if !ok {
+		problem.Write(w, r, problem.BadRequest("Invalid sandbox ID"))
+		return
+	}
+
+	var patch SandboxPatch
+	errs, decodeProblem := decodeMergePatch(r, patch.fields())
+	if decodeProblem != nil {
+		problem.Write(w, r, decodeProblem)
+		return
+	}
+	patch.check(errs)
+	if len(errs) > 0 {
+		problem.Write(w, r, problem.InvalidFields(errs))
+		return
+	}
+
+	sandboxDto, err := s.Sandboxes.UpdateSandbox(ctx, userId, sandboxId, repository.SandboxPatchDto{Name: patch.Name.Value})
+	if errors.Is(err, pgx.ErrNoRows) {
+		problem.Write(w, r, problem.NotFound("Sandbox not found or not authorized to update"))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to update sandbox: %v", err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toSandbox(sandboxDto))
+}
+
+func (s *Server) DeleteSandbox(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	sandboxId, ok := urlParamId(r, "id")
+	if !ok {
+		problem.Write(w, r, problem.BadRequest("Invalid sandbox ID"))
+		return
+	}
+
+	err := s.Sandboxes.DeleteSandbox(ctx, userId, sandboxId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		problem.Write(w, r, problem.NotFound("Sandbox not found or not authorized to delete"))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to delete sandbox: %v", err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) GetSandboxPositions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	sandboxId, ok := urlParamId(r, "id")
+	if !ok {
+		problem.Write(w, r, problem.BadRequest("Invalid sandbox ID"))
+		return
+	}
+
+	_, err := s.Sandboxes.GetSandboxByUserAndId(ctx, userId, sandboxId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		problem.Write(w, r, problem.NotFound("Sandbox not found"))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to get sandbox by id: %v", err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	positionsDto, err := s.Sandboxes.GetSandboxPositions(ctx, userId, sandboxId)
+	if err != nil {
+		log.Printf("Failed to get sandbox positions: %v", err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toSandboxPositions(positionsDto))
+}
+
+func (s *Server) CreateSandboxPosition(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	sandboxId, ok := urlParamId(r, "id")
+	if !ok {
+		problem.Write(w, r, problem.BadRequest("Invalid sandbox ID"))
+		return
+	}
+
+	var req SandboxPositionEdit
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("Failed to decode request as handlers.SandboxPositionEdit: %v", err)
+		problem.Write(w, r, problem.BadRequest("Invalid request"))
+		return
+	}
+
+	validate := newValidator()
+
+	if err := validate.Struct(req); err != nil {
+		problem.Write(w, r, validationProblem(err))
+		return
+	}
+
+	positionDto, err := s.Sandboxes.CreateSandboxPosition(ctx, userId, sandboxId, repository.SandboxPositionEditDto{
+		ClothingItemId: req.ClothingItemId,
+		PositionX:      *req.PositionX,
+		PositionY:      *req.PositionY,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		problem.Write(w, r, problem.NotFound("Sandbox not found"))
+		return
+	}
+	if errors.Is(err, repository.ErrItemNotOwned) {
+		problem.Write(w, r, problem.BadRequest("Clothing item not found"))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to place clothing item in sandbox: %v", err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, toSandboxPosition(positionDto))
+}
+
+func (s *Server) UpdateSandboxPosition(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	sandboxId, ok := urlParamId(r, "id")
+	if !ok {
+		problem.Write(w, r, problem.BadRequest("Invalid sandbox ID"))
+		return
+	}
+
+	positionId, ok := urlParamId(r, "positionId")
+	if !ok {
+		problem.Write(w, r, problem.BadRequest("Invalid position ID"))
+		return
+	}
+
+	var patch SandboxPositionPatch
+	errs, decodeProblem := decodeMergePatch(r, patch.fields())
+	if decodeProblem != nil {
+		problem.Write(w, r, decodeProblem)
+		return
+	}
+	if patch.PositionX.null() {
+		errs["position_x"] = "can't be null"
+	}
+	if patch.PositionY.null() {
+		errs["position_y"] = "can't be null"
+	}
+	if len(errs) > 0 {
+		problem.Write(w, r, problem.InvalidFields(errs))
+		return
+	}
+
+	positionDto, err := s.Sandboxes.MoveSandboxPosition(ctx, userId, sandboxId, positionId, patch.PositionX.Value, patch.PositionY.Value)
+	if errors.Is(err, pgx.ErrNoRows) {
+		problem.Write(w, r, problem.NotFound("Sandbox position not found or not authorized to update"))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to move sandbox position: %v", err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toSandboxPosition(positionDto))
+}
+
+func (s *Server) DeleteSandboxPosition(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	sandboxId, ok := urlParamId(r, "id")
+	if !ok {
+		problem.Write(w, r, problem.BadRequest("Invalid sandbox ID"))
+		return
+	}
+
+	positionId, ok := urlParamId(r, "positionId")
+	if !ok {
+		problem.Write(w, r, problem.BadRequest("Invalid position ID"))
+		return
+	}
+
+	err := s.Sandboxes.DeleteSandboxPosition(ctx, userId, sandboxId, positionId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		problem.Write(w, r, problem.NotFound("Sandbox position not found or not authorized to delete"))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to delete sandbox position: %v", err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SaveSandboxLayout replaces every position in the sandbox with the submitted layout
+func (s *Server) SaveSandboxLayout(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	sandboxId, ok := urlParamId(r, "id")
+	if !ok {
+		problem.Write(w, r, problem.BadRequest("Invalid sandbox ID"))
+		return
+	}
+
+	var req SandboxLayout
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("Failed to decode request as handlers.SandboxLayout: %v", err)
+		problem.Write(w, r, problem.BadRequest("Invalid request"))
+		return
+	}
+
+	validate := newValidator()
+
+	if err := validate.Struct(req); err != nil {
+		problem.Write(w, r, validationProblem(err))
+		return
+	}
+
+	layout := []repository.SandboxPositionEditDto{}
+	for _, position := range req.Positions {
+		layout = append(layout, repository.SandboxPositionEditDto{
+			ClothingItemId: position.ClothingItemId,
+			PositionX:      *position.PositionX,
+			PositionY:      *position.PositionY,
+		})
+	}
+
+	positionsDto, err := s.Sandboxes.ReplaceSandboxPositions(ctx, userId, sandboxId, layout)
+	if errors.Is(err, pgx.ErrNoRows) {
+		problem.Write(w, r, problem.NotFound("Sandbox not found"))
+		return
+	}
+	if errors.Is(err, repository.ErrItemNotOwned) {
+		problem.Write(w, r, problem.BadRequest("Clothing item not found"))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to save sandbox layout: %v", err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toSandboxPositions(positionsDto))
+}
+
+func toSandbox(sandboxDto repository.SandboxDto) Sandbox {
+	return Sandbox{
+		Id:        sandboxDto.Id,
+		UserId:    sandboxDto.UserId,
+		Name:      sandboxDto.Name,
+		CreatedAt: sandboxDto.CreatedAt,
+		UpdatedAt: sandboxDto.UpdatedAt,
+	}
+}
+
+func toSandboxPosition(positionDto repository.SandboxPositionDto) SandboxPosition {
+	return SandboxPosition{
+		Id:             positionDto.Id,
+		SandboxId:      positionDto.SandboxId,
+		ClothingItemId: positionDto.ClothingItemId,
+		PositionX:      positionDto.PositionX,
+		PositionY:      positionDto.PositionY,
+		CreatedAt:      positionDto.CreatedAt,
+		UpdatedAt:      positionDto.UpdatedAt,
+	}
+}
+
+func toSandboxPositions(positionsDto []repository.SandboxPositionDto) []SandboxPosition {
+	positions := []SandboxPosition{}
+	for _, positionDto := range positionsDto {
+		positions = append(positions, toSandboxPosition(positionDto))
+	}
+	return positions
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"com.fukubox/middleware"
+	"com.fukubox/problem"
+	"com.fukubox/repository"
+)
+
+const defaultSearchLimit = 20
+const maxSearchLimit = 100
+
+// searchTypes are the resource types /search can return, in response order
+var searchTypes = []string{"items", "categories", "tags"}
+
+// SearchGroup is one page of ranked results of a single resource type
+type SearchGroup[T any] struct {
+	Results    []T  `json:"results"`
+	Total      int  `json:"total"`
+	NextOffset *int `json:"next_offset"`
+}
+
+// SearchResults holds a group per requested type, best matches first
+type SearchResults struct {
+	Query      string                 `json:"query"`
+	Items      *SearchGroup[Cloth]    `json:"items,omitempty"`
+	Categories *SearchGroup[Category] `json:"categories,omitempty"`
+	Tags       *SearchGroup[TagItem]  `json:"tags,omitempty"`
+}
+
+func (s *Server) Search(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	query := r.URL.Query()
+
+	text := strings.TrimSpace(query.Get("q"))
+	if text == "" {
+		problem.Write(w, r, problem.BadRequest("q is required"))
+		return
+	}
+
+	types, err := parseSearchTypes(query.Get("type"))
+	if err != nil {
+		problem.Write(w, r, problem.BadRequest(err.Error()))
+		return
+	}
+
+	page, err := parseSearchPage(query.Get("limit"), query.Get("offset"))
+	if err != nil {
+		problem.Write(w, r, problem.BadRequest(err.Error()))
+		return
+	}
+
+	results := SearchResults{Query: text}
+
+	if types["items"] {
+		clothDtos, total, err := s.Searches.SearchClothes(ctx, userId, text, page)
+		if err != nil {
+			log.Printf("Failed to search clothes: %v", err)
+			problem.Write(w, r, problem.Internal())
+			return
+		}
+
+		group := &SearchGroup[Cloth]{Results: []Cloth{}, Total: total, NextOffset: nextSearchOffset(page, total)}
+		for _, clothDto := range clothDtos {
+			group.Results = append(group.Results, toCloth(clothDto))
+		}
+		results.Items = group
+	}
+
+	if types["categories"] {
+		categoryDtos, total, err := s.Searches.SearchCategories(ctx, userId, text, page)
+		if err != nil {
+			log.Printf("Failed to search categories: %v", err)
+			problem.Write(w, r, problem.Internal())
+			return
+		}
+
+		group := &SearchGroup[Category]{Results: []Category{}, Total: total, NextOffset: nextSearchOffset(page, total)}
+		for _, categoryDto := range categoryDtos {
+			group.Results = append(group.Results, toCategory(categoryDto))
+		}
+		results.Categories = group
+	}
+
+	if types["tags"] {
+		tagDtos, total, err := s.Searches.SearchTags(ctx, userId, text, page)
+		if err != nil {
+			log.Printf("Failed to search tags: %v", err)
+			problem.Write(w, r, problem.Internal())
+			return
+		}
+
+		group := &SearchGroup[TagItem]{Results: []TagItem{}, Total: total, NextOffset: nextSearchOffset(page, total)}
+		for _, tagDto := range tagDtos {
+			group.Results = append(group.Results, toTagItem(tagDto))
+		}
+		results.Tags = group
+	}
+
+	writeJSON(w, http.StatusOK, results)
+}
+
+// parseSearchTypes reads a comma separated list of searchTypes, defaulting to all of them
+func parseSearchTypes(value string) (map[string]bool, error) {
+	types := map[string]bool{}
+	if value == "" {
+		for _, searchType := range searchTypes {
+			types[searchType] = true
+		}
+		return types, nil
+	}
+
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		known := false
+		for _, searchType := range searchTypes {
+			known = known || field == searchType
+		}
+		if !known {
+			return nil, fmt.Errorf("type must be a comma separated list of %v", strings.Join(searchTypes, ", "))
+		}
+		types[field] = true
+	}
+
+	return types, nil
+}
+
+func parseSearchPage(limitValue string, offsetValue string) (repository.SearchPageDto, error) {
+	page := repository.SearchPageDto{Limit: defaultSearchLimit}
+
+	if limitValue != "" {
+		limit, err := strconv.Atoi(limitValue)
+		if err != nil || limit < 1 || limit > maxSearchLimit {
+			return page, fmt.Errorf("limit must be between 1 and %d", maxSearchLimit)
+		}
+		page.Limit = limit
+	}
+
+	if offsetValue != "" {
+		offset, err := strconv.Atoi(offsetValue)
+		if err != nil || offset < 0 {
+			return page, errors.New("offset must be a non-negative integer")
+		}
+		page.Offset = offset
+	}
+
+	return page, nil
+}
+
+// nextSearchOffset returns where the following page starts, or nil when this page is the last
+func nextSearchOffset(page repository.SearchPageDto, total int) *int {
+	next := page.Offset + page.Limit
+	if next >= total {
+		return nil
+	}
+	return &next
+}
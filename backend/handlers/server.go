@@ -0,0 +1,32 @@
+package handlers
+
+import "com.fukubox/repository"
+
+// Server holds the repositories the handlers read and write through, so they can be
+// served from Postgres or from the in-memory store in tests
+type Server struct {
+	Clothes    repository.ClothesRepository
+	Categories repository.CategoryRepository
+	Tags       repository.TagRepository
+	Users      repository.UserRepository
+	Sandboxes  repository.SandboxRepository
+	Outfits    repository.OutfitRepository
+	Wears      repository.WearRepository
+	Trash      repository.TrashRepository
+	Searches   repository.SearchRepository
+}
+
+// NewServer serves every handler from one store
+func NewServer(repos repository.Repositories) *Server {
+	return &Server{
+		Clothes:    repos,
+		Categories: repos,
+		Tags:       repos,
+		Users:      repos,
+		Sandboxes:  repos,
+		Outfits:    repos,
+		Wears:      repos,
+		Trash:      repos,
+		Searches:   repos,
+	}
+}
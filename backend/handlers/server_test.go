@@ -0,0 +1,272 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"com.fukubox/apptest"
+	"com.fukubox/handlers"
+	"com.fukubox/repository/memory"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(apptest.Main(m))
+}
+
+// The tests below run the handlers on a memory.Store, each arranging the users it needs
+
+type namedBody struct {
+	Id       int    `json:"id"`
+	ParentId *int   `json:"parent_id"`
+	Name     string `json:"name"`
+}
+
+type clothBody struct {
+	Id         int               `json:"id"`
+	UserId     int               `json:"user_id"`
+	CategoryId int               `json:"category_id"`
+	ImageUrl   string            `json:"image_url"`
+	CutoutUrl  *string           `json:"cutout_url"`
+	Images     map[string]string `json:"images"`
+}
+
+type problemBody struct {
+	Status int               `json:"status"`
+	Errors map[string]string `json:"errors"`
+}
+
+// newUser signs a user up in store the way login does, without a starter kit
+func newUser(t *testing.T, store *memory.Store, name string) int {
+	t.Helper()
+
+	userId, err := store.UpsertGoogleUser(context.Background(), "google-"+name, name+"@example.com", true, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return userId
+}
+
+// newCategory creates a root category for userId, returning its id
+func newCategory(t *testing.T, h *apptest.Harness, userId int, name string) int {
+	t.Helper()
+
+	var category namedBody
+	h.Do(t, userId, http.MethodPost, "/categories", map[string]any{"name": name}).
+		ExpectStatus(t, http.StatusCreated).Decode(t, &category)
+	return category.Id
+}
+
+// imageForm builds a multipart body holding a red square on white in the image field and the given form fields
+func imageForm(t *testing.T, fields map[string]string) (string, *bytes.Buffer) {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	for x := 0; x < 32; x++ {
+		for y := 0; y < 32; y++ {
+			img.Set(x, y, color.White)
+			if x >= 8 && x < 24 && y >= 8 && y < 24 {
+				img.Set(x, y, color.RGBA{R: 200, A: 255})
+			}
+		}
+	}
+
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+	file, err := form.CreateFormFile("image", "shirt.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(file, img); err != nil {
+		t.Fatal(err)
+	}
+	form.Close()
+
+	return form.FormDataContentType(), body
+}
+
+func TestApplyStarterKit(t *testing.T) {
+	store := memory.NewStore()
+	userId := newUser(t, store, "marie")
+	server := handlers.NewServer(store)
+	h := apptest.NewInMemory(t, store)
+
+	handlers.ApplyStarterKit(server, context.Background(), userId, "", "fr-CA,en;q=0.5")
+
+	var categories []namedBody
+	h.Do(t, userId, http.MethodGet, "/categories", nil).ExpectStatus(t, http.StatusOK).Decode(t, &categories)
+	if len(categories) == 0 || categories[0].ParentId != nil || categories[0].Name != "Hauts" {
+		t.Fatalf("expected the French kit, got %+v", categories)
+	}
+	var tags []namedBody
+	h.Do(t, userId, http.MethodGet, "/tags", nil).ExpectStatus(t, http.StatusOK).Decode(t, &tags)
+	if len(tags) == 0 {
+		t.Fatal("the kit has no tags")
+	}
+
+	// logging in again doesn't hand out a second kit
+	handlers.ApplyStarterKit(server, context.Background(), userId, "en")
+	var again []namedBody
+	h.Do(t, userId, http.MethodGet, "/categories", nil).ExpectStatus(t, http.StatusOK).Decode(t, &again)
+	if len(again) != len(categories) {
+		t.Fatalf("expected %d categories after a second login, got %+v", len(categories), again)
+	}
+
+	// nor does a user who deleted the kit get it back
+	for _, tag := range tags {
+		h.Do(t, userId, http.MethodDelete, fmt.Sprintf("/tags/%d", tag.Id), nil).ExpectStatus(t, http.StatusNoContent)
+	}
+	handlers.ApplyStarterKit(server, context.Background(), userId)
+	h.Do(t, userId, http.MethodGet, "/tags", nil).ExpectStatus(t, http.StatusOK).Decode(t, &tags)
+	if len(tags) != 0 {
+		t.Fatalf("deleted tags came back: %+v", tags)
+	}
+}
+
+func TestClothImagesAreReplaced(t *testing.T) {
+	store := memory.NewStore()
+	userId := newUser(t, store, "ines")
+	h := apptest.NewInMemory(t, store)
+	categoryId := newCategory(t, h, userId, "Tops")
+
+	var cloth clothBody
+	contentType, body := imageForm(t, map[string]string{"category_id": fmt.Sprint(categoryId)})
+	h.DoRaw(t, userId, http.MethodPost, "/clothes", contentType, body).ExpectStatus(t, http.StatusCreated).Decode(t, &cloth)
+	if cloth.CutoutUrl == nil || len(cloth.Images) == 0 {
+		t.Fatalf("expected a cutout and images, got %+v", cloth)
+	}
+	original := cloth.ImageUrl
+	h.Do(t, userId, http.MethodGet, original, nil).ExpectStatus(t, http.StatusOK)
+
+	// pointing the item at another image drops everything made from the stored one
+	path := fmt.Sprintf("/clothes/%d", cloth.Id)
+	var patched clothBody
+	h.Do(t, userId, http.MethodPatch, path, map[string]any{"image_url": "http://example.com/other.jpg"}).
+		ExpectStatus(t, http.StatusOK).Decode(t, &patched)
+	if patched.CutoutUrl != nil || len(patched.Images) != 1 || patched.Images["full"] != "http://example.com/other.jpg" {
+		t.Fatalf("cutout and images outlived their image: %+v", patched)
+	}
+	h.Do(t, userId, http.MethodGet, original, nil).ExpectStatus(t, http.StatusNotFound)
+}
+
+func TestOtherUsersClothes(t *testing.T) {
+	store := memory.NewStore()
+	owner := newUser(t, store, "owner")
+	other := newUser(t, store, "other")
+	h := apptest.NewInMemory(t, store)
+	categoryId := newCategory(t, h, owner, "Tops")
+
+	var cloth clothBody
+	contentType, body := imageForm(t, map[string]string{"category_id": fmt.Sprint(categoryId)})
+	h.DoRaw(t, owner, http.MethodPost, "/clothes", contentType, body).ExpectStatus(t, http.StatusCreated).Decode(t, &cloth)
+	path := fmt.Sprintf("/clothes/%d", cloth.Id)
+
+	h.Do(t, other, http.MethodGet, path, nil).ExpectStatus(t, http.StatusNotFound)
+	h.Do(t, other, http.MethodPatch, path, map[string]any{"notes": "Mine now"}).ExpectStatus(t, http.StatusNotFound)
+	h.Do(t, other, http.MethodDelete, path, nil).ExpectStatus(t, http.StatusNotFound)
+
+	var problem problemBody
+	h.Do(t, other, http.MethodPost, "/clothes", map[string]any{"category_id": categoryId, "image_url": "http://example.com/new.jpg"}).
+		ExpectStatus(t, http.StatusUnprocessableEntity).Decode(t, &problem)
+	if problem.Errors["category_id"] == "" {
+		t.Fatalf("expected an error on category_id, got %+v", problem)
+	}
+	h.Do(t, other, http.MethodPost, "/clothes", map[string]any{"category_id": newCategory(t, h, other, "Tops"), "image_url": cloth.ImageUrl}).
+		ExpectStatus(t, http.StatusUnprocessableEntity).Decode(t, &problem)
+	if problem.Errors["image_url"] == "" {
+		t.Fatalf("expected an error on image_url, got %+v", problem)
+	}
+
+	// the owner still has it, and deleting it for good from the trash removes its image
+	h.Do(t, owner, http.MethodGet, path, nil).ExpectStatus(t, http.StatusOK)
+	h.Do(t, owner, http.MethodDelete, path, nil).ExpectStatus(t, http.StatusOK)
+	h.Do(t, owner, http.MethodGet, cloth.ImageUrl, nil).ExpectStatus(t, http.StatusOK)
+	h.Do(t, owner, http.MethodDelete, fmt.Sprintf("/trash/clothes/%d", cloth.Id), nil).ExpectStatus(t, http.StatusNoContent)
+	h.Do(t, owner, http.MethodGet, cloth.ImageUrl, nil).ExpectStatus(t, http.StatusNotFound)
+}
+
+func TestDeletingAccountDeletesUploads(t *testing.T) {
+	store := memory.NewStore()
+	userId := newUser(t, store, "ines")
+	other := newUser(t, store, "other")
+	h := apptest.NewInMemory(t, store)
+
+	upload := func(userId int) clothBody {
+		var cloth clothBody
+		contentType, body := imageForm(t, map[string]string{"category_id": fmt.Sprint(newCategory(t, h, userId, "Tops"))})
+		h.DoRaw(t, userId, http.MethodPost, "/clothes", contentType, body).ExpectStatus(t, http.StatusCreated).Decode(t, &cloth)
+		return cloth
+	}
+	kept, trashed, others := upload(userId), upload(userId), upload(other)
+	h.Do(t, userId, http.MethodDelete, fmt.Sprintf("/clothes/%d", trashed.Id), nil).ExpectStatus(t, http.StatusOK)
+
+	h.Do(t, userId, http.MethodDelete, "/me", nil).ExpectStatus(t, http.StatusNoContent)
+
+	// every file made for the account's items goes with it, whether or not they were in the trash
+	for _, cloth := range []clothBody{kept, trashed} {
+		urls := []string{cloth.ImageUrl, *cloth.CutoutUrl}
+		for _, url := range cloth.Images {
+			urls = append(urls, url)
+		}
+		for _, url := range urls {
+			h.Do(t, other, http.MethodGet, url, nil).ExpectStatus(t, http.StatusNotFound)
+		}
+	}
+	h.Do(t, other, http.MethodGet, others.ImageUrl, nil).ExpectStatus(t, http.StatusOK)
+}
+
+func TestNamesAreValidated(t *testing.T) {
+	store := memory.NewStore()
+	userId := newUser(t, store, "sam")
+	h := apptest.NewInMemory(t, store)
+
+	for _, path := range []string{"/tags", "/categories"} {
+		for _, name := range []string{"", strings.Repeat("x", 256)} {
+			var problem problemBody
+			h.Do(t, userId, http.MethodPost, path, map[string]any{"name": name}).
+				ExpectStatus(t, http.StatusUnprocessableEntity).Decode(t, &problem)
+			if problem.Errors["name"] == "" {
+				t.Fatalf("POST %v with a %d character name: expected an error on name, got %+v", path, len(name), problem)
+			}
+		}
+	}
+
+	categoryId := newCategory(t, h, userId, "Tops")
+	h.Do(t, userId, http.MethodPatch, fmt.Sprintf("/categories/%d", categoryId), map[string]any{"name": strings.Repeat("x", 256)}).
+		ExpectStatus(t, http.StatusUnprocessableEntity)
+}
+
+func TestWearDates(t *testing.T) {
+	store := memory.NewStore()
+	userId := newUser(t, store, "kim")
+	h := apptest.NewInMemory(t, store)
+	categoryId := newCategory(t, h, userId, "Tops")
+
+	var cloth clothBody
+	h.Do(t, userId, http.MethodPost, "/clothes", map[string]any{"category_id": categoryId, "image_url": "http://example.com/new.jpg"}).
+		ExpectStatus(t, http.StatusCreated).Decode(t, &cloth)
+
+	// today is the date in UTC+14, the first timezone a day starts in
+	today := time.Now().UTC().Add(14 * time.Hour)
+	h.Do(t, userId, http.MethodPost, "/wear", map[string]any{"date": today.Format("2006-01-02"), "clothing_item_ids": []int{cloth.Id}}).
+		ExpectStatus(t, http.StatusCreated)
+
+	var problem problemBody
+	h.Do(t, userId, http.MethodPost, "/wear", map[string]any{"date": today.AddDate(0, 0, 1).Format("2006-01-02"), "clothing_item_ids": []int{cloth.Id}}).
+		ExpectStatus(t, http.StatusUnprocessableEntity).Decode(t, &problem)
+	if problem.Errors["date"] == "" {
+		t.Fatalf("expected an error on date, got %+v", problem)
+	}
+}
@@ -2,227 +2,185 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
-	"strconv"
 	"time"
 
-	"com.fukubox/database"
-	"github.com/go-chi/chi"
+	"com.fukubox/middleware"
+	"com.fukubox/problem"
+	"com.fukubox/repository"
+	"github.com/jackc/pgx/v5"
 )
 
 type TagItem struct {
 	Id        int       `json:"id"`
+	UserId    int       `json:"user_id"`
 	Name      string    `json:"name"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type TagEdit struct {
-	Name string `json:"name"`
+	Name string `json:"name" validate:"required,max=255"`
 }
 
-func GetTags(w http.ResponseWriter, r *http.Request) {
-	dbpool := database.GetDB()
-	ctx := r.Context()
+// TagPatch is the merge patch body of PATCH /tags/{id}
+type TagPatch struct {
+	Name Optional[string]
+}
 
-	conn, err := dbpool.Acquire(ctx)
-	if err != nil {
-		log.Printf("Failed to acquire a database connection: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
-	defer conn.Release()
+func (patch *TagPatch) fields() map[string]patchField {
+	return map[string]patchField{"name": &patch.Name}
+}
 
-	rows, err := conn.Query(ctx, "SELECT id, name, created_at, updated_at FROM tags")
-	if err != nil {
-		log.Printf("Query failed: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+func (patch TagPatch) check(errs fieldErrors) {
+	checkNotEmpty(errs, "name", patch.Name)
+	if patch.Name.Value != nil && *patch.Name.Value != "" {
+		if err := newValidator().Struct(TagEdit{Name: *patch.Name.Value}); err != nil {
+			addValidationErrors(errs, err)
+		}
 	}
-	defer rows.Close()
+}
 
-	tags := []TagItem{}
+func (s *Server) GetTags(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
 
-	for rows.Next() {
-		var tag TagItem
-		if err := rows.Scan(&tag.Id, &tag.Name, &tag.CreatedAt, &tag.UpdatedAt); err != nil {
-			log.Printf("Failed to scan row: %v", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return
-		}
-		tags = append(tags, tag)
-	}
+	userId := middleware.GetUserId(ctx)
 
-	if err := rows.Err(); err != nil {
-		log.Printf("Error after iterating rows: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	tagDtos, err := s.Tags.GetTagsByUser(ctx, userId)
+	if err != nil {
+		log.Printf("Failed to get tags for user %v: %v", userId, err)
+		problem.Write(w, r, problem.Internal())
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(tags); err != nil {
-		log.Printf("Failed to encode response as JSON: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+	tags := []TagItem{}
+	for _, tagDto := range tagDtos {
+		tags = append(tags, toTagItem(tagDto))
 	}
+
+	writeJSON(w, http.StatusOK, tags)
 }
 
-func GetTagById(w http.ResponseWriter, r *http.Request) {
-	dbpool := database.GetDB()
+func (s *Server) GetTagById(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	tagIdStr := chi.URLParam(r, "id")
-	tagId, err := strconv.Atoi(tagIdStr)
-	if err != nil {
-		http.Error(w, "Invalid tag ID", http.StatusBadRequest)
+	userId := middleware.GetUserId(ctx)
+
+	tagId, ok := urlParamId(r, "id")
+	if !ok {
+		problem.Write(w, r, problem.BadRequest("Invalid tag ID"))
 		return
 	}
 
-	conn, err := dbpool.Acquire(ctx)
-	if err != nil {
-		log.Printf("Failed to acquire a database connection: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	tagDto, err := s.Tags.GetTagByUserAndId(ctx, userId, tagId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		problem.Write(w, r, problem.NotFound("Tag not found"))
 		return
 	}
-	defer conn.Release()
-
-	var tag TagItem
-	err = dbpool.QueryRow(ctx, "SELECT id, name, created_at, updated_at FROM tags WHERE id = $1", tagId).
-		Scan(&tag.Id, &tag.Name, &tag.CreatedAt, &tag.UpdatedAt)
 	if err != nil {
-		log.Printf("Failed to query row: %v", err)
-		http.Error(w, "Tag not found", http.StatusNotFound)
+		log.Printf("Failed to get tag %v: %v", tagId, err)
+		problem.Write(w, r, problem.Internal())
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(tag); err != nil {
-		log.Printf("Failed to encode response as JSON: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
+	writeJSON(w, http.StatusOK, toTagItem(tagDto))
 }
 
-func CreateTag(w http.ResponseWriter, r *http.Request) {
-	dbpool := database.GetDB()
+func (s *Server) CreateTag(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
+	userId := middleware.GetUserId(ctx)
+
 	var req TagEdit
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-	if req.Name == "" {
-		http.Error(w, "Tag name is required", http.StatusBadRequest)
+		problem.Write(w, r, problem.BadRequest("Invalid request body"))
 		return
 	}
-
-	conn, err := dbpool.Acquire(ctx)
-	if err != nil {
-		log.Printf("Failed to acquire a database connection: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	if err := newValidator().Struct(req); err != nil {
+		problem.Write(w, r, validationProblem(err))
 		return
 	}
-	defer conn.Release()
 
-	var newTag TagItem
-	err = conn.QueryRow(
-		ctx,
-		`INSERT INTO tags (name, created_at, updated_at)
-     	VALUES ($1, now(), now())
-     	RETURNING id, name, created_at, updated_at`,
-		req.Name).Scan(&newTag.Id, &newTag.Name, &newTag.CreatedAt, &newTag.UpdatedAt)
+	tagDto, err := s.Tags.CreateTag(ctx, userId, req.Name)
 	if err != nil {
-		log.Printf("Failed to insert new tag: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("Failed to create tag: %v", err)
+		problem.Write(w, r, problem.Internal())
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(newTag); err != nil {
-		log.Printf("Failed to encode response as JSON: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
+	writeJSON(w, http.StatusCreated, toTagItem(tagDto))
 }
 
-func UpdateTag(w http.ResponseWriter, r *http.Request) {
-	dbpool := database.GetDB()
+func (s *Server) UpdateTag(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	tagIdStr := chi.URLParam(r, "id")
-	tagId, err := strconv.Atoi(tagIdStr)
-	if err != nil {
-		http.Error(w, "Invalid tag ID", http.StatusBadRequest)
+	userId := middleware.GetUserId(ctx)
+
+	tagId, ok := urlParamId(r, "id")
+	if !ok {
+		problem.Write(w, r, problem.BadRequest("Invalid tag ID"))
 		return
 	}
 
-	var req TagEdit
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	var patch TagPatch
+	errs, decodeProblem := decodeMergePatch(r, patch.fields())
+	if decodeProblem != nil {
+		problem.Write(w, r, decodeProblem)
 		return
 	}
-	if req.Name == "" {
-		http.Error(w, "Tag name is required", http.StatusBadRequest)
+	patch.check(errs)
+	if len(errs) > 0 {
+		problem.Write(w, r, problem.InvalidFields(errs))
 		return
 	}
 
-	conn, err := dbpool.Acquire(ctx)
-	if err != nil {
-		log.Printf("Failed to acquire a database connection: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	tagDto, err := s.Tags.UpdateTag(ctx, userId, tagId, patch.Name.Value)
+	if errors.Is(err, pgx.ErrNoRows) {
+		problem.Write(w, r, problem.NotFound("Tag not found"))
 		return
 	}
-	defer conn.Release()
-
-	var updatedTag TagItem
-	err = conn.QueryRow(ctx,
-		"UPDATE tags SET name = $1, updated_at = now() WHERE id = $2 RETURNING id, name, created_at, updated_at",
-		req.Name, tagId).Scan(&updatedTag.Id, &updatedTag.Name, &updatedTag.CreatedAt, &updatedTag.UpdatedAt)
 	if err != nil {
-		log.Printf("Failed to update tag: %v", err)
-		http.Error(w, "Tag not found", http.StatusNotFound)
+		log.Printf("Failed to update tag %v: %v", tagId, err)
+		problem.Write(w, r, problem.Internal())
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(updatedTag); err != nil {
-		log.Printf("Failed to encode response as JSON: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
+	writeJSON(w, http.StatusOK, toTagItem(tagDto))
 }
 
-func DeleteTag(w http.ResponseWriter, r *http.Request) {
-	dbpool := database.GetDB()
+func (s *Server) DeleteTag(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	tagIdStr := chi.URLParam(r, "id")
-	tagId, err := strconv.Atoi(tagIdStr)
-	if err != nil {
-		http.Error(w, "Invalid tag ID", http.StatusBadRequest)
-		return
-	}
+	userId := middleware.GetUserId(ctx)
 
-	conn, err := dbpool.Acquire(ctx)
-	if err != nil {
-		log.Printf("Failed to acquire a database connection: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	tagId, ok := urlParamId(r, "id")
+	if !ok {
+		problem.Write(w, r, problem.BadRequest("Invalid tag ID"))
 		return
 	}
-	defer conn.Release()
 
-	commandTag, err := conn.Exec(ctx, "DELETE FROM tags WHERE id = $1", tagId)
-	if err != nil {
-		log.Printf("Failed to delete tag: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	err := s.Tags.DeleteTag(ctx, userId, tagId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		problem.Write(w, r, problem.NotFound("Tag not found"))
 		return
 	}
-	if commandTag.RowsAffected() == 0 {
-		http.Error(w, "Tag not found", http.StatusNotFound)
+	if err != nil {
+		log.Printf("Failed to delete tag %v: %v", tagId, err)
+		problem.Write(w, r, problem.Internal())
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func toTagItem(tagDto repository.TagDto) TagItem {
+	return TagItem{
+		Id:        tagDto.Id,
+		UserId:    tagDto.UserId,
+		Name:      tagDto.Name,
+		CreatedAt: tagDto.CreatedAt,
+		UpdatedAt: tagDto.UpdatedAt,
+	}
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"com.fukubox/middleware"
+	"com.fukubox/problem"
+	"com.fukubox/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+// defaultTrashRetention is how long deleted clothing items and categories stay restorable
+const defaultTrashRetention = 30 * 24 * time.Hour
+
+// TrashEntry is a deleted clothing item or category, type tells which. It is purged for good at PurgeAt.
+type TrashEntry struct {
+	Type      string    `json:"type"`
+	Id        int       `json:"id"`
+	Name      *string   `json:"name"`
+	ImageUrl  *string   `json:"image_url"`
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
+
+// trashRetention reads how long the trash keeps things from TRASH_RETENTION, a Go duration like 720h
+func trashRetention() time.Duration {
+	if value := os.Getenv("TRASH_RETENTION"); value != "" {
+		retention, err := time.ParseDuration(value)
+		if err == nil && retention > 0 {
+			return retention
+		}
+		log.Printf("Ignoring invalid TRASH_RETENTION %q", value)
+	}
+	return defaultTrashRetention
+}
+
+func (s *Server) GetTrash(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	entriesDto, err := s.Trash.GetTrashByUser(ctx, userId)
+	if err != nil {
+		log.Printf("Failed to get trash for user %v: %v", userId, err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	retention := trashRetention()
+	entries := []TrashEntry{}
+	for _, entryDto := range entriesDto {
+		entries = append(entries, TrashEntry{
+			Type:      entryDto.Type,
+			Id:        entryDto.Id,
+			Name:      entryDto.Name,
+			ImageUrl:  entryDto.ImageUrl,
+			DeletedAt: entryDto.DeletedAt,
+			PurgeAt:   entryDto.DeletedAt.Add(retention),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (s *Server) RestoreClothes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	clothId, ok := urlParamId(r, "id")
+	if !ok {
+		problem.Write(w, r, problem.BadRequest("Invalid clothing item ID"))
+		return
+	}
+
+	clothDto, err := s.Trash.RestoreCloth(ctx, userId, clothId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		problem.Write(w, r, problem.NotFound("Clothing item not found in the trash"))
+		return
+	}
+	if errors.Is(err, repository.ErrCategoryTrashed) {
+		problem.Write(w, r, problem.Conflict("The category of the clothing item is in the trash, restore it first"))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to restore cloth %v: %v", clothId, err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toCloth(clothDto))
+}
+
+func (s *Server) RestoreCategory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	categoryId, ok := urlParamId(r, "id")
+	if !ok {
+		problem.Write(w, r, problem.BadRequest("Invalid category ID"))
+		return
+	}
+
+	categoryDto, err := s.Trash.RestoreCategory(ctx, userId, categoryId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		problem.Write(w, r, problem.NotFound("Category not found in the trash"))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to restore category %v: %v", categoryId, err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toCategory(categoryDto))
+}
+
+func (s *Server) PurgeClothes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	clothId, ok := urlParamId(r, "id")
+	if !ok {
+		problem.Write(w, r, problem.BadRequest("Invalid clothing item ID"))
+		return
+	}
+
+	images, err := s.Trash.PurgeCloth(ctx, userId, clothId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		problem.Write(w, r, problem.NotFound("Clothing item not found in the trash"))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to purge cloth %v: %v", clothId, err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	deleteStoredImages(ctx, userId, images)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PurgeCategory deletes a category in the trash for good, along with the clothing items trashed in it
+func (s *Server) PurgeCategory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	categoryId, ok := urlParamId(r, "id")
+	if !ok {
+		problem.Write(w, r, problem.BadRequest("Invalid category ID"))
+		return
+	}
+
+	images, err := s.Trash.PurgeCategory(ctx, userId, categoryId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		problem.Write(w, r, problem.NotFound("Category not found in the trash"))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to purge category %v: %v", categoryId, err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	for _, itemImages := range images {
+		deleteStoredImages(ctx, userId, itemImages)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) EmptyTrash(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	images, err := s.Trash.EmptyTrash(ctx, userId)
+	if err != nil {
+		log.Printf("Failed to empty trash for user %v: %v", userId, err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	for _, itemImages := range images {
+		deleteStoredImages(ctx, userId, itemImages)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RunTrashPurge deletes whatever has been in the trash longer than TRASH_RETENTION, then again
+// every interval until ctx is done
+func (s *Server) RunTrashPurge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.purgeExpiredTrash(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) purgeExpiredTrash(ctx context.Context) {
+	images, err := s.Trash.PurgeTrashedBefore(ctx, time.Now().Add(-trashRetention()))
+	if err != nil {
+		log.Printf("Failed to purge expired trash: %v", err)
+		return
+	}
+
+	for _, itemImages := range images {
+		deleteStoredImages(ctx, itemImages.UserId, itemImages)
+	}
+	if len(images) > 0 {
+		log.Printf("Purged %d clothing items from the trash", len(images))
+	}
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"com.fukubox/imaging"
+	"com.fukubox/problem"
+	"com.fukubox/repository"
+	"com.fukubox/storage"
+)
+
+const defaultMaxUploadBytes = 10 << 20
+const imageFormField = "image"
+
+// allowedImageTypes maps the sniffed content types we accept to the extension they are stored with
+var allowedImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+const fullVariant = "full"
+
+// imageVariants are the downscaled sizes generated for every upload, next to the full size image
+var imageVariants = []struct {
+	Name    string
+	MaxSize int
+}{
+	{"thumbnail", 200},
+	{"medium", 800},
+}
+
+type imageUpload struct {
+	Data        []byte
+	ContentType string
+	Image       image.Image
+}
+
+// maxUploadBytes reads the per-image size limit from UPLOAD_MAX_BYTES
+func maxUploadBytes() int64 {
+	if value := os.Getenv("UPLOAD_MAX_BYTES"); value != "" {
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err == nil && limit > 0 {
+			return limit
+		}
+		log.Printf("Ignoring invalid UPLOAD_MAX_BYTES %q", value)
+	}
+	return defaultMaxUploadBytes
+}
+
+// parseImageUpload parses a multipart form and validates the size and sniffed content type of its image field
+func parseImageUpload(w http.ResponseWriter, r *http.Request) (imageUpload, error) {
+	limit := maxUploadBytes()
+
+	// leave headroom for the other form fields and multipart framing
+	r.Body = http.MaxBytesReader(w, r.Body, limit+1<<20)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return imageUpload{}, problem.PayloadTooLarge(fmt.Sprintf("Image must be at most %d bytes", limit))
+		}
+		return imageUpload{}, problem.BadRequest("Invalid multipart form")
+	}
+
+	file, header, err := r.FormFile(imageFormField)
+	if err != nil {
+		return imageUpload{}, problem.BadRequest("Missing image file")
+	}
+	defer file.Close()
+
+	if header.Size > limit {
+		return imageUpload{}, problem.PayloadTooLarge(fmt.Sprintf("Image must be at most %d bytes", limit))
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, limit+1))
+	if err != nil {
+		return imageUpload{}, err
+	}
+	if int64(len(data)) > limit {
+		return imageUpload{}, problem.PayloadTooLarge(fmt.Sprintf("Image must be at most %d bytes", limit))
+	}
+
+	return decodeImageUpload(data)
+}
+
+// decodeImageUpload checks the sniffed content type of an image and decodes it
+func decodeImageUpload(data []byte) (imageUpload, error) {
+	// trust the bytes rather than the client supplied Content-Type
+	contentType := http.DetectContentType(data)
+	if _, ok := allowedImageTypes[contentType]; !ok {
+		return imageUpload{}, problem.UnsupportedMediaType(fmt.Sprintf("Unsupported image type %v", contentType))
+	}
+
+	img, err := imaging.Decode(data)
+	if errors.Is(err, imaging.ErrTooManyPixels) {
+		return imageUpload{}, problem.PayloadTooLarge(fmt.Sprintf("Image must be at most %d pixels", imaging.MaxPixels))
+	}
+	if err != nil {
+		return imageUpload{}, problem.BadRequest("Image could not be decoded")
+	}
+
+	return imageUpload{Data: data, ContentType: contentType, Image: img}, nil
+}
+
+// storeClothImages saves the original upload for the user alongside a transparent PNG cutout of the garment
+// and the resized variants the closet displays. Photos without a uniform background are kept without a cutout.
+func storeClothImages(ctx context.Context, userId int, upload imageUpload) (repository.ClothImagesDto, error) {
+	store := storage.GetStorage()
+
+	key, err := storage.NewKey(userId)
+	if err != nil {
+		return repository.ClothImagesDto{}, err
+	}
+
+	originalKey := key + allowedImageTypes[upload.ContentType]
+	err = store.Put(ctx, originalKey, bytes.NewReader(upload.Data), int64(len(upload.Data)), upload.ContentType)
+	if err != nil {
+		return repository.ClothImagesDto{}, err
+	}
+
+	images := repository.ClothImagesDto{
+		ImageUrl: store.URL(originalKey),
+		Variants: map[string]string{},
+	}
+
+	// variants are derived from the cutout when we have one, as that is what the closet shows
+	source := upload.Image
+	fullUrl := images.ImageUrl
+
+	cutout, err := imaging.Cutout(upload.Image, imaging.DefaultCutoutOptions)
+	if errors.Is(err, imaging.ErrNoUniformBackground) || errors.Is(err, imaging.ErrNoSubject) {
+		log.Printf("Skipping cutout for %v: %v", originalKey, err)
+	} else if err != nil {
+		deleteStoredImages(ctx, userId, images)
+		return repository.ClothImagesDto{}, err
+	} else {
+		cutoutUrl, err := putImage(ctx, key+"-cutout", cutout, true)
+		if err != nil {
+			deleteStoredImages(ctx, userId, images)
+			return repository.ClothImagesDto{}, err
+		}
+		images.CutoutUrl = &cutoutUrl
+
+		source = cutout
+		fullUrl = cutoutUrl
+	}
+
+	transparent := imaging.HasTransparency(source)
+	for _, variant := range imageVariants {
+		url, err := putImage(ctx, key+"-"+variant.Name, imaging.Fit(source, variant.MaxSize), transparent)
+		if err != nil {
+			deleteStoredImages(ctx, userId, images)
+			return repository.ClothImagesDto{}, err
+		}
+		images.Variants[variant.Name] = url
+	}
+	images.Variants[fullVariant] = fullUrl
+
+	return images, nil
+}
+
+// putImage encodes img as a PNG when it needs transparency and a JPEG otherwise and stores it under keyPrefix
+func putImage(ctx context.Context, keyPrefix string, img image.Image, transparent bool) (string, error) {
+	store := storage.GetStorage()
+
+	key, contentType := keyPrefix+".jpg", "image/jpeg"
+	encode := imaging.EncodeJPEG
+	if transparent {
+		key, contentType = keyPrefix+".png", "image/png"
+		encode = imaging.EncodePNG
+	}
+
+	data, err := encode(img)
+	if err != nil {
+		return "", err
+	}
+
+	err = store.Put(ctx, key, bytes.NewReader(data), int64(len(data)), contentType)
+	if err != nil {
+		return "", err
+	}
+
+	return store.URL(key), nil
+}
+
+// deleteStoredImages removes images we stored earlier for the user, ignoring external URLs
+func deleteStoredImages(ctx context.Context, userId int, images repository.ClothImagesDto) {
+	deleted := map[string]bool{}
+
+	urls := []string{images.ImageUrl}
+	if images.CutoutUrl != nil {
+		urls = append(urls, *images.CutoutUrl)
+	}
+	for _, url := range images.Variants {
+		urls = append(urls, url)
+	}
+
+	for _, url := range urls {
+		if !deleted[url] {
+			deleteStoredImage(ctx, userId, url)
+			deleted[url] = true
+		}
+	}
+}
+
+func deleteStoredImage(ctx context.Context, userId int, url string) {
+	store := storage.GetStorage()
+
+	key, ok := store.Key(url)
+	if !ok {
+		return
+	}
+	// image_url is set by clients, it can't be trusted to point at the user's own files
+	if !storage.OwnsKey(userId, key) {
+		log.Printf("Not deleting stored image %v, it isn't one of user %v", key, userId)
+		return
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		log.Printf("Failed to delete stored image %v: %v", key, err)
+	}
+}
+
+// checkImageUrl adds an error to errs when url is a file stored for another user. Stored images are
+// deleted along with the item pointing at them, so an item may only point at its owner's.
+func checkImageUrl(errs fieldErrors, field string, userId int, url string) {
+	key, ok := storage.GetStorage().Key(url)
+	if ok && !storage.OwnsKey(userId, key) {
+		errs[field] = "is an image stored for another user"
+	}
+}
+
+// writeUploadError responds to an error returned by parseImageUpload
+func writeUploadError(w http.ResponseWriter, r *http.Request, err error) {
+	var uploadProblem *problem.Problem
+	if errors.As(err, &uploadProblem) {
+		problem.Write(w, r, uploadProblem)
+		return
+	}
+
+	log.Printf("Failed to read image upload: %v", err)
+	problem.Write(w, r, problem.Internal())
+}
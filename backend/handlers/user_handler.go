@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"com.fukubox/middleware"
+	"com.fukubox/problem"
+	"com.fukubox/repository"
+	"com.fukubox/starter"
+	"github.com/jackc/pgx/v5"
+)
+
+type User struct {
+	Id        int       `json:"id"`
+	Username  string    `json:"username"`
+	Email     *string   `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type UserCreate struct {
+	Username string `json:"username" validate:"required,max=255"`
+	Email    string `json:"email" validate:"required,email,max=255"`
+}
+
+type UserEdit struct {
+	Username *string `json:"username" validate:"omitempty,min=1,max=255"`
+	Email    *string `json:"email" validate:"omitempty,email,max=255"`
+}
+
+// UserPatch is the merge patch body of PATCH /me, a null email removes it
+type UserPatch struct {
+	Username Optional[string]
+	Email    Optional[string]
+}
+
+func (patch *UserPatch) fields() map[string]patchField {
+	return map[string]patchField{"username": &patch.Username, "email": &patch.Email}
+}
+
+func (patch UserPatch) check(errs fieldErrors) {
+	if patch.Username.null() {
+		errs["username"] = "can't be null"
+	}
+
+	err := newValidator().Struct(UserEdit{Username: patch.Username.Value, Email: patch.Email.Value})
+	if err != nil {
+		addValidationErrors(errs, err)
+	}
+}
+
+func (s *Server) CreateUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req UserCreate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("Failed to decode request as handlers.UserCreate: %v", err)
+		problem.Write(w, r, problem.BadRequest("Invalid request"))
+		return
+	}
+
+	validate := newValidator()
+
+	if err := validate.Struct(req); err != nil {
+		problem.Write(w, r, validationProblem(err))
+		return
+	}
+
+	userDto, err := s.Users.CreateUser(ctx, repository.UserEditDto{
+		Username: &req.Username,
+		Email:    &req.Email,
+	})
+	if errors.Is(err, repository.ErrDuplicate) {
+		problem.Write(w, r, problem.Conflict("A user with that email already exists"))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to create user: %v", err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	s.applyStarterKit(ctx, userDto.Id, r.Header.Get("Accept-Language"))
+
+	writeJSON(w, http.StatusCreated, toUser(userDto))
+}
+
+// applyStarterKit gives a new user the starter categories and tags for their languages. A user
+// only ever gets one kit, and failing to apply it doesn't fail signing up, so errors are only logged.
+func (s *Server) applyStarterKit(ctx context.Context, userId int, languages ...string) {
+	kit, err := starter.For(languages...)
+	if err != nil {
+		log.Printf("Failed to apply starter kit for user %v: %v", userId, err)
+		return
+	}
+
+	applied, err := s.Users.ApplyStarterKit(ctx, userId, kit)
+	if err != nil {
+		log.Printf("Failed to apply starter kit for user %v: %v", userId, err)
+		return
+	}
+	if applied {
+		log.Printf("Applied starter kit for user %v", userId)
+	}
+}
+
+func (s *Server) GetMe(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	userDto, err := s.Users.GetUserById(ctx, userId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		problem.Write(w, r, problem.NotFound("User not found"))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to get user %v: %v", userId, err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toUser(userDto))
+}
+
+func (s *Server) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	var patch UserPatch
+	errs, decodeProblem := decodeMergePatch(r, patch.fields())
+	if decodeProblem != nil {
+		problem.Write(w, r, decodeProblem)
+		return
+	}
+	patch.check(errs)
+	if len(errs) > 0 {
+		problem.Write(w, r, problem.InvalidFields(errs))
+		return
+	}
+
+	userDto, err := s.Users.UpdateUser(ctx, userId, repository.UserPatchDto{
+		Username: patch.Username.Value,
+		Email:    patch.Email.dto(),
+	})
+	if errors.Is(err, repository.ErrDuplicate) {
+		problem.Write(w, r, problem.Conflict("A user with that email already exists"))
+		return
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		problem.Write(w, r, problem.NotFound("User not found"))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to update user %v: %v", userId, err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toUser(userDto))
+}
+
+func (s *Server) DeleteMe(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	images, err := s.Users.DeleteUser(ctx, userId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		problem.Write(w, r, problem.NotFound("User not found"))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to delete user %v: %v", userId, err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	// the account is gone, so are the uploads under it
+	for _, itemImages := range images {
+		deleteStoredImages(ctx, userId, itemImages)
+	}
+
+	clearCookie(w, r, middleware.SessionCookieName)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func toUser(userDto repository.UserDto) User {
+	return User{
+		Id:        userDto.Id,
+		Username:  userDto.Username,
+		Email:     userDto.Email,
+		CreatedAt: userDto.CreatedAt,
+		UpdatedAt: userDto.UpdatedAt,
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"com.fukubox/problem"
+	"github.com/go-playground/validator"
+)
+
+// newValidator returns a validator that reports fields by their json name
+func newValidator() *validator.Validate {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return validate
+}
+
+// addValidationErrors adds each failed validation rule to errs, under the json path of its field
+func addValidationErrors(errs fieldErrors, err error) {
+	validationErrs, ok := err.(validator.ValidationErrors)
+	if !ok {
+		errs[""] = err.Error()
+		return
+	}
+
+	for _, err := range validationErrs {
+		errs[fieldPath(err.Namespace())] = ruleMessage(err)
+	}
+}
+
+// validationProblem reports the rules a request body failed
+func validationProblem(err error) *problem.Problem {
+	errs := fieldErrors{}
+	addValidationErrors(errs, err)
+	return problem.InvalidFields(errs)
+}
+
+// fieldPath turns a namespace like ClothEdit.ClothAttributes.name or SandboxLayout.positions[0].position_x
+// into the path of the field in the request body, dropping the Go names of the struct and embedded structs
+func fieldPath(namespace string) string {
+	path := []string{}
+	for _, part := range strings.Split(namespace, ".")[1:] {
+		if first, _ := utf8.DecodeRuneInString(part); !unicode.IsUpper(first) {
+			path = append(path, part)
+		}
+	}
+	return strings.Join(path, ".")
+}
+
+func ruleMessage(err validator.FieldError) string {
+	unit := ""
+	if err.Kind() == reflect.String {
+		unit = " characters"
+	}
+
+	switch err.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		return fmt.Sprintf("must be at least %v%v", err.Param(), unit)
+	case "max":
+		return fmt.Sprintf("must be at most %v%v", err.Param(), unit)
+	case "len":
+		return fmt.Sprintf("must be exactly %v%v", err.Param(), unit)
+	case "gt":
+		return fmt.Sprintf("must be greater than %v", err.Param())
+	case "gte":
+		return fmt.Sprintf("must be at least %v", err.Param())
+	case "lt":
+		return fmt.Sprintf("must be less than %v", err.Param())
+	case "email":
+		return "must be an email address"
+	case "alpha":
+		return "must only contain letters"
+	case "cloth_color":
+		return "must be one of " + strings.Join(clothColors, ", ")
+	case "cloth_season":
+		return "must be one of " + strings.Join(clothSeasons, ", ")
+	default:
+		return fmt.Sprintf("fails the %v rule", err.Tag())
+	}
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"com.fukubox/middleware"
+	"com.fukubox/problem"
+	"com.fukubox/repository"
+)
+
+// maxCalendarDays is the longest span GET /calendar returns at once
+const maxCalendarDays = 366
+
+// WearEvent is a day the user wore clothing items, with the outfit they logged it as if any.
+// Outfit is null once the outfit is deleted, ClothingItemIds keeps what was worn.
+type WearEvent struct {
+	Id              int        `json:"id"`
+	UserId          int        `json:"user_id"`
+	Date            Date       `json:"date"`
+	Outfit          *OutfitRef `json:"outfit"`
+	ClothingItemIds []int      `json:"clothing_item_ids"`
+	Note            *string    `json:"note"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// WearEdit is the body of POST /wear, logging an outfit, loose clothing items or both
+type WearEdit struct {
+	Date            *Date   `json:"date" validate:"required"`
+	OutfitId        *int    `json:"outfit_id" validate:"omitempty,gt=0"`
+	ClothingItemIds []int   `json:"clothing_item_ids" validate:"dive,gt=0"`
+	Note            *string `json:"note" validate:"omitempty,max=4000"`
+}
+
+// check adds the rules struct tags can't express to errs
+func (edit WearEdit) check(errs fieldErrors) {
+	if edit.OutfitId == nil && len(edit.ClothingItemIds) == 0 {
+		errs["clothing_item_ids"] = "is required without an outfit_id"
+	}
+	if edit.Date != nil && edit.Date.inFuture() {
+		errs["date"] = "can't be in the future"
+	}
+}
+
+// CalendarDay lists what was worn on a day
+type CalendarDay struct {
+	Date  Date        `json:"date"`
+	Wears []WearEvent `json:"wears"`
+}
+
+func (s *Server) CreateWearEvent(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	var req WearEdit
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("Failed to decode request as handlers.WearEdit: %v", err)
+		problem.Write(w, r, problem.BadRequest("Invalid request"))
+		return
+	}
+
+	errs := fieldErrors{}
+	if err := newValidator().Struct(req); err != nil {
+		addValidationErrors(errs, err)
+	}
+	req.check(errs)
+	if len(errs) > 0 {
+		problem.Write(w, r, problem.InvalidFields(errs))
+		return
+	}
+
+	eventDto, err := s.Wears.CreateWearEvent(ctx, userId, repository.WearEventEditDto{
+		WornOn:          req.Date.Time,
+		OutfitId:        req.OutfitId,
+		ClothingItemIds: req.ClothingItemIds,
+		Note:            req.Note,
+	})
+	if errors.Is(err, repository.ErrOutfitNotOwned) {
+		problem.Write(w, r, problem.InvalidFields(fieldErrors{"outfit_id": "is not an outfit of yours"}))
+		return
+	}
+	if errors.Is(err, repository.ErrItemNotOwned) {
+		problem.Write(w, r, problem.InvalidFields(fieldErrors{"clothing_item_ids": "contains an unknown clothing item id"}))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to create wear event: %v", err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, toWearEvent(eventDto))
+}
+
+// GetCalendar returns the days from one date to another, both included, on which the user wore something
+func (s *Server) GetCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := middleware.GetUserId(ctx)
+
+	from, err := time.Parse(dateLayout, r.URL.Query().Get("from"))
+	if err != nil {
+		problem.Write(w, r, problem.BadRequest("from must be a date formatted as YYYY-MM-DD"))
+		return
+	}
+	to, err := time.Parse(dateLayout, r.URL.Query().Get("to"))
+	if err != nil {
+		problem.Write(w, r, problem.BadRequest("to must be a date formatted as YYYY-MM-DD"))
+		return
+	}
+	if to.Before(from) {
+		problem.Write(w, r, problem.BadRequest("to can't be before from"))
+		return
+	}
+	if to.Sub(from) >= maxCalendarDays*24*time.Hour {
+		problem.Write(w, r, problem.BadRequest("The calendar spans at most 366 days"))
+		return
+	}
+
+	eventsDto, err := s.Wears.GetWearEventsByUser(ctx, userId, from, to)
+	if err != nil {
+		log.Printf("Failed to get wear events: %v", err)
+		problem.Write(w, r, problem.Internal())
+		return
+	}
+
+	// events come ordered by day, so each day is the last one or a new one
+	days := []CalendarDay{}
+	for _, eventDto := range eventsDto {
+		event := toWearEvent(eventDto)
+		if len(days) == 0 || !days[len(days)-1].Date.Equal(event.Date.Time) {
+			days = append(days, CalendarDay{Date: event.Date, Wears: []WearEvent{}})
+		}
+		days[len(days)-1].Wears = append(days[len(days)-1].Wears, event)
+	}
+
+	writeJSON(w, http.StatusOK, days)
+}
+
+func toWearEvent(eventDto repository.WearEventDto) WearEvent {
+	event := WearEvent{
+		Id:              eventDto.Id,
+		UserId:          eventDto.UserId,
+		Date:            Date{eventDto.WornOn},
+		ClothingItemIds: eventDto.ClothingItemIds,
+		Note:            eventDto.Note,
+		CreatedAt:       eventDto.CreatedAt,
+	}
+	if event.ClothingItemIds == nil {
+		event.ClothingItemIds = []int{}
+	}
+	if eventDto.OutfitId != nil && eventDto.OutfitName != nil {
+		event.Outfit = &OutfitRef{Id: *eventDto.OutfitId, Name: *eventDto.OutfitName}
+	}
+	return event
+}
@@ -0,0 +1,51 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+
+	// register the formats accepted for upload with image.Decode
+	_ "image/gif"
+	_ "image/jpeg"
+
+	_ "golang.org/x/image/webp"
+)
+
+// MaxPixels is the largest width×height Decode accepts. A few kilobytes of PNG or JPEG can claim
+// a huge image, and decoding it, let alone cutting it out, allocates all of its pixels.
+const MaxPixels = 24_000_000
+
+// ErrTooManyPixels means an image is larger than MaxPixels
+var ErrTooManyPixels = errors.New("image has too many pixels")
+
+// Decode parses an uploaded JPEG, PNG, GIF or WebP image, checking its size before decoding it.
+// JPEGs are turned the way their EXIF orientation says, as phones store photos taken sideways
+// unrotated.
+func Decode(data []byte) (image.Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if int64(config.Width)*int64(config.Height) > MaxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if format == "jpeg" {
+		img = orient(img, jpegOrientation(data))
+	}
+	return img, nil
+}
+
+func EncodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
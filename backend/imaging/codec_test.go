@@ -0,0 +1,211 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+var (
+	red  = color.NRGBA{R: 220, G: 20, B: 20, A: 255}
+	blue = color.NRGBA{R: 20, G: 20, B: 220, A: 255}
+)
+
+// halves is a width x height image, red on its left half and blue on its right
+func halves(width int, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, red)
+			if x >= width/2 {
+				img.SetNRGBA(x, y, blue)
+			}
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withOrientation inserts an EXIF segment holding orientation right after the start of a JPEG
+func withOrientation(data []byte, order binary.AppendByteOrder, orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a")
+	if order == binary.LittleEndian {
+		tiff = []byte("II\x2a\x00")
+	}
+	tiff = order.AppendUint32(tiff, 8)
+	// one entry: the orientation, a single SHORT
+	tiff = order.AppendUint16(tiff, 1)
+	tiff = order.AppendUint16(tiff, orientationTag)
+	tiff = order.AppendUint16(tiff, 3)
+	tiff = order.AppendUint32(tiff, 1)
+	tiff = order.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0)
+	tiff = order.AppendUint32(tiff, 0)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := binary.BigEndian.AppendUint16([]byte{0xFF, 0xE1}, uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	return append(append(append([]byte{}, data[:2]...), app1...), data[2:]...)
+}
+
+// pngHeader is the start of a PNG claiming to be width by height pixels, which is all it takes
+// to read its size
+func pngHeader(width uint32, height uint32) []byte {
+	chunk := binary.BigEndian.AppendUint32([]byte("IHDR"), width)
+	chunk = binary.BigEndian.AppendUint32(chunk, height)
+	// 8 bit RGBA, no interlacing
+	chunk = append(chunk, 8, 6, 0, 0, 0)
+
+	data := binary.BigEndian.AppendUint32([]byte("\x89PNG\r\n\x1a\n"), uint32(len(chunk)-4))
+	data = append(data, chunk...)
+	return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(chunk))
+}
+
+// isColor reports whether c is roughly want, JPEG doesn't keep colors exactly
+func isColor(c color.Color, want color.NRGBA) bool {
+	got := color.NRGBAModel.Convert(c).(color.NRGBA)
+	if got.A != want.A {
+		return false
+	}
+	return got.A == 0 || distanceSq([]uint8{got.R, got.G, got.B}, want) < 40*40
+}
+
+func TestDecode(t *testing.T) {
+	// a palette of exactly the two colors, as GIF can't store any other
+	paletted := image.NewPaletted(image.Rect(0, 0, 16, 8), color.Palette{red, blue})
+	draw.Draw(paletted, paletted.Bounds(), halves(16, 8), image.Point{}, draw.Src)
+	var gifData bytes.Buffer
+	if err := gif.Encode(&gifData, paletted, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	transparent := halves(16, 8)
+	transparent.SetNRGBA(0, 0, color.NRGBA{})
+
+	jpegData := encodeJPEG(t, halves(16, 8))
+
+	for _, test := range []struct {
+		name string
+		data []byte
+		// fails is set when decoding should fail, with err when it should fail with that error
+		fails bool
+		err   error
+		// size is the size of the decoded image, topLeft and bottomRight the colors in its corners
+		size        image.Point
+		topLeft     color.NRGBA
+		bottomRight color.NRGBA
+	}{
+		{name: "png", data: encodePNG(t, halves(16, 8)), size: image.Pt(16, 8), topLeft: red, bottomRight: blue},
+		{name: "transparent png", data: encodePNG(t, transparent), size: image.Pt(16, 8), topLeft: color.NRGBA{}, bottomRight: blue},
+		{name: "gif", data: gifData.Bytes(), size: image.Pt(16, 8), topLeft: red, bottomRight: blue},
+		{name: "jpeg", data: jpegData, size: image.Pt(16, 8), topLeft: red, bottomRight: blue},
+		{name: "jpeg as stored", data: withOrientation(jpegData, binary.BigEndian, 1), size: image.Pt(16, 8), topLeft: red, bottomRight: blue},
+		{name: "jpeg upside down", data: withOrientation(jpegData, binary.LittleEndian, 3), size: image.Pt(16, 8), topLeft: blue, bottomRight: red},
+		{name: "jpeg turned clockwise", data: withOrientation(jpegData, binary.BigEndian, 6), size: image.Pt(8, 16), topLeft: red, bottomRight: blue},
+		{name: "jpeg turned counterclockwise", data: withOrientation(jpegData, binary.LittleEndian, 8), size: image.Pt(8, 16), topLeft: blue, bottomRight: red},
+		{name: "jpeg with an invalid orientation", data: withOrientation(jpegData, binary.BigEndian, 42), size: image.Pt(16, 8), topLeft: red, bottomRight: blue},
+		{name: "oversized", data: pngHeader(50000, 50000), fails: true, err: ErrTooManyPixels},
+		{name: "just over the limit", data: pngHeader(MaxPixels/1000+1, 1000), fails: true, err: ErrTooManyPixels},
+		{name: "truncated", data: pngHeader(16, 8), fails: true},
+		{name: "not an image", data: []byte("not an image"), fails: true, err: image.ErrFormat},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			img, err := Decode(test.data)
+			if test.fails {
+				if err == nil || (test.err != nil && !errors.Is(err, test.err)) {
+					t.Fatalf("expected an error like %v, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			bounds := img.Bounds()
+			if bounds.Size() != test.size {
+				t.Fatalf("expected a %v image, got %v", test.size, bounds.Size())
+			}
+			if got := img.At(bounds.Min.X, bounds.Min.Y); !isColor(got, test.topLeft) {
+				t.Errorf("expected %v in the top left corner, got %v", test.topLeft, got)
+			}
+			if got := img.At(bounds.Max.X-1, bounds.Max.Y-1); !isColor(got, test.bottomRight) {
+				t.Errorf("expected %v in the bottom right corner, got %v", test.bottomRight, got)
+			}
+		})
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// a 3 x 2 image numbered in reading order, and how each orientation shows it
+	src := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	for i := range 6 {
+		src.Pix[i*4], src.Pix[i*4+3] = uint8(i+1), 255
+	}
+
+	for _, test := range []struct {
+		orientation int
+		want        [][]uint8
+	}{
+		{1, [][]uint8{{1, 2, 3}, {4, 5, 6}}},
+		{2, [][]uint8{{3, 2, 1}, {6, 5, 4}}},
+		{3, [][]uint8{{6, 5, 4}, {3, 2, 1}}},
+		{4, [][]uint8{{4, 5, 6}, {1, 2, 3}}},
+		{5, [][]uint8{{1, 4}, {2, 5}, {3, 6}}},
+		{6, [][]uint8{{4, 1}, {5, 2}, {6, 3}}},
+		{7, [][]uint8{{6, 3}, {5, 2}, {4, 1}}},
+		{8, [][]uint8{{3, 6}, {2, 5}, {1, 4}}},
+	} {
+		img := orient(src, test.orientation)
+		got := [][]uint8{}
+		for y := 0; y < img.Bounds().Dy(); y++ {
+			row := []uint8{}
+			for x := 0; x < img.Bounds().Dx(); x++ {
+				row = append(row, color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA).R)
+			}
+			got = append(got, row)
+		}
+		if !equalRows(got, test.want) {
+			t.Errorf("orientation %d: expected %v, got %v", test.orientation, test.want, got)
+		}
+	}
+}
+
+func equalRows(a [][]uint8, b [][]uint8) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
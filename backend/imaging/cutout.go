@@ -0,0 +1,176 @@
+package imaging
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+)
+
+// ErrNoUniformBackground means the photo's border is too busy to tell garment from background
+var ErrNoUniformBackground = errors.New("image does not have a uniform background")
+
+// ErrNoSubject means nothing stood out from the background
+var ErrNoSubject = errors.New("no garment found against the background")
+
+type CutoutOptions struct {
+	// Tolerance is the largest RGB distance from the background color still treated as background
+	Tolerance float64
+	// MinBorderUniformity is the fraction of border pixels that must match the background color
+	MinBorderUniformity float64
+	// MinSubjectFraction is the smallest share of the image the garment may cover
+	MinSubjectFraction float64
+	// Padding is how many pixels of background to keep around the garment's bounding box
+	Padding int
+}
+
+var DefaultCutoutOptions = CutoutOptions{
+	Tolerance:           48,
+	MinBorderUniformity: 0.6,
+	MinSubjectFraction:  0.01,
+	Padding:             4,
+}
+
+// Cutout isolates the garment in a photo taken against a mostly uniform background.
+// The background is found by flood filling inward from the border with pixels close to
+// the dominant border color; the result is cropped to the garment and the background made transparent.
+func Cutout(img image.Image, options CutoutOptions) (*image.NRGBA, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return nil, ErrNoSubject
+	}
+
+	src := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	border := borderPixels(width, height)
+
+	background := medianColor(src, border)
+	toleranceSq := options.Tolerance * options.Tolerance
+
+	isBackground := func(i int) bool {
+		offset := i * 4
+		if src.Pix[offset+3] < 16 {
+			return true
+		}
+		return distanceSq(src.Pix[offset:offset+3], background) <= toleranceSq
+	}
+
+	matching := 0
+	for _, i := range border {
+		if isBackground(i) {
+			matching++
+		}
+	}
+	if float64(matching) < options.MinBorderUniformity*float64(len(border)) {
+		return nil, ErrNoUniformBackground
+	}
+
+	// flood fill the background from the border so enclosed regions of a similar color stay part of the garment
+	mask := make([]bool, width*height)
+	queue := make([]int, 0, len(border))
+	for _, i := range border {
+		if !mask[i] && isBackground(i) {
+			mask[i] = true
+			queue = append(queue, i)
+		}
+	}
+
+	for len(queue) > 0 {
+		i := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+
+		x, y := i%width, i/width
+		for _, n := range [4][2]int{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}} {
+			if n[0] < 0 || n[0] >= width || n[1] < 0 || n[1] >= height {
+				continue
+			}
+			j := n[1]*width + n[0]
+			if !mask[j] && isBackground(j) {
+				mask[j] = true
+				queue = append(queue, j)
+			}
+		}
+	}
+
+	minX, minY, maxX, maxY := width, height, -1, -1
+	subject := 0
+	for i, background := range mask {
+		if background {
+			continue
+		}
+		subject++
+		x, y := i%width, i/width
+		minX, maxX = min(minX, x), max(maxX, x)
+		minY, maxY = min(minY, y), max(maxY, y)
+	}
+	if subject == 0 || float64(subject) < options.MinSubjectFraction*float64(width*height) {
+		return nil, ErrNoSubject
+	}
+
+	box := image.Rect(minX-options.Padding, minY-options.Padding, maxX+1+options.Padding, maxY+1+options.Padding).
+		Intersect(src.Bounds())
+
+	cutout := image.NewNRGBA(image.Rect(0, 0, box.Dx(), box.Dy()))
+	for y := box.Min.Y; y < box.Max.Y; y++ {
+		for x := box.Min.X; x < box.Max.X; x++ {
+			i := y*width + x
+			if mask[i] {
+				continue
+			}
+			cutout.SetNRGBA(x-box.Min.X, y-box.Min.Y, src.NRGBAAt(x, y))
+		}
+	}
+
+	return cutout, nil
+}
+
+// borderPixels returns the index of every pixel on the edge of a width x height image
+func borderPixels(width int, height int) []int {
+	border := make([]int, 0, 2*(width+height))
+	for x := 0; x < width; x++ {
+		border = append(border, x)
+		if height > 1 {
+			border = append(border, (height-1)*width+x)
+		}
+	}
+	for y := 1; y < height-1; y++ {
+		border = append(border, y*width)
+		if width > 1 {
+			border = append(border, y*width+width-1)
+		}
+	}
+	return border
+}
+
+// medianColor takes the per channel median of the given pixels, which ignores the odd
+// garment pixel touching the edge better than an average would
+func medianColor(img *image.NRGBA, pixels []int) color.NRGBA {
+	channels := [3][]uint8{}
+	for c := range channels {
+		channels[c] = make([]uint8, 0, len(pixels))
+	}
+
+	for _, i := range pixels {
+		for c := range channels {
+			channels[c] = append(channels[c], img.Pix[i*4+c])
+		}
+	}
+
+	median := [3]uint8{}
+	for c, values := range channels {
+		sort.Slice(values, func(a, b int) bool { return values[a] < values[b] })
+		median[c] = values[len(values)/2]
+	}
+
+	return color.NRGBA{R: median[0], G: median[1], B: median[2], A: 255}
+}
+
+func distanceSq(rgb []uint8, c color.NRGBA) float64 {
+	dr := float64(rgb[0]) - float64(c.R)
+	dg := float64(rgb[1]) - float64(c.G)
+	db := float64(rgb[2]) - float64(c.B)
+	return dr*dr + dg*dg + db*db
+}
@@ -0,0 +1,149 @@
+package imaging
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+var white = color.NRGBA{R: 250, G: 250, B: 250, A: 255}
+
+// photo is a size x size image of background with draw applied to every pixel on top of it
+func photo(size int, background color.NRGBA, draw func(x, y int) (color.NRGBA, bool)) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.SetNRGBA(x, y, background)
+			if c, ok := draw(x, y); ok {
+				img.SetNRGBA(x, y, c)
+			}
+		}
+	}
+	return img
+}
+
+// square draws c over the pixels from min to max, both included
+func square(min int, max int, c color.NRGBA) func(x, y int) (color.NRGBA, bool) {
+	return func(x, y int) (color.NRGBA, bool) {
+		return c, x >= min && x <= max && y >= min && y <= max
+	}
+}
+
+func TestCutout(t *testing.T) {
+	noise := rand.New(rand.NewSource(1))
+
+	for _, test := range []struct {
+		name string
+		img  image.Image
+		err  error
+		// size is the size of the cutout, and opaque and clear points in it that should keep
+		// their color or have been made transparent
+		size   image.Point
+		opaque []image.Point
+		clear  []image.Point
+	}{
+		{
+			name: "garment on white",
+			img:  photo(32, white, square(10, 21, red)),
+			// the 12 pixel square with 4 pixels of padding on every side
+			size:   image.Pt(20, 20),
+			opaque: []image.Point{{4, 4}, {15, 15}},
+			clear:  []image.Point{{0, 0}, {3, 3}, {19, 19}},
+		},
+		{
+			name: "slightly uneven background",
+			img: photo(32, white, func(x, y int) (color.NRGBA, bool) {
+				if c, ok := square(10, 21, red)(x, y); ok {
+					return c, true
+				}
+				shade := uint8(noise.Intn(20))
+				return color.NRGBA{R: 240 + shade/2, G: 235 + shade/2, B: 230 + shade, A: 255}, true
+			}),
+			size:   image.Pt(20, 20),
+			opaque: []image.Point{{4, 4}, {15, 15}},
+			clear:  []image.Point{{0, 0}, {19, 19}},
+		},
+		{
+			name:   "transparent background",
+			img:    photo(32, color.NRGBA{}, square(10, 21, blue)),
+			size:   image.Pt(20, 20),
+			opaque: []image.Point{{4, 4}, {15, 15}},
+			clear:  []image.Point{{0, 0}, {19, 19}},
+		},
+		{
+			// the flood fill only reaches background connected to the border, so a hole in the
+			// garment the color of the background stays part of it
+			name: "enclosed background colored patch",
+			img: photo(32, white, func(x, y int) (color.NRGBA, bool) {
+				if c, ok := square(14, 17, white)(x, y); ok {
+					return c, true
+				}
+				return square(10, 21, red)(x, y)
+			}),
+			size:   image.Pt(20, 20),
+			opaque: []image.Point{{4, 4}, {8, 8}, {9, 9}},
+		},
+		{
+			name: "garment touching the edge",
+			img:  photo(32, white, square(20, 31, red)),
+			// padding can't go past the edge of the photo
+			size:   image.Pt(16, 16),
+			opaque: []image.Point{{4, 4}, {15, 15}},
+			clear:  []image.Point{{0, 0}, {3, 3}},
+		},
+		{
+			name: "busy background",
+			img: photo(32, white, func(x, y int) (color.NRGBA, bool) {
+				return color.NRGBA{R: uint8(noise.Intn(256)), G: uint8(noise.Intn(256)), B: uint8(noise.Intn(256)), A: 255}, true
+			}),
+			err: ErrNoUniformBackground,
+		},
+		{
+			name: "nothing but background",
+			img:  photo(32, white, square(0, -1, red)),
+			err:  ErrNoSubject,
+		},
+		{
+			name: "speck smaller than a garment",
+			img:  photo(32, white, square(16, 16, red)),
+			err:  ErrNoSubject,
+		},
+		{
+			name: "empty image",
+			img:  image.NewNRGBA(image.Rect(0, 0, 0, 0)),
+			err:  ErrNoSubject,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cutout, err := Cutout(test.img, DefaultCutoutOptions)
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Fatalf("expected %v, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if cutout.Bounds().Size() != test.size {
+				t.Fatalf("expected a %v cutout, got %v", test.size, cutout.Bounds().Size())
+			}
+			for _, p := range test.opaque {
+				if c := cutout.NRGBAAt(p.X, p.Y); c.A != 255 {
+					t.Errorf("expected %v to be kept, got %v", p, c)
+				}
+			}
+			for _, p := range test.clear {
+				if c := cutout.NRGBAAt(p.X, p.Y); c.A != 0 {
+					t.Errorf("expected %v to be transparent, got %v", p, c)
+				}
+			}
+			if !HasTransparency(cutout) {
+				t.Error("cutout isn't transparent")
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestFit(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		size    image.Point
+		maxSize int
+		want    image.Point
+	}{
+		// the sizes of the thumbnail and medium variants
+		{"landscape thumbnail", image.Pt(1000, 500), 200, image.Pt(200, 100)},
+		{"portrait thumbnail", image.Pt(500, 1000), 200, image.Pt(100, 200)},
+		{"square medium", image.Pt(1200, 1200), 800, image.Pt(800, 800)},
+		{"portrait medium", image.Pt(3000, 4000), 800, image.Pt(600, 800)},
+		{"already fits", image.Pt(120, 80), 200, image.Pt(120, 80)},
+		{"exactly the size", image.Pt(200, 150), 200, image.Pt(200, 150)},
+		{"sliver", image.Pt(1000, 1), 200, image.Pt(200, 1)},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			img := image.NewNRGBA(image.Rectangle{Max: test.size})
+
+			fitted := Fit(img, test.maxSize)
+			if got := fitted.Bounds().Size(); got != test.want {
+				t.Fatalf("expected %v, got %v", test.want, got)
+			}
+			if test.size == test.want && fitted != image.Image(img) {
+				t.Fatal("an image that fits was copied")
+			}
+		})
+	}
+}
+
+func TestFitKeepsTransparency(t *testing.T) {
+	cutout, err := Cutout(photo(64, white, square(20, 43, red)), DefaultCutoutOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	thumbnail := Fit(cutout, 16)
+	if !HasTransparency(thumbnail) {
+		t.Fatal("thumbnail of a cutout lost its transparency")
+	}
+	if c := color.NRGBAModel.Convert(thumbnail.At(0, 0)).(color.NRGBA); c.A != 0 {
+		t.Fatalf("expected a transparent corner, got %v", c)
+	}
+	if c := color.NRGBAModel.Convert(thumbnail.At(8, 8)).(color.NRGBA); !isColor(c, red) {
+		t.Fatalf("expected the garment in the middle, got %v", c)
+	}
+}
+
+func TestHasTransparency(t *testing.T) {
+	transparent := halves(4, 4)
+	transparent.SetNRGBA(0, 0, color.NRGBA{R: 255, A: 128})
+
+	for _, test := range []struct {
+		name string
+		img  image.Image
+		want bool
+	}{
+		{"opaque", halves(4, 4), false},
+		{"one translucent pixel", transparent, true},
+		{"jpeg", image.NewYCbCr(image.Rect(0, 0, 4, 4), image.YCbCrSubsampleRatio420), false},
+		{"gray", image.NewGray(image.Rect(0, 0, 4, 4)), false},
+	} {
+		if got := HasTransparency(test.img); got != test.want {
+			t.Errorf("%v: expected %v, got %v", test.name, test.want, got)
+		}
+	}
+}
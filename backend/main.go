@@ -1,9 +1,19 @@
 package main
 
-import "com.fukubox/app"
+import (
+	"os"
+
+	"com.fukubox/app"
+)
 
 func main() {
-	err := app.SetupAndRunApp()
+	var err error
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err = app.RunMigrateCommand(os.Args[2:])
+	} else {
+		err = app.SetupAndRunApp()
+	}
+
 	if err != nil {
 		panic(err)
 	}
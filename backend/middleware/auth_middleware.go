@@ -1,27 +1,78 @@
 package middleware
 
 import (
+	"context"
 	"log"
 	"net/http"
-	"strconv"
+	"strings"
+
+	"com.fukubox/auth"
+	"com.fukubox/problem"
+	"com.fukubox/repository"
 )
 
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		userHeader := r.Header.Get("userId")
-		if userHeader == "" {
-			log.Printf("userID Header not set")
-			http.Error(w, `Unauthorized (401)`, http.StatusUnauthorized)
-			return
-		}
+const SessionCookieName = "session"
+
+type contextKey string
+
+const userIdKey contextKey = "userId"
+
+// AuthMiddleware only lets requests with a valid session of a user that still exists in users through
+func AuthMiddleware(users repository.UserRepository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := sessionToken(r)
+			if token == "" {
+				log.Printf("Session token not set")
+				problem.Write(w, r, problem.Unauthorized("A valid session is required"))
+				return
+			}
+
+			userId, err := auth.ParseSession(token)
+			if err != nil {
+				log.Printf("Invalid session token: %v", err)
+				problem.Write(w, r, problem.Unauthorized("A valid session is required"))
+				return
+			}
 
-		_, err := strconv.Atoi(userHeader)
-		if err != nil {
-			log.Printf("Invalid `userId` Header: %v", userHeader)
-			http.Error(w, "Internal Server Error", http.StatusBadRequest)
-			return
+			exists, err := users.UserExists(r.Context(), userId)
+			if err != nil {
+				log.Printf("Failed to resolve session user %v: %v", userId, err)
+				problem.Write(w, r, problem.Internal())
+				return
+			}
+			if !exists {
+				log.Printf("Session user %v no longer exists", userId)
+				problem.Write(w, r, problem.Unauthorized("A valid session is required"))
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), userIdKey, userId)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// GetUserId returns the id of the authenticated user set by AuthMiddleware
+func GetUserId(ctx context.Context) int {
+	userId, _ := ctx.Value(userIdKey).(int)
+	return userId
+}
+
+// sessionToken reads the session from the Authorization bearer header, falling back to the session cookie
+func sessionToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, found := strings.Cut(header, " ")
+		if found && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
 		}
+		return ""
+	}
+
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil {
+		return ""
+	}
 
-		next.ServeHTTP(w, r)
-	})
+	return cookie.Value
 }
@@ -0,0 +1,124 @@
+// Package problem writes error responses as RFC 7807 problem details, so every failure the
+// api returns has the same application/problem+json shape
+package problem
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+const ContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object. Type and Title are stable for each kind of
+// problem, Detail describes this occurrence.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	RequestId string `json:"request_id,omitempty"`
+	// Errors says what is wrong with each invalid field, keyed by its json name
+	Errors map[string]string `json:"errors,omitempty"`
+	// Count is how many things still depend on what a conflicting request wanted to change
+	Count int `json:"count,omitempty"`
+}
+
+func (p *Problem) Error() string {
+	return fmt.Sprintf("%v (%d): %v", p.Title, p.Status, p.Detail)
+}
+
+// kind is one of the stable problem types the api documents
+type kind struct {
+	slug   string
+	title  string
+	status int
+}
+
+var (
+	badRequest           = kind{"bad-request", "Bad request", http.StatusBadRequest}
+	unauthorized         = kind{"unauthorized", "Unauthorized", http.StatusUnauthorized}
+	notFound             = kind{"not-found", "Not found", http.StatusNotFound}
+	methodNotAllowed     = kind{"method-not-allowed", "Method not allowed", http.StatusMethodNotAllowed}
+	conflict             = kind{"conflict", "Conflict", http.StatusConflict}
+	payloadTooLarge      = kind{"payload-too-large", "Payload too large", http.StatusRequestEntityTooLarge}
+	unsupportedMediaType = kind{"unsupported-media-type", "Unsupported media type", http.StatusUnsupportedMediaType}
+	invalidFields        = kind{"invalid-fields", "Invalid fields", http.StatusUnprocessableEntity}
+	internal             = kind{"internal", "Internal server error", http.StatusInternalServerError}
+)
+
+func (k kind) new(detail string) *Problem {
+	return &Problem{Type: "/problems/" + k.slug, Title: k.title, Status: k.status, Detail: detail}
+}
+
+func BadRequest(detail string) *Problem {
+	return badRequest.new(detail)
+}
+
+func Unauthorized(detail string) *Problem {
+	return unauthorized.new(detail)
+}
+
+func NotFound(detail string) *Problem {
+	return notFound.new(detail)
+}
+
+func MethodNotAllowed(detail string) *Problem {
+	return methodNotAllowed.new(detail)
+}
+
+func Conflict(detail string) *Problem {
+	return conflict.new(detail)
+}
+
+// InUse reports a conflict with count things that still depend on the resource
+func InUse(detail string, count int) *Problem {
+	p := conflict.new(detail)
+	p.Count = count
+	return p
+}
+
+func PayloadTooLarge(detail string) *Problem {
+	return payloadTooLarge.new(detail)
+}
+
+func UnsupportedMediaType(detail string) *Problem {
+	return unsupportedMediaType.new(detail)
+}
+
+// InvalidFields reports a body that was read fine but has invalid values, listed in errors
+func InvalidFields(errors map[string]string) *Problem {
+	p := invalidFields.new("One or more fields are invalid")
+	p.Errors = errors
+	return p
+}
+
+// Internal reports a failure that isn't the client's fault. The cause is only logged, never sent.
+func Internal() *Problem {
+	return internal.new("The server failed to handle the request")
+}
+
+// Write sends p, tagged with the chi request id so a failure can be found in the logs
+func Write(w http.ResponseWriter, r *http.Request, p *Problem) {
+	p.RequestId = middleware.GetReqID(r.Context())
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		log.Printf("Failed to encode problem as JSON: %v", err)
+	}
+}
+
+// NotFoundHandler answers requests for routes that don't exist
+func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, NotFound(fmt.Sprintf("No route for %v", r.URL.Path)))
+}
+
+// MethodNotAllowedHandler answers requests for routes that exist with another method
+func MethodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, MethodNotAllowed(fmt.Sprintf("%v is not allowed on %v", r.Method, r.URL.Path)))
+}
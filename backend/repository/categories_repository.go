@@ -0,0 +1,378 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrCategoryNotOwned is returned when a category id does not belong to the user or is in the trash
+var ErrCategoryNotOwned = errors.New("category not found or not owned by user")
+
+// ErrCategoryCycle is returned when moving a category under itself or one of its subcategories
+var ErrCategoryCycle = errors.New("category can't be moved into its own subtree")
+
+// ErrCategoryInUse is returned when deleting a category that still has clothing items with CategoryItemsRefuse
+var ErrCategoryInUse = errors.New("category still has clothing items")
+
+// What DeleteCategory does with the clothing items still in the category
+const (
+	CategoryItemsRefuse   = "refuse"
+	CategoryItemsReassign = "reassign"
+	CategoryItemsTrash    = "trash"
+)
+
+// CategoryDeletionDto says how to delete a category. ReassignTo is the category the items move to
+// with CategoryItemsReassign.
+type CategoryDeletionDto struct {
+	Items      string
+	ReassignTo int
+}
+
+// CategoryDeletedDto reports what deleting a category did to the ItemCount clothing items in it.
+// BrokenOutfits are the outfits that lost an item to the trash.
+type CategoryDeletedDto struct {
+	ItemCount     int
+	BrokenOutfits []OutfitRefDto
+}
+
+// CategoryDto is a category, nested under the category ParentId unless that is nil
+type CategoryDto struct {
+	Id        int
+	UserId    int
+	ParentId  *int
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// categoryColumns are the columns scanCategoryRow expects
+const categoryColumns = `id, user_id, parent_id, name, created_at, updated_at`
+
+func scanCategoryRow(row pgx.Row, category *CategoryDto) error {
+	return row.Scan(&category.Id, &category.UserId, &category.ParentId, &category.Name, &category.CreatedAt, &category.UpdatedAt)
+}
+
+func (pg *Postgres) GetCategoriesByUser(ctx context.Context, userId int) ([]CategoryDto, error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return nil, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	query := `SELECT ` + categoryColumns + ` FROM categories WHERE user_id = $1 AND deleted_at IS NULL ORDER BY id`
+
+	rows, err := conn.Query(ctx, query, userId)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []CategoryDto{}
+
+	for rows.Next() {
+		var category CategoryDto
+		if err := scanCategoryRow(rows, &category); err != nil {
+			log.Printf("Failed to scan row: %v", err)
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error after iterating rows: %v", err)
+		return nil, err
+	}
+
+	return categories, nil
+}
+
+func (pg *Postgres) GetCategoryByUserAndId(ctx context.Context, userId int, categoryId int) (CategoryDto, error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return CategoryDto{}, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	query := `SELECT ` + categoryColumns + ` FROM categories WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`
+
+	var category CategoryDto
+	err := scanCategoryRow(conn.QueryRow(ctx, query, categoryId, userId), &category)
+	if err != nil {
+		log.Printf("Failed to query %v with params {id: %v, user_id: %v}: %v", query, categoryId, userId, err)
+		return CategoryDto{}, err
+	}
+
+	return category, nil
+}
+
+// CreateCategory adds a category for the user, nested under parentId unless it is nil. It fails
+// with ErrCategoryNotOwned unless the parent is one of the user's categories outside the trash.
+func (pg *Postgres) CreateCategory(ctx context.Context, userId int, name string, parentId *int) (category CategoryDto, err error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return CategoryDto{}, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		log.Printf("Begin Transation Failure: %v", err)
+		return CategoryDto{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			err = tx.Commit(ctx)
+		}
+	}()
+
+	if parentId != nil {
+		err = checkCategoryOwnedTx(tx, ctx, userId, *parentId)
+		if err != nil {
+			return CategoryDto{}, err
+		}
+	}
+
+	query := `INSERT INTO categories (user_id, parent_id, name, created_at, updated_at) VALUES ($1, $2, $3, now(), now())
+			  RETURNING ` + categoryColumns
+
+	err = scanCategoryRow(tx.QueryRow(ctx, query, userId, parentId, name), &category)
+	if err != nil {
+		log.Printf("Failed to insert new category: %v", err)
+		return CategoryDto{}, err
+	}
+
+	return category, nil
+}
+
+// UpdateCategory renames the user's category, leaving the name alone when it is nil
+func (pg *Postgres) UpdateCategory(ctx context.Context, userId int, categoryId int, name *string) (CategoryDto, error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return CategoryDto{}, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	query := `UPDATE categories SET name = COALESCE($1, name), updated_at = now()
+			  WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL
+			  RETURNING ` + categoryColumns
+
+	var category CategoryDto
+	err := scanCategoryRow(conn.QueryRow(ctx, query, name, categoryId, userId), &category)
+	if err != nil {
+		log.Printf("Failed to update category %v: %v", categoryId, err)
+		return CategoryDto{}, err
+	}
+
+	return category, nil
+}
+
+// MoveCategory nests the user's category, with its whole subtree, under parentId, or makes it a
+// top level category when parentId is nil. It fails with ErrCategoryNotOwned unless the parent is
+// one of the user's categories outside the trash, and with ErrCategoryCycle if the parent is the
+// category itself or one of its subcategories.
+func (pg *Postgres) MoveCategory(ctx context.Context, userId int, categoryId int, parentId *int) (category CategoryDto, err error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return CategoryDto{}, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		log.Printf("Begin Transation Failure: %v", err)
+		return CategoryDto{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			err = tx.Commit(ctx)
+		}
+	}()
+
+	// two moves checked side by side could each pass and still close a cycle together, so a move
+	// holds every category of the user until it commits
+	query := `SELECT COALESCE(array_agg(id), '{}')
+			  FROM (SELECT id FROM categories WHERE user_id = $1 AND deleted_at IS NULL FOR UPDATE) ids`
+
+	var ids []int
+	err = tx.QueryRow(ctx, query, userId).Scan(&ids)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return CategoryDto{}, err
+	}
+	if !slices.Contains(ids, categoryId) {
+		err = pgx.ErrNoRows
+		return CategoryDto{}, err
+	}
+
+	if parentId != nil {
+		if !slices.Contains(ids, *parentId) {
+			err = ErrCategoryNotOwned
+			return CategoryDto{}, err
+		}
+
+		query = `WITH RECURSIVE subtree AS (
+				     SELECT id FROM categories WHERE id = $1
+				     UNION ALL
+				     SELECT cat.id FROM categories cat JOIN subtree s ON cat.parent_id = s.id
+				 )
+				 SELECT EXISTS (SELECT 1 FROM subtree WHERE id = $2)`
+
+		var cycle bool
+		err = tx.QueryRow(ctx, query, categoryId, *parentId).Scan(&cycle)
+		if err != nil {
+			log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+			return CategoryDto{}, err
+		}
+		if cycle {
+			err = ErrCategoryCycle
+			return CategoryDto{}, err
+		}
+	}
+
+	query = `UPDATE categories SET parent_id = $1, updated_at = now() WHERE id = $2 RETURNING ` + categoryColumns
+
+	err = scanCategoryRow(tx.QueryRow(ctx, query, parentId, categoryId), &category)
+	if err != nil {
+		log.Printf("Failed to move category %v: %v", categoryId, err)
+		return CategoryDto{}, err
+	}
+
+	return category, nil
+}
+
+// DeleteCategory moves the user's category to the trash and deals with the clothing items in it as
+// deletion.Items says: CategoryItemsRefuse fails with ErrCategoryInUse if there are any, still
+// counting them, CategoryItemsReassign moves them, trashed ones included, to deletion.ReassignTo,
+// failing with ErrCategoryNotOwned unless it is another of the user's categories, and
+// CategoryItemsTrash moves them to the trash along with the category, leaving their sandboxes and
+// outfits like DeleteCloth. RestoreCategory brings trashed items back with the category. Whatever
+// the policy, the subcategories move up to the category's parent.
+func (pg *Postgres) DeleteCategory(ctx context.Context, userId int, categoryId int, deletion CategoryDeletionDto) (deleted CategoryDeletedDto, err error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return CategoryDeletedDto{}, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		log.Printf("Begin Transation Failure: %v", err)
+		return CategoryDeletedDto{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			err = tx.Commit(ctx)
+		}
+	}()
+
+	// now() is the same throughout the transaction, so the items share the category's deleted_at
+	commandTag, err := tx.Exec(ctx, `UPDATE categories SET deleted_at = now() WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`, categoryId, userId)
+	if err != nil {
+		log.Printf("Failed to trash category %v: %v", categoryId, err)
+		return CategoryDeletedDto{}, err
+	}
+	if commandTag.RowsAffected() == 0 {
+		err = pgx.ErrNoRows
+		return CategoryDeletedDto{}, err
+	}
+
+	query := `UPDATE categories SET parent_id = (SELECT parent_id FROM categories WHERE id = $1), updated_at = now()
+			  WHERE parent_id = $1 AND deleted_at IS NULL`
+
+	_, err = tx.Exec(ctx, query, categoryId)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return CategoryDeletedDto{}, err
+	}
+
+	query = `SELECT COALESCE(array_agg(id), '{}') FROM clothing_items WHERE category_id = $1 AND user_id = $2 AND deleted_at IS NULL`
+
+	var clothIds []int
+	err = tx.QueryRow(ctx, query, categoryId, userId).Scan(&clothIds)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return CategoryDeletedDto{}, err
+	}
+
+	deleted = CategoryDeletedDto{ItemCount: len(clothIds), BrokenOutfits: []OutfitRefDto{}}
+
+	switch deletion.Items {
+	case CategoryItemsReassign:
+		// the category is in the trash by now, so it can't be its own target
+		err = checkCategoryOwnedTx(tx, ctx, userId, deletion.ReassignTo)
+		if err != nil {
+			return CategoryDeletedDto{}, err
+		}
+
+		query = `UPDATE clothing_items SET category_id = $1, updated_at = now() WHERE category_id = $2 AND user_id = $3`
+
+		_, err = tx.Exec(ctx, query, deletion.ReassignTo, categoryId, userId)
+		if err != nil {
+			log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+			return CategoryDeletedDto{}, err
+		}
+	case CategoryItemsTrash:
+		deleted.BrokenOutfits, err = trashClothesTx(tx, ctx, userId, clothIds)
+		if err != nil {
+			return CategoryDeletedDto{}, err
+		}
+	default:
+		if len(clothIds) > 0 {
+			err = ErrCategoryInUse
+			return deleted, err
+		}
+	}
+
+	return deleted, nil
+}
+
+// checkCategoryOwnedTx returns ErrCategoryNotOwned unless the category is one of the user's outside the trash
+func checkCategoryOwnedTx(tx pgx.Tx, ctx context.Context, userId int, categoryId int) error {
+	query := `SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL)`
+
+	var owned bool
+	err := tx.QueryRow(ctx, query, categoryId, userId).Scan(&owned)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return err
+	}
+	if !owned {
+		return ErrCategoryNotOwned
+	}
+
+	return nil
+}
+
+// findOrCreateCategoryTx returns the user's category called name under parentId outside the
+// trash, creating it when they don't have one
+func findOrCreateCategoryTx(tx pgx.Tx, ctx context.Context, userId int, parentId *int, name string) (NamedIdDto, error) {
+	query := `SELECT id FROM categories
+			  WHERE user_id = $1 AND parent_id IS NOT DISTINCT FROM $2 AND name = $3 AND deleted_at IS NULL
+			  LIMIT 1`
+
+	category := NamedIdDto{}
+	err := tx.QueryRow(ctx, query, userId, parentId, name).Scan(&category.Id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		query = `INSERT INTO categories (user_id, parent_id, name, created_at, updated_at) VALUES ($1, $2, $3, now(), now()) RETURNING id`
+		err = tx.QueryRow(ctx, query, userId, parentId, name).Scan(&category.Id)
+		category.Created = true
+	}
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return NamedIdDto{}, err
+	}
+
+	return category, nil
+}
@@ -3,10 +3,11 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 
-	"com.fukubox/database"
 	"github.com/jackc/pgx/v5"
 )
 
@@ -14,65 +15,356 @@ type ClothDto struct {
 	Id         int
 	UserId     int
 	CategoryId int
-	ImageUrl   string
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
-	TagsJson   string
+	ClothAttributesDto
+	ImageUrl  string
+	CutoutUrl *string
+	Variants  map[string]string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	TagsJson  string
+	// TimesWorn and LastWornAt are derived from the wear log
+	TimesWorn  int
+	LastWornAt *time.Time
+}
+
+// ClothAttributesDto are the optional descriptive fields of a clothing item
+type ClothAttributesDto struct {
+	Name           *string
+	Brand          *string
+	Size           *string
+	PrimaryColor   *string
+	SecondaryColor *string
+	Material       *string
+	Season         *string
+	PurchasePrice  *float64
+	Currency       *string
+	PurchaseDate   *time.Time
+	Notes          *string
 }
 
 type ClothEditDto struct {
 	CategoryId int
-	ImageUrl   string
+	ClothAttributesDto
+	ImageUrl  string
+	CutoutUrl *string
+	Variants  map[string]string
+}
+
+// ClothByNamesDto is a clothing item with its category and tags given by name, as a CSV import has them
+type ClothByNamesDto struct {
+	ClothAttributesDto
+	ImageUrl string
+	// CategoryPath names the item's category after its ancestors, from the top level down
+	CategoryPath []string
+	TagNames     []string
+}
+
+// ClothByNamesCreatedDto is a clothing item created by CreateClothByNames with the categories
+// of its path and its tags, in the order they were named
+type ClothByNamesCreatedDto struct {
+	Id         int
+	Categories []NamedIdDto
+	Tags       []NamedIdDto
+}
+
+// NamedIdDto is a category or tag looked up by name, Created when the user didn't have it yet
+type NamedIdDto struct {
+	Id      int
+	Created bool
+}
+
+// ClothImagesDto holds the stored images of a clothing item: the original upload,
+// its transparent cutout and resized variants keyed by size name. Purges also report the
+// user the item belonged to.
+type ClothImagesDto struct {
+	UserId    int
+	ImageUrl  string
+	CutoutUrl *string
+	Variants  map[string]string
+}
+
+// clothAttributeColumns are the columns of ClothAttributesDto in field order
+const clothAttributeColumns = `name, brand, size, primary_color, secondary_color, material, season, purchase_price, currency, purchase_date, notes`
+
+// clothColumns are the columns scanClothRow expects, selected from clothing_items aliased as c
+const clothColumns = `c.id, c.user_id, c.category_id,
+	c.name, c.brand, c.size, c.primary_color, c.secondary_color, c.material, c.season, c.purchase_price, c.currency, c.purchase_date, c.notes,
+	c.image_url, c.cutout_url, c.image_variants, c.created_at, c.updated_at,
+	COALESCE((SELECT json_agg(json_build_object('id', t.id, 'name', t.name) ORDER BY t.id)
+			  FROM clothing_item_tags cit
+			  JOIN tags t ON t.id = cit.tag_id
+			  WHERE cit.clothing_item_id = c.id), '[]')::text,
+	(SELECT count(*) FROM wear_event_items wi WHERE wi.clothing_item_id = c.id)::int,
+	(SELECT max(w.worn_on)
+	 FROM wear_event_items wi
+	 JOIN wear_events w ON w.id = wi.wear_event_id
+	 WHERE wi.clothing_item_id = c.id)`
+
+// getClothQuery selects one of the user's clothing items for scanClothRow, given user_id and id
+const getClothQuery = `SELECT id, user_id, category_id, ` + clothAttributeColumns + `, image_url, cutout_url, image_variants, created_at, updated_at, tags, times_worn, last_worn_at
+	FROM get_clothes_by_user_and_id($1, $2)`
+
+func scanClothRow(row pgx.Row, cloth *ClothDto) error {
+	dest := []any{&cloth.Id, &cloth.UserId, &cloth.CategoryId}
+	dest = append(dest, cloth.ClothAttributesDto.fields()...)
+	dest = append(dest, &cloth.ImageUrl, &cloth.CutoutUrl, &cloth.Variants, &cloth.CreatedAt, &cloth.UpdatedAt, &cloth.TagsJson, &cloth.TimesWorn, &cloth.LastWornAt)
+	return row.Scan(dest...)
+}
+
+// fields returns pointers to the attributes in clothAttributeColumns order, for scanning
+func (attributes *ClothAttributesDto) fields() []any {
+	return []any{
+		&attributes.Name, &attributes.Brand, &attributes.Size, &attributes.PrimaryColor, &attributes.SecondaryColor,
+		&attributes.Material, &attributes.Season, &attributes.PurchasePrice, &attributes.Currency, &attributes.PurchaseDate,
+		&attributes.Notes,
+	}
+}
+
+// values returns the attributes in clothAttributeColumns order, as query arguments
+func (attributes ClothAttributesDto) values() []any {
+	return []any{
+		attributes.Name, attributes.Brand, attributes.Size, attributes.PrimaryColor, attributes.SecondaryColor,
+		attributes.Material, attributes.Season, attributes.PurchasePrice, attributes.Currency, attributes.PurchaseDate,
+		attributes.Notes,
+	}
+}
+
+// ClothFilterDto selects and orders a page of a user's clothes. Unset fields don't filter.
+type ClothFilterDto struct {
+	CategoryId *int
+	// IncludeSubcategories also matches the clothes in the subcategories of CategoryId, at any depth
+	IncludeSubcategories bool
+
+	TagIds []int
+	// MatchAllTags requires every tag in TagIds instead of any of them
+	MatchAllTags bool
+
+	// Name and Notes match items containing the text, ignoring case
+	Name  *string
+	Notes *string
+	// Brand, Size and Material match whole values, ignoring case
+	Brand    *string
+	Size     *string
+	Material *string
+	// Color matches either the primary or the secondary color
+	Color          *string
+	PrimaryColor   *string
+	SecondaryColor *string
+	Season         *string
+	Currency       *string
+	MinPrice       *float64
+	MaxPrice       *float64
+	PurchasedFrom  *time.Time
+	PurchasedTo    *time.Time
+
+	SortBy     string
+	Descending bool
+	Limit      int
+	// After continues a listing from the last item of the previous page
+	After *ClothCursorDto
+}
+
+// ClothCursorDto is the position of an item in a listing sorted by SortBy
+type ClothCursorDto struct {
+	SortBy    string
+	SortValue time.Time
+	Id        int
+}
+
+type ClothPageDto struct {
+	Items []ClothDto
+	Total int
+	Next  *ClothCursorDto
+}
+
+const (
+	ClothSortCreatedAt = "created_at"
+	ClothSortUpdatedAt = "updated_at"
+)
+
+// clothSortColumns whitelists the columns a listing can be ordered by
+var clothSortColumns = map[string]string{
+	ClothSortCreatedAt: "c.created_at",
+	ClothSortUpdatedAt: "c.updated_at",
+}
+
+// queryArgs collects positional query arguments
+type queryArgs []any
+
+// add appends value and returns its placeholder
+func (args *queryArgs) add(value any) string {
+	*args = append(*args, value)
+	return fmt.Sprintf("$%d", len(*args))
+}
+
+// clothFilterConditions returns the WHERE conditions on clothing_items aliased as c selecting
+// the user's clothes that match filter, adding their arguments to args
+func clothFilterConditions(userId int, filter ClothFilterDto, args *queryArgs) []string {
+	conditions := []string{"c.user_id = " + args.add(userId), "c.deleted_at IS NULL"}
+
+	if filter.CategoryId != nil && filter.IncludeSubcategories {
+		conditions = append(conditions, `c.category_id IN (
+			WITH RECURSIVE subtree AS (
+				SELECT id FROM categories WHERE id = `+args.add(*filter.CategoryId)+`
+				UNION ALL
+				SELECT cat.id FROM categories cat JOIN subtree s ON cat.parent_id = s.id WHERE cat.deleted_at IS NULL
+			)
+			SELECT id FROM subtree)`)
+	} else if filter.CategoryId != nil {
+		conditions = append(conditions, "c.category_id = "+args.add(*filter.CategoryId))
+	}
+
+	if len(filter.TagIds) > 0 {
+		tagIds := args.add(filter.TagIds)
+		needed := "1"
+		if filter.MatchAllTags {
+			needed = "(SELECT count(DISTINCT i) FROM unnest(" + tagIds + "::int[]) AS i)"
+		}
+		conditions = append(conditions, `(SELECT count(DISTINCT cit.tag_id) FROM clothing_item_tags cit
+			WHERE cit.clothing_item_id = c.id AND cit.tag_id = ANY(`+tagIds+`)) >= `+needed)
+	}
+
+	type textFilter struct {
+		column string
+		value  *string
+	}
+
+	contains := []textFilter{
+		{"c.name", filter.Name},
+		{"c.notes", filter.Notes},
+	}
+	for _, contain := range contains {
+		if contain.value != nil {
+			conditions = append(conditions, fmt.Sprintf("strpos(lower(%v), lower(%v)) > 0", contain.column, args.add(*contain.value)))
+		}
+	}
+
+	equals := []textFilter{
+		{"c.brand", filter.Brand},
+		{"c.size", filter.Size},
+		{"c.material", filter.Material},
+		{"c.primary_color", filter.PrimaryColor},
+		{"c.secondary_color", filter.SecondaryColor},
+		{"c.season", filter.Season},
+		{"c.currency", filter.Currency},
+	}
+	for _, equal := range equals {
+		if equal.value != nil {
+			conditions = append(conditions, fmt.Sprintf("lower(%v) = lower(%v)", equal.column, args.add(*equal.value)))
+		}
+	}
+
+	if filter.Color != nil {
+		color := args.add(*filter.Color)
+		conditions = append(conditions, fmt.Sprintf("(lower(c.primary_color) = lower(%[1]v) OR lower(c.secondary_color) = lower(%[1]v))", color))
+	}
+
+	if filter.MinPrice != nil {
+		conditions = append(conditions, "c.purchase_price >= "+args.add(*filter.MinPrice))
+	}
+	if filter.MaxPrice != nil {
+		conditions = append(conditions, "c.purchase_price <= "+args.add(*filter.MaxPrice))
+	}
+	if filter.PurchasedFrom != nil {
+		conditions = append(conditions, "c.purchase_date >= "+args.add(*filter.PurchasedFrom)+"::date")
+	}
+	if filter.PurchasedTo != nil {
+		conditions = append(conditions, "c.purchase_date <= "+args.add(*filter.PurchasedTo)+"::date")
+	}
+
+	return conditions
 }
 
-func GetClothesByUser(ctx context.Context, userId string) ([]ClothDto, error) {
-	conn := database.AcquireConnection(ctx)
+// GetClothesPageByUser returns one page of the user's clothes and how many match the filter in total
+func (pg *Postgres) GetClothesPageByUser(ctx context.Context, userId int, filter ClothFilterDto) (ClothPageDto, error) {
+	sortColumn, ok := clothSortColumns[filter.SortBy]
+	if !ok {
+		return ClothPageDto{}, fmt.Errorf("unknown sort column %q", filter.SortBy)
+	}
+
+	direction, comparison := "ASC", ">"
+	if filter.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	conn := pg.acquire(ctx)
 	if conn == nil {
-		return nil, errors.New("failed to acquire database connection")
+		return ClothPageDto{}, errors.New("failed to acquire database connection")
 	}
 	defer conn.Release()
 
-	query := `SELECT id, user_id, category_id, image_url, created_at, updated_at, tags FROM get_clothes_by_user($1)`
+	var args queryArgs
+	conditions := clothFilterConditions(userId, filter, &args)
+
+	countQuery := `SELECT count(*) FROM clothing_items c WHERE ` + strings.Join(conditions, " AND ")
+
+	var page ClothPageDto
+	err := conn.QueryRow(ctx, countQuery, args...).Scan(&page.Total)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", countQuery, err)
+		return ClothPageDto{}, err
+	}
+
+	if filter.After != nil {
+		conditions = append(conditions, fmt.Sprintf("(%v, c.id) %v (%v::timestamp, %v::int)",
+			sortColumn, comparison, args.add(filter.After.SortValue), args.add(filter.After.Id)))
+	}
+
+	// fetch one extra row to learn whether there is a next page
+	query := fmt.Sprintf(`SELECT %[1]v
+		FROM clothing_items c
+		WHERE %[2]v
+		ORDER BY %[3]v %[4]v, c.id %[4]v
+		LIMIT %[5]v`, clothColumns, strings.Join(conditions, " AND "), sortColumn, direction, args.add(filter.Limit+1))
 
-	rows, err := conn.Query(ctx, query, userId)
+	rows, err := conn.Query(ctx, query, args...)
 	if err != nil {
 		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
-		return nil, err
+		return ClothPageDto{}, err
 	}
 	defer rows.Close()
 
-	clothes := []ClothDto{}
+	page.Items = []ClothDto{}
 
 	for rows.Next() {
 		var cloth ClothDto
-		if err := rows.Scan(&cloth.Id, &cloth.UserId, &cloth.CategoryId, &cloth.ImageUrl, &cloth.CreatedAt, &cloth.UpdatedAt, &cloth.TagsJson); err != nil {
+		if err := scanClothRow(rows, &cloth); err != nil {
 			log.Printf("Failed to scan row: %v", err)
-			return nil, err
+			return ClothPageDto{}, err
 		}
-		clothes = append(clothes, cloth)
+		page.Items = append(page.Items, cloth)
 	}
 
 	if err := rows.Err(); err != nil {
 		log.Printf("Error after iterating rows: %v", err)
-		return nil, err
+		return ClothPageDto{}, err
 	}
 
-	return clothes, nil
+	if len(page.Items) > filter.Limit {
+		page.Items = page.Items[:filter.Limit]
+
+		last := page.Items[len(page.Items)-1]
+		page.Next = &ClothCursorDto{SortBy: filter.SortBy, SortValue: last.CreatedAt, Id: last.Id}
+		if filter.SortBy == ClothSortUpdatedAt {
+			page.Next.SortValue = last.UpdatedAt
+		}
+	}
+
+	return page, nil
 }
 
-func GetClothesByUserAndId(ctx context.Context, userId string, clothId string) (ClothDto, error) {
-	conn := database.AcquireConnection(ctx)
+func (pg *Postgres) GetClothesByUserAndId(ctx context.Context, userId int, clothId int) (ClothDto, error) {
+	conn := pg.acquire(ctx)
 	if conn == nil {
 		return ClothDto{}, errors.New("failed to acquire database connection")
 	}
 	defer conn.Release()
 
-	query := `SELECT id, user_id, category_id, image_url, created_at, updated_at, tags FROM get_clothes_by_user_and_id($1, $2)`
+	query := getClothQuery
 
 	var cloth ClothDto
 
-	err := conn.QueryRow(ctx, query, userId, clothId).
-		Scan(&cloth.Id, &cloth.UserId, &cloth.CategoryId, &cloth.ImageUrl, &cloth.CreatedAt, &cloth.UpdatedAt, &cloth.TagsJson)
+	err := scanClothRow(conn.QueryRow(ctx, query, userId, clothId), &cloth)
 	if err != nil {
 		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
 		log.Printf("Failed to query %v with params {user_id: %v, id:%v}: %v", query, userId, clothId, err)
@@ -82,19 +374,17 @@ func GetClothesByUserAndId(ctx context.Context, userId string, clothId string) (
 	return cloth, nil
 }
 
-func CreateCloth(ctx context.Context, userId string, newCloth ClothEditDto) (int, error) {
-	conn := database.AcquireConnection(ctx)
-	if conn == nil {
-		return -1, errors.New("failed to acquire database connection")
-	}
-	defer conn.Release()
+func CreateClothTx(tx pgx.Tx, ctx context.Context, userId int, newCloth ClothEditDto) (int, error) {
 
-	query := `INSERT INTO clothing_items (user_id, category_id, image_url, created_at, updated_at)
-			  VALUES ($1, $2, $3, now(), now()) RETURNING id, user_id, category_id, image_url, created_at, updated_at
+	query := `INSERT INTO clothing_items (user_id, category_id, ` + clothAttributeColumns + `, image_url, cutout_url, image_variants, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, now(), now())
 			  RETURNING id`
 
+	args := append([]any{userId, newCloth.CategoryId}, newCloth.ClothAttributesDto.values()...)
+	args = append(args, newCloth.ImageUrl, newCloth.CutoutUrl, newCloth.Variants)
+
 	var id int
-	err := conn.QueryRow(ctx, query, userId, newCloth.CategoryId, newCloth.ImageUrl).Scan(&id)
+	err := tx.QueryRow(ctx, query, args...).Scan(&id)
 	if err != nil {
 		log.Printf("Failed to insert new clothing item: %v", err)
 		return -1, err
@@ -103,70 +393,309 @@ func CreateCloth(ctx context.Context, userId string, newCloth ClothEditDto) (int
 	return id, nil
 }
 
-func CreateClothTx(tx pgx.Tx, ctx context.Context, userId string, newCloth ClothEditDto) (int, error) {
+func (pg *Postgres) CreateClothWithTags(ctx context.Context, userId int, newCloth ClothEditDto, tags []int) (id int, err error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return -1, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
 
-	query := `INSERT INTO clothing_items (user_id, category_id, image_url, created_at, updated_at)
-			  VALUES ($1, $2, $3, now(), now()) 
-			  RETURNING id`
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		log.Printf("Begin Transation Failure: %v", err)
+		return -1, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			err = tx.Commit(ctx)
+		}
+	}()
 
-	var id int
-	err := tx.QueryRow(ctx, query, userId, newCloth.CategoryId, newCloth.ImageUrl).Scan(&id)
+	err = checkCategoryOwnedTx(tx, ctx, userId, newCloth.CategoryId)
 	if err != nil {
-		log.Printf("Failed to insert new clothing item: %v", err)
+		return -1, err
+	}
+
+	id, err = CreateClothTx(tx, ctx, userId, newCloth)
+	if err != nil {
+		log.Printf("Failed to create cloth: %v", err)
+		return -1, err
+	}
+
+	err = BindTagsTx(tx, ctx, userId, id, tags)
+	if err != nil {
+		log.Printf("Failed to bind cloth tags: %v", err)
 		return -1, err
 	}
 
 	return id, nil
 }
 
-func CreateClothWithTags(ctx context.Context, userId string, newCloth ClothEditDto, tags []int) (int, error) {
-	conn := database.AcquireConnection(ctx)
+// CreateClothByNames creates a clothing item in the category at the end of its path, tagged with
+// its tags. Categories and tags the user has by name are reused, the others are created in the
+// same transaction as the item, so none are left behind when it can't be created.
+func (pg *Postgres) CreateClothByNames(ctx context.Context, userId int, newCloth ClothByNamesDto) (created ClothByNamesCreatedDto, err error) {
+	if len(newCloth.CategoryPath) == 0 {
+		return ClothByNamesCreatedDto{}, errors.New("a clothing item needs a category")
+	}
+
+	conn := pg.acquire(ctx)
 	if conn == nil {
-		return -1, errors.New("failed to acquire database connection")
+		return ClothByNamesCreatedDto{}, errors.New("failed to acquire database connection")
 	}
 	defer conn.Release()
 
 	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		log.Printf("Begin Transation Failure: %v", err)
-		return -1, err
+		return ClothByNamesCreatedDto{}, err
 	}
 	defer func() {
 		if err != nil {
 			tx.Rollback(ctx)
 		} else {
-			tx.Commit(ctx)
+			err = tx.Commit(ctx)
 		}
 	}()
 
-	id, err := CreateClothTx(tx, ctx, userId, newCloth)
+	var parentId *int
+	for _, name := range newCloth.CategoryPath {
+		category, err := findOrCreateCategoryTx(tx, ctx, userId, parentId, name)
+		if err != nil {
+			return ClothByNamesCreatedDto{}, err
+		}
+		created.Categories = append(created.Categories, category)
+		parentId = &category.Id
+	}
+
+	tagIds := []int{}
+	for _, name := range newCloth.TagNames {
+		tag, err := findOrCreateTagTx(tx, ctx, userId, name)
+		if err != nil {
+			return ClothByNamesCreatedDto{}, err
+		}
+		created.Tags = append(created.Tags, tag)
+		tagIds = append(tagIds, tag.Id)
+	}
+
+	created.Id, err = CreateClothTx(tx, ctx, userId, ClothEditDto{
+		CategoryId:         *parentId,
+		ClothAttributesDto: newCloth.ClothAttributesDto,
+		ImageUrl:           newCloth.ImageUrl,
+	})
 	if err != nil {
-		log.Printf("Failed to create cloth: %v", err)
-		return -1, err
+		return ClothByNamesCreatedDto{}, err
 	}
 
-	err = BindTagsTx(tx, ctx, id, tags)
+	err = BindTagsTx(tx, ctx, userId, created.Id, tagIds)
 	if err != nil {
 		log.Printf("Failed to bind cloth tags: %v", err)
-		return -1, err
+		return ClothByNamesCreatedDto{}, err
 	}
 
-	return id, nil
+	return created, nil
+}
+
+// ErrPriceWithoutCurrency is returned when an update would leave only one of purchase_price and currency set
+var ErrPriceWithoutCurrency = errors.New("purchase_price and currency must be set together")
+
+// ClothPatchDto changes the fields of a clothing item that are set, leaving the others alone
+type ClothPatchDto struct {
+	CategoryId *int
+	ClothAttributesPatchDto
+	ImageUrl *string
+	// TagIds replaces the item's tags when not nil, an empty slice removes them all
+	TagIds *[]int
+}
+
+// ClothAttributesPatchDto changes the attributes that are Set, a nil Value clears one
+type ClothAttributesPatchDto struct {
+	Name           Optional[string]
+	Brand          Optional[string]
+	Size           Optional[string]
+	PrimaryColor   Optional[string]
+	SecondaryColor Optional[string]
+	Material       Optional[string]
+	Season         Optional[string]
+	PurchasePrice  Optional[float64]
+	Currency       Optional[string]
+	PurchaseDate   Optional[time.Time]
+	Notes          Optional[string]
+}
+
+// fields returns the attributes in clothAttributeColumns order
+func (patch ClothAttributesPatchDto) fields() []optionalValue {
+	return []optionalValue{
+		patch.Name, patch.Brand, patch.Size, patch.PrimaryColor, patch.SecondaryColor,
+		patch.Material, patch.Season, patch.PurchasePrice, patch.Currency, patch.PurchaseDate,
+		patch.Notes,
+	}
 }
 
-func UpdateCloth(ctx context.Context) (int, error) {
-	conn := database.AcquireConnection(ctx)
+// UpdateCloth applies patch to the user's clothing item in a single transaction and returns
+// the updated item, or pgx.ErrNoRows if the user has no such item. Nothing changes when the
+// result would have a purchase_price without a currency or the other way around, or when
+// the new category isn't one of the user's. A new image_url drops the cutout and variants of
+// the old image, which are returned so the caller can delete them.
+func (pg *Postgres) UpdateCloth(ctx context.Context, userId int, clothId int, patch ClothPatchDto) (cloth ClothDto, replaced ClothImagesDto, err error) {
+	conn := pg.acquire(ctx)
 	if conn == nil {
-		return -1, errors.New("failed to acquire database connection")
+		return ClothDto{}, ClothImagesDto{}, errors.New("failed to acquire database connection")
 	}
 	defer conn.Release()
 
-	return 1, nil
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		log.Printf("Begin Transation Failure: %v", err)
+		return ClothDto{}, ClothImagesDto{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			err = tx.Commit(ctx)
+		}
+	}()
+
+	var args queryArgs
+	assignments := []string{"updated_at = now()"}
+
+	if patch.CategoryId != nil {
+		err = checkCategoryOwnedTx(tx, ctx, userId, *patch.CategoryId)
+		if err != nil {
+			return ClothDto{}, ClothImagesDto{}, err
+		}
+		assignments = append(assignments, "category_id = "+args.add(*patch.CategoryId))
+	}
+
+	columns := strings.Split(clothAttributeColumns, ", ")
+	for i, field := range patch.ClothAttributesPatchDto.fields() {
+		if value, set := field.value(); set {
+			assignments = append(assignments, columns[i]+" = "+args.add(value))
+		}
+	}
+
+	if patch.ImageUrl != nil {
+		query := `SELECT COALESCE(image_url, ''), cutout_url, image_variants FROM clothing_items
+				  WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL FOR UPDATE`
+
+		var previous ClothImagesDto
+		err = tx.QueryRow(ctx, query, clothId, userId).Scan(&previous.ImageUrl, &previous.CutoutUrl, &previous.Variants)
+		if err != nil {
+			log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+			return ClothDto{}, ClothImagesDto{}, err
+		}
+
+		// the cutout and variants were made from the old image, the new one has none yet
+		if *patch.ImageUrl != previous.ImageUrl {
+			assignments = append(assignments, "image_url = "+args.add(*patch.ImageUrl), "cutout_url = NULL", "image_variants = NULL")
+			replaced = previous
+		}
+	}
+
+	query := fmt.Sprintf(`UPDATE clothing_items SET %v WHERE id = %v AND user_id = %v AND deleted_at IS NULL
+		RETURNING (purchase_price IS NULL) = (currency IS NULL)`,
+		strings.Join(assignments, ", "), args.add(clothId), args.add(userId))
+
+	var pricePaired bool
+	err = tx.QueryRow(ctx, query, args...).Scan(&pricePaired)
+	if err != nil {
+		log.Printf("Failed to update clothing item %v: %v", clothId, err)
+		return ClothDto{}, ClothImagesDto{}, err
+	}
+	if !pricePaired {
+		err = ErrPriceWithoutCurrency
+		return ClothDto{}, ClothImagesDto{}, err
+	}
+
+	if patch.TagIds != nil {
+		_, err = tx.Exec(ctx, `DELETE FROM clothing_item_tags WHERE clothing_item_id = $1`, clothId)
+		if err != nil {
+			log.Printf("Failed to delete tags of clothing item %v: %v", clothId, err)
+			return ClothDto{}, ClothImagesDto{}, err
+		}
+
+		err = BindTagsTx(tx, ctx, userId, clothId, *patch.TagIds)
+		if err != nil {
+			log.Printf("Failed to bind cloth tags: %v", err)
+			return ClothDto{}, ClothImagesDto{}, err
+		}
+	}
+
+	err = scanClothRow(tx.QueryRow(ctx, getClothQuery, userId, clothId), &cloth)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", getClothQuery, err)
+		return ClothDto{}, ClothImagesDto{}, err
+	}
+
+	return cloth, replaced, nil
 }
 
-func BindTagsTx(tx pgx.Tx, ctx context.Context, clothId int, tags []int) error {
+// UpdateClothImages points the item at newly stored images and returns the ones they replaced
+func (pg *Postgres) UpdateClothImages(ctx context.Context, userId int, clothId int, images ClothImagesDto) (ClothImagesDto, error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return ClothImagesDto{}, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	query := `UPDATE clothing_items c SET image_url = $1, cutout_url = $2, image_variants = $3, updated_at = now()
+			  FROM (SELECT id, image_url, cutout_url, image_variants FROM clothing_items WHERE id = $4 AND user_id = $5 AND deleted_at IS NULL FOR UPDATE) old
+			  WHERE c.id = old.id
+			  RETURNING COALESCE(old.image_url, ''), old.cutout_url, old.image_variants`
+
+	var previous ClothImagesDto
+	err := conn.QueryRow(ctx, query, images.ImageUrl, images.CutoutUrl, images.Variants, clothId, userId).
+		Scan(&previous.ImageUrl, &previous.CutoutUrl, &previous.Variants)
+	if err != nil {
+		log.Printf("Failed to update images of clothing item %v: %v", clothId, err)
+		return ClothImagesDto{}, err
+	}
+
+	return previous, nil
+}
+
+// DeleteCloth moves the user's clothing item to the trash. It keeps its tags and wears for
+// RestoreCloth but leaves its sandboxes and outfits; the outfits are returned, ordered by id.
+func (pg *Postgres) DeleteCloth(ctx context.Context, userId int, clothId int) (brokenOutfits []OutfitRefDto, err error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return nil, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		log.Printf("Begin Transation Failure: %v", err)
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			err = tx.Commit(ctx)
+		}
+	}()
+
+	brokenOutfits, err = trashClothesTx(tx, ctx, userId, []int{clothId})
+	if err != nil {
+		return nil, err
+	}
+
+	return brokenOutfits, nil
+}
+
+// BindTagsTx attaches the user's tags to a clothing item, returning ErrTagNotOwned
+// if any of them belongs to someone else
+func BindTagsTx(tx pgx.Tx, ctx context.Context, userId int, clothId int, tags []int) error {
+	if err := checkTagsOwnedTx(tx, ctx, userId, tags); err != nil {
+		return err
+	}
 
-	query := `INSERT INTO clothing_item_tags (clothing_item_id, tag_id) VALUES ($1, $2)`
+	query := `INSERT INTO clothing_item_tags (clothing_item_id, tag_id) VALUES ($1, $2)
+			  ON CONFLICT DO NOTHING`
 
 	batch := &pgx.Batch{}
 
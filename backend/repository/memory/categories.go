@@ -0,0 +1,177 @@
+package memory
+
+import (
+	"context"
+	"slices"
+
+	"com.fukubox/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+func (store *Store) GetCategoriesByUser(ctx context.Context, userId int) ([]repository.CategoryDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return sortedValues(store.categories, func(category repository.CategoryDto) bool {
+		return store.ownsCategory(userId, category.Id)
+	}), nil
+}
+
+func (store *Store) GetCategoryByUserAndId(ctx context.Context, userId int, categoryId int) (repository.CategoryDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	category, ok := store.categories[categoryId]
+	if !ok || !store.ownsCategory(userId, categoryId) {
+		return repository.CategoryDto{}, pgx.ErrNoRows
+	}
+
+	return category, nil
+}
+
+func (store *Store) CreateCategory(ctx context.Context, userId int, name string, parentId *int) (repository.CategoryDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if parentId != nil && !store.ownsCategory(userId, *parentId) {
+		return repository.CategoryDto{}, repository.ErrCategoryNotOwned
+	}
+
+	createdAt := now()
+	category := repository.CategoryDto{Id: store.nextId(), UserId: userId, ParentId: clone(parentId), Name: name, CreatedAt: createdAt, UpdatedAt: createdAt}
+	store.categories[category.Id] = category
+
+	return category, nil
+}
+
+func (store *Store) UpdateCategory(ctx context.Context, userId int, categoryId int, name *string) (repository.CategoryDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	category, ok := store.categories[categoryId]
+	if !ok || !store.ownsCategory(userId, categoryId) {
+		return repository.CategoryDto{}, pgx.ErrNoRows
+	}
+
+	if name != nil {
+		category.Name = *name
+	}
+	category.UpdatedAt = now()
+	store.categories[categoryId] = category
+
+	return category, nil
+}
+
+func (store *Store) MoveCategory(ctx context.Context, userId int, categoryId int, parentId *int) (repository.CategoryDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	category, ok := store.categories[categoryId]
+	if !ok || !store.ownsCategory(userId, categoryId) {
+		return repository.CategoryDto{}, pgx.ErrNoRows
+	}
+	if parentId != nil {
+		if !store.ownsCategory(userId, *parentId) {
+			return repository.CategoryDto{}, repository.ErrCategoryNotOwned
+		}
+		if slices.Contains(store.subtree(categoryId), *parentId) {
+			return repository.CategoryDto{}, repository.ErrCategoryCycle
+		}
+	}
+
+	category.ParentId = clone(parentId)
+	category.UpdatedAt = now()
+	store.categories[categoryId] = category
+
+	return category, nil
+}
+
+func (store *Store) DeleteCategory(ctx context.Context, userId int, categoryId int, deletion repository.CategoryDeletionDto) (repository.CategoryDeletedDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if !store.ownsCategory(userId, categoryId) {
+		return repository.CategoryDeletedDto{}, pgx.ErrNoRows
+	}
+
+	clothIds := []int{}
+	for _, cloth := range sortedValues(store.clothes, func(cloth repository.ClothDto) bool {
+		return cloth.CategoryId == categoryId && store.ownsCloth(userId, cloth.Id)
+	}) {
+		clothIds = append(clothIds, cloth.Id)
+	}
+
+	deleted := repository.CategoryDeletedDto{ItemCount: len(clothIds), BrokenOutfits: []repository.OutfitRefDto{}}
+
+	// the items share the category's deleted_at, so restoring it can tell which went in with it
+	deletedAt := now()
+	switch deletion.Items {
+	case repository.CategoryItemsReassign:
+		if deletion.ReassignTo == categoryId || !store.ownsCategory(userId, deletion.ReassignTo) {
+			return repository.CategoryDeletedDto{}, repository.ErrCategoryNotOwned
+		}
+		for id, cloth := range store.clothes {
+			if cloth.CategoryId == categoryId {
+				cloth.CategoryId = deletion.ReassignTo
+				cloth.UpdatedAt = deletedAt
+				store.clothes[id] = cloth
+			}
+		}
+	case repository.CategoryItemsTrash:
+		deleted.BrokenOutfits = store.trashClothes(clothIds, deletedAt)
+	default:
+		if len(clothIds) > 0 {
+			return deleted, repository.ErrCategoryInUse
+		}
+	}
+	store.trashedCategories[categoryId] = deletedAt
+
+	// the subcategories move up to the deleted category's parent
+	for id, subcategory := range store.categories {
+		if _, trashed := store.trashedCategories[id]; !trashed && subcategory.ParentId != nil && *subcategory.ParentId == categoryId {
+			subcategory.ParentId = clone(store.categories[categoryId].ParentId)
+			subcategory.UpdatedAt = deletedAt
+			store.categories[id] = subcategory
+		}
+	}
+
+	return deleted, nil
+}
+
+// subtree returns the ids of the category and of its subcategories outside the trash, at any depth
+func (store *Store) subtree(categoryId int) []int {
+	ids := []int{categoryId}
+	for i := 0; i < len(ids); i++ {
+		for id, category := range store.categories {
+			if _, trashed := store.trashedCategories[id]; !trashed && category.ParentId != nil && *category.ParentId == ids[i] {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// ownsCategory reports whether the category is the user's and outside the trash
+func (store *Store) ownsCategory(userId int, categoryId int) bool {
+	category, ok := store.categories[categoryId]
+	_, trashed := store.trashedCategories[categoryId]
+	return ok && category.UserId == userId && !trashed
+}
+
+// findOrCreateCategory returns the user's category called name under parentId, creating it when
+// they don't have one
+func (store *Store) findOrCreateCategory(userId int, parentId *int, name string) repository.NamedIdDto {
+	matches := sortedValues(store.categories, func(category repository.CategoryDto) bool {
+		return store.ownsCategory(userId, category.Id) && category.Name == name &&
+			((parentId == nil && category.ParentId == nil) || (parentId != nil && category.ParentId != nil && *parentId == *category.ParentId))
+	})
+	if len(matches) > 0 {
+		return repository.NamedIdDto{Id: matches[0].Id}
+	}
+
+	createdAt := now()
+	category := repository.CategoryDto{Id: store.nextId(), UserId: userId, ParentId: clone(parentId), Name: name, CreatedAt: createdAt, UpdatedAt: createdAt}
+	store.categories[category.Id] = category
+
+	return repository.NamedIdDto{Id: category.Id, Created: true}
+}
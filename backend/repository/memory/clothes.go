@@ -0,0 +1,383 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"com.fukubox/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+func (store *Store) GetClothesPageByUser(ctx context.Context, userId int, filter repository.ClothFilterDto) (repository.ClothPageDto, error) {
+	if filter.SortBy != repository.ClothSortCreatedAt && filter.SortBy != repository.ClothSortUpdatedAt {
+		return repository.ClothPageDto{}, fmt.Errorf("unknown sort column %q", filter.SortBy)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	sortValue := func(cloth repository.ClothDto) time.Time {
+		if filter.SortBy == repository.ClothSortUpdatedAt {
+			return cloth.UpdatedAt
+		}
+		return cloth.CreatedAt
+	}
+
+	// compare orders clothes by sort value then id, in the direction of the listing
+	compare := func(value time.Time, id int, otherValue time.Time, otherId int) int {
+		order := value.Compare(otherValue)
+		if order == 0 {
+			order = id - otherId
+		}
+		if filter.Descending {
+			return -order
+		}
+		return order
+	}
+
+	matches := sortedValues(store.clothes, func(cloth repository.ClothDto) bool {
+		return store.ownsCloth(userId, cloth.Id) && store.matchesFilter(cloth, filter)
+	})
+	slices.SortFunc(matches, func(a, b repository.ClothDto) int {
+		return compare(sortValue(a), a.Id, sortValue(b), b.Id)
+	})
+
+	page := repository.ClothPageDto{Items: []repository.ClothDto{}, Total: len(matches)}
+
+	for _, cloth := range matches {
+		if filter.After != nil && compare(sortValue(cloth), cloth.Id, filter.After.SortValue, filter.After.Id) <= 0 {
+			continue
+		}
+		if len(page.Items) == filter.Limit {
+			last := page.Items[len(page.Items)-1]
+			page.Next = &repository.ClothCursorDto{SortBy: filter.SortBy, SortValue: sortValue(last), Id: last.Id}
+			break
+		}
+		page.Items = append(page.Items, store.withTags(cloth))
+	}
+
+	return page, nil
+}
+
+func (store *Store) GetClothesByUserAndId(ctx context.Context, userId int, clothId int) (repository.ClothDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	cloth, ok := store.clothes[clothId]
+	if !ok || !store.ownsCloth(userId, clothId) {
+		return repository.ClothDto{}, pgx.ErrNoRows
+	}
+
+	return store.withTags(cloth), nil
+}
+
+func (store *Store) CreateClothWithTags(ctx context.Context, userId int, newCloth repository.ClothEditDto, tags []int) (int, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if err := store.checkCategoryOwned(userId, newCloth.CategoryId); err != nil {
+		return -1, err
+	}
+	if err := store.checkTagsOwned(userId, tags); err != nil {
+		return -1, err
+	}
+
+	createdAt := now()
+	cloth := repository.ClothDto{
+		Id:                 store.nextId(),
+		UserId:             userId,
+		CategoryId:         newCloth.CategoryId,
+		ClothAttributesDto: cloneAttributes(newCloth.ClothAttributesDto),
+		ImageUrl:           newCloth.ImageUrl,
+		CutoutUrl:          clone(newCloth.CutoutUrl),
+		Variants:           maps.Clone(newCloth.Variants),
+		CreatedAt:          createdAt,
+		UpdatedAt:          createdAt,
+	}
+	store.clothes[cloth.Id] = cloth
+	store.clothTags[cloth.Id] = uniqueIds(tags)
+
+	return cloth.Id, nil
+}
+
+// CreateClothByNames creates a clothing item in the category at the end of its path, creating the
+// categories and tags the user doesn't have by name
+func (store *Store) CreateClothByNames(ctx context.Context, userId int, newCloth repository.ClothByNamesDto) (repository.ClothByNamesCreatedDto, error) {
+	if len(newCloth.CategoryPath) == 0 {
+		return repository.ClothByNamesCreatedDto{}, errors.New("a clothing item needs a category")
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	// nothing below can fail, so there is nothing to roll back
+	created := repository.ClothByNamesCreatedDto{}
+	var parentId *int
+	for _, name := range newCloth.CategoryPath {
+		category := store.findOrCreateCategory(userId, parentId, name)
+		created.Categories = append(created.Categories, category)
+		parentId = &category.Id
+	}
+
+	tagIds := []int{}
+	for _, name := range newCloth.TagNames {
+		tag := store.findOrCreateTag(userId, name)
+		created.Tags = append(created.Tags, tag)
+		tagIds = append(tagIds, tag.Id)
+	}
+
+	createdAt := now()
+	cloth := repository.ClothDto{
+		Id:                 store.nextId(),
+		UserId:             userId,
+		CategoryId:         *parentId,
+		ClothAttributesDto: cloneAttributes(newCloth.ClothAttributesDto),
+		ImageUrl:           newCloth.ImageUrl,
+		CreatedAt:          createdAt,
+		UpdatedAt:          createdAt,
+	}
+	store.clothes[cloth.Id] = cloth
+	store.clothTags[cloth.Id] = uniqueIds(tagIds)
+	created.Id = cloth.Id
+
+	return created, nil
+}
+
+// UpdateCloth applies patch to the user's clothing item, changing nothing when it fails. A new
+// image_url drops the cutout and variants of the old image, which are returned.
+func (store *Store) UpdateCloth(ctx context.Context, userId int, clothId int, patch repository.ClothPatchDto) (repository.ClothDto, repository.ClothImagesDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	cloth, ok := store.clothes[clothId]
+	if !ok || !store.ownsCloth(userId, clothId) {
+		return repository.ClothDto{}, repository.ClothImagesDto{}, pgx.ErrNoRows
+	}
+
+	if patch.CategoryId != nil {
+		if err := store.checkCategoryOwned(userId, *patch.CategoryId); err != nil {
+			return repository.ClothDto{}, repository.ClothImagesDto{}, err
+		}
+		cloth.CategoryId = *patch.CategoryId
+	}
+
+	applyAttributesPatch(&cloth.ClothAttributesDto, patch.ClothAttributesPatchDto)
+	if (cloth.PurchasePrice == nil) != (cloth.Currency == nil) {
+		return repository.ClothDto{}, repository.ClothImagesDto{}, repository.ErrPriceWithoutCurrency
+	}
+
+	var replaced repository.ClothImagesDto
+	if patch.ImageUrl != nil && *patch.ImageUrl != cloth.ImageUrl {
+		replaced = repository.ClothImagesDto{ImageUrl: cloth.ImageUrl, CutoutUrl: cloth.CutoutUrl, Variants: cloth.Variants}
+		cloth.ImageUrl = *patch.ImageUrl
+		cloth.CutoutUrl = nil
+		cloth.Variants = nil
+	}
+
+	if patch.TagIds != nil {
+		if err := store.checkTagsOwned(userId, *patch.TagIds); err != nil {
+			return repository.ClothDto{}, repository.ClothImagesDto{}, err
+		}
+		store.clothTags[clothId] = uniqueIds(*patch.TagIds)
+	}
+
+	cloth.UpdatedAt = now()
+	store.clothes[clothId] = cloth
+
+	return store.withTags(cloth), replaced, nil
+}
+
+// UpdateClothImages points the item at newly stored images and returns the ones they replaced
+func (store *Store) UpdateClothImages(ctx context.Context, userId int, clothId int, images repository.ClothImagesDto) (repository.ClothImagesDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	cloth, ok := store.clothes[clothId]
+	if !ok || !store.ownsCloth(userId, clothId) {
+		return repository.ClothImagesDto{}, pgx.ErrNoRows
+	}
+
+	previous := repository.ClothImagesDto{ImageUrl: cloth.ImageUrl, CutoutUrl: cloth.CutoutUrl, Variants: cloth.Variants}
+
+	cloth.ImageUrl = images.ImageUrl
+	cloth.CutoutUrl = clone(images.CutoutUrl)
+	cloth.Variants = maps.Clone(images.Variants)
+	cloth.UpdatedAt = now()
+	store.clothes[clothId] = cloth
+
+	return previous, nil
+}
+
+// DeleteCloth moves the item to the trash, see trashClothes
+func (store *Store) DeleteCloth(ctx context.Context, userId int, clothId int) ([]repository.OutfitRefDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if !store.ownsCloth(userId, clothId) {
+		return nil, pgx.ErrNoRows
+	}
+
+	return store.trashClothes([]int{clothId}, now()), nil
+}
+
+// deleteCloth removes the item for good along with its tags, its wears and the sandbox positions
+// it is placed at, returning the outfits it was taken out of
+func (store *Store) deleteCloth(clothId int) []repository.OutfitRefDto {
+	for id, position := range store.positions {
+		if position.ClothingItemId == clothId {
+			delete(store.positions, id)
+		}
+	}
+	for id, event := range store.wearEvents {
+		event.ClothingItemIds = slices.DeleteFunc(event.ClothingItemIds, func(itemId int) bool { return itemId == clothId })
+		store.wearEvents[id] = event
+	}
+	brokenOutfits := store.removeFromOutfits(clothId)
+	delete(store.clothTags, clothId)
+	delete(store.trashedClothes, clothId)
+	delete(store.clothes, clothId)
+	return brokenOutfits
+}
+
+// ownsCloth reports whether the clothing item is the user's and outside the trash
+func (store *Store) ownsCloth(userId int, clothId int) bool {
+	cloth, ok := store.clothes[clothId]
+	_, trashed := store.trashedClothes[clothId]
+	return ok && cloth.UserId == userId && !trashed
+}
+
+// checkCategoryOwned returns repository.ErrCategoryNotOwned unless the category is the user's and outside the trash
+func (store *Store) checkCategoryOwned(userId int, categoryId int) error {
+	if !store.ownsCategory(userId, categoryId) {
+		return repository.ErrCategoryNotOwned
+	}
+	return nil
+}
+
+// withTags returns a copy of cloth with TagsJson and the wear stats filled in the way Postgres aggregates them
+func (store *Store) withTags(cloth repository.ClothDto) repository.ClothDto {
+	type tagJson struct {
+		Id   int    `json:"id"`
+		Name string `json:"name"`
+	}
+
+	tags := []tagJson{}
+	for _, tagId := range store.clothTags[cloth.Id] {
+		tags = append(tags, tagJson{Id: tagId, Name: store.tags[tagId].Name})
+	}
+
+	tagsJson, _ := json.Marshal(tags)
+	cloth.TagsJson = string(tagsJson)
+	cloth.TimesWorn, cloth.LastWornAt = store.wearStats(cloth.Id)
+	cloth.ClothAttributesDto = cloneAttributes(cloth.ClothAttributesDto)
+	cloth.CutoutUrl = clone(cloth.CutoutUrl)
+	cloth.Variants = maps.Clone(cloth.Variants)
+
+	return cloth
+}
+
+// matchesFilter reports whether cloth passes every filter that is set, compared the way the Postgres query does
+func (store *Store) matchesFilter(cloth repository.ClothDto, filter repository.ClothFilterDto) bool {
+	if filter.CategoryId != nil && filter.IncludeSubcategories && !slices.Contains(store.subtree(*filter.CategoryId), cloth.CategoryId) {
+		return false
+	}
+	if filter.CategoryId != nil && !filter.IncludeSubcategories && cloth.CategoryId != *filter.CategoryId {
+		return false
+	}
+
+	if len(filter.TagIds) > 0 {
+		wanted := uniqueIds(filter.TagIds)
+		found := 0
+		for _, tagId := range wanted {
+			if slices.Contains(store.clothTags[cloth.Id], tagId) {
+				found++
+			}
+		}
+		if found == 0 || (filter.MatchAllTags && found < len(wanted)) {
+			return false
+		}
+	}
+
+	contains := func(value *string, text *string) bool {
+		return text == nil || (value != nil && strings.Contains(strings.ToLower(*value), strings.ToLower(*text)))
+	}
+	equals := func(value *string, text *string) bool {
+		return text == nil || (value != nil && strings.EqualFold(*value, *text))
+	}
+
+	if !contains(cloth.Name, filter.Name) || !contains(cloth.Notes, filter.Notes) {
+		return false
+	}
+	if !equals(cloth.Brand, filter.Brand) || !equals(cloth.Size, filter.Size) || !equals(cloth.Material, filter.Material) ||
+		!equals(cloth.PrimaryColor, filter.PrimaryColor) || !equals(cloth.SecondaryColor, filter.SecondaryColor) ||
+		!equals(cloth.Season, filter.Season) || !equals(cloth.Currency, filter.Currency) {
+		return false
+	}
+	if filter.Color != nil && !equals(cloth.PrimaryColor, filter.Color) && !equals(cloth.SecondaryColor, filter.Color) {
+		return false
+	}
+
+	if filter.MinPrice != nil && (cloth.PurchasePrice == nil || *cloth.PurchasePrice < *filter.MinPrice) {
+		return false
+	}
+	if filter.MaxPrice != nil && (cloth.PurchasePrice == nil || *cloth.PurchasePrice > *filter.MaxPrice) {
+		return false
+	}
+	if filter.PurchasedFrom != nil && (cloth.PurchaseDate == nil || cloth.PurchaseDate.Before(*filter.PurchasedFrom)) {
+		return false
+	}
+	if filter.PurchasedTo != nil && (cloth.PurchaseDate == nil || cloth.PurchaseDate.After(*filter.PurchasedTo)) {
+		return false
+	}
+
+	return true
+}
+
+func applyAttributesPatch(attributes *repository.ClothAttributesDto, patch repository.ClothAttributesPatchDto) {
+	applyOptional(&attributes.Name, patch.Name)
+	applyOptional(&attributes.Brand, patch.Brand)
+	applyOptional(&attributes.Size, patch.Size)
+	applyOptional(&attributes.PrimaryColor, patch.PrimaryColor)
+	applyOptional(&attributes.SecondaryColor, patch.SecondaryColor)
+	applyOptional(&attributes.Material, patch.Material)
+	applyOptional(&attributes.Season, patch.Season)
+	applyOptional(&attributes.PurchasePrice, patch.PurchasePrice)
+	applyOptional(&attributes.Currency, patch.Currency)
+	applyOptional(&attributes.PurchaseDate, patch.PurchaseDate)
+	applyOptional(&attributes.Notes, patch.Notes)
+}
+
+func applyOptional[T any](field **T, patch repository.Optional[T]) {
+	if patch.Set {
+		*field = clone(patch.Value)
+	}
+}
+
+func cloneAttributes(attributes repository.ClothAttributesDto) repository.ClothAttributesDto {
+	return repository.ClothAttributesDto{
+		Name:           clone(attributes.Name),
+		Brand:          clone(attributes.Brand),
+		Size:           clone(attributes.Size),
+		PrimaryColor:   clone(attributes.PrimaryColor),
+		SecondaryColor: clone(attributes.SecondaryColor),
+		Material:       clone(attributes.Material),
+		Season:         clone(attributes.Season),
+		PurchasePrice:  clone(attributes.PurchasePrice),
+		Currency:       clone(attributes.Currency),
+		PurchaseDate:   clone(attributes.PurchaseDate),
+		Notes:          clone(attributes.Notes),
+	}
+}
+
+// uniqueIds returns ids sorted without duplicates, the way tags come back from Postgres
+func uniqueIds(ids []int) []int {
+	unique := slices.Clone(ids)
+	slices.Sort(unique)
+	return slices.Compact(unique)
+}
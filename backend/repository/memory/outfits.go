@@ -0,0 +1,219 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"com.fukubox/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+func (store *Store) GetOutfitsByUser(ctx context.Context, userId int, clothingItemId *int) ([]repository.OutfitDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	outfits := sortedValues(store.outfits, func(outfit repository.OutfitDto) bool {
+		return outfit.UserId == userId && (clothingItemId == nil || store.wears(outfit.Id, *clothingItemId))
+	})
+	for i, outfit := range outfits {
+		outfits[i] = store.withOutfitContents(outfit)
+	}
+
+	return outfits, nil
+}
+
+func (store *Store) GetOutfitByUserAndId(ctx context.Context, userId int, outfitId int) (repository.OutfitDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	outfit, ok := store.outfits[outfitId]
+	if !ok || outfit.UserId != userId {
+		return repository.OutfitDto{}, pgx.ErrNoRows
+	}
+
+	return store.withOutfitContents(outfit), nil
+}
+
+func (store *Store) CreateOutfit(ctx context.Context, userId int, newOutfit repository.OutfitEditDto) (repository.OutfitDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if err := store.checkOutfitItems(userId, newOutfit.Items); err != nil {
+		return repository.OutfitDto{}, err
+	}
+	if err := store.checkTagsOwned(userId, newOutfit.TagIds); err != nil {
+		return repository.OutfitDto{}, err
+	}
+
+	createdAt := now()
+	outfit := repository.OutfitDto{
+		Id:        store.nextId(),
+		UserId:    userId,
+		Name:      newOutfit.Name,
+		Notes:     clone(newOutfit.Notes),
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	}
+	store.outfits[outfit.Id] = outfit
+	store.outfitItems[outfit.Id] = cloneOutfitItems(newOutfit.Items)
+	store.outfitTags[outfit.Id] = uniqueIds(newOutfit.TagIds)
+
+	return store.withOutfitContents(outfit), nil
+}
+
+// UpdateOutfit applies patch to the user's outfit, changing nothing when it fails
+func (store *Store) UpdateOutfit(ctx context.Context, userId int, outfitId int, patch repository.OutfitPatchDto) (repository.OutfitDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	outfit, ok := store.outfits[outfitId]
+	if !ok || outfit.UserId != userId {
+		return repository.OutfitDto{}, pgx.ErrNoRows
+	}
+
+	if patch.Items != nil {
+		if err := store.checkOutfitItems(userId, *patch.Items); err != nil {
+			return repository.OutfitDto{}, err
+		}
+	}
+	if patch.TagIds != nil {
+		if err := store.checkTagsOwned(userId, *patch.TagIds); err != nil {
+			return repository.OutfitDto{}, err
+		}
+	}
+
+	if patch.Name != nil {
+		outfit.Name = *patch.Name
+	}
+	applyOptional(&outfit.Notes, patch.Notes)
+	if patch.Items != nil {
+		store.outfitItems[outfitId] = cloneOutfitItems(*patch.Items)
+	}
+	if patch.TagIds != nil {
+		store.outfitTags[outfitId] = uniqueIds(*patch.TagIds)
+	}
+
+	outfit.UpdatedAt = now()
+	store.outfits[outfitId] = outfit
+
+	return store.withOutfitContents(outfit), nil
+}
+
+func (store *Store) DeleteOutfit(ctx context.Context, userId int, outfitId int) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	outfit, ok := store.outfits[outfitId]
+	if !ok || outfit.UserId != userId {
+		return pgx.ErrNoRows
+	}
+
+	store.deleteOutfit(outfitId)
+	return nil
+}
+
+// deleteOutfit removes the outfit, the wears logged with it keep their items
+func (store *Store) deleteOutfit(outfitId int) {
+	for id, event := range store.wearEvents {
+		if event.OutfitId != nil && *event.OutfitId == outfitId {
+			event.OutfitId = nil
+			store.wearEvents[id] = event
+		}
+	}
+	delete(store.outfitItems, outfitId)
+	delete(store.outfitTags, outfitId)
+	delete(store.outfits, outfitId)
+}
+
+// removeFromOutfits takes the clothing item out of every outfit wearing it and returns those outfits
+func (store *Store) removeFromOutfits(clothId int) []repository.OutfitRefDto {
+	broken := sortedValues(store.outfits, func(outfit repository.OutfitDto) bool {
+		return store.wears(outfit.Id, clothId)
+	})
+
+	outfits := []repository.OutfitRefDto{}
+	for _, outfit := range broken {
+		store.outfitItems[outfit.Id] = slices.DeleteFunc(store.outfitItems[outfit.Id], func(item repository.OutfitItemDto) bool {
+			return item.ClothingItemId == clothId
+		})
+		outfit.UpdatedAt = now()
+		store.outfits[outfit.Id] = outfit
+
+		outfits = append(outfits, repository.OutfitRefDto{Id: outfit.Id, Name: outfit.Name})
+	}
+	return outfits
+}
+
+func (store *Store) wears(outfitId int, clothId int) bool {
+	return slices.ContainsFunc(store.outfitItems[outfitId], func(item repository.OutfitItemDto) bool {
+		return item.ClothingItemId == clothId
+	})
+}
+
+// checkOutfitItems fails like Postgres does for items the user doesn't own or that are listed twice
+func (store *Store) checkOutfitItems(userId int, items []repository.OutfitItemDto) error {
+	itemIds := []int{}
+	for _, item := range items {
+		if slices.Contains(itemIds, item.ClothingItemId) {
+			return fmt.Errorf("clothing item %v is in the outfit twice", item.ClothingItemId)
+		}
+		itemIds = append(itemIds, item.ClothingItemId)
+	}
+	return store.checkItemsOwned(userId, itemIds)
+}
+
+// withOutfitContents returns a copy of outfit with ItemsJson and TagsJson filled in the way Postgres aggregates them
+func (store *Store) withOutfitContents(outfit repository.OutfitDto) repository.OutfitDto {
+	type itemJson struct {
+		ClothingItemId int  `json:"clothing_item_id"`
+		Layer          *int `json:"layer"`
+	}
+	type tagJson struct {
+		Id   int    `json:"id"`
+		Name string `json:"name"`
+	}
+
+	// by layer, items without one last
+	items := cloneOutfitItems(store.outfitItems[outfit.Id])
+	slices.SortFunc(items, func(a, b repository.OutfitItemDto) int {
+		if (a.Layer == nil) != (b.Layer == nil) {
+			if a.Layer == nil {
+				return 1
+			}
+			return -1
+		}
+		if a.Layer != nil && *a.Layer != *b.Layer {
+			return cmp.Compare(*a.Layer, *b.Layer)
+		}
+		return cmp.Compare(a.ClothingItemId, b.ClothingItemId)
+	})
+
+	itemsList := []itemJson{}
+	for _, item := range items {
+		itemsList = append(itemsList, itemJson{ClothingItemId: item.ClothingItemId, Layer: item.Layer})
+	}
+
+	tags := []tagJson{}
+	for _, tagId := range store.outfitTags[outfit.Id] {
+		tags = append(tags, tagJson{Id: tagId, Name: store.tags[tagId].Name})
+	}
+
+	itemsJson, _ := json.Marshal(itemsList)
+	tagsJson, _ := json.Marshal(tags)
+	outfit.ItemsJson = string(itemsJson)
+	outfit.TagsJson = string(tagsJson)
+	outfit.Notes = clone(outfit.Notes)
+
+	return outfit
+}
+
+func cloneOutfitItems(items []repository.OutfitItemDto) []repository.OutfitItemDto {
+	cloned := []repository.OutfitItemDto{}
+	for _, item := range items {
+		cloned = append(cloned, repository.OutfitItemDto{ClothingItemId: item.ClothingItemId, Layer: clone(item.Layer)})
+	}
+	return cloned
+}
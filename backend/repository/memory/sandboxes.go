@@ -0,0 +1,213 @@
+package memory
+
+import (
+	"context"
+
+	"com.fukubox/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+func (store *Store) GetSandboxesByUser(ctx context.Context, userId int) ([]repository.SandboxDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return sortedValues(store.sandboxes, func(sandbox repository.SandboxDto) bool {
+		return sandbox.UserId == userId
+	}), nil
+}
+
+func (store *Store) GetSandboxByUserAndId(ctx context.Context, userId int, sandboxId int) (repository.SandboxDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if !store.ownsSandbox(userId, sandboxId) {
+		return repository.SandboxDto{}, pgx.ErrNoRows
+	}
+
+	return store.sandboxes[sandboxId], nil
+}
+
+func (store *Store) CreateSandbox(ctx context.Context, userId int, newSandbox repository.SandboxEditDto) (repository.SandboxDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	createdAt := now()
+	sandbox := repository.SandboxDto{Id: store.nextId(), UserId: userId, Name: newSandbox.Name, CreatedAt: createdAt, UpdatedAt: createdAt}
+	store.sandboxes[sandbox.Id] = sandbox
+
+	return sandbox, nil
+}
+
+func (store *Store) UpdateSandbox(ctx context.Context, userId int, sandboxId int, patch repository.SandboxPatchDto) (repository.SandboxDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if !store.ownsSandbox(userId, sandboxId) {
+		return repository.SandboxDto{}, pgx.ErrNoRows
+	}
+
+	sandbox := store.sandboxes[sandboxId]
+	if patch.Name != nil {
+		sandbox.Name = *patch.Name
+	}
+	sandbox.UpdatedAt = now()
+	store.sandboxes[sandboxId] = sandbox
+
+	return sandbox, nil
+}
+
+func (store *Store) DeleteSandbox(ctx context.Context, userId int, sandboxId int) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if !store.ownsSandbox(userId, sandboxId) {
+		return pgx.ErrNoRows
+	}
+
+	store.deleteSandbox(sandboxId)
+	return nil
+}
+
+// GetSandboxPositions returns no positions, rather than an error, for a sandbox the user doesn't own
+func (store *Store) GetSandboxPositions(ctx context.Context, userId int, sandboxId int) ([]repository.SandboxPositionDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if !store.ownsSandbox(userId, sandboxId) {
+		return []repository.SandboxPositionDto{}, nil
+	}
+
+	return store.sandboxPositions(sandboxId), nil
+}
+
+func (store *Store) CreateSandboxPosition(ctx context.Context, userId int, sandboxId int, newPosition repository.SandboxPositionEditDto) (repository.SandboxPositionDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if !store.ownsSandbox(userId, sandboxId) {
+		return repository.SandboxPositionDto{}, pgx.ErrNoRows
+	}
+	if err := store.checkItemsOwned(userId, []int{newPosition.ClothingItemId}); err != nil {
+		return repository.SandboxPositionDto{}, err
+	}
+
+	position := store.insertPosition(sandboxId, newPosition)
+	store.touchSandbox(sandboxId)
+
+	return position, nil
+}
+
+func (store *Store) MoveSandboxPosition(ctx context.Context, userId int, sandboxId int, positionId int, x *float64, y *float64) (repository.SandboxPositionDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	position, ok := store.positions[positionId]
+	if !ok || position.SandboxId != sandboxId || !store.ownsSandbox(userId, sandboxId) {
+		return repository.SandboxPositionDto{}, pgx.ErrNoRows
+	}
+
+	if x != nil {
+		position.PositionX = *x
+	}
+	if y != nil {
+		position.PositionY = *y
+	}
+	position.UpdatedAt = now()
+	store.positions[positionId] = position
+
+	return position, nil
+}
+
+func (store *Store) DeleteSandboxPosition(ctx context.Context, userId int, sandboxId int, positionId int) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	position, ok := store.positions[positionId]
+	if !ok || position.SandboxId != sandboxId || !store.ownsSandbox(userId, sandboxId) {
+		return pgx.ErrNoRows
+	}
+
+	delete(store.positions, positionId)
+	return nil
+}
+
+func (store *Store) ReplaceSandboxPositions(ctx context.Context, userId int, sandboxId int, layout []repository.SandboxPositionEditDto) ([]repository.SandboxPositionDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if !store.ownsSandbox(userId, sandboxId) {
+		return nil, pgx.ErrNoRows
+	}
+
+	itemIds := make([]int, 0, len(layout))
+	for _, position := range layout {
+		itemIds = append(itemIds, position.ClothingItemId)
+	}
+	if err := store.checkItemsOwned(userId, itemIds); err != nil {
+		return nil, err
+	}
+
+	for _, position := range store.sandboxPositions(sandboxId) {
+		delete(store.positions, position.Id)
+	}
+
+	positions := []repository.SandboxPositionDto{}
+	for _, newPosition := range layout {
+		positions = append(positions, store.insertPosition(sandboxId, newPosition))
+	}
+	store.touchSandbox(sandboxId)
+
+	return positions, nil
+}
+
+func (store *Store) ownsSandbox(userId int, sandboxId int) bool {
+	sandbox, ok := store.sandboxes[sandboxId]
+	return ok && sandbox.UserId == userId
+}
+
+func (store *Store) sandboxPositions(sandboxId int) []repository.SandboxPositionDto {
+	return sortedValues(store.positions, func(position repository.SandboxPositionDto) bool {
+		return position.SandboxId == sandboxId
+	})
+}
+
+func (store *Store) insertPosition(sandboxId int, newPosition repository.SandboxPositionEditDto) repository.SandboxPositionDto {
+	createdAt := now()
+	position := repository.SandboxPositionDto{
+		Id:             store.nextId(),
+		SandboxId:      sandboxId,
+		ClothingItemId: newPosition.ClothingItemId,
+		PositionX:      newPosition.PositionX,
+		PositionY:      newPosition.PositionY,
+		CreatedAt:      createdAt,
+		UpdatedAt:      createdAt,
+	}
+	store.positions[position.Id] = position
+	return position
+}
+
+func (store *Store) touchSandbox(sandboxId int) {
+	sandbox := store.sandboxes[sandboxId]
+	sandbox.UpdatedAt = now()
+	store.sandboxes[sandboxId] = sandbox
+}
+
+func (store *Store) deleteSandbox(sandboxId int) {
+	for id, position := range store.positions {
+		if position.SandboxId == sandboxId {
+			delete(store.positions, id)
+		}
+	}
+	delete(store.sandboxes, sandboxId)
+}
+
+// checkItemsOwned returns repository.ErrItemNotOwned unless every clothing item id belongs to the user
+// and is outside the trash
+func (store *Store) checkItemsOwned(userId int, itemIds []int) error {
+	for _, itemId := range itemIds {
+		if !store.ownsCloth(userId, itemId) {
+			return repository.ErrItemNotOwned
+		}
+	}
+	return nil
+}
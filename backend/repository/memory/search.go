@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"com.fukubox/repository"
+)
+
+// The search methods stand in for Postgres full text search with a plain match: every word of
+// text has to appear somewhere in the searched fields, ignoring case. Results come newest first.
+
+func (store *Store) SearchClothes(ctx context.Context, userId int, text string, page repository.SearchPageDto) ([]repository.ClothDto, int, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	matches := sortedValues(store.clothes, func(cloth repository.ClothDto) bool {
+		return store.ownsCloth(userId, cloth.Id) && matchesWords(text, cloth.Name, cloth.Notes)
+	})
+
+	clothes := []repository.ClothDto{}
+	for _, cloth := range searchPage(matches, page) {
+		clothes = append(clothes, store.withTags(cloth))
+	}
+
+	return clothes, len(matches), nil
+}
+
+func (store *Store) SearchCategories(ctx context.Context, userId int, text string, page repository.SearchPageDto) ([]repository.CategoryDto, int, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	matches := sortedValues(store.categories, func(category repository.CategoryDto) bool {
+		return store.ownsCategory(userId, category.Id) && matchesWords(text, &category.Name)
+	})
+
+	return searchPage(matches, page), len(matches), nil
+}
+
+func (store *Store) SearchTags(ctx context.Context, userId int, text string, page repository.SearchPageDto) ([]repository.TagDto, int, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	matches := sortedValues(store.tags, func(tag repository.TagDto) bool {
+		return tag.UserId == userId && matchesWords(text, &tag.Name)
+	})
+
+	return searchPage(matches, page), len(matches), nil
+}
+
+// matchesWords reports whether every word of text is contained in one of fields
+func matchesWords(text string, fields ...*string) bool {
+	var searched []string
+	for _, field := range fields {
+		if field != nil {
+			searched = append(searched, strings.ToLower(*field))
+		}
+	}
+	joined := strings.Join(searched, " ")
+
+	words := strings.Fields(strings.ToLower(text))
+	for _, word := range words {
+		if !strings.Contains(joined, word) {
+			return false
+		}
+	}
+	return len(words) > 0
+}
+
+// searchPage returns the requested page of matches, which are ordered by id, newest first
+func searchPage[T any](matches []T, page repository.SearchPageDto) []T {
+	results := slices.Clone(matches)
+	slices.Reverse(results)
+
+	start := min(page.Offset, len(results))
+	end := min(start+page.Limit, len(results))
+	return results[start:end]
+}
@@ -0,0 +1,51 @@
+package memory
+
+import (
+	"context"
+	"slices"
+
+	"com.fukubox/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+func (store *Store) ApplyStarterKit(ctx context.Context, userId int, kit repository.StarterKitDto) (bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if _, ok := store.users[userId]; !ok {
+		return false, pgx.ErrNoRows
+	}
+	if store.starterApplied[userId] {
+		return false, nil
+	}
+	store.starterApplied[userId] = true
+
+	store.createStarterCategories(userId, nil, kit.Categories)
+
+	names := []string{}
+	for _, tag := range store.tags {
+		if tag.UserId == userId {
+			names = append(names, tag.Name)
+		}
+	}
+	for _, name := range kit.Tags {
+		if slices.Contains(names, name) {
+			continue
+		}
+		createdAt := now()
+		tag := repository.TagDto{Id: store.nextId(), UserId: userId, Name: name, CreatedAt: createdAt, UpdatedAt: createdAt}
+		store.tags[tag.Id] = tag
+		names = append(names, name)
+	}
+
+	return true, nil
+}
+
+// createStarterCategories creates the categories under parentId, reusing those the user already
+// has there by name, and then their children under each
+func (store *Store) createStarterCategories(userId int, parentId *int, categories []repository.StarterCategoryDto) {
+	for _, starter := range categories {
+		category := store.findOrCreateCategory(userId, parentId, starter.Name)
+		store.createStarterCategories(userId, &category.Id, starter.Children)
+	}
+}
@@ -0,0 +1,95 @@
+// Package memory keeps the api's data in maps instead of Postgres, so handlers can be
+// exercised without a database. It follows the behaviour of repository.Postgres, down to
+// the errors it returns, but nothing survives the process.
+package memory
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"com.fukubox/repository"
+)
+
+// Store implements every repository interface in memory. It is safe for concurrent use.
+type Store struct {
+	mu     sync.Mutex
+	lastId int
+
+	users      map[int]repository.UserDto
+	categories map[int]repository.CategoryDto
+	tags       map[int]repository.TagDto
+	clothes    map[int]repository.ClothDto
+	// clothTags holds the tag ids of each clothing item, ClothDto.TagsJson is built from it on read
+	clothTags map[int][]int
+	sandboxes map[int]repository.SandboxDto
+	positions map[int]repository.SandboxPositionDto
+	// outfitItems and outfitTags hold what each outfit is made of, the json of OutfitDto is built from them on read
+	outfits     map[int]repository.OutfitDto
+	outfitItems map[int][]repository.OutfitItemDto
+	outfitTags  map[int][]int
+	wearEvents  map[int]repository.WearEventDto
+	// trashedClothes and trashedCategories hold when each row in the trash was deleted, the rest of
+	// the store leaves those rows out
+	trashedClothes    map[int]time.Time
+	trashedCategories map[int]time.Time
+	// starterApplied holds the users who already got their starter kit
+	starterApplied map[int]bool
+}
+
+var _ repository.Repositories = (*Store)(nil)
+
+func NewStore() *Store {
+	return &Store{
+		users:       map[int]repository.UserDto{},
+		categories:  map[int]repository.CategoryDto{},
+		tags:        map[int]repository.TagDto{},
+		clothes:     map[int]repository.ClothDto{},
+		clothTags:   map[int][]int{},
+		sandboxes:   map[int]repository.SandboxDto{},
+		positions:   map[int]repository.SandboxPositionDto{},
+		outfits:     map[int]repository.OutfitDto{},
+		outfitItems: map[int][]repository.OutfitItemDto{},
+		outfitTags:  map[int][]int{},
+		wearEvents:  map[int]repository.WearEventDto{},
+
+		trashedClothes:    map[int]time.Time{},
+		trashedCategories: map[int]time.Time{},
+		starterApplied:    map[int]bool{},
+	}
+}
+
+// nextId hands out ids from one sequence, so ids of different kinds never collide in tests
+func (store *Store) nextId() int {
+	store.lastId++
+	return store.lastId
+}
+
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+// sortedValues returns the values of m ordered by id, keeping those matching keep
+func sortedValues[T any](m map[int]T, keep func(T) bool) []T {
+	ids := make([]int, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	values := []T{}
+	for _, id := range ids {
+		if keep(m[id]) {
+			values = append(values, m[id])
+		}
+	}
+	return values
+}
+
+func clone[T any](value *T) *T {
+	if value == nil {
+		return nil
+	}
+	copied := *value
+	return &copied
+}
@@ -0,0 +1,105 @@
+package memory
+
+import (
+	"context"
+	"slices"
+
+	"com.fukubox/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+func (store *Store) GetTagsByUser(ctx context.Context, userId int) ([]repository.TagDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return sortedValues(store.tags, func(tag repository.TagDto) bool {
+		return tag.UserId == userId
+	}), nil
+}
+
+func (store *Store) GetTagByUserAndId(ctx context.Context, userId int, tagId int) (repository.TagDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	tag, ok := store.tags[tagId]
+	if !ok || tag.UserId != userId {
+		return repository.TagDto{}, pgx.ErrNoRows
+	}
+
+	return tag, nil
+}
+
+func (store *Store) CreateTag(ctx context.Context, userId int, name string) (repository.TagDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	createdAt := now()
+	tag := repository.TagDto{Id: store.nextId(), UserId: userId, Name: name, CreatedAt: createdAt, UpdatedAt: createdAt}
+	store.tags[tag.Id] = tag
+
+	return tag, nil
+}
+
+func (store *Store) UpdateTag(ctx context.Context, userId int, tagId int, name *string) (repository.TagDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	tag, ok := store.tags[tagId]
+	if !ok || tag.UserId != userId {
+		return repository.TagDto{}, pgx.ErrNoRows
+	}
+
+	if name != nil {
+		tag.Name = *name
+	}
+	tag.UpdatedAt = now()
+	store.tags[tagId] = tag
+
+	return tag, nil
+}
+
+func (store *Store) DeleteTag(ctx context.Context, userId int, tagId int) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	tag, ok := store.tags[tagId]
+	if !ok || tag.UserId != userId {
+		return pgx.ErrNoRows
+	}
+
+	for clothId, tagIds := range store.clothTags {
+		store.clothTags[clothId] = slices.DeleteFunc(tagIds, func(id int) bool { return id == tagId })
+	}
+	for outfitId, tagIds := range store.outfitTags {
+		store.outfitTags[outfitId] = slices.DeleteFunc(tagIds, func(id int) bool { return id == tagId })
+	}
+	delete(store.tags, tagId)
+
+	return nil
+}
+
+// checkTagsOwned returns repository.ErrTagNotOwned unless every tag id belongs to the user
+func (store *Store) checkTagsOwned(userId int, tagIds []int) error {
+	for _, tagId := range tagIds {
+		if tag, ok := store.tags[tagId]; !ok || tag.UserId != userId {
+			return repository.ErrTagNotOwned
+		}
+	}
+	return nil
+}
+
+// findOrCreateTag returns the user's tag called name, creating it when they don't have one
+func (store *Store) findOrCreateTag(userId int, name string) repository.NamedIdDto {
+	matches := sortedValues(store.tags, func(tag repository.TagDto) bool {
+		return tag.UserId == userId && tag.Name == name
+	})
+	if len(matches) > 0 {
+		return repository.NamedIdDto{Id: matches[0].Id}
+	}
+
+	createdAt := now()
+	tag := repository.TagDto{Id: store.nextId(), UserId: userId, Name: name, CreatedAt: createdAt, UpdatedAt: createdAt}
+	store.tags[tag.Id] = tag
+
+	return repository.NamedIdDto{Id: tag.Id, Created: true}
+}
@@ -0,0 +1,216 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"time"
+
+	"com.fukubox/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+func (store *Store) GetTrashByUser(ctx context.Context, userId int) ([]repository.TrashEntryDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	entries := []repository.TrashEntryDto{}
+	for id, deletedAt := range store.trashedClothes {
+		if cloth := store.clothes[id]; cloth.UserId == userId {
+			entries = append(entries, repository.TrashEntryDto{
+				Type: repository.TrashClothes, Id: id, Name: clone(cloth.Name), ImageUrl: clone(&cloth.ImageUrl), DeletedAt: deletedAt,
+			})
+		}
+	}
+	for id, deletedAt := range store.trashedCategories {
+		if category := store.categories[id]; category.UserId == userId {
+			entries = append(entries, repository.TrashEntryDto{
+				Type: repository.TrashCategories, Id: id, Name: clone(&category.Name), DeletedAt: deletedAt,
+			})
+		}
+	}
+
+	// most recently deleted first, like the Postgres query
+	slices.SortFunc(entries, func(a, b repository.TrashEntryDto) int {
+		if order := b.DeletedAt.Compare(a.DeletedAt); order != 0 {
+			return order
+		}
+		if order := cmp.Compare(a.Type, b.Type); order != 0 {
+			return order
+		}
+		return cmp.Compare(a.Id, b.Id)
+	})
+
+	return entries, nil
+}
+
+func (store *Store) RestoreCloth(ctx context.Context, userId int, clothId int) (repository.ClothDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	cloth, ok := store.clothes[clothId]
+	if _, trashed := store.trashedClothes[clothId]; !ok || !trashed || cloth.UserId != userId {
+		return repository.ClothDto{}, pgx.ErrNoRows
+	}
+	if _, trashed := store.trashedCategories[cloth.CategoryId]; trashed {
+		return repository.ClothDto{}, repository.ErrCategoryTrashed
+	}
+
+	delete(store.trashedClothes, clothId)
+	return store.withTags(cloth), nil
+}
+
+func (store *Store) RestoreCategory(ctx context.Context, userId int, categoryId int) (repository.CategoryDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	category, ok := store.categories[categoryId]
+	deletedAt, trashed := store.trashedCategories[categoryId]
+	if !ok || !trashed || category.UserId != userId {
+		return repository.CategoryDto{}, pgx.ErrNoRows
+	}
+
+	for id, clothDeletedAt := range store.trashedClothes {
+		if store.clothes[id].CategoryId == categoryId && clothDeletedAt.Equal(deletedAt) {
+			delete(store.trashedClothes, id)
+		}
+	}
+	delete(store.trashedCategories, categoryId)
+
+	// it goes back under its parent unless that is gone to the trash too
+	if category.ParentId != nil {
+		if _, trashed := store.trashedCategories[*category.ParentId]; trashed {
+			category.ParentId = nil
+			store.categories[categoryId] = category
+		}
+	}
+
+	return category, nil
+}
+
+func (store *Store) PurgeCloth(ctx context.Context, userId int, clothId int) (repository.ClothImagesDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	cloth, ok := store.clothes[clothId]
+	if _, trashed := store.trashedClothes[clothId]; !ok || !trashed || cloth.UserId != userId {
+		return repository.ClothImagesDto{}, pgx.ErrNoRows
+	}
+
+	return store.purge([]int{clothId}, nil)[0], nil
+}
+
+func (store *Store) PurgeCategory(ctx context.Context, userId int, categoryId int) ([]repository.ClothImagesDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	category, ok := store.categories[categoryId]
+	if _, trashed := store.trashedCategories[categoryId]; !ok || !trashed || category.UserId != userId {
+		return nil, pgx.ErrNoRows
+	}
+
+	clothIds := store.trashedClothIds(func(cloth repository.ClothDto, deletedAt time.Time) bool {
+		return cloth.CategoryId == categoryId
+	})
+	return store.purge(clothIds, []int{categoryId}), nil
+}
+
+func (store *Store) EmptyTrash(ctx context.Context, userId int) ([]repository.ClothImagesDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	clothIds := store.trashedClothIds(func(cloth repository.ClothDto, deletedAt time.Time) bool {
+		return cloth.UserId == userId
+	})
+	categoryIds := []int{}
+	for id := range store.trashedCategories {
+		if store.categories[id].UserId == userId {
+			categoryIds = append(categoryIds, id)
+		}
+	}
+	return store.purge(clothIds, categoryIds), nil
+}
+
+func (store *Store) PurgeTrashedBefore(ctx context.Context, before time.Time) ([]repository.ClothImagesDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	categoryIds := []int{}
+	for id, deletedAt := range store.trashedCategories {
+		if deletedAt.Before(before) {
+			categoryIds = append(categoryIds, id)
+		}
+	}
+	clothIds := store.trashedClothIds(func(cloth repository.ClothDto, deletedAt time.Time) bool {
+		return deletedAt.Before(before) || slices.Contains(categoryIds, cloth.CategoryId)
+	})
+	return store.purge(clothIds, categoryIds), nil
+}
+
+// trashClothes moves the clothing items to the trash, taking them out of their sandboxes and
+// outfits, and returns the outfits that lost an item ordered by id
+func (store *Store) trashClothes(clothIds []int, deletedAt time.Time) []repository.OutfitRefDto {
+	brokenOutfits := []repository.OutfitRefDto{}
+	for _, clothId := range clothIds {
+		for id, position := range store.positions {
+			if position.ClothingItemId == clothId {
+				delete(store.positions, id)
+			}
+		}
+		for _, outfit := range store.removeFromOutfits(clothId) {
+			if !slices.Contains(brokenOutfits, outfit) {
+				brokenOutfits = append(brokenOutfits, outfit)
+			}
+		}
+		store.trashedClothes[clothId] = deletedAt
+	}
+	slices.SortFunc(brokenOutfits, func(a, b repository.OutfitRefDto) int { return cmp.Compare(a.Id, b.Id) })
+	return brokenOutfits
+}
+
+// trashedClothIds returns the ids of the clothing items in the trash that keep accepts
+func (store *Store) trashedClothIds(keep func(cloth repository.ClothDto, deletedAt time.Time) bool) []int {
+	ids := []int{}
+	for id, deletedAt := range store.trashedClothes {
+		if keep(store.clothes[id], deletedAt) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// purge deletes the trashed clothing items and categories for good and returns the images of the
+// items in clothIds order. Like in Postgres, categories still holding an item are left alone.
+func (store *Store) purge(clothIds []int, categoryIds []int) []repository.ClothImagesDto {
+	images := []repository.ClothImagesDto{}
+	for _, clothId := range clothIds {
+		cloth := store.clothes[clothId]
+		images = append(images, repository.ClothImagesDto{UserId: cloth.UserId, ImageUrl: cloth.ImageUrl, CutoutUrl: clone(cloth.CutoutUrl), Variants: maps.Clone(cloth.Variants)})
+		store.deleteCloth(clothId)
+	}
+
+	for _, categoryId := range categoryIds {
+		inUse := false
+		for _, cloth := range store.clothes {
+			inUse = inUse || cloth.CategoryId == categoryId
+		}
+		if !inUse {
+			delete(store.trashedCategories, categoryId)
+			delete(store.categories, categoryId)
+			store.forgetParent(categoryId)
+		}
+	}
+
+	return images
+}
+
+// forgetParent takes the deleted category off the trashed categories that still name it as their parent
+func (store *Store) forgetParent(categoryId int) {
+	for id, category := range store.categories {
+		if category.ParentId != nil && *category.ParentId == categoryId {
+			category.ParentId = nil
+			store.categories[id] = category
+		}
+	}
+}
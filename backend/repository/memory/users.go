@@ -0,0 +1,176 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"strings"
+
+	"com.fukubox/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+func (store *Store) UpsertGoogleUser(ctx context.Context, googleId string, email string, emailVerified bool, name string) (int, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for id, user := range store.users {
+		if user.GoogleId != nil && *user.GoogleId == googleId {
+			user.UpdatedAt = now()
+			store.users[id] = user
+			return id, nil
+		}
+	}
+
+	if emailVerified && email != "" {
+		for id, user := range store.users {
+			if user.GoogleId == nil && user.Email != nil && *user.Email == email {
+				user.GoogleId = &googleId
+				user.UpdatedAt = now()
+				store.users[id] = user
+				return id, nil
+			}
+		}
+	}
+
+	username := name
+	if username == "" {
+		username, _, _ = strings.Cut(email, "@")
+	}
+
+	var emailValue *string
+	if email != "" {
+		emailValue = &email
+	}
+	if store.emailTaken(emailValue, 0) {
+		return -1, repository.ErrDuplicate
+	}
+
+	createdAt := now()
+	user := repository.UserDto{Id: store.nextId(), Username: username, Email: emailValue, GoogleId: &googleId, CreatedAt: createdAt, UpdatedAt: createdAt}
+	store.users[user.Id] = user
+
+	return user.Id, nil
+}
+
+func (store *Store) UserExists(ctx context.Context, userId int) (bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	_, ok := store.users[userId]
+	return ok, nil
+}
+
+func (store *Store) GetUserById(ctx context.Context, userId int) (repository.UserDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	user, ok := store.users[userId]
+	if !ok {
+		return repository.UserDto{}, pgx.ErrNoRows
+	}
+
+	return user, nil
+}
+
+func (store *Store) CreateUser(ctx context.Context, newUser repository.UserEditDto) (repository.UserDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.emailTaken(newUser.Email, 0) {
+		return repository.UserDto{}, repository.ErrDuplicate
+	}
+
+	createdAt := now()
+	user := repository.UserDto{Id: store.nextId(), Email: clone(newUser.Email), CreatedAt: createdAt, UpdatedAt: createdAt}
+	if newUser.Username != nil {
+		user.Username = *newUser.Username
+	}
+	store.users[user.Id] = user
+
+	return user, nil
+}
+
+func (store *Store) UpdateUser(ctx context.Context, userId int, patch repository.UserPatchDto) (repository.UserDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	user, ok := store.users[userId]
+	if !ok {
+		return repository.UserDto{}, pgx.ErrNoRows
+	}
+
+	if patch.Username != nil {
+		user.Username = *patch.Username
+	}
+	if patch.Email.Set {
+		if store.emailTaken(patch.Email.Value, userId) {
+			return repository.UserDto{}, repository.ErrDuplicate
+		}
+		user.Email = clone(patch.Email.Value)
+	}
+	user.UpdatedAt = now()
+	store.users[userId] = user
+
+	return user, nil
+}
+
+// DeleteUser removes the user together with everything they own, returning the images of their
+// clothing items, trashed ones included
+func (store *Store) DeleteUser(ctx context.Context, userId int) ([]repository.ClothImagesDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if _, ok := store.users[userId]; !ok {
+		return nil, pgx.ErrNoRows
+	}
+
+	for id, sandbox := range store.sandboxes {
+		if sandbox.UserId == userId {
+			store.deleteSandbox(id)
+		}
+	}
+	for id, event := range store.wearEvents {
+		if event.UserId == userId {
+			delete(store.wearEvents, id)
+		}
+	}
+	for id, outfit := range store.outfits {
+		if outfit.UserId == userId {
+			store.deleteOutfit(id)
+		}
+	}
+	images := []repository.ClothImagesDto{}
+	for _, cloth := range sortedValues(store.clothes, func(cloth repository.ClothDto) bool { return cloth.UserId == userId }) {
+		images = append(images, repository.ClothImagesDto{UserId: cloth.UserId, ImageUrl: cloth.ImageUrl, CutoutUrl: clone(cloth.CutoutUrl), Variants: maps.Clone(cloth.Variants)})
+		store.deleteCloth(cloth.Id)
+	}
+	for id, category := range store.categories {
+		if category.UserId == userId {
+			delete(store.trashedCategories, id)
+			delete(store.categories, id)
+		}
+	}
+	for id, tag := range store.tags {
+		if tag.UserId == userId {
+			delete(store.tags, id)
+		}
+	}
+	delete(store.starterApplied, userId)
+	delete(store.users, userId)
+
+	return images, nil
+}
+
+// emailTaken reports whether a user other than exceptId already has email, which like the
+// unique constraint in Postgres never applies to a missing email
+func (store *Store) emailTaken(email *string, exceptId int) bool {
+	if email == nil {
+		return false
+	}
+	for id, user := range store.users {
+		if id != exceptId && user.Email != nil && *user.Email == *email {
+			return true
+		}
+	}
+	return false
+}
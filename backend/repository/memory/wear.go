@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"com.fukubox/repository"
+)
+
+func (store *Store) CreateWearEvent(ctx context.Context, userId int, newEvent repository.WearEventEditDto) (repository.WearEventDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	itemIds := slices.Clone(newEvent.ClothingItemIds)
+	if newEvent.OutfitId != nil {
+		outfit, ok := store.outfits[*newEvent.OutfitId]
+		if !ok || outfit.UserId != userId {
+			return repository.WearEventDto{}, repository.ErrOutfitNotOwned
+		}
+	}
+	if err := store.checkItemsOwned(userId, itemIds); err != nil {
+		return repository.WearEventDto{}, err
+	}
+	// the outfit's items as they are today, so later changes to it don't rewrite the log
+	if newEvent.OutfitId != nil {
+		for _, item := range store.outfitItems[*newEvent.OutfitId] {
+			itemIds = append(itemIds, item.ClothingItemId)
+		}
+	}
+
+	event := repository.WearEventDto{
+		Id:              store.nextId(),
+		UserId:          userId,
+		WornOn:          newEvent.WornOn,
+		OutfitId:        clone(newEvent.OutfitId),
+		ClothingItemIds: uniqueIds(itemIds),
+		Note:            clone(newEvent.Note),
+		CreatedAt:       now(),
+	}
+	store.wearEvents[event.Id] = event
+
+	return store.withOutfitName(event), nil
+}
+
+func (store *Store) GetWearEventsByUser(ctx context.Context, userId int, from time.Time, to time.Time) ([]repository.WearEventDto, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	events := sortedValues(store.wearEvents, func(event repository.WearEventDto) bool {
+		return event.UserId == userId && !event.WornOn.Before(from) && !event.WornOn.After(to)
+	})
+	slices.SortStableFunc(events, func(a, b repository.WearEventDto) int {
+		return a.WornOn.Compare(b.WornOn)
+	})
+	for i, event := range events {
+		events[i] = store.withOutfitName(event)
+	}
+
+	return events, nil
+}
+
+// withOutfitName returns a copy of event with the current name of its outfit, the way Postgres joins it
+func (store *Store) withOutfitName(event repository.WearEventDto) repository.WearEventDto {
+	event.OutfitName = nil
+	if event.OutfitId != nil {
+		name := store.outfits[*event.OutfitId].Name
+		event.OutfitName = &name
+	}
+	event.OutfitId = clone(event.OutfitId)
+	event.ClothingItemIds = slices.Clone(event.ClothingItemIds)
+	event.Note = clone(event.Note)
+	return event
+}
+
+// wearStats returns how often the clothing item was worn and on which day last
+func (store *Store) wearStats(clothId int) (int, *time.Time) {
+	timesWorn := 0
+	var lastWornAt *time.Time
+	for _, event := range store.wearEvents {
+		if !slices.Contains(event.ClothingItemIds, clothId) {
+			continue
+		}
+		timesWorn++
+		if lastWornAt == nil || event.WornOn.After(*lastWornAt) {
+			lastWornAt = clone(&event.WornOn)
+		}
+	}
+	return timesWorn, lastWornAt
+}
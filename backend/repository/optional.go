@@ -0,0 +1,16 @@
+package repository
+
+// Optional is a field of a partial update: it is only changed when Set, and a nil Value sets it to NULL
+type Optional[T any] struct {
+	Set   bool
+	Value *T
+}
+
+// optionalValue lets fields of different types be listed together
+type optionalValue interface {
+	value() (any, bool)
+}
+
+func (field Optional[T]) value() (any, bool) {
+	return field.Value, field.Set
+}
@@ -0,0 +1,412 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type OutfitDto struct {
+	Id        int
+	UserId    int
+	Name      string
+	Notes     *string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	ItemsJson string
+	TagsJson  string
+}
+
+// OutfitItemDto is a clothing item worn in an outfit, items with a lower Layer go underneath
+type OutfitItemDto struct {
+	ClothingItemId int
+	Layer          *int
+}
+
+type OutfitEditDto struct {
+	Name   string
+	Notes  *string
+	Items  []OutfitItemDto
+	TagIds []int
+}
+
+// OutfitPatchDto changes the fields of an outfit that are set, leaving the others alone
+type OutfitPatchDto struct {
+	Name  *string
+	Notes Optional[string]
+	// Items and TagIds replace the outfit's items and tags when not nil, an empty slice removes them all
+	Items  *[]OutfitItemDto
+	TagIds *[]int
+}
+
+// OutfitRefDto names an outfit, for reporting the outfits a change affected
+type OutfitRefDto struct {
+	Id   int
+	Name string
+}
+
+// outfitColumns are the columns scanOutfitRow expects, selected from outfits aliased as o.
+// Items are ordered by layer, those without one last.
+const outfitColumns = `o.id, o.user_id, o.name, o.notes, o.created_at, o.updated_at,
+	COALESCE((SELECT json_agg(json_build_object('clothing_item_id', oi.clothing_item_id, 'layer', oi.layer) ORDER BY oi.layer NULLS LAST, oi.clothing_item_id)
+			  FROM outfit_items oi
+			  WHERE oi.outfit_id = o.id), '[]')::text,
+	COALESCE((SELECT json_agg(json_build_object('id', t.id, 'name', t.name) ORDER BY t.id)
+			  FROM outfit_tags ot
+			  JOIN tags t ON t.id = ot.tag_id
+			  WHERE ot.outfit_id = o.id), '[]')::text`
+
+// getOutfitQuery selects one of the user's outfits for scanOutfitRow, given user_id and id
+const getOutfitQuery = `SELECT ` + outfitColumns + ` FROM outfits o WHERE o.user_id = $1 AND o.id = $2`
+
+func scanOutfitRow(row pgx.Row, outfit *OutfitDto) error {
+	return row.Scan(&outfit.Id, &outfit.UserId, &outfit.Name, &outfit.Notes, &outfit.CreatedAt, &outfit.UpdatedAt, &outfit.ItemsJson, &outfit.TagsJson)
+}
+
+// GetOutfitsByUser returns the user's outfits, only those wearing the clothing item when clothingItemId is set
+func (pg *Postgres) GetOutfitsByUser(ctx context.Context, userId int, clothingItemId *int) ([]OutfitDto, error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return nil, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	query := `SELECT ` + outfitColumns + `
+			  FROM outfits o
+			  WHERE o.user_id = $1
+			    AND ($2::int IS NULL OR EXISTS (SELECT 1 FROM outfit_items oi WHERE oi.outfit_id = o.id AND oi.clothing_item_id = $2))
+			  ORDER BY o.id`
+
+	rows, err := conn.Query(ctx, query, userId, clothingItemId)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	outfits := []OutfitDto{}
+
+	for rows.Next() {
+		var outfit OutfitDto
+		if err := scanOutfitRow(rows, &outfit); err != nil {
+			log.Printf("Failed to scan row: %v", err)
+			return nil, err
+		}
+		outfits = append(outfits, outfit)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error after iterating rows: %v", err)
+		return nil, err
+	}
+
+	return outfits, nil
+}
+
+func (pg *Postgres) GetOutfitByUserAndId(ctx context.Context, userId int, outfitId int) (OutfitDto, error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return OutfitDto{}, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	var outfit OutfitDto
+	err := scanOutfitRow(conn.QueryRow(ctx, getOutfitQuery, userId, outfitId), &outfit)
+	if err != nil {
+		log.Printf("Failed to query %v with params {user_id: %v, id:%v}: %v", getOutfitQuery, userId, outfitId, err)
+		return OutfitDto{}, err
+	}
+
+	return outfit, nil
+}
+
+// CreateOutfit saves an outfit with its items and tags in a single transaction, returning
+// ErrItemNotOwned or ErrTagNotOwned when any of them belongs to someone else
+func (pg *Postgres) CreateOutfit(ctx context.Context, userId int, newOutfit OutfitEditDto) (outfit OutfitDto, err error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return OutfitDto{}, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		log.Printf("Begin Transation Failure: %v", err)
+		return OutfitDto{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			err = tx.Commit(ctx)
+		}
+	}()
+
+	query := `INSERT INTO outfits (user_id, name, notes, created_at, updated_at)
+			  VALUES ($1, $2, $3, now(), now())
+			  RETURNING id`
+
+	var outfitId int
+	err = tx.QueryRow(ctx, query, userId, newOutfit.Name, newOutfit.Notes).Scan(&outfitId)
+	if err != nil {
+		log.Printf("Failed to insert new outfit: %v", err)
+		return OutfitDto{}, err
+	}
+
+	err = bindOutfitItemsTx(tx, ctx, userId, outfitId, newOutfit.Items)
+	if err != nil {
+		return OutfitDto{}, err
+	}
+
+	err = bindOutfitTagsTx(tx, ctx, userId, outfitId, newOutfit.TagIds)
+	if err != nil {
+		return OutfitDto{}, err
+	}
+
+	err = scanOutfitRow(tx.QueryRow(ctx, getOutfitQuery, userId, outfitId), &outfit)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", getOutfitQuery, err)
+		return OutfitDto{}, err
+	}
+
+	return outfit, nil
+}
+
+// UpdateOutfit applies patch to the user's outfit in a single transaction and returns the
+// updated outfit, or pgx.ErrNoRows if the user has no such outfit
+func (pg *Postgres) UpdateOutfit(ctx context.Context, userId int, outfitId int, patch OutfitPatchDto) (outfit OutfitDto, err error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return OutfitDto{}, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		log.Printf("Begin Transation Failure: %v", err)
+		return OutfitDto{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			err = tx.Commit(ctx)
+		}
+	}()
+
+	var args queryArgs
+	assignments := []string{"updated_at = now()"}
+
+	if patch.Name != nil {
+		assignments = append(assignments, "name = "+args.add(*patch.Name))
+	}
+	if patch.Notes.Set {
+		assignments = append(assignments, "notes = "+args.add(patch.Notes.Value))
+	}
+
+	query := fmt.Sprintf(`UPDATE outfits SET %v WHERE id = %v AND user_id = %v RETURNING id`,
+		strings.Join(assignments, ", "), args.add(outfitId), args.add(userId))
+
+	err = tx.QueryRow(ctx, query, args...).Scan(&outfitId)
+	if err != nil {
+		log.Printf("Failed to update outfit %v: %v", outfitId, err)
+		return OutfitDto{}, err
+	}
+
+	if patch.Items != nil {
+		_, err = tx.Exec(ctx, `DELETE FROM outfit_items WHERE outfit_id = $1`, outfitId)
+		if err != nil {
+			log.Printf("Failed to delete items of outfit %v: %v", outfitId, err)
+			return OutfitDto{}, err
+		}
+
+		err = bindOutfitItemsTx(tx, ctx, userId, outfitId, *patch.Items)
+		if err != nil {
+			return OutfitDto{}, err
+		}
+	}
+
+	if patch.TagIds != nil {
+		_, err = tx.Exec(ctx, `DELETE FROM outfit_tags WHERE outfit_id = $1`, outfitId)
+		if err != nil {
+			log.Printf("Failed to delete tags of outfit %v: %v", outfitId, err)
+			return OutfitDto{}, err
+		}
+
+		err = bindOutfitTagsTx(tx, ctx, userId, outfitId, *patch.TagIds)
+		if err != nil {
+			return OutfitDto{}, err
+		}
+	}
+
+	err = scanOutfitRow(tx.QueryRow(ctx, getOutfitQuery, userId, outfitId), &outfit)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", getOutfitQuery, err)
+		return OutfitDto{}, err
+	}
+
+	return outfit, nil
+}
+
+// DeleteOutfit removes the user's outfit together with its items and tags, the clothing items themselves
+// and the wears logged with it stay
+func (pg *Postgres) DeleteOutfit(ctx context.Context, userId int, outfitId int) (err error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		log.Printf("Begin Transation Failure: %v", err)
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			err = tx.Commit(ctx)
+		}
+	}()
+
+	queries := []string{
+		// wears logged with the outfit keep their items
+		`UPDATE wear_events w SET outfit_id = NULL FROM outfits o
+		 WHERE w.outfit_id = o.id AND o.id = $1 AND o.user_id = $2`,
+		`DELETE FROM outfit_items oi USING outfits o
+		 WHERE oi.outfit_id = o.id AND o.id = $1 AND o.user_id = $2`,
+		`DELETE FROM outfit_tags ot USING outfits o
+		 WHERE ot.outfit_id = o.id AND o.id = $1 AND o.user_id = $2`,
+	}
+
+	for _, query := range queries {
+		_, err = tx.Exec(ctx, query, outfitId, userId)
+		if err != nil {
+			log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+			return err
+		}
+	}
+
+	commandTag, err := tx.Exec(ctx, `DELETE FROM outfits WHERE id = $1 AND user_id = $2`, outfitId, userId)
+	if err != nil {
+		log.Printf("Failed to delete outfit %v: %v", outfitId, err)
+		return err
+	}
+	if commandTag.RowsAffected() == 0 {
+		err = pgx.ErrNoRows
+		return err
+	}
+
+	return nil
+}
+
+// removeFromOutfitsTx takes the user's clothing items out of every outfit wearing them and
+// returns those outfits, ordered by id
+func removeFromOutfitsTx(tx pgx.Tx, ctx context.Context, userId int, clothIds []int) ([]OutfitRefDto, error) {
+	query := `WITH removed AS (
+				DELETE FROM outfit_items oi USING clothing_items c
+				WHERE oi.clothing_item_id = c.id AND c.id = ANY($1) AND c.user_id = $2
+				RETURNING oi.outfit_id
+			  ), touched AS (
+				UPDATE outfits o SET updated_at = now()
+				FROM removed
+				WHERE o.id = removed.outfit_id
+				RETURNING o.id, o.name
+			  )
+			  SELECT id, name FROM touched ORDER BY id`
+
+	rows, err := tx.Query(ctx, query, clothIds, userId)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	outfits := []OutfitRefDto{}
+
+	for rows.Next() {
+		var outfit OutfitRefDto
+		if err := rows.Scan(&outfit.Id, &outfit.Name); err != nil {
+			log.Printf("Failed to scan row: %v", err)
+			return nil, err
+		}
+		outfits = append(outfits, outfit)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error after iterating rows: %v", err)
+		return nil, err
+	}
+
+	return outfits, nil
+}
+
+// bindOutfitItemsTx puts the user's clothing items in an outfit, returning ErrItemNotOwned
+// if any of them belongs to someone else
+func bindOutfitItemsTx(tx pgx.Tx, ctx context.Context, userId int, outfitId int, items []OutfitItemDto) error {
+	itemIds := make([]int, 0, len(items))
+	for _, item := range items {
+		itemIds = append(itemIds, item.ClothingItemId)
+	}
+
+	if err := checkItemsOwnedTx(tx, ctx, userId, itemIds); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO outfit_items (outfit_id, clothing_item_id, layer) VALUES ($1, $2, $3)`
+
+	batch := &pgx.Batch{}
+
+	for _, item := range items {
+		batch.Queue(query, outfitId, item.ClothingItemId, item.Layer)
+	}
+
+	results := tx.SendBatch(ctx, batch)
+	defer results.Close()
+
+	for _, item := range items {
+		_, err := results.Exec()
+		if err != nil {
+			log.Printf("Failed to insert outfit item %v: %v", item.ClothingItemId, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// bindOutfitTagsTx attaches the user's tags to an outfit, returning ErrTagNotOwned
+// if any of them belongs to someone else
+func bindOutfitTagsTx(tx pgx.Tx, ctx context.Context, userId int, outfitId int, tags []int) error {
+	if err := checkTagsOwnedTx(tx, ctx, userId, tags); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO outfit_tags (outfit_id, tag_id) VALUES ($1, $2)
+			  ON CONFLICT DO NOTHING`
+
+	batch := &pgx.Batch{}
+
+	for _, tagId := range tags {
+		batch.Queue(query, outfitId, tagId)
+	}
+
+	results := tx.SendBatch(ctx, batch)
+	defer results.Close()
+
+	for _, tagId := range tags {
+		_, err := results.Exec()
+		if err != nil {
+			log.Printf("Failed to insert tag %v: %v", tagId, err)
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+	"log"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Postgres keeps everything in PostgreSQL and implements every repository interface
+type Postgres struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgres(db *pgxpool.Pool) *Postgres {
+	return &Postgres{db: db}
+}
+
+// acquire returns a connection from the pool, or nil when none could be had
+func (pg *Postgres) acquire(ctx context.Context) *pgxpool.Conn {
+	conn, err := pg.db.Acquire(ctx)
+	if err != nil {
+		log.Printf("Failed to acquire a database connection: %v", err)
+		return nil
+	}
+
+	return conn
+}
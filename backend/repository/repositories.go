@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// The handlers only depend on these interfaces, so they can run against Postgres
+// or the in-memory store in tests. Lookups of something the user doesn't own, or
+// that doesn't exist, return pgx.ErrNoRows.
+
+type ClothesRepository interface {
+	GetClothesPageByUser(ctx context.Context, userId int, filter ClothFilterDto) (ClothPageDto, error)
+	GetClothesByUserAndId(ctx context.Context, userId int, clothId int) (ClothDto, error)
+	CreateClothWithTags(ctx context.Context, userId int, newCloth ClothEditDto, tags []int) (int, error)
+	CreateClothByNames(ctx context.Context, userId int, newCloth ClothByNamesDto) (ClothByNamesCreatedDto, error)
+	UpdateCloth(ctx context.Context, userId int, clothId int, patch ClothPatchDto) (ClothDto, ClothImagesDto, error)
+	UpdateClothImages(ctx context.Context, userId int, clothId int, images ClothImagesDto) (ClothImagesDto, error)
+	DeleteCloth(ctx context.Context, userId int, clothId int) ([]OutfitRefDto, error)
+}
+
+type CategoryRepository interface {
+	GetCategoriesByUser(ctx context.Context, userId int) ([]CategoryDto, error)
+	GetCategoryByUserAndId(ctx context.Context, userId int, categoryId int) (CategoryDto, error)
+	CreateCategory(ctx context.Context, userId int, name string, parentId *int) (CategoryDto, error)
+	UpdateCategory(ctx context.Context, userId int, categoryId int, name *string) (CategoryDto, error)
+	MoveCategory(ctx context.Context, userId int, categoryId int, parentId *int) (CategoryDto, error)
+	DeleteCategory(ctx context.Context, userId int, categoryId int, deletion CategoryDeletionDto) (CategoryDeletedDto, error)
+}
+
+type TagRepository interface {
+	GetTagsByUser(ctx context.Context, userId int) ([]TagDto, error)
+	GetTagByUserAndId(ctx context.Context, userId int, tagId int) (TagDto, error)
+	CreateTag(ctx context.Context, userId int, name string) (TagDto, error)
+	UpdateTag(ctx context.Context, userId int, tagId int, name *string) (TagDto, error)
+	DeleteTag(ctx context.Context, userId int, tagId int) error
+}
+
+type UserRepository interface {
+	UpsertGoogleUser(ctx context.Context, googleId string, email string, emailVerified bool, name string) (int, error)
+	UserExists(ctx context.Context, userId int) (bool, error)
+	GetUserById(ctx context.Context, userId int) (UserDto, error)
+	CreateUser(ctx context.Context, newUser UserEditDto) (UserDto, error)
+	UpdateUser(ctx context.Context, userId int, patch UserPatchDto) (UserDto, error)
+	DeleteUser(ctx context.Context, userId int) ([]ClothImagesDto, error)
+	ApplyStarterKit(ctx context.Context, userId int, kit StarterKitDto) (bool, error)
+}
+
+type SandboxRepository interface {
+	GetSandboxesByUser(ctx context.Context, userId int) ([]SandboxDto, error)
+	GetSandboxByUserAndId(ctx context.Context, userId int, sandboxId int) (SandboxDto, error)
+	CreateSandbox(ctx context.Context, userId int, newSandbox SandboxEditDto) (SandboxDto, error)
+	UpdateSandbox(ctx context.Context, userId int, sandboxId int, patch SandboxPatchDto) (SandboxDto, error)
+	DeleteSandbox(ctx context.Context, userId int, sandboxId int) error
+	GetSandboxPositions(ctx context.Context, userId int, sandboxId int) ([]SandboxPositionDto, error)
+	CreateSandboxPosition(ctx context.Context, userId int, sandboxId int, newPosition SandboxPositionEditDto) (SandboxPositionDto, error)
+	MoveSandboxPosition(ctx context.Context, userId int, sandboxId int, positionId int, x *float64, y *float64) (SandboxPositionDto, error)
+	DeleteSandboxPosition(ctx context.Context, userId int, sandboxId int, positionId int) error
+	ReplaceSandboxPositions(ctx context.Context, userId int, sandboxId int, layout []SandboxPositionEditDto) ([]SandboxPositionDto, error)
+}
+
+type OutfitRepository interface {
+	GetOutfitsByUser(ctx context.Context, userId int, clothingItemId *int) ([]OutfitDto, error)
+	GetOutfitByUserAndId(ctx context.Context, userId int, outfitId int) (OutfitDto, error)
+	CreateOutfit(ctx context.Context, userId int, newOutfit OutfitEditDto) (OutfitDto, error)
+	UpdateOutfit(ctx context.Context, userId int, outfitId int, patch OutfitPatchDto) (OutfitDto, error)
+	DeleteOutfit(ctx context.Context, userId int, outfitId int) error
+}
+
+type WearRepository interface {
+	CreateWearEvent(ctx context.Context, userId int, newEvent WearEventEditDto) (WearEventDto, error)
+	GetWearEventsByUser(ctx context.Context, userId int, from time.Time, to time.Time) ([]WearEventDto, error)
+}
+
+type TrashRepository interface {
+	GetTrashByUser(ctx context.Context, userId int) ([]TrashEntryDto, error)
+	RestoreCloth(ctx context.Context, userId int, clothId int) (ClothDto, error)
+	RestoreCategory(ctx context.Context, userId int, categoryId int) (CategoryDto, error)
+	PurgeCloth(ctx context.Context, userId int, clothId int) (ClothImagesDto, error)
+	PurgeCategory(ctx context.Context, userId int, categoryId int) ([]ClothImagesDto, error)
+	EmptyTrash(ctx context.Context, userId int) ([]ClothImagesDto, error)
+	PurgeTrashedBefore(ctx context.Context, before time.Time) ([]ClothImagesDto, error)
+}
+
+type SearchRepository interface {
+	SearchClothes(ctx context.Context, userId int, text string, page SearchPageDto) ([]ClothDto, int, error)
+	SearchCategories(ctx context.Context, userId int, text string, page SearchPageDto) ([]CategoryDto, int, error)
+	SearchTags(ctx context.Context, userId int, text string, page SearchPageDto) ([]TagDto, int, error)
+}
+
+// Repositories is a store that holds all of the api's data
+type Repositories interface {
+	ClothesRepository
+	CategoryRepository
+	TagRepository
+	UserRepository
+	SandboxRepository
+	OutfitRepository
+	WearRepository
+	TrashRepository
+	SearchRepository
+}
+
+var _ Repositories = (*Postgres)(nil)
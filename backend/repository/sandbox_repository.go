@@ -0,0 +1,447 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrItemNotOwned is returned when a clothing item id does not belong to the user
+var ErrItemNotOwned = errors.New("clothing item not found or not owned by user")
+
+type SandboxDto struct {
+	Id        int
+	UserId    int
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type SandboxEditDto struct {
+	Name string
+}
+
+type SandboxPatchDto struct {
+	Name *string
+}
+
+type SandboxPositionDto struct {
+	Id             int
+	SandboxId      int
+	ClothingItemId int
+	PositionX      float64
+	PositionY      float64
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+type SandboxPositionEditDto struct {
+	ClothingItemId int
+	PositionX      float64
+	PositionY      float64
+}
+
+func (pg *Postgres) GetSandboxesByUser(ctx context.Context, userId int) ([]SandboxDto, error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return nil, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	query := `SELECT id, user_id, COALESCE(name, ''), created_at, updated_at FROM sandbox WHERE user_id = $1 ORDER BY id`
+
+	rows, err := conn.Query(ctx, query, userId)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	sandboxes := []SandboxDto{}
+
+	for rows.Next() {
+		var sandbox SandboxDto
+		if err := rows.Scan(&sandbox.Id, &sandbox.UserId, &sandbox.Name, &sandbox.CreatedAt, &sandbox.UpdatedAt); err != nil {
+			log.Printf("Failed to scan row: %v", err)
+			return nil, err
+		}
+		sandboxes = append(sandboxes, sandbox)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error after iterating rows: %v", err)
+		return nil, err
+	}
+
+	return sandboxes, nil
+}
+
+func (pg *Postgres) GetSandboxByUserAndId(ctx context.Context, userId int, sandboxId int) (SandboxDto, error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return SandboxDto{}, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	query := `SELECT id, user_id, COALESCE(name, ''), created_at, updated_at FROM sandbox WHERE user_id = $1 AND id = $2`
+
+	var sandbox SandboxDto
+	err := conn.QueryRow(ctx, query, userId, sandboxId).
+		Scan(&sandbox.Id, &sandbox.UserId, &sandbox.Name, &sandbox.CreatedAt, &sandbox.UpdatedAt)
+	if err != nil {
+		log.Printf("Failed to query %v with params {user_id: %v, id:%v}: %v", query, userId, sandboxId, err)
+		return SandboxDto{}, err
+	}
+
+	return sandbox, nil
+}
+
+func (pg *Postgres) CreateSandbox(ctx context.Context, userId int, newSandbox SandboxEditDto) (SandboxDto, error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return SandboxDto{}, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	query := `INSERT INTO sandbox (user_id, name, created_at, updated_at)
+			  VALUES ($1, $2, now(), now())
+			  RETURNING id, user_id, name, created_at, updated_at`
+
+	var sandbox SandboxDto
+	err := conn.QueryRow(ctx, query, userId, newSandbox.Name).
+		Scan(&sandbox.Id, &sandbox.UserId, &sandbox.Name, &sandbox.CreatedAt, &sandbox.UpdatedAt)
+	if err != nil {
+		log.Printf("Failed to insert new sandbox: %v", err)
+		return SandboxDto{}, err
+	}
+
+	return sandbox, nil
+}
+
+// UpdateSandbox changes only the fields that are set on patch
+func (pg *Postgres) UpdateSandbox(ctx context.Context, userId int, sandboxId int, patch SandboxPatchDto) (SandboxDto, error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return SandboxDto{}, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	query := `UPDATE sandbox SET name = COALESCE($1, name), updated_at = now()
+			  WHERE id = $2 AND user_id = $3
+			  RETURNING id, user_id, name, created_at, updated_at`
+
+	var sandbox SandboxDto
+	err := conn.QueryRow(ctx, query, patch.Name, sandboxId, userId).
+		Scan(&sandbox.Id, &sandbox.UserId, &sandbox.Name, &sandbox.CreatedAt, &sandbox.UpdatedAt)
+	if err != nil {
+		log.Printf("Failed to update sandbox %v: %v", sandboxId, err)
+		return SandboxDto{}, err
+	}
+
+	return sandbox, nil
+}
+
+// DeleteSandbox removes the sandbox and its positions, returning pgx.ErrNoRows when the user does not own it
+func (pg *Postgres) DeleteSandbox(ctx context.Context, userId int, sandboxId int) (err error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		log.Printf("Begin Transation Failure: %v", err)
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			err = tx.Commit(ctx)
+		}
+	}()
+
+	_, err = tx.Exec(ctx, `DELETE FROM sandbox_positions sp USING sandbox s
+						   WHERE sp.sandbox_id = s.id AND s.id = $1 AND s.user_id = $2`, sandboxId, userId)
+	if err != nil {
+		log.Printf("Failed to delete sandbox positions: %v", err)
+		return err
+	}
+
+	commandTag, err := tx.Exec(ctx, `DELETE FROM sandbox WHERE id = $1 AND user_id = $2`, sandboxId, userId)
+	if err != nil {
+		log.Printf("Failed to delete sandbox: %v", err)
+		return err
+	}
+	if commandTag.RowsAffected() == 0 {
+		err = pgx.ErrNoRows
+		return err
+	}
+
+	return nil
+}
+
+func (pg *Postgres) GetSandboxPositions(ctx context.Context, userId int, sandboxId int) ([]SandboxPositionDto, error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return nil, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	query := `SELECT sp.id, sp.sandbox_id, sp.clothing_item_id, sp.position_x, sp.position_y, sp.created_at, sp.updated_at
+			  FROM sandbox_positions sp
+			  JOIN sandbox s ON s.id = sp.sandbox_id
+			  WHERE s.user_id = $1 AND s.id = $2
+			  ORDER BY sp.id`
+
+	rows, err := conn.Query(ctx, query, userId, sandboxId)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	positions := []SandboxPositionDto{}
+
+	for rows.Next() {
+		var position SandboxPositionDto
+		if err := rows.Scan(&position.Id, &position.SandboxId, &position.ClothingItemId, &position.PositionX, &position.PositionY, &position.CreatedAt, &position.UpdatedAt); err != nil {
+			log.Printf("Failed to scan row: %v", err)
+			return nil, err
+		}
+		positions = append(positions, position)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error after iterating rows: %v", err)
+		return nil, err
+	}
+
+	return positions, nil
+}
+
+// CreateSandboxPosition places a clothing item in a sandbox, both of which must belong to the user
+func (pg *Postgres) CreateSandboxPosition(ctx context.Context, userId int, sandboxId int, newPosition SandboxPositionEditDto) (position SandboxPositionDto, err error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return SandboxPositionDto{}, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		log.Printf("Begin Transation Failure: %v", err)
+		return SandboxPositionDto{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			err = tx.Commit(ctx)
+		}
+	}()
+
+	err = lockSandboxTx(tx, ctx, userId, sandboxId)
+	if err != nil {
+		return SandboxPositionDto{}, err
+	}
+
+	err = checkItemsOwnedTx(tx, ctx, userId, []int{newPosition.ClothingItemId})
+	if err != nil {
+		return SandboxPositionDto{}, err
+	}
+
+	query := `INSERT INTO sandbox_positions (sandbox_id, clothing_item_id, position_x, position_y, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, now(), now())
+			  RETURNING id, sandbox_id, clothing_item_id, position_x, position_y, created_at, updated_at`
+
+	err = tx.QueryRow(ctx, query, sandboxId, newPosition.ClothingItemId, newPosition.PositionX, newPosition.PositionY).
+		Scan(&position.Id, &position.SandboxId, &position.ClothingItemId, &position.PositionX, &position.PositionY, &position.CreatedAt, &position.UpdatedAt)
+	if err != nil {
+		log.Printf("Failed to insert new sandbox position: %v", err)
+		return SandboxPositionDto{}, err
+	}
+
+	err = touchSandboxTx(tx, ctx, sandboxId)
+	if err != nil {
+		return SandboxPositionDto{}, err
+	}
+
+	return position, nil
+}
+
+// MoveSandboxPosition changes the coordinates of a placed item that aren't nil
+func (pg *Postgres) MoveSandboxPosition(ctx context.Context, userId int, sandboxId int, positionId int, x *float64, y *float64) (SandboxPositionDto, error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return SandboxPositionDto{}, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	query := `UPDATE sandbox_positions sp SET position_x = COALESCE($1, sp.position_x), position_y = COALESCE($2, sp.position_y), updated_at = now()
+			  FROM sandbox s
+			  WHERE sp.sandbox_id = s.id AND sp.id = $3 AND s.id = $4 AND s.user_id = $5
+			  RETURNING sp.id, sp.sandbox_id, sp.clothing_item_id, sp.position_x, sp.position_y, sp.created_at, sp.updated_at`
+
+	var position SandboxPositionDto
+	err := conn.QueryRow(ctx, query, x, y, positionId, sandboxId, userId).
+		Scan(&position.Id, &position.SandboxId, &position.ClothingItemId, &position.PositionX, &position.PositionY, &position.CreatedAt, &position.UpdatedAt)
+	if err != nil {
+		log.Printf("Failed to move sandbox position %v: %v", positionId, err)
+		return SandboxPositionDto{}, err
+	}
+
+	return position, nil
+}
+
+func (pg *Postgres) DeleteSandboxPosition(ctx context.Context, userId int, sandboxId int, positionId int) error {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	query := `DELETE FROM sandbox_positions sp USING sandbox s
+			  WHERE sp.sandbox_id = s.id AND sp.id = $1 AND s.id = $2 AND s.user_id = $3`
+
+	commandTag, err := conn.Exec(ctx, query, positionId, sandboxId, userId)
+	if err != nil {
+		log.Printf("Failed to delete sandbox position %v: %v", positionId, err)
+		return err
+	}
+	if commandTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// ReplaceSandboxPositions saves a whole layout in one transaction, replacing every existing position
+func (pg *Postgres) ReplaceSandboxPositions(ctx context.Context, userId int, sandboxId int, layout []SandboxPositionEditDto) (positions []SandboxPositionDto, err error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return nil, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		log.Printf("Begin Transation Failure: %v", err)
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			err = tx.Commit(ctx)
+		}
+	}()
+
+	err = lockSandboxTx(tx, ctx, userId, sandboxId)
+	if err != nil {
+		return nil, err
+	}
+
+	itemIds := make([]int, 0, len(layout))
+	for _, position := range layout {
+		itemIds = append(itemIds, position.ClothingItemId)
+	}
+
+	err = checkItemsOwnedTx(tx, ctx, userId, itemIds)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, `DELETE FROM sandbox_positions WHERE sandbox_id = $1`, sandboxId)
+	if err != nil {
+		log.Printf("Failed to clear sandbox positions: %v", err)
+		return nil, err
+	}
+
+	query := `INSERT INTO sandbox_positions (sandbox_id, clothing_item_id, position_x, position_y, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, now(), now())
+			  RETURNING id, sandbox_id, clothing_item_id, position_x, position_y, created_at, updated_at`
+
+	batch := &pgx.Batch{}
+
+	for _, position := range layout {
+		batch.Queue(query, sandboxId, position.ClothingItemId, position.PositionX, position.PositionY)
+	}
+
+	results := tx.SendBatch(ctx, batch)
+
+	positions = []SandboxPositionDto{}
+
+	for range layout {
+		var position SandboxPositionDto
+		err = results.QueryRow().
+			Scan(&position.Id, &position.SandboxId, &position.ClothingItemId, &position.PositionX, &position.PositionY, &position.CreatedAt, &position.UpdatedAt)
+		if err != nil {
+			log.Printf("Failed to insert sandbox position: %v", err)
+			results.Close()
+			return nil, err
+		}
+		positions = append(positions, position)
+	}
+
+	err = results.Close()
+	if err != nil {
+		log.Printf("Failed to close batch results: %v", err)
+		return nil, err
+	}
+
+	err = touchSandboxTx(tx, ctx, sandboxId)
+	if err != nil {
+		return nil, err
+	}
+
+	return positions, nil
+}
+
+// lockSandboxTx locks the user's sandbox row for the rest of the transaction, returning pgx.ErrNoRows when it is not theirs
+func lockSandboxTx(tx pgx.Tx, ctx context.Context, userId int, sandboxId int) error {
+	var id int
+	err := tx.QueryRow(ctx, `SELECT id FROM sandbox WHERE id = $1 AND user_id = $2 FOR UPDATE`, sandboxId, userId).Scan(&id)
+	if err != nil {
+		log.Printf("Failed to lock sandbox %v for user %v: %v", sandboxId, userId, err)
+		return err
+	}
+
+	return nil
+}
+
+func touchSandboxTx(tx pgx.Tx, ctx context.Context, sandboxId int) error {
+	_, err := tx.Exec(ctx, `UPDATE sandbox SET updated_at = now() WHERE id = $1`, sandboxId)
+	if err != nil {
+		log.Printf("Failed to touch sandbox %v: %v", sandboxId, err)
+		return err
+	}
+
+	return nil
+}
+
+// checkItemsOwnedTx returns ErrItemNotOwned unless every clothing item id belongs to the user and is outside the trash
+func checkItemsOwnedTx(tx pgx.Tx, ctx context.Context, userId int, itemIds []int) error {
+	if len(itemIds) == 0 {
+		return nil
+	}
+
+	query := `SELECT count(*) = (SELECT count(DISTINCT i) FROM unnest($2::int[]) AS i)
+			  FROM clothing_items WHERE user_id = $1 AND id = ANY($2) AND deleted_at IS NULL`
+
+	var owned bool
+	err := tx.QueryRow(ctx, query, userId, itemIds).Scan(&owned)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return err
+	}
+	if !owned {
+		return ErrItemNotOwned
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SearchPageDto selects which results of a ranked search to return
+type SearchPageDto struct {
+	Limit  int
+	Offset int
+}
+
+// searchQuery is parsed like a web search box: quoted phrases, "or" and -excluded words
+const searchQuery = `websearch_to_tsquery('english', $2)`
+
+// SearchClothes ranks the user's clothing items by how well their name and notes match text
+func (pg *Postgres) SearchClothes(ctx context.Context, userId int, text string, page SearchPageDto) ([]ClothDto, int, error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return nil, 0, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	total, err := countMatches(ctx, conn, "clothing_items", userId, text)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + clothColumns + `
+			  FROM clothing_items c
+			  WHERE c.user_id = $1 AND c.deleted_at IS NULL AND c.search_vector @@ ` + searchQuery + `
+			  ORDER BY ts_rank(c.search_vector, ` + searchQuery + `) DESC, c.id DESC
+			  LIMIT $3 OFFSET $4`
+
+	rows, err := conn.Query(ctx, query, userId, text, page.Limit, page.Offset)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	clothes := []ClothDto{}
+
+	for rows.Next() {
+		var cloth ClothDto
+		if err := scanClothRow(rows, &cloth); err != nil {
+			log.Printf("Failed to scan row: %v", err)
+			return nil, 0, err
+		}
+		clothes = append(clothes, cloth)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error after iterating rows: %v", err)
+		return nil, 0, err
+	}
+
+	return clothes, total, nil
+}
+
+// SearchCategories ranks the user's categories by how well their name matches text
+func (pg *Postgres) SearchCategories(ctx context.Context, userId int, text string, page SearchPageDto) ([]CategoryDto, int, error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return nil, 0, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	total, err := countMatches(ctx, conn, "categories", userId, text)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + categoryColumns + `
+			  FROM categories
+			  WHERE user_id = $1 AND deleted_at IS NULL AND search_vector @@ ` + searchQuery + `
+			  ORDER BY ts_rank(search_vector, ` + searchQuery + `) DESC, id DESC
+			  LIMIT $3 OFFSET $4`
+
+	rows, err := conn.Query(ctx, query, userId, text, page.Limit, page.Offset)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	categories := []CategoryDto{}
+
+	for rows.Next() {
+		var category CategoryDto
+		if err := scanCategoryRow(rows, &category); err != nil {
+			log.Printf("Failed to scan row: %v", err)
+			return nil, 0, err
+		}
+		categories = append(categories, category)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error after iterating rows: %v", err)
+		return nil, 0, err
+	}
+
+	return categories, total, nil
+}
+
+// SearchTags ranks the user's tags by how well their name matches text
+func (pg *Postgres) SearchTags(ctx context.Context, userId int, text string, page SearchPageDto) ([]TagDto, int, error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return nil, 0, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	total, err := countMatches(ctx, conn, "tags", userId, text)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT id, user_id, name, created_at, updated_at
+			  FROM tags
+			  WHERE user_id = $1 AND search_vector @@ ` + searchQuery + `
+			  ORDER BY ts_rank(search_vector, ` + searchQuery + `) DESC, id DESC
+			  LIMIT $3 OFFSET $4`
+
+	rows, err := conn.Query(ctx, query, userId, text, page.Limit, page.Offset)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	tags := []TagDto{}
+
+	for rows.Next() {
+		var tag TagDto
+		if err := rows.Scan(&tag.Id, &tag.UserId, &tag.Name, &tag.CreatedAt, &tag.UpdatedAt); err != nil {
+			log.Printf("Failed to scan row: %v", err)
+			return nil, 0, err
+		}
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error after iterating rows: %v", err)
+		return nil, 0, err
+	}
+
+	return tags, total, nil
+}
+
+// trashableTables have a deleted_at, their trashed rows are never searched
+var trashableTables = map[string]bool{"clothing_items": true, "categories": true}
+
+// countMatches counts the user's rows of table matching text; table is always one of ours, never user input
+func countMatches(ctx context.Context, conn *pgxpool.Conn, table string, userId int, text string) (int, error) {
+	query := fmt.Sprintf(`SELECT count(*) FROM %v WHERE user_id = $1 AND search_vector @@ %v`, table, searchQuery)
+	if trashableTables[table] {
+		query += ` AND deleted_at IS NULL`
+	}
+
+	var total int
+	err := conn.QueryRow(ctx, query, userId, text).Scan(&total)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return 0, err
+	}
+
+	return total, nil
+}
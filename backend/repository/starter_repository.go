@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// StarterKitDto is the set of categories and tags a new user starts with
+type StarterKitDto struct {
+	Categories []StarterCategoryDto
+	Tags       []string
+}
+
+// StarterCategoryDto is a starter category with the subcategories to create under it
+type StarterCategoryDto struct {
+	Name     string
+	Children []StarterCategoryDto
+}
+
+// ApplyStarterKit gives the user the kit's categories and tags, once: it reports false without
+// changing anything if the user already got a kit. Categories and tags the user already has by
+// name are kept rather than duplicated.
+func (pg *Postgres) ApplyStarterKit(ctx context.Context, userId int, kit StarterKitDto) (applied bool, err error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return false, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		log.Printf("Begin Transation Failure: %v", err)
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			err = tx.Commit(ctx)
+		}
+	}()
+
+	// marking the user first holds their row, so two logins at once can't both apply the kit
+	commandTag, err := tx.Exec(ctx, `UPDATE users SET starter_applied_at = now() WHERE id = $1 AND starter_applied_at IS NULL`, userId)
+	if err != nil {
+		log.Printf("Failed to mark starter kit applied for user %v: %v", userId, err)
+		return false, err
+	}
+	if commandTag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	err = createStarterCategoriesTx(tx, ctx, userId, nil, kit.Categories)
+	if err != nil {
+		return false, err
+	}
+
+	query := `INSERT INTO tags (user_id, name, created_at, updated_at)
+			  SELECT DISTINCT $1::int, n.name, now(), now() FROM unnest($2::text[]) AS n(name)
+			  WHERE NOT EXISTS (SELECT 1 FROM tags t WHERE t.user_id = $1 AND t.name = n.name)`
+
+	_, err = tx.Exec(ctx, query, userId, kit.Tags)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// createStarterCategoriesTx creates the categories under parentId, reusing those the user
+// already has there by name, and then their children under each
+func createStarterCategoriesTx(tx pgx.Tx, ctx context.Context, userId int, parentId *int, categories []StarterCategoryDto) error {
+	for _, category := range categories {
+		found, err := findOrCreateCategoryTx(tx, ctx, userId, parentId, category.Name)
+		if err != nil {
+			return err
+		}
+
+		err = createStarterCategoriesTx(tx, ctx, userId, &found.Id, category.Children)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrTagNotOwned is returned when a tag id does not belong to the user
+var ErrTagNotOwned = errors.New("tag not found or not owned by user")
+
+type TagDto struct {
+	Id        int
+	UserId    int
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (pg *Postgres) GetTagsByUser(ctx context.Context, userId int) ([]TagDto, error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return nil, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	query := `SELECT id, user_id, name, created_at, updated_at FROM tags WHERE user_id = $1 ORDER BY id`
+
+	rows, err := conn.Query(ctx, query, userId)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []TagDto{}
+
+	for rows.Next() {
+		var tag TagDto
+		if err := rows.Scan(&tag.Id, &tag.UserId, &tag.Name, &tag.CreatedAt, &tag.UpdatedAt); err != nil {
+			log.Printf("Failed to scan row: %v", err)
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error after iterating rows: %v", err)
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+func (pg *Postgres) GetTagByUserAndId(ctx context.Context, userId int, tagId int) (TagDto, error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return TagDto{}, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	query := `SELECT id, user_id, name, created_at, updated_at FROM tags WHERE user_id = $1 AND id = $2`
+
+	var tag TagDto
+	err := conn.QueryRow(ctx, query, userId, tagId).
+		Scan(&tag.Id, &tag.UserId, &tag.Name, &tag.CreatedAt, &tag.UpdatedAt)
+	if err != nil {
+		log.Printf("Failed to query %v with params {user_id: %v, id:%v}: %v", query, userId, tagId, err)
+		return TagDto{}, err
+	}
+
+	return tag, nil
+}
+
+func (pg *Postgres) CreateTag(ctx context.Context, userId int, name string) (TagDto, error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return TagDto{}, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	query := `INSERT INTO tags (user_id, name, created_at, updated_at)
+			  VALUES ($1, $2, now(), now())
+			  RETURNING id, user_id, name, created_at, updated_at`
+
+	var tag TagDto
+	err := conn.QueryRow(ctx, query, userId, name).
+		Scan(&tag.Id, &tag.UserId, &tag.Name, &tag.CreatedAt, &tag.UpdatedAt)
+	if err != nil {
+		log.Printf("Failed to insert new tag: %v", err)
+		return TagDto{}, err
+	}
+
+	return tag, nil
+}
+
+// UpdateTag renames the user's tag, leaving the name alone when it is nil
+func (pg *Postgres) UpdateTag(ctx context.Context, userId int, tagId int, name *string) (TagDto, error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return TagDto{}, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	query := `UPDATE tags SET name = COALESCE($1, name), updated_at = now()
+			  WHERE id = $2 AND user_id = $3
+			  RETURNING id, user_id, name, created_at, updated_at`
+
+	var tag TagDto
+	err := conn.QueryRow(ctx, query, name, tagId, userId).
+		Scan(&tag.Id, &tag.UserId, &tag.Name, &tag.CreatedAt, &tag.UpdatedAt)
+	if err != nil {
+		log.Printf("Failed to update tag %v: %v", tagId, err)
+		return TagDto{}, err
+	}
+
+	return tag, nil
+}
+
+// DeleteTag removes the user's tag from every clothing item and outfit and then deletes it
+func (pg *Postgres) DeleteTag(ctx context.Context, userId int, tagId int) (err error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		log.Printf("Begin Transation Failure: %v", err)
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			err = tx.Commit(ctx)
+		}
+	}()
+
+	queries := []string{
+		`DELETE FROM clothing_item_tags cit USING tags t
+		 WHERE cit.tag_id = t.id AND t.id = $1 AND t.user_id = $2`,
+		`DELETE FROM outfit_tags ot USING tags t
+		 WHERE ot.tag_id = t.id AND t.id = $1 AND t.user_id = $2`,
+	}
+
+	for _, query := range queries {
+		_, err = tx.Exec(ctx, query, tagId, userId)
+		if err != nil {
+			log.Printf("Failed to delete tag associations: %v", err)
+			return err
+		}
+	}
+
+	commandTag, err := tx.Exec(ctx, `DELETE FROM tags WHERE id = $1 AND user_id = $2`, tagId, userId)
+	if err != nil {
+		log.Printf("Failed to delete tag: %v", err)
+		return err
+	}
+	if commandTag.RowsAffected() == 0 {
+		err = pgx.ErrNoRows
+		return err
+	}
+
+	return nil
+}
+
+// checkTagsOwnedTx returns ErrTagNotOwned unless every tag id belongs to the user
+func checkTagsOwnedTx(tx pgx.Tx, ctx context.Context, userId int, tagIds []int) error {
+	if len(tagIds) == 0 {
+		return nil
+	}
+
+	query := `SELECT count(*) = (SELECT count(DISTINCT i) FROM unnest($2::int[]) AS i)
+			  FROM tags WHERE user_id = $1 AND id = ANY($2)`
+
+	var owned bool
+	err := tx.QueryRow(ctx, query, userId, tagIds).Scan(&owned)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return err
+	}
+	if !owned {
+		return ErrTagNotOwned
+	}
+
+	return nil
+}
+
+// findOrCreateTagTx returns the user's tag called name, creating it when they don't have one
+func findOrCreateTagTx(tx pgx.Tx, ctx context.Context, userId int, name string) (NamedIdDto, error) {
+	query := `SELECT id FROM tags WHERE user_id = $1 AND name = $2 ORDER BY id LIMIT 1`
+
+	tag := NamedIdDto{}
+	err := tx.QueryRow(ctx, query, userId, name).Scan(&tag.Id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		query = `INSERT INTO tags (user_id, name, created_at, updated_at) VALUES ($1, $2, now(), now()) RETURNING id`
+		err = tx.QueryRow(ctx, query, userId, name).Scan(&tag.Id)
+		tag.Created = true
+	}
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return NamedIdDto{}, err
+	}
+
+	return tag, nil
+}
@@ -0,0 +1,366 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrCategoryTrashed is returned when restoring a clothing item whose category is still in the trash
+var ErrCategoryTrashed = errors.New("category of the clothing item is in the trash")
+
+// The kinds of rows the trash holds
+const (
+	TrashClothes    = "clothes"
+	TrashCategories = "categories"
+)
+
+// TrashEntryDto is a clothing item or category in the trash, Type tells which.
+// ImageUrl is only set for clothing items.
+type TrashEntryDto struct {
+	Type      string
+	Id        int
+	Name      *string
+	ImageUrl  *string
+	DeletedAt time.Time
+}
+
+// trashClothesTx moves the user's clothing items to the trash, taking them out of their sandboxes
+// and outfits, and returns the outfits that lost an item. It fails with pgx.ErrNoRows unless every
+// item is one of the user's outside the trash.
+func trashClothesTx(tx pgx.Tx, ctx context.Context, userId int, clothIds []int) ([]OutfitRefDto, error) {
+	if len(clothIds) == 0 {
+		return []OutfitRefDto{}, nil
+	}
+
+	query := `DELETE FROM sandbox_positions sp USING clothing_items c
+			  WHERE sp.clothing_item_id = c.id AND c.id = ANY($1) AND c.user_id = $2`
+
+	_, err := tx.Exec(ctx, query, clothIds, userId)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return nil, err
+	}
+
+	brokenOutfits, err := removeFromOutfitsTx(tx, ctx, userId, clothIds)
+	if err != nil {
+		return nil, err
+	}
+
+	commandTag, err := tx.Exec(ctx, `UPDATE clothing_items SET deleted_at = now() WHERE id = ANY($1) AND user_id = $2 AND deleted_at IS NULL`, clothIds, userId)
+	if err != nil {
+		log.Printf("Failed to trash clothing items %v: %v", clothIds, err)
+		return nil, err
+	}
+	if commandTag.RowsAffected() != int64(len(clothIds)) {
+		return nil, pgx.ErrNoRows
+	}
+
+	return brokenOutfits, nil
+}
+
+// GetTrashByUser returns what the user has in the trash, most recently deleted first
+func (pg *Postgres) GetTrashByUser(ctx context.Context, userId int) ([]TrashEntryDto, error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return nil, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	query := `SELECT 'clothes', id, name, image_url, deleted_at
+			  FROM clothing_items WHERE user_id = $1 AND deleted_at IS NOT NULL
+			  UNION ALL
+			  SELECT 'categories', id, name, NULL, deleted_at
+			  FROM categories WHERE user_id = $1 AND deleted_at IS NOT NULL
+			  ORDER BY 5 DESC, 1, 2`
+
+	rows, err := conn.Query(ctx, query, userId)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []TrashEntryDto{}
+
+	for rows.Next() {
+		var entry TrashEntryDto
+		if err := rows.Scan(&entry.Type, &entry.Id, &entry.Name, &entry.ImageUrl, &entry.DeletedAt); err != nil {
+			log.Printf("Failed to scan row: %v", err)
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error after iterating rows: %v", err)
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// RestoreCloth takes the user's clothing item out of the trash with its tags and wears, returning
+// ErrCategoryTrashed while its category is still in there. Sandboxes and outfits don't get it back.
+func (pg *Postgres) RestoreCloth(ctx context.Context, userId int, clothId int) (cloth ClothDto, err error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return ClothDto{}, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		log.Printf("Begin Transation Failure: %v", err)
+		return ClothDto{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			err = tx.Commit(ctx)
+		}
+	}()
+
+	query := `SELECT cat.deleted_at IS NOT NULL
+			  FROM clothing_items c
+			  LEFT JOIN categories cat ON cat.id = c.category_id
+			  WHERE c.id = $1 AND c.user_id = $2 AND c.deleted_at IS NOT NULL
+			  FOR UPDATE OF c`
+
+	var categoryTrashed *bool
+	err = tx.QueryRow(ctx, query, clothId, userId).Scan(&categoryTrashed)
+	if err != nil {
+		log.Printf("Failed to query %v with params {id: %v, user_id: %v}: %v", query, clothId, userId, err)
+		return ClothDto{}, err
+	}
+	if categoryTrashed != nil && *categoryTrashed {
+		err = ErrCategoryTrashed
+		return ClothDto{}, err
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE clothing_items SET deleted_at = NULL WHERE id = $1`, clothId)
+	if err != nil {
+		log.Printf("Failed to restore clothing item %v: %v", clothId, err)
+		return ClothDto{}, err
+	}
+
+	err = scanClothRow(tx.QueryRow(ctx, getClothQuery, userId, clothId), &cloth)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", getClothQuery, err)
+		return ClothDto{}, err
+	}
+
+	return cloth, nil
+}
+
+// RestoreCategory takes the user's category out of the trash together with the clothing items
+// that went in with it. Items deleted on their own before stay in the trash. The category comes
+// back at the top level if its parent is in the trash, and without the subcategories it had.
+func (pg *Postgres) RestoreCategory(ctx context.Context, userId int, categoryId int) (category CategoryDto, err error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return CategoryDto{}, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		log.Printf("Begin Transation Failure: %v", err)
+		return CategoryDto{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			err = tx.Commit(ctx)
+		}
+	}()
+
+	query := `UPDATE clothing_items c SET deleted_at = NULL
+			  FROM categories cat
+			  WHERE c.category_id = cat.id AND cat.id = $1 AND cat.user_id = $2 AND c.deleted_at = cat.deleted_at`
+
+	_, err = tx.Exec(ctx, query, categoryId, userId)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return CategoryDto{}, err
+	}
+
+	// it goes back under its parent unless that is gone to the trash too
+	query = `UPDATE categories cat SET deleted_at = NULL,
+			     parent_id = (SELECT p.id FROM categories p WHERE p.id = cat.parent_id AND p.deleted_at IS NULL)
+			 WHERE cat.id = $1 AND cat.user_id = $2 AND cat.deleted_at IS NOT NULL
+			 RETURNING ` + categoryColumns
+
+	err = scanCategoryRow(tx.QueryRow(ctx, query, categoryId, userId), &category)
+	if err != nil {
+		log.Printf("Failed to restore category %v: %v", categoryId, err)
+		return CategoryDto{}, err
+	}
+
+	return category, nil
+}
+
+// PurgeCloth permanently deletes the user's clothing item from the trash and returns its images,
+// which are left for the caller to delete from storage
+func (pg *Postgres) PurgeCloth(ctx context.Context, userId int, clothId int) (ClothImagesDto, error) {
+	images, _, err := pg.purge(ctx,
+		`SELECT id FROM clothing_items WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL`, "",
+		clothId, userId)
+	if err != nil {
+		return ClothImagesDto{}, err
+	}
+	if len(images) == 0 {
+		return ClothImagesDto{}, pgx.ErrNoRows
+	}
+
+	return images[0], nil
+}
+
+// PurgeCategory permanently deletes the user's category from the trash together with the clothing
+// items in it, returning the images of the items
+func (pg *Postgres) PurgeCategory(ctx context.Context, userId int, categoryId int) ([]ClothImagesDto, error) {
+	// an item in a trashed category is always trashed itself
+	images, categories, err := pg.purge(ctx,
+		`SELECT c.id FROM clothing_items c
+		 JOIN categories cat ON cat.id = c.category_id
+		 WHERE cat.id = $1 AND cat.user_id = $2 AND cat.deleted_at IS NOT NULL`,
+		`SELECT id FROM categories WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL`,
+		categoryId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if categories == 0 {
+		return nil, pgx.ErrNoRows
+	}
+
+	return images, nil
+}
+
+// EmptyTrash permanently deletes everything the user has in the trash, returning the images of the clothing items
+func (pg *Postgres) EmptyTrash(ctx context.Context, userId int) ([]ClothImagesDto, error) {
+	images, _, err := pg.purge(ctx,
+		`SELECT id FROM clothing_items WHERE user_id = $1 AND deleted_at IS NOT NULL`,
+		`SELECT id FROM categories WHERE user_id = $1 AND deleted_at IS NOT NULL`,
+		userId)
+	return images, err
+}
+
+// PurgeTrashedBefore permanently deletes what anyone moved to the trash before the given time,
+// returning the images of the clothing items
+func (pg *Postgres) PurgeTrashedBefore(ctx context.Context, before time.Time) ([]ClothImagesDto, error) {
+	images, _, err := pg.purge(ctx,
+		`SELECT id FROM clothing_items
+		 WHERE deleted_at < $1
+		    OR category_id IN (SELECT id FROM categories WHERE deleted_at < $1)`,
+		`SELECT id FROM categories WHERE deleted_at < $1`,
+		before)
+	return images, err
+}
+
+// purge permanently deletes the trashed clothing items and categories whose ids the queries select,
+// in a single transaction, and returns the images of the items and how many categories went. An
+// empty categoryIdsQuery selects none. Categories still holding an item outside the trash are left alone.
+func (pg *Postgres) purge(ctx context.Context, clothIdsQuery string, categoryIdsQuery string, args ...any) (images []ClothImagesDto, purged int64, err error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return nil, 0, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		log.Printf("Begin Transation Failure: %v", err)
+		return nil, 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			err = tx.Commit(ctx)
+		}
+	}()
+
+	var clothIds, categoryIds []int
+	for _, ids := range []struct {
+		query string
+		dest  *[]int
+	}{{clothIdsQuery, &clothIds}, {categoryIdsQuery, &categoryIds}} {
+		if ids.query == "" {
+			continue
+		}
+		query := `SELECT COALESCE(array_agg(id), '{}') FROM (` + ids.query + `) ids`
+		err = tx.QueryRow(ctx, query, args...).Scan(ids.dest)
+		if err != nil {
+			log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+			return nil, 0, err
+		}
+	}
+
+	queries := []string{
+		`DELETE FROM clothing_item_tags WHERE clothing_item_id = ANY($1)`,
+		`DELETE FROM wear_event_items WHERE clothing_item_id = ANY($1)`,
+		`DELETE FROM sandbox_positions WHERE clothing_item_id = ANY($1)`,
+		`DELETE FROM outfit_items WHERE clothing_item_id = ANY($1)`,
+	}
+
+	for _, query := range queries {
+		_, err = tx.Exec(ctx, query, clothIds)
+		if err != nil {
+			log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+			return nil, 0, err
+		}
+	}
+
+	query := `DELETE FROM clothing_items WHERE id = ANY($1) AND deleted_at IS NOT NULL
+			  RETURNING user_id, COALESCE(image_url, ''), cutout_url, image_variants`
+
+	rows, err := tx.Query(ctx, query, clothIds)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return nil, 0, err
+	}
+
+	images = []ClothImagesDto{}
+
+	for rows.Next() {
+		var image ClothImagesDto
+		if err = rows.Scan(&image.UserId, &image.ImageUrl, &image.CutoutUrl, &image.Variants); err != nil {
+			log.Printf("Failed to scan row: %v", err)
+			rows.Close()
+			return nil, 0, err
+		}
+		images = append(images, image)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		log.Printf("Error after iterating rows: %v", err)
+		return nil, 0, err
+	}
+
+	// only trashed categories can still name a trashed one as their parent
+	query = `UPDATE categories SET parent_id = NULL WHERE parent_id = ANY($1) AND deleted_at IS NOT NULL`
+
+	_, err = tx.Exec(ctx, query, categoryIds)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return nil, 0, err
+	}
+
+	query = `DELETE FROM categories cat
+			 WHERE cat.id = ANY($1) AND cat.deleted_at IS NOT NULL
+			   AND NOT EXISTS (SELECT 1 FROM clothing_items c WHERE c.category_id = cat.id)`
+
+	commandTag, err := tx.Exec(ctx, query, categoryIds)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return nil, 0, err
+	}
+
+	return images, commandTag.RowsAffected(), nil
+}
@@ -0,0 +1,298 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrDuplicate is returned when an insert or update violates a unique constraint
+var ErrDuplicate = errors.New("duplicate value violates unique constraint")
+
+type UserDto struct {
+	Id        int
+	Username  string
+	Email     *string
+	GoogleId  *string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type UserEditDto struct {
+	Username *string
+	Email    *string
+}
+
+type UserPatchDto struct {
+	Username *string
+	Email    Optional[string]
+}
+
+// UpsertGoogleUser returns the id of the user linked to googleId, creating the user on first login.
+// A first login with a verified email an existing user has, who isn't linked to a Google account
+// yet, links that user instead. ErrDuplicate means the email belongs to a user it can't be linked to.
+func (pg *Postgres) UpsertGoogleUser(ctx context.Context, googleId string, email string, emailVerified bool, name string) (id int, err error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return -1, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		log.Printf("Begin Transation Failure: %v", err)
+		return -1, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			err = tx.Commit(ctx)
+		}
+	}()
+
+	query := `UPDATE users SET updated_at = now() WHERE google_id = $1 RETURNING id`
+
+	err = tx.QueryRow(ctx, query, googleId).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return -1, err
+	}
+
+	if emailVerified && email != "" {
+		query = `UPDATE users SET google_id = $1, updated_at = now()
+				 WHERE email = $2 AND google_id IS NULL
+				 RETURNING id`
+
+		err = tx.QueryRow(ctx, query, googleId, email).Scan(&id)
+		if err == nil {
+			log.Printf("Linked google_id %v to existing user %v by email", googleId, id)
+			return id, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+			return -1, err
+		}
+	}
+
+	username := name
+	if username == "" {
+		username, _, _ = strings.Cut(email, "@")
+	}
+
+	query = `INSERT INTO users (username, email, google_id, created_at, updated_at)
+			 VALUES ($1, NULLIF($2, ''), $3, now(), now())
+			 ON CONFLICT (google_id) DO UPDATE SET updated_at = now()
+			 RETURNING id`
+
+	err = tx.QueryRow(ctx, query, username, email, googleId).Scan(&id)
+	if err != nil {
+		log.Printf("Failed to upsert user with google_id %v: %v", googleId, err)
+		return -1, translateUniqueViolation(err)
+	}
+
+	return id, nil
+}
+
+func (pg *Postgres) UserExists(ctx context.Context, userId int) (bool, error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return false, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	query := `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`
+
+	var exists bool
+	err := conn.QueryRow(ctx, query, userId).Scan(&exists)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return false, err
+	}
+
+	return exists, nil
+}
+
+func (pg *Postgres) GetUserById(ctx context.Context, userId int) (UserDto, error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return UserDto{}, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	query := `SELECT id, COALESCE(username, ''), email, google_id, created_at, updated_at FROM users WHERE id = $1`
+
+	var user UserDto
+	err := conn.QueryRow(ctx, query, userId).
+		Scan(&user.Id, &user.Username, &user.Email, &user.GoogleId, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		log.Printf("Failed to query %v with params {id: %v}: %v", query, userId, err)
+		return UserDto{}, err
+	}
+
+	return user, nil
+}
+
+func (pg *Postgres) CreateUser(ctx context.Context, newUser UserEditDto) (UserDto, error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return UserDto{}, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	query := `INSERT INTO users (username, email, created_at, updated_at)
+			  VALUES ($1, $2, now(), now())
+			  RETURNING id, COALESCE(username, ''), email, google_id, created_at, updated_at`
+
+	var user UserDto
+	err := conn.QueryRow(ctx, query, newUser.Username, newUser.Email).
+		Scan(&user.Id, &user.Username, &user.Email, &user.GoogleId, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		log.Printf("Failed to insert new user: %v", err)
+		return UserDto{}, translateUniqueViolation(err)
+	}
+
+	return user, nil
+}
+
+// UpdateUser changes only the fields that are set on patch
+func (pg *Postgres) UpdateUser(ctx context.Context, userId int, patch UserPatchDto) (UserDto, error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return UserDto{}, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	query := `UPDATE users SET
+				username = COALESCE($1, username),
+				email = CASE WHEN $2 THEN $3 ELSE email END,
+				updated_at = now()
+			  WHERE id = $4
+			  RETURNING id, COALESCE(username, ''), email, google_id, created_at, updated_at`
+
+	var user UserDto
+	err := conn.QueryRow(ctx, query, patch.Username, patch.Email.Set, patch.Email.Value, userId).
+		Scan(&user.Id, &user.Username, &user.Email, &user.GoogleId, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		log.Printf("Failed to update user %v: %v", userId, err)
+		return UserDto{}, translateUniqueViolation(err)
+	}
+
+	return user, nil
+}
+
+// DeleteUser removes the user together with everything they own in a single transaction,
+// returning the images of their clothing items, trashed ones included, so the caller can delete them
+func (pg *Postgres) DeleteUser(ctx context.Context, userId int) (images []ClothImagesDto, err error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return nil, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		log.Printf("Begin Transation Failure: %v", err)
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			err = tx.Commit(ctx)
+		}
+	}()
+
+	queries := []string{
+		`DELETE FROM sandbox_positions
+		 WHERE sandbox_id IN (SELECT id FROM sandbox WHERE user_id = $1)
+		    OR clothing_item_id IN (SELECT id FROM clothing_items WHERE user_id = $1)`,
+		`DELETE FROM sandbox WHERE user_id = $1`,
+		`DELETE FROM wear_event_items
+		 WHERE wear_event_id IN (SELECT id FROM wear_events WHERE user_id = $1)
+		    OR clothing_item_id IN (SELECT id FROM clothing_items WHERE user_id = $1)`,
+		`DELETE FROM wear_events WHERE user_id = $1`,
+		`DELETE FROM outfit_items
+		 WHERE outfit_id IN (SELECT id FROM outfits WHERE user_id = $1)
+		    OR clothing_item_id IN (SELECT id FROM clothing_items WHERE user_id = $1)`,
+		`DELETE FROM outfit_tags WHERE outfit_id IN (SELECT id FROM outfits WHERE user_id = $1)`,
+		`DELETE FROM outfits WHERE user_id = $1`,
+		`DELETE FROM clothing_item_tags WHERE clothing_item_id IN (SELECT id FROM clothing_items WHERE user_id = $1)`,
+	}
+
+	for _, query := range queries {
+		_, err = tx.Exec(ctx, query, userId)
+		if err != nil {
+			log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+			return nil, err
+		}
+	}
+
+	query := `DELETE FROM clothing_items WHERE user_id = $1
+			  RETURNING user_id, COALESCE(image_url, ''), cutout_url, image_variants`
+
+	rows, err := tx.Query(ctx, query, userId)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return nil, err
+	}
+
+	images = []ClothImagesDto{}
+
+	for rows.Next() {
+		var image ClothImagesDto
+		if err = rows.Scan(&image.UserId, &image.ImageUrl, &image.CutoutUrl, &image.Variants); err != nil {
+			log.Printf("Failed to scan row: %v", err)
+			rows.Close()
+			return nil, err
+		}
+		images = append(images, image)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		log.Printf("Error after iterating rows: %v", err)
+		return nil, err
+	}
+
+	queries = []string{
+		`DELETE FROM categories WHERE user_id = $1`,
+		`DELETE FROM tags WHERE user_id = $1`,
+	}
+
+	for _, query := range queries {
+		_, err = tx.Exec(ctx, query, userId)
+		if err != nil {
+			log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+			return nil, err
+		}
+	}
+
+	commandTag, err := tx.Exec(ctx, `DELETE FROM users WHERE id = $1`, userId)
+	if err != nil {
+		log.Printf("Failed to delete user %v: %v", userId, err)
+		return nil, err
+	}
+	if commandTag.RowsAffected() == 0 {
+		err = pgx.ErrNoRows
+		return nil, err
+	}
+
+	return images, nil
+}
+
+func translateUniqueViolation(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrDuplicate
+	}
+	return err
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrOutfitNotOwned is returned when an outfit id does not belong to the user
+var ErrOutfitNotOwned = errors.New("outfit not found or not owned by user")
+
+// WearEventDto is a day the user wore clothing items, logged with an outfit or item by item.
+// OutfitId is nil once the outfit is deleted, ClothingItemIds keeps what was worn.
+type WearEventDto struct {
+	Id              int
+	UserId          int
+	WornOn          time.Time
+	OutfitId        *int
+	OutfitName      *string
+	ClothingItemIds []int
+	Note            *string
+	CreatedAt       time.Time
+}
+
+// WearEventEditDto logs a wear of the outfit's current items together with ClothingItemIds
+type WearEventEditDto struct {
+	WornOn          time.Time
+	OutfitId        *int
+	ClothingItemIds []int
+	Note            *string
+}
+
+// wearEventColumns are the columns scanWearEventRow expects, selected from wear_events aliased as w
+// joined to outfits aliased as o
+const wearEventColumns = `w.id, w.user_id, w.worn_on, w.outfit_id, o.name, w.note, w.created_at,
+	COALESCE((SELECT array_agg(wi.clothing_item_id ORDER BY wi.clothing_item_id)
+			  FROM wear_event_items wi
+			  WHERE wi.wear_event_id = w.id), '{}')`
+
+func scanWearEventRow(row pgx.Row, event *WearEventDto) error {
+	return row.Scan(&event.Id, &event.UserId, &event.WornOn, &event.OutfitId, &event.OutfitName, &event.Note, &event.CreatedAt, &event.ClothingItemIds)
+}
+
+// CreateWearEvent logs a wear in a single transaction, returning ErrOutfitNotOwned or
+// ErrItemNotOwned when the outfit or any of the items belongs to someone else
+func (pg *Postgres) CreateWearEvent(ctx context.Context, userId int, newEvent WearEventEditDto) (event WearEventDto, err error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return WearEventDto{}, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		log.Printf("Begin Transation Failure: %v", err)
+		return WearEventDto{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			err = tx.Commit(ctx)
+		}
+	}()
+
+	if newEvent.OutfitId != nil {
+		var id int
+		err = tx.QueryRow(ctx, `SELECT id FROM outfits WHERE id = $1 AND user_id = $2`, *newEvent.OutfitId, userId).Scan(&id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = ErrOutfitNotOwned
+			return WearEventDto{}, err
+		}
+		if err != nil {
+			log.Printf("Failed to find outfit %v for user %v: %v", *newEvent.OutfitId, userId, err)
+			return WearEventDto{}, err
+		}
+	}
+
+	err = checkItemsOwnedTx(tx, ctx, userId, newEvent.ClothingItemIds)
+	if err != nil {
+		return WearEventDto{}, err
+	}
+
+	query := `INSERT INTO wear_events (user_id, worn_on, outfit_id, note, created_at)
+			  VALUES ($1, $2, $3, $4, now())
+			  RETURNING id`
+
+	var eventId int
+	err = tx.QueryRow(ctx, query, userId, newEvent.WornOn, newEvent.OutfitId, newEvent.Note).Scan(&eventId)
+	if err != nil {
+		log.Printf("Failed to insert new wear event: %v", err)
+		return WearEventDto{}, err
+	}
+
+	// the outfit's items as they are today, so later changes to it don't rewrite the log
+	query = `INSERT INTO wear_event_items (wear_event_id, clothing_item_id)
+			 SELECT $1, i FROM unnest($2::int[]) AS i
+			 UNION
+			 SELECT $1, clothing_item_id FROM outfit_items WHERE outfit_id = $3`
+
+	_, err = tx.Exec(ctx, query, eventId, newEvent.ClothingItemIds, newEvent.OutfitId)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return WearEventDto{}, err
+	}
+
+	query = `SELECT ` + wearEventColumns + `
+			 FROM wear_events w
+			 LEFT JOIN outfits o ON o.id = w.outfit_id
+			 WHERE w.id = $1`
+
+	err = scanWearEventRow(tx.QueryRow(ctx, query, eventId), &event)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return WearEventDto{}, err
+	}
+
+	return event, nil
+}
+
+// GetWearEventsByUser returns the user's wears from one day to another, both included,
+// ordered by day and then by when they were logged
+func (pg *Postgres) GetWearEventsByUser(ctx context.Context, userId int, from time.Time, to time.Time) ([]WearEventDto, error) {
+	conn := pg.acquire(ctx)
+	if conn == nil {
+		return nil, errors.New("failed to acquire database connection")
+	}
+	defer conn.Release()
+
+	query := `SELECT ` + wearEventColumns + `
+			  FROM wear_events w
+			  LEFT JOIN outfits o ON o.id = w.outfit_id
+			  WHERE w.user_id = $1 AND w.worn_on BETWEEN $2::date AND $3::date
+			  ORDER BY w.worn_on, w.id`
+
+	rows, err := conn.Query(ctx, query, userId, from, to)
+	if err != nil {
+		log.Printf("Query failed \n\tQuery: %v \n\tError: %v", query, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []WearEventDto{}
+
+	for rows.Next() {
+		var event WearEventDto
+		if err := scanWearEventRow(rows, &event); err != nil {
+			log.Printf("Failed to scan row: %v", err)
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error after iterating rows: %v", err)
+		return nil, err
+	}
+
+	return events, nil
+}
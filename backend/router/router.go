@@ -1,35 +1,115 @@
 package router
 
 import (
+	"net/http"
+
 	"com.fukubox/handlers"
 	"com.fukubox/middleware"
+	"com.fukubox/storage"
 	"github.com/go-chi/chi"
 )
 
-func SetupAuthenticatedRoutes(r *chi.Mux) {
-	r.Use(middleware.AuthMiddleware)
+func SetupPublicRoutes(r chi.Router, server *handlers.Server) {
+	// stored images are served by the app itself when using local storage
+	if fileServer, ok := storage.GetStorage().(http.Handler); ok {
+		r.Handle(storage.LocalRoutePrefix+"/*", http.StripPrefix(storage.LocalRoutePrefix, fileServer))
+	}
 
-	r.Route("/clothes", func(r chi.Router) {
-		r.Get("/", handlers.GetClothes)
-		r.Get("/{id}", handlers.GetClothesById)
-		r.Post("/", handlers.CreateClothes)
-		r.Patch("/{id}", handlers.UpdateClothes)
-		r.Delete("/{id}", handlers.DeleteClothes)
+	r.Route("/auth", func(r chi.Router) {
+		r.Get("/login", server.Login)
+		r.Get("/callback", server.Callback)
+		r.Post("/logout", server.Logout)
 	})
+}
+
+func SetupAuthenticatedRoutes(r chi.Router, server *handlers.Server) {
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.AuthMiddleware(server.Users))
+
+		r.Route("/users", func(r chi.Router) {
+			r.Post("/", server.CreateUser)
+		})
+
+		r.Route("/me", func(r chi.Router) {
+			r.Get("/", server.GetMe)
+			r.Patch("/", server.UpdateMe)
+			r.Delete("/", server.DeleteMe)
+		})
+
+		r.Route("/sandboxes", func(r chi.Router) {
+			r.Get("/", server.GetSandboxes)
+			r.Get("/{id}", server.GetSandboxById)
+			r.Post("/", server.CreateSandbox)
+			r.Patch("/{id}", server.UpdateSandbox)
+			r.Delete("/{id}", server.DeleteSandbox)
+
+			r.Route("/{id}/positions", func(r chi.Router) {
+				r.Get("/", server.GetSandboxPositions)
+				r.Post("/", server.CreateSandboxPosition)
+				r.Put("/", server.SaveSandboxLayout)
+				r.Patch("/{positionId}", server.UpdateSandboxPosition)
+				r.Delete("/{positionId}", server.DeleteSandboxPosition)
+			})
+		})
+
+		r.Route("/clothes", func(r chi.Router) {
+			r.Get("/", server.GetClothes)
+			r.Get("/{id}", server.GetClothesById)
+			r.Post("/", server.CreateClothes)
+			r.Patch("/{id}", server.UpdateClothes)
+			r.Put("/{id}/image", server.UploadClothImage)
+			r.Delete("/{id}", server.DeleteClothes)
+		})
 
-	r.Route("/categories", func(r chi.Router) {
-		r.Get("/", handlers.GetCategories)
-		r.Get("/{id}", handlers.GetCategoriesById)
-		r.Post("/", handlers.CreateCategory)
-		r.Patch("/{id}", handlers.UpdateCategory)
-		r.Delete("/{id}", handlers.DeleteCategory)
+		r.Route("/outfits", func(r chi.Router) {
+			r.Get("/", server.GetOutfits)
+			r.Get("/{id}", server.GetOutfitById)
+			r.Post("/", server.CreateOutfit)
+			r.Patch("/{id}", server.UpdateOutfit)
+			r.Delete("/{id}", server.DeleteOutfit)
+		})
+
+		r.Post("/wear", server.CreateWearEvent)
+		r.Get("/calendar", server.GetCalendar)
+
+		r.Route("/categories", func(r chi.Router) {
+			r.Get("/", server.GetCategories)
+			r.Get("/tree", server.GetCategoryTree)
+			r.Get("/{id}", server.GetCategoriesById)
+			r.Post("/", server.CreateCategory)
+			r.Patch("/{id}", server.UpdateCategory)
+			r.Post("/{id}/move", server.MoveCategory)
+			r.Delete("/{id}", server.DeleteCategory)
+		})
+
+		r.Route("/tags", func(r chi.Router) {
+			r.Get("/", server.GetTags)
+			r.Get("/{id}", server.GetTagById)
+			r.Post("/", server.CreateTag)
+			r.Patch("/{id}", server.UpdateTag)
+			r.Delete("/{id}", server.DeleteTag)
+		})
+
+		r.Route("/trash", func(r chi.Router) {
+			r.Get("/", server.GetTrash)
+			r.Delete("/", server.EmptyTrash)
+			r.Post("/clothes/{id}/restore", server.RestoreClothes)
+			r.Delete("/clothes/{id}", server.PurgeClothes)
+			r.Post("/categories/{id}/restore", server.RestoreCategory)
+			r.Delete("/categories/{id}", server.PurgeCategory)
+		})
+
+		r.Get("/search", server.Search)
 	})
+}
+
+// SetupArchiveRoutes adds the closet export and import, which read or write every image of a
+// closet, so they are set up apart from the other routes to be given a longer timeout
+func SetupArchiveRoutes(r chi.Router, server *handlers.Server) {
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.AuthMiddleware(server.Users))
 
-	r.Route("/tags", func(r chi.Router) {
-		r.Get("/", handlers.GetTags)
-		r.Get("/{id}", handlers.GetClothesById)
-		r.Post("/", handlers.CreateTag)
-		r.Patch("/{id}", handlers.UpdateTag)
-		r.Delete("/{id}", handlers.DeleteTag)
+		r.Get("/export", server.ExportCloset)
+		r.Post("/import", server.ImportCloset)
 	})
 }
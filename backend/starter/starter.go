@@ -0,0 +1,125 @@
+// Package starter holds the categories and tags new users start with. The set comes from an
+// embedded template, or the file at STARTER_TEMPLATE, and can differ per locale.
+package starter
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+
+	"com.fukubox/repository"
+	"github.com/ghodss/yaml"
+	"golang.org/x/text/language"
+)
+
+//go:embed starter.yaml
+var defaultTemplate []byte
+
+// Category is a starter category with the subcategories nested in it
+type Category struct {
+	Name     string     `json:"name"`
+	Children []Category `json:"children"`
+}
+
+// Kit is everything one user starts with
+type Kit struct {
+	Categories []Category `json:"categories"`
+	Tags       []string   `json:"tags"`
+}
+
+// Template is the starter kit for each locale, Default serving Language and the languages it
+// has none for
+type Template struct {
+	Language string         `json:"language"`
+	Default  Kit            `json:"default"`
+	Locales  map[string]Kit `json:"locales"`
+}
+
+var (
+	template Template
+	// matcher is nil until StartStarter loaded the template
+	matcher language.Matcher
+	// locales are the keys of template.Locales in matcher order, after the default
+	locales []string
+)
+
+// StartStarter loads the template at STARTER_TEMPLATE, or the embedded one if it isn't set.
+// It has to run before For.
+func StartStarter() error {
+	data := defaultTemplate
+	if path := os.Getenv("STARTER_TEMPLATE"); path != "" {
+		var err error
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read STARTER_TEMPLATE: %w", err)
+		}
+	}
+
+	return load(data)
+}
+
+func load(data []byte) error {
+	var parsed Template
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		return fmt.Errorf("invalid starter template: %w", err)
+	}
+
+	// the default comes first, so the matcher falls back to it
+	defaultTag := language.Und
+	if parsed.Language != "" {
+		tag, err := language.Parse(parsed.Language)
+		if err != nil {
+			return fmt.Errorf("invalid starter template language %q: %w", parsed.Language, err)
+		}
+		defaultTag = tag
+	}
+	tags := []language.Tag{defaultTag}
+	keys := []string{""}
+	for locale := range parsed.Locales {
+		tag, err := language.Parse(locale)
+		if err != nil {
+			return fmt.Errorf("invalid starter template locale %q: %w", locale, err)
+		}
+		tags = append(tags, tag)
+		keys = append(keys, locale)
+	}
+
+	template, matcher, locales = parsed, language.NewMatcher(tags), keys
+	return nil
+}
+
+// For returns the starter kit that best fits the user's languages, given as BCP 47 tags or
+// Accept-Language headers in order of preference. Empty and invalid ones are skipped.
+func For(languages ...string) (repository.StarterKitDto, error) {
+	if matcher == nil {
+		return repository.StarterKitDto{}, errors.New("no starter template loaded, StartStarter has to run first")
+	}
+
+	wanted := []language.Tag{}
+	for _, value := range languages {
+		tags, _, err := language.ParseAcceptLanguage(value)
+		if err == nil {
+			wanted = append(wanted, tags...)
+		}
+	}
+
+	kit := template.Default
+	if _, index, confidence := matcher.Match(wanted...); index > 0 && confidence != language.No {
+		kit = template.Locales[locales[index]]
+	}
+
+	return kit.toDto(), nil
+}
+
+func (kit Kit) toDto() repository.StarterKitDto {
+	return repository.StarterKitDto{Categories: toCategoryDtos(kit.Categories), Tags: kit.Tags}
+}
+
+func toCategoryDtos(categories []Category) []repository.StarterCategoryDto {
+	dtos := []repository.StarterCategoryDto{}
+	for _, category := range categories {
+		dtos = append(dtos, repository.StarterCategoryDto{Name: category.Name, Children: toCategoryDtos(category.Children)})
+	}
+	return dtos
+}
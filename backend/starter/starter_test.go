@@ -0,0 +1,55 @@
+package starter
+
+import "testing"
+
+func TestEmbeddedTemplate(t *testing.T) {
+	if err := load(defaultTemplate); err != nil {
+		t.Fatal(err)
+	}
+	if len(template.Default.Categories) == 0 || len(template.Default.Tags) == 0 {
+		t.Fatalf("default kit is empty: %+v", template.Default)
+	}
+	for locale, kit := range template.Locales {
+		if len(kit.Categories) == 0 || len(kit.Tags) == 0 {
+			t.Fatalf("%v kit is empty: %+v", locale, kit)
+		}
+	}
+}
+
+func TestForWithoutTemplate(t *testing.T) {
+	loaded := matcher
+	matcher = nil
+	defer func() { matcher = loaded }()
+
+	if _, err := For("en"); err == nil {
+		t.Fatal("For succeeded before a template was loaded")
+	}
+}
+
+func TestFor(t *testing.T) {
+	if err := load(defaultTemplate); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		languages []string
+		first     string
+	}{
+		{nil, "Tops"},
+		{[]string{""}, "Tops"},
+		{[]string{"not a language"}, "Tops"},
+		{[]string{"de-DE"}, "Tops"},
+		{[]string{"fr"}, "Hauts"},
+		{[]string{"fr-CA,en;q=0.5"}, "Hauts"},
+		{[]string{"", "ja-JP"}, template.Locales["ja"].Categories[0].Name},
+		{[]string{"en-US", "fr"}, "Tops"},
+	} {
+		kit, err := For(test.languages...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(kit.Categories) == 0 || kit.Categories[0].Name != test.first {
+			t.Errorf("For(%q) starts with %+v, expected %v", test.languages, kit.Categories, test.first)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	store, err := NewLocalStorage(t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testRoundTrip(t, store)
+
+	if url := store.URL("users/7/abc.png"); url != LocalRoutePrefix+"/users/7/abc.png" {
+		t.Fatalf("expected files under %v by default, got %v", LocalRoutePrefix, url)
+	}
+}
+
+func TestLocalStoragePublicURL(t *testing.T) {
+	store, err := NewLocalStorage(t.TempDir(), "https://cdn.example.com/uploads/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	url := store.URL("users/7/abc.png")
+	if url != "https://cdn.example.com/uploads/users/7/abc.png" {
+		t.Fatalf("unexpected url %v", url)
+	}
+	if key, ok := store.Key(url); !ok || key != "users/7/abc.png" {
+		t.Fatalf("Key(%v) = %v, %v", url, key, ok)
+	}
+}
+
+func TestLocalStoragePath(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalStorage(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		key  string
+		want string
+	}{
+		{"users/7/abc.png", filepath.Join(dir, "users", "7", "abc.png")},
+		// every key that isn't already clean is refused, so none can reach outside dir
+		{"../abc.png", ""},
+		{"users/7/../../../abc.png", ""},
+		{"users/7/../8/abc.png", ""},
+		{"..", ""},
+		{"/etc/passwd", ""},
+		{"users//7/abc.png", ""},
+		{"users/7/./abc.png", ""},
+		{"users/7/", ""},
+		{"", ""},
+	} {
+		got, err := store.path(test.key)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("path(%q) = %v, expected it to be refused", test.key, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("path(%q) = %v, %v, expected %v", test.key, got, err, test.want)
+		}
+	}
+}
+
+func TestLocalStorageStaysInItsDirectory(t *testing.T) {
+	ctx := context.Background()
+	parent := t.TempDir()
+	store, err := NewLocalStorage(filepath.Join(parent, "uploads"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outside := filepath.Join(parent, "secret.txt")
+	if err := os.WriteFile(outside, []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Put(ctx, "../escaped.txt", strings.NewReader("x"), 1, "text/plain"); err == nil {
+		t.Error("Put wrote outside the storage directory")
+	}
+	if _, err := os.Stat(filepath.Join(parent, "escaped.txt")); err == nil {
+		t.Error("Put created a file outside the storage directory")
+	}
+	if file, err := store.Get(ctx, "../secret.txt"); err == nil {
+		file.Close()
+		t.Error("Get read outside the storage directory")
+	}
+	if err := store.Delete(ctx, "../secret.txt"); err == nil {
+		t.Error("Delete reached outside the storage directory")
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Errorf("file outside the storage directory is gone: %v", err)
+	}
+}
+
+func TestLocalStorageServeHTTP(t *testing.T) {
+	ctx := context.Background()
+	parent := t.TempDir()
+	store, err := NewLocalStorage(filepath.Join(parent, "uploads"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(parent, "secret.txt"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(ctx, "users/7/abc.png", strings.NewReader("png"), 3, "image/png"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		path   string
+		status int
+	}{
+		{"/users/7/abc.png", http.StatusOK},
+		{"/users/7/missing.png", http.StatusNotFound},
+		// directories would list other users' keys
+		{"/users/7", http.StatusNotFound},
+		{"/users/7/", http.StatusNotFound},
+		{"/../secret.txt", http.StatusNotFound},
+		{"/users/7/../../../secret.txt", http.StatusNotFound},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		// set the path as is, a request would clean it first
+		req.URL.Path = test.path
+		resp := httptest.NewRecorder()
+		store.ServeHTTP(resp, req)
+
+		if resp.Code != test.status {
+			t.Errorf("GET %v: expected status %d, got %d", test.path, test.status, resp.Code)
+		}
+		if resp.Code == http.StatusOK && resp.Header().Get("Cache-Control") != CacheControl {
+			t.Errorf("GET %v: expected Cache-Control %q, got %q", test.path, CacheControl, resp.Header().Get("Cache-Control"))
+		}
+	}
+}
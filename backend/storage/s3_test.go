@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 serves the part of the S3 API S3Storage uses, for one bucket addressed path-style
+type fakeS3 struct {
+	bucket string
+
+	mu      sync.Mutex
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	data         []byte
+	contentType  string
+	cacheControl string
+	modified     time.Time
+}
+
+func newFakeS3(t *testing.T, bucket string) (*fakeS3, S3Config) {
+	fake := &fakeS3{bucket: bucket, objects: map[string]fakeObject{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	return fake, S3Config{
+		Endpoint:  strings.TrimPrefix(server.URL, "http://"),
+		Region:    "us-east-1",
+		Bucket:    bucket,
+		AccessKey: "access",
+		SecretKey: "secret",
+	}
+}
+
+func (fake *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != fake.bucket {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	if key == "" {
+		// BucketExists
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := readS3Body(r)
+		if err != nil {
+			writeS3Error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		fake.objects[key] = fakeObject{data: data, contentType: r.Header.Get("Content-Type"), cacheControl: r.Header.Get("Cache-Control"), modified: time.Now()}
+		w.Header().Set("ETag", etag(data))
+
+	case http.MethodGet, http.MethodHead:
+		object, ok := fake.objects[key]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Type", object.contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(object.data)))
+		w.Header().Set("ETag", etag(object.data))
+		w.Header().Set("Last-Modified", object.modified.UTC().Format(http.TimeFormat))
+		if r.Method == http.MethodGet {
+			w.Write(object.data)
+		}
+
+	case http.MethodDelete:
+		delete(fake.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// readS3Body reads an upload, taking apart the signed chunks of a streaming upload
+func readS3Body(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	var data []byte
+	body := bufio.NewReader(r.Body)
+	for {
+		header, err := body.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(header), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return data, nil
+		}
+
+		chunk := make([]byte, size+2)
+		if _, err := io.ReadFull(body, chunk); err != nil {
+			return nil, err
+		}
+		data = append(data, chunk[:size]...)
+	}
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%v</Code><Message>%v</Message></Error>`, code, code)
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func TestS3Storage(t *testing.T) {
+	fake, config := newFakeS3(t, "closet")
+	store, err := NewS3Storage(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testRoundTrip(t, store)
+
+	// files are uploaded with their type and cached for good, as keys are never reused
+	data := []byte("png")
+	if err := store.Put(context.Background(), "users/7/abc.png", bytes.NewReader(data), int64(len(data)), "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	object := fake.objects["users/7/abc.png"]
+	if object.contentType != "image/png" || object.cacheControl != CacheControl {
+		t.Fatalf("uploaded as %q with Cache-Control %q", object.contentType, object.cacheControl)
+	}
+
+	// without S3_PUBLIC_URL objects are addressed path-style on the endpoint
+	if url := store.URL("users/7/abc.png"); url != "http://"+config.Endpoint+"/closet/users/7/abc.png" {
+		t.Fatalf("unexpected url %v", url)
+	}
+}
+
+func TestS3StoragePublicURL(t *testing.T) {
+	_, config := newFakeS3(t, "closet")
+	config.PublicURL = "https://cdn.example.com/"
+	store, err := NewS3Storage(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	url := store.URL("users/7/abc.png")
+	if url != "https://cdn.example.com/users/7/abc.png" {
+		t.Fatalf("unexpected url %v", url)
+	}
+	if key, ok := store.Key(url); !ok || key != "users/7/abc.png" {
+		t.Fatalf("Key(%v) = %v, %v", url, key, ok)
+	}
+}
+
+func TestS3StorageConfig(t *testing.T) {
+	_, config := newFakeS3(t, "closet")
+
+	for _, test := range []struct {
+		name   string
+		change func(*S3Config)
+	}{
+		{"no endpoint", func(config *S3Config) { config.Endpoint = "" }},
+		{"no bucket", func(config *S3Config) { config.Bucket = "" }},
+		{"missing bucket", func(config *S3Config) { config.Bucket = "elsewhere" }},
+	} {
+		changed := config
+		test.change(&changed)
+		if _, err := NewS3Storage(context.Background(), changed); err == nil {
+			t.Errorf("%v: expected an error", test.name)
+		}
+	}
+}
+
+// TestS3StorageMinIO runs the round trip against a real S3-compatible store, such as a local
+// MinIO, when S3_TEST_ENDPOINT and S3_TEST_BUCKET point at one
+func TestS3StorageMinIO(t *testing.T) {
+	endpoint, bucket := os.Getenv("S3_TEST_ENDPOINT"), os.Getenv("S3_TEST_BUCKET")
+	if endpoint == "" || bucket == "" {
+		t.Skip("S3_TEST_ENDPOINT and S3_TEST_BUCKET aren't set")
+	}
+
+	store, err := NewS3Storage(context.Background(), S3Config{
+		Endpoint:  endpoint,
+		Region:    os.Getenv("S3_TEST_REGION"),
+		Bucket:    bucket,
+		AccessKey: os.Getenv("S3_TEST_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_TEST_SECRET_KEY"),
+		UseSSL:    os.Getenv("S3_TEST_USE_SSL") == "true",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testRoundTrip(t, store)
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Storage persists uploaded files and knows the public URL each one is served from
+type Storage interface {
+	// Put stores size bytes read from body under key
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Get opens the file stored under key, the caller closes it
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the file stored under key, succeeding if it does not exist
+	Delete(ctx context.Context, key string) error
+	// URL returns the public URL for key
+	URL(key string) string
+	// Key reverses URL, reporting false for URLs this storage did not hand out
+	Key(url string) (string, bool)
+}
+
+// CacheControl is sent with every stored file; keys are never reused, so files can be cached forever
+const CacheControl = "public, max-age=31536000, immutable"
+
+var store Storage
+
+func GetStorage() Storage {
+	return store
+}
+
+// StartStorage selects the backend named by STORAGE_BACKEND ("local" by default, or "s3")
+func StartStorage(ctx context.Context) error {
+	var err error
+
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", "local":
+		store, err = NewLocalStorage(os.Getenv("STORAGE_LOCAL_DIR"), os.Getenv("STORAGE_PUBLIC_URL"))
+	case "s3":
+		store, err = NewS3Storage(ctx, S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			UseSSL:    os.Getenv("S3_USE_SSL") != "false",
+			PublicURL: os.Getenv("S3_PUBLIC_URL"),
+		})
+	default:
+		return fmt.Errorf("unknown STORAGE_BACKEND %q", backend)
+	}
+
+	return err
+}
+
+// NewKey returns a unique, unguessable key prefix for files owned by userId;
+// related files such as an image and its derivatives share the prefix and differ by suffix
+func NewKey(userId int) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return userKeyPrefix(userId) + hex.EncodeToString(b), nil
+}
+
+// OwnsKey reports whether key is under the prefix NewKey hands out for userId
+func OwnsKey(userId int, key string) bool {
+	return strings.HasPrefix(key, userKeyPrefix(userId)) && !strings.Contains(key, "..")
+}
+
+func userKeyPrefix(userId int) string {
+	return fmt.Sprintf("users/%d/", userId)
+}
+
+func keyFromURL(baseURL string, url string) (string, bool) {
+	prefix := strings.TrimSuffix(baseURL, "/") + "/"
+	if !strings.HasPrefix(url, prefix) {
+		return "", false
+	}
+
+	key := strings.TrimPrefix(url, prefix)
+	if key == "" {
+		return "", false
+	}
+
+	return key, true
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestOwnsKey(t *testing.T) {
+	for _, test := range []struct {
+		userId int
+		key    string
+		want   bool
+	}{
+		{7, "users/7/abc.png", true},
+		{7, "users/7/abc-thumbnail.jpg", true},
+		{7, "users/70/abc.png", false},
+		{7, "users/8/abc.png", false},
+		{7, "users/7", false},
+		{7, "users/7/../8/abc.png", false},
+		{7, "users/7/..", false},
+		{7, "other/users/7/abc.png", false},
+		{7, "", false},
+	} {
+		if got := OwnsKey(test.userId, test.key); got != test.want {
+			t.Errorf("OwnsKey(%d, %q) = %v, expected %v", test.userId, test.key, got, test.want)
+		}
+	}
+}
+
+func TestNewKey(t *testing.T) {
+	first, err := NewKey(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewKey(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first == second {
+		t.Fatalf("NewKey handed out %v twice", first)
+	}
+	if !OwnsKey(7, first) || OwnsKey(8, first) {
+		t.Fatalf("%v isn't owned by user 7 alone", first)
+	}
+}
+
+// testRoundTrip stores a file in store and checks it can be read back, addressed by URL and
+// deleted, as every Storage has to
+func testRoundTrip(t *testing.T, store Storage) {
+	t.Helper()
+	ctx := context.Background()
+
+	key, err := NewKey(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key += ".png"
+	data := "not really a png"
+
+	if err := store.Put(ctx, key, strings.NewReader(data), int64(len(data)), "image/png"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	file, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	read, err := io.ReadAll(file)
+	file.Close()
+	if err != nil || string(read) != data {
+		t.Fatalf("Get read %q, %v, expected %q", read, err, data)
+	}
+
+	url := store.URL(key)
+	if got, ok := store.Key(url); !ok || got != key {
+		t.Fatalf("Key(%v) = %v, %v, expected %v", url, got, ok, key)
+	}
+	if !OwnsKey(7, key) || OwnsKey(8, key) {
+		t.Fatalf("%v isn't owned by user 7 alone", key)
+	}
+	for _, foreign := range []string{"http://elsewhere.example.com/" + key, store.URL(""), ""} {
+		if got, ok := store.Key(foreign); ok {
+			t.Fatalf("Key(%q) = %v, expected it to be refused", foreign, got)
+		}
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if file, err := store.Get(ctx, key); err == nil {
+		file.Close()
+		t.Fatal("Get succeeded after Delete")
+	}
+	// deleting what isn't there succeeds
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete of a missing file: %v", err)
+	}
+}